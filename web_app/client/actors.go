@@ -0,0 +1,62 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// ActorsClient - /actors
+type ActorsClient struct {
+	client *Client
+}
+
+func (c ActorsClient) Create(ctx context.Context, actor Actor) (Actor, error) {
+	var created Actor
+	err := c.client.do(ctx, request{method: http.MethodPost, path: "/actors", body: actor}, &created)
+
+	return created, err
+}
+
+func (c ActorsClient) Get(ctx context.Context, id int) (Actor, error) {
+	var actor Actor
+	err := c.client.do(ctx, request{method: http.MethodGet, path: actorPath(id)}, &actor)
+
+	return actor, err
+}
+
+// Update меняет переданные поля. version - версия, которую видел вызывающий,
+// при расхождении вернется ErrVersionMismatch; 0 - изменить любую версию.
+func (c ActorsClient) Update(ctx context.Context, id, version int, actorUpdate ActorUpdate) (Actor, error) {
+	var updated Actor
+	err := c.client.do(ctx, request{method: http.MethodPatch, path: actorPath(id), version: version, body: actorUpdate}, &updated)
+
+	return updated, err
+}
+
+func (c ActorsClient) Delete(ctx context.Context, id, version int) error {
+	return c.client.do(ctx, request{method: http.MethodDelete, path: actorPath(id), version: version}, nil)
+}
+
+func (c ActorsClient) Restore(ctx context.Context, id int) (Actor, error) {
+	var restored Actor
+	err := c.client.do(ctx, request{method: http.MethodPost, path: actorPath(id) + "/restore"}, &restored)
+
+	return restored, err
+}
+
+func (c ActorsClient) List(ctx context.Context, query ActorsQuery) (ActorPage, error) {
+	values, err := actorsValues(query)
+	if err != nil {
+		return ActorPage{}, err
+	}
+
+	var page ActorPage
+	err = c.client.do(ctx, request{method: http.MethodGet, path: "/actors", query: values}, &page)
+
+	return page, err
+}
+
+func actorPath(id int) string {
+	return fmt.Sprintf("/actors/%d", id)
+}
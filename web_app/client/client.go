@@ -0,0 +1,225 @@
+// Package client - типизированный клиент HTTP API актеров и фильмов. Методы повторяют
+// сервисы из internal/services: те же имена, типы и значение version (0 - без If-Match).
+// Ответ с ошибкой возвращается как *Error, с которым работают errors.Is и ошибки домена.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config - адрес сервиса, учетные данные и повторы запросов
+type Config struct {
+	// BaseURL - адрес сервиса, например http://localhost:8080
+	BaseURL string
+	// HTTPClient - nil значит http.DefaultClient. Таймаут лучше задавать контекстом вызова.
+	HTTPClient *http.Client
+
+	// APIKey передается в X-API-Key, Token - в Authorization: Bearer. Без аутентификации
+	// на сервисе Caller называет вызывающего в X-Caller, в журнал он попадет как unverified:<Caller>.
+	APIKey string
+	Token  string
+	Caller string
+
+	// MaxRetries - сколько раз повторить запрос после сетевой ошибки или ответа 429, 502, 503
+	// и 504. POST не повторяется: создание могло пройти, а ответ потеряться.
+	MaxRetries int
+	// RetryBackoff - пауза перед первым повтором, каждая следующая вдвое длиннее, но не больше
+	// MaxBackoff. Заголовок Retry-After в ответе ее заменяет, но тоже не дольше MaxBackoff:
+	// прокси может попросить подождать час.
+	RetryBackoff time.Duration
+	MaxBackoff   time.Duration
+}
+
+// DefaultConfig - три повтора с паузами от 100ms
+func DefaultConfig(baseURL string) Config {
+	return Config{
+		BaseURL:      baseURL,
+		MaxRetries:   3,
+		RetryBackoff: 100 * time.Millisecond,
+		MaxBackoff:   2 * time.Second,
+	}
+}
+
+type Client struct {
+	cfg     Config
+	baseURL string
+	http    *http.Client
+
+	Actors ActorsClient
+	Movies MoviesClient
+}
+
+func New(cfg Config) (*Client, error) {
+	base, err := url.Parse(cfg.BaseURL)
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("invalid base url %q", cfg.BaseURL)
+	}
+	if cfg.MaxRetries < 0 || cfg.RetryBackoff < 0 || cfg.MaxBackoff < 0 {
+		return nil, errors.New("retries and backoff must not be negative")
+	}
+
+	c := &Client{
+		cfg:     cfg,
+		baseURL: strings.TrimSuffix(base.String(), "/"),
+		http:    cfg.HTTPClient,
+	}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+	c.Actors = ActorsClient{client: c}
+	c.Movies = MoviesClient{client: c}
+
+	return c, nil
+}
+
+// request - один вызов API
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	// version уходит в If-Match, 0 - без проверки версии
+	version int
+	body    any
+}
+
+// do выполняет запрос, при необходимости с повторами, и разбирает ответ в out
+func (c *Client) do(ctx context.Context, req request, out any) error {
+	var body []byte
+	if req.body != nil {
+		var err error
+		if body, err = json.Marshal(req.body); err != nil {
+			return fmt.Errorf("failed to encode request body: %w", err)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, req, body)
+		if attempt < c.cfg.MaxRetries && req.method != http.MethodPost && retryable(ctx, resp, err) {
+			wait := c.backoff(attempt, resp)
+			if resp != nil {
+				_, _ = io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}
+			if err = sleep(ctx, wait); err != nil {
+				return fmt.Errorf("%s %s: %w", req.method, req.path, err)
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", req.method, req.path, err)
+		}
+
+		defer resp.Body.Close()
+		if resp.StatusCode >= http.StatusBadRequest {
+			return decodeError(resp)
+		}
+		if out == nil || resp.StatusCode == http.StatusNoContent {
+			return nil
+		}
+		if err = json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("%s %s: failed to decode response: %w", req.method, req.path, err)
+		}
+
+		return nil
+	}
+}
+
+func (c *Client) send(ctx context.Context, req request, body []byte) (*http.Response, error) {
+	target := c.baseURL + req.path
+	if len(req.query) > 0 {
+		target += "?" + req.query.Encode()
+	}
+
+	// тело читается заново при каждом повторе
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	r, err := http.NewRequestWithContext(ctx, req.method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Header.Set("Accept", "application/json")
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if req.version > 0 {
+		r.Header.Set("If-Match", strconv.Quote(strconv.Itoa(req.version)))
+	}
+	switch {
+	case c.cfg.APIKey != "":
+		r.Header.Set("X-API-Key", c.cfg.APIKey)
+	case c.cfg.Token != "":
+		r.Header.Set("Authorization", "Bearer "+c.cfg.Token)
+	}
+	if c.cfg.Caller != "" {
+		r.Header.Set("X-Caller", c.cfg.Caller)
+	}
+
+	return c.http.Do(r)
+}
+
+// retryable - стоит ли повторить запрос: сеть или перегруженный сервис, но не отмена вызова
+func retryable(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+// backoff - пауза перед повтором attempt+1. Случайная половина паузы разводит по
+// времени повторы клиентов, которые получили ошибку одновременно.
+func (c *Client) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			// сравнение в секундах не переполняется даже на огромных значениях
+			if c.cfg.MaxBackoff > 0 && time.Duration(seconds) > c.cfg.MaxBackoff/time.Second {
+				return c.cfg.MaxBackoff
+			}
+			return time.Duration(seconds) * time.Second
+		}
+	}
+
+	wait := c.cfg.RetryBackoff << attempt
+	if c.cfg.MaxBackoff > 0 && (wait > c.cfg.MaxBackoff || wait < c.cfg.RetryBackoff) {
+		wait = c.cfg.MaxBackoff
+	}
+	if wait <= 0 {
+		return 0
+	}
+
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
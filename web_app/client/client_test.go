@@ -0,0 +1,348 @@
+package client
+
+import (
+	"arch-demo/internal/api"
+	"arch-demo/internal/domain"
+	"arch-demo/internal/services"
+	"arch-demo/internal/storage/inmemory"
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestServer - настоящие обработчики актеров, фильмов и состава поверх хранилища в памяти.
+// before, если задан, вызывается перед обработчиком и может сам ответить на запрос.
+func newTestServer(t *testing.T, before func(w http.ResponseWriter, r *http.Request) bool) *Client {
+	t.Helper()
+
+	store := inmemory.NewStorage()
+	audit := services.NewAuditLog(store)
+	actors := api.NewActorsHandler(services.NewActorService(store, domain.DeleteSoft, audit))
+	movies := api.NewLaptopsHandler(services.NewMovieService(store, domain.DeleteSoft, audit))
+
+	r := chi.NewRouter()
+	r.Use(api.RequestID, api.Caller)
+	r.NotFound(api.NotFound)
+	r.Route("/actors", func(r chi.Router) {
+		r.Post("/", actors.Create)
+		r.Get("/", actors.List)
+		r.Get("/{id}", actors.Get)
+		r.Patch("/{id}", actors.Update)
+		r.Delete("/{id}", actors.Delete)
+		r.Post("/{id}/restore", actors.Restore)
+		r.Get("/{id}/movies", movies.ListByActor)
+	})
+	r.Route("/movies", func(r chi.Router) {
+		r.Post("/", movies.Create)
+		r.Get("/", movies.List)
+		r.Get("/{id}", movies.Get)
+		r.Patch("/{id}", movies.Update)
+		r.Delete("/{id}", movies.Delete)
+		r.Post("/{id}/restore", movies.Restore)
+		r.Get("/{id}/actors", movies.GetActors)
+		r.Post("/{id}/actors", movies.CreateActorsForMovie)
+		r.Put("/{id}/actors", movies.ReplaceCast)
+		r.Patch("/{id}/actors", movies.UpdateCast)
+		r.Delete("/{id}/actors", movies.ClearCast)
+		r.Put("/{id}/actors/{actor_id}", movies.SetCastMember)
+		r.Patch("/{id}/actors/{actor_id}", movies.UpdateCastMember)
+		r.Delete("/{id}/actors/{actor_id}", movies.RemoveCastMember)
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if before != nil && before(w, req) {
+			return
+		}
+		r.ServeHTTP(w, req)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := DefaultConfig(server.URL)
+	cfg.Caller = "catalog-sync"
+	cfg.RetryBackoff = time.Millisecond
+	cfg.MaxBackoff = 5 * time.Millisecond
+	c, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	return c
+}
+
+func TestClient_ActorsAndMovies(t *testing.T) {
+	ctx := context.Background()
+	c := newTestServer(t, nil)
+
+	actor, err := c.Actors.Create(ctx, Actor{Name: "Al Pacino", BirthYear: 1940, CountryOfBirth: "us", Gender: GenderMale})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if actor.ID == 0 || actor.CountryOfBirth != "US" || actor.Version != 1 {
+		t.Errorf("created actor = %+v, want id, normalized country and version 1", actor)
+	}
+	if got, err := c.Actors.Get(ctx, actor.ID); err != nil || got.Name != actor.Name {
+		t.Errorf("Get = %+v, %v, want %+v", got, err, actor)
+	}
+
+	name := "Alfredo James Pacino"
+	updated, err := c.Actors.Update(ctx, actor.ID, actor.Version, ActorUpdate{Name: &name})
+	if err != nil || updated.Name != name || updated.Version != 2 {
+		t.Fatalf("Update = %+v, %v, want new name and version 2", updated, err)
+	}
+	// запись уже изменилась, изменение по старой версии отклоняется
+	if _, err = c.Actors.Update(ctx, actor.ID, actor.Version, ActorUpdate{Name: &name}); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("Update with stale version = %v, want ErrVersionMismatch", err)
+	}
+
+	_, err = c.Actors.Create(ctx, Actor{Name: "Nobody", BirthYear: 1700, CountryOfBirth: "US", Gender: "unknown"})
+	var apiErr *Error
+	if !errors.Is(err, ErrValidation) || !errors.As(err, &apiErr) {
+		t.Fatalf("Create with invalid fields = %v, want *Error with ErrValidation", err)
+	}
+	var fields []string
+	for _, f := range apiErr.Fields {
+		fields = append(fields, f.Field)
+	}
+	if want := []string{"birth_year", "gender"}; !slices.Equal(fields, want) || apiErr.Status != http.StatusUnprocessableEntity || apiErr.RequestID == "" {
+		t.Errorf("validation error = %+v, want status 422, request id and fields %q", apiErr, want)
+	}
+	if _, err = c.Actors.Create(ctx, Actor{Name: "Nobody"}); !errors.Is(err, ErrFieldsRequired) {
+		t.Errorf("Create without required fields = %v, want ErrFieldsRequired", err)
+	}
+
+	movie, err := c.Movies.Create(ctx, Movie{Name: "Heat", ReleaseDate: time.Date(1995, 12, 15, 0, 0, 0, 0, time.UTC), Country: "US", Genre: "crime", Rating: 5})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	var rating int8 = 4
+	if movie, err = c.Movies.Update(ctx, movie.ID, 0, MovieUpdate{Rating: &rating}); err != nil || movie.Rating != 4 {
+		t.Errorf("Update without version = %+v, %v, want rating 4", movie, err)
+	}
+
+	if err = c.Movies.Delete(ctx, movie.ID, movie.Version); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err = c.Movies.Get(ctx, movie.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get deleted movie = %v, want ErrNotFound", err)
+	}
+	if restored, err := c.Movies.Restore(ctx, movie.ID); err != nil || restored.ID != movie.ID {
+		t.Errorf("Restore = %+v, %v", restored, err)
+	}
+	if err = c.Actors.Delete(ctx, 1000, 0); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete of unknown actor = %v, want ErrNotFound", err)
+	}
+}
+
+func TestClient_List(t *testing.T) {
+	ctx := context.Background()
+	c := newTestServer(t, nil)
+
+	for i, name := range []string{"Robert De Niro", "Robert Duvall", "Al Pacino", "Diane Keaton"} {
+		gender := GenderMale
+		if name == "Diane Keaton" {
+			gender = GenderFemale
+		}
+		if _, err := c.Actors.Create(ctx, Actor{Name: name, BirthYear: 1930 + i*5, CountryOfBirth: "US", Gender: gender}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	sort, err := ParseSort("-birth_year", ActorSortFields)
+	if err != nil {
+		t.Fatalf("ParseSort: %v", err)
+	}
+	from := 1931
+	query := ActorsQuery{
+		Name:      TextFilter{Prefix: []string{"Robert", "Al"}},
+		Gender:    TextFilter{Eq: []string{GenderMale}},
+		BirthYear: IntRange{Gte: &from},
+		Sort:      sort,
+		Limit:     1,
+	}
+
+	// страницы по одной записи, следующая - по курсору предыдущей
+	var names []string
+	for {
+		page, err := c.Actors.List(ctx, query)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if page.Total != 2 {
+			t.Errorf("total = %d, want 2", page.Total)
+		}
+		for _, actor := range page.Items {
+			names = append(names, actor.Name)
+		}
+		if query.After, err = Next(page); err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		if query.After == nil {
+			break
+		}
+	}
+	if want := []string{"Al Pacino", "Robert Duvall"}; !slices.Equal(names, want) {
+		t.Errorf("actors = %q, want %q", names, want)
+	}
+
+	// фильтр, которого нет в API, отклоняется до запроса
+	if _, err = c.Actors.List(ctx, ActorsQuery{Gender: TextFilter{Prefix: []string{"m"}}}); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("List with unsupported filter = %v, want ErrInvalidFilter", err)
+	}
+	if _, err = c.Actors.List(ctx, ActorsQuery{Country: TextFilter{Eq: []string{"Atlantis"}}}); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("List with unknown country = %v, want ErrInvalidFilter from the service", err)
+	}
+
+	for _, m := range []struct {
+		name string
+		date time.Time
+	}{{"Heat", time.Date(1995, 12, 15, 0, 0, 0, 0, time.UTC)}, {"The Godfather", time.Date(1972, 3, 24, 0, 0, 0, 0, time.UTC)}} {
+		if _, err = c.Movies.Create(ctx, Movie{Name: m.name, ReleaseDate: m.date, Country: "US", Genre: "crime", Rating: 5}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	after := time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)
+	page, err := c.Movies.List(ctx, MoviesQuery{Genre: TextFilter{Eq: []string{"crime"}}, ReleaseDate: TimeRange{Gt: &after}})
+	if err != nil || len(page.Items) != 1 || page.Items[0].Name != "Heat" {
+		t.Errorf("movies released after 1990 = %+v, %v, want Heat", page.Items, err)
+	}
+}
+
+func TestClient_Cast(t *testing.T) {
+	ctx := context.Background()
+	c := newTestServer(t, nil)
+
+	movie, err := c.Movies.Create(ctx, Movie{Name: "Heat", ReleaseDate: time.Date(1995, 12, 15, 0, 0, 0, 0, time.UTC), Country: "US", Genre: "crime", Rating: 5})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	var ids []int
+	for _, name := range []string{"Al Pacino", "Robert De Niro", "Val Kilmer"} {
+		actor, err := c.Actors.Create(ctx, Actor{Name: name, BirthYear: 1940, CountryOfBirth: "US", Gender: GenderMale})
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		ids = append(ids, actor.ID)
+	}
+	castIDs := func(cast []CastMember) []int {
+		var got []int
+		for _, member := range cast {
+			got = append(got, member.ID)
+		}
+		return got
+	}
+
+	if added, err := c.Movies.CreateActorsForMovie(ctx, movie.ID, ids[:2]); err != nil || !slices.Equal(added, ids[:2]) {
+		t.Fatalf("CreateActorsForMovie = %v, %v, want %v", added, err, ids[:2])
+	}
+	member, err := c.Movies.SetCastMember(ctx, movie.ID, CastEntry{ActorID: ids[1], Character: "Neil McCauley", BillingOrder: 2, Role: RoleLead})
+	if err != nil || member.Character != "Neil McCauley" || member.Name != "Robert De Niro" {
+		t.Errorf("SetCastMember = %+v, %v", member, err)
+	}
+	role, billingOrder := RoleLead, 5
+	if member, err = c.Movies.UpdateCastMember(ctx, movie.ID, ids[0], CastEntryUpdate{Role: &role, BillingOrder: &billingOrder}); err != nil || member.Role != RoleLead || member.BillingOrder != 5 {
+		t.Errorf("UpdateCastMember = %+v, %v, want lead role fifth in billing", member, err)
+	}
+	cast, err := c.Movies.UpdateCast(ctx, movie.ID, []CastEntry{{ActorID: ids[2], Character: "Chris Shiherlis"}})
+	if err != nil || !slices.Equal(castIDs(cast), []int{ids[1], ids[0], ids[2]}) {
+		t.Errorf("UpdateCast = %v, %v, want actors in billing order %v", castIDs(cast), err, []int{ids[1], ids[0], ids[2]})
+	}
+
+	if err = c.Movies.RemoveCastMember(ctx, movie.ID, ids[0]); err != nil {
+		t.Fatalf("RemoveCastMember: %v", err)
+	}
+	if cast, err = c.Movies.GetCast(ctx, movie.ID); err != nil || !slices.Equal(castIDs(cast), []int{ids[1], ids[2]}) {
+		t.Errorf("GetCast = %v, %v, want %v", castIDs(cast), err, []int{ids[1], ids[2]})
+	}
+	if page, err := c.Movies.ListByActor(ctx, ids[2], MoviesQuery{}); err != nil || len(page.Items) != 1 || page.Items[0].ID != movie.ID {
+		t.Errorf("ListByActor = %+v, %v, want the movie", page.Items, err)
+	}
+
+	if cast, err = c.Movies.ReplaceCast(ctx, movie.ID, []CastEntry{{ActorID: ids[0], Role: RoleLead}}); err != nil || !slices.Equal(castIDs(cast), ids[:1]) {
+		t.Errorf("ReplaceCast = %v, %v, want %v", castIDs(cast), err, ids[:1])
+	}
+	if _, err = c.Movies.ReplaceCast(ctx, movie.ID, []CastEntry{{ActorID: 1000}}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ReplaceCast with unknown actor = %v, want ErrNotFound", err)
+	}
+	if err = c.Movies.ClearCast(ctx, movie.ID); err != nil {
+		t.Fatalf("ClearCast: %v", err)
+	}
+	if cast, err = c.Movies.GetCast(ctx, movie.ID); err != nil || len(cast) != 0 {
+		t.Errorf("GetCast after ClearCast = %v, %v, want empty cast", cast, err)
+	}
+}
+
+func TestClient_Retries(t *testing.T) {
+	ctx := context.Background()
+
+	// первые failures запросов получают 503, остальные доходят до обработчиков
+	var requests, failures atomic.Int32
+	c := newTestServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+		requests.Add(1)
+		if failures.Add(-1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return true
+		}
+		return false
+	})
+
+	actor, err := c.Actors.Create(ctx, Actor{Name: "Al Pacino", BirthYear: 1940, CountryOfBirth: "US", Gender: GenderMale})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	requests.Store(0)
+	failures.Store(2)
+	if _, err = c.Actors.Get(ctx, actor.ID); err != nil || requests.Load() != 3 {
+		t.Errorf("Get after two failures = %v in %d requests, want success in 3", err, requests.Load())
+	}
+
+	requests.Store(0)
+	failures.Store(10)
+	var apiErr *Error
+	if _, err = c.Actors.Get(ctx, actor.ID); !errors.As(err, &apiErr) || apiErr.Status != http.StatusServiceUnavailable || requests.Load() != 4 {
+		t.Errorf("Get while the service is down = %v in %d requests, want 503 after 4 requests", err, requests.Load())
+	}
+
+	// создание могло пройти, поэтому POST не повторяется
+	requests.Store(0)
+	failures.Store(1)
+	if _, err = c.Actors.Create(ctx, Actor{Name: "Val Kilmer", BirthYear: 1959, CountryOfBirth: "US", Gender: GenderMale}); err == nil || requests.Load() != 1 {
+		t.Errorf("Create after failure = %v in %d requests, want error in 1", err, requests.Load())
+	}
+
+	// Retry-After тоже ограничен MaxBackoff: час ожидания превратился бы в зависший вызов
+	retryAfter := newTestServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+		if failures.Add(-1) >= 0 {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return true
+		}
+		return false
+	})
+	failures.Store(1)
+	started := time.Now()
+	if _, err = retryAfter.Actors.List(ctx, ActorsQuery{}); err != nil || time.Since(started) > time.Second {
+		t.Errorf("List after Retry-After: 3600 = %v after %v, want success within MaxBackoff", err, time.Since(started))
+	}
+	for _, header := range []string{"1", "3600", "9223372036854775807"} {
+		if wait := c.backoff(0, &http.Response{Header: http.Header{"Retry-After": {header}}}); wait != c.cfg.MaxBackoff {
+			t.Errorf("backoff for Retry-After: %s = %v, want MaxBackoff %v", header, wait, c.cfg.MaxBackoff)
+		}
+	}
+
+	// отмена вызова прерывает ожидание повтора
+	c.cfg.RetryBackoff, c.cfg.MaxBackoff = time.Minute, time.Minute
+	failures.Store(10)
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	started = time.Now()
+	if _, err = c.Actors.Get(ctx, actor.ID); !errors.Is(err, context.DeadlineExceeded) || time.Since(started) > time.Second {
+		t.Errorf("Get with deadline = %v after %v, want context.DeadlineExceeded without waiting for retries", err, time.Since(started))
+	}
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"arch-demo/internal/domain"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// Ошибки сервиса. Ответ с ошибкой превращается в *Error, а errors.Is сравнивает
+// его с этими значениями по полю code, например errors.Is(err, client.ErrNotFound).
+var (
+	ErrUnauthenticated = domain.ErrUnauthenticated
+	ErrForbidden       = domain.ErrForbidden
+	ErrFieldsRequired  = domain.ErrFieldsRequired
+	ErrValidation      = domain.ErrValidation
+	ErrReferenced      = domain.ErrReferenced
+	ErrVersionMismatch = domain.ErrVersionMismatch
+	ErrExists          = domain.ErrExists
+	ErrNotFound        = domain.ErrNotFound
+	ErrNotExists       = domain.ErrNotExists
+	ErrIDRequired      = domain.ErrIDRequired
+	ErrInvalidPage     = domain.ErrInvalidPage
+	ErrInvalidCursor   = domain.ErrInvalidCursor
+	ErrInvalidFilter   = domain.ErrInvalidFilter
+	ErrInvalidSort     = domain.ErrInvalidSort
+	ErrInvalidBatch    = domain.ErrInvalidBatch
+)
+
+// codeErrors сопоставляет code из ответа с ошибкой домена, обратно к problemTypes в internal/api
+var codeErrors = map[string]error{
+	"unauthenticated":     domain.ErrUnauthenticated,
+	"forbidden":           domain.ErrForbidden,
+	"fields_required":     domain.ErrFieldsRequired,
+	"validation_failed":   domain.ErrValidation,
+	"referenced":          domain.ErrReferenced,
+	"precondition_failed": domain.ErrVersionMismatch,
+	"already_exists":      domain.ErrExists,
+	"not_found":           domain.ErrNotFound,
+	"not_exists":          domain.ErrNotExists,
+	"id_required":         domain.ErrIDRequired,
+	"invalid_pagination":  domain.ErrInvalidPage,
+	"invalid_cursor":      domain.ErrInvalidCursor,
+	"invalid_filter":      domain.ErrInvalidFilter,
+	"invalid_sort":        domain.ErrInvalidSort,
+	"invalid_batch":       domain.ErrInvalidBatch,
+}
+
+// Error - ответ сервиса с ошибкой в формате RFC 7807
+type Error struct {
+	Status    int    `json:"status"`
+	Code      string `json:"code"`
+	Title     string `json:"title"`
+	Detail    string `json:"detail"`
+	RequestID string `json:"request_id"`
+	// Fields - неверные поля при ErrFieldsRequired и ErrValidation
+	Fields []FieldError `json:"errors"`
+	// ReferencedBy - фильмы, из-за которых актера нельзя удалить, при ErrReferenced
+	ReferencedBy []Movie `json:"referenced_by"`
+}
+
+func (e *Error) Error() string {
+	message := e.Detail
+	if message == "" {
+		message = e.Title
+	}
+	if e.Code == "" {
+		return fmt.Sprintf("%d %s", e.Status, message)
+	}
+
+	return fmt.Sprintf("%d %s: %s", e.Status, e.Code, message)
+}
+
+// Unwrap - ошибка домена для code, nil для кодов, у которых ее нет (malformed_body, internal и т.п.)
+func (e *Error) Unwrap() error {
+	return codeErrors[e.Code]
+}
+
+// decodeError читает ответ с ошибкой. Ответ не в problem+json (например, от прокси
+// перед сервисом) превращается в Error только со статусом.
+func decodeError(resp *http.Response) error {
+	e := &Error{Status: resp.StatusCode, Title: http.StatusText(resp.StatusCode)}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "application/problem+json" {
+		return e
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read error response, status: %d, err: %w", resp.StatusCode, err)
+	}
+	if err = json.Unmarshal(data, e); err != nil {
+		return fmt.Errorf("failed to decode error response, status: %d, err: %w", resp.StatusCode, err)
+	}
+	e.Status = resp.StatusCode
+
+	return e
+}
@@ -0,0 +1,144 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// MoviesClient - /movies и состав фильмов
+type MoviesClient struct {
+	client *Client
+}
+
+func (c MoviesClient) Create(ctx context.Context, movie Movie) (Movie, error) {
+	var created Movie
+	err := c.client.do(ctx, request{method: http.MethodPost, path: "/movies", body: movie}, &created)
+
+	return created, err
+}
+
+func (c MoviesClient) Get(ctx context.Context, id int) (Movie, error) {
+	var movie Movie
+	err := c.client.do(ctx, request{method: http.MethodGet, path: moviePath(id)}, &movie)
+
+	return movie, err
+}
+
+// Update меняет переданные поля, version - как у ActorsClient.Update
+func (c MoviesClient) Update(ctx context.Context, id, version int, movieUpdate MovieUpdate) (Movie, error) {
+	var updated Movie
+	err := c.client.do(ctx, request{method: http.MethodPatch, path: moviePath(id), version: version, body: movieUpdate}, &updated)
+
+	return updated, err
+}
+
+func (c MoviesClient) Delete(ctx context.Context, id, version int) error {
+	return c.client.do(ctx, request{method: http.MethodDelete, path: moviePath(id), version: version}, nil)
+}
+
+func (c MoviesClient) Restore(ctx context.Context, id int) (Movie, error) {
+	var restored Movie
+	err := c.client.do(ctx, request{method: http.MethodPost, path: moviePath(id) + "/restore"}, &restored)
+
+	return restored, err
+}
+
+// List - список фильмов. Если в query задан ActorID, это фильмография актера.
+func (c MoviesClient) List(ctx context.Context, query MoviesQuery) (MoviePage, error) {
+	values, err := moviesValues(query)
+	if err != nil {
+		return MoviePage{}, err
+	}
+
+	path := "/movies"
+	if query.ActorID != 0 {
+		path = actorPath(query.ActorID) + "/movies"
+	}
+
+	var page MoviePage
+	err = c.client.do(ctx, request{method: http.MethodGet, path: path, query: values}, &page)
+
+	return page, err
+}
+
+func (c MoviesClient) ListByActor(ctx context.Context, actorID int, query MoviesQuery) (MoviePage, error) {
+	query.ActorID = actorID
+	return c.List(ctx, query)
+}
+
+// GetCast - состав фильма в порядке титров
+func (c MoviesClient) GetCast(ctx context.Context, movieID int) ([]CastMember, error) {
+	var cast []CastMember
+	err := c.client.do(ctx, request{method: http.MethodGet, path: castPath(movieID)}, &cast)
+
+	return cast, err
+}
+
+// CreateActorsForMovie заменяет состав фильма актерами с этими id без ролей и возвращает их id
+func (c MoviesClient) CreateActorsForMovie(ctx context.Context, movieID int, actorIDs []int) ([]int, error) {
+	var ids []int
+	err := c.client.do(ctx, request{method: http.MethodPost, path: castPath(movieID), body: actorIDs}, &ids)
+
+	return ids, err
+}
+
+// ReplaceCast заменяет состав целиком
+func (c MoviesClient) ReplaceCast(ctx context.Context, movieID int, cast []CastEntry) ([]CastMember, error) {
+	if cast == nil {
+		// nil ушел бы как null, а сервис ждет массив
+		cast = []CastEntry{}
+	}
+
+	var updated []CastMember
+	err := c.client.do(ctx, request{method: http.MethodPut, path: castPath(movieID), body: cast}, &updated)
+
+	return updated, err
+}
+
+// UpdateCast добавляет или обновляет переданных актеров, остальные не меняются
+func (c MoviesClient) UpdateCast(ctx context.Context, movieID int, entries []CastEntry) ([]CastMember, error) {
+	if entries == nil {
+		entries = []CastEntry{}
+	}
+
+	var updated []CastMember
+	err := c.client.do(ctx, request{method: http.MethodPatch, path: castPath(movieID), body: entries}, &updated)
+
+	return updated, err
+}
+
+func (c MoviesClient) ClearCast(ctx context.Context, movieID int) error {
+	return c.client.do(ctx, request{method: http.MethodDelete, path: castPath(movieID)}, nil)
+}
+
+// SetCastMember добавляет актера в состав или заменяет его запись целиком
+func (c MoviesClient) SetCastMember(ctx context.Context, movieID int, entry CastEntry) (CastMember, error) {
+	var member CastMember
+	err := c.client.do(ctx, request{method: http.MethodPut, path: castMemberPath(movieID, entry.ActorID), body: entry}, &member)
+
+	return member, err
+}
+
+func (c MoviesClient) UpdateCastMember(ctx context.Context, movieID, actorID int, update CastEntryUpdate) (CastMember, error) {
+	var member CastMember
+	err := c.client.do(ctx, request{method: http.MethodPatch, path: castMemberPath(movieID, actorID), body: update}, &member)
+
+	return member, err
+}
+
+func (c MoviesClient) RemoveCastMember(ctx context.Context, movieID, actorID int) error {
+	return c.client.do(ctx, request{method: http.MethodDelete, path: castMemberPath(movieID, actorID)}, nil)
+}
+
+func moviePath(id int) string {
+	return fmt.Sprintf("/movies/%d", id)
+}
+
+func castPath(movieID int) string {
+	return moviePath(movieID) + "/actors"
+}
+
+func castMemberPath(movieID, actorID int) string {
+	return fmt.Sprintf("%s/%d", castPath(movieID), actorID)
+}
@@ -0,0 +1,134 @@
+package client
+
+import (
+	"arch-demo/internal/domain"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Next - курсор для After следующего запроса, nil - страница последняя:
+//
+//	for {
+//		page, err := c.Actors.List(ctx, query)
+//		...
+//		if query.After, err = client.Next(page); err != nil || query.After == nil {
+//			break
+//		}
+//	}
+func Next[T any](page domain.Page[T]) (*domain.Cursor[T], error) {
+	if page.NextCursor == "" {
+		return nil, nil
+	}
+
+	cursor, err := domain.DecodeCursor[T](page.NextCursor)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cursor, nil
+}
+
+// textParams - имена параметров для условий TextFilter, пустое имя - сервис такое условие не принимает
+type textParams struct {
+	contains string
+	eq       string
+	prefix   string
+}
+
+func actorsValues(q ActorsQuery) (url.Values, error) {
+	values := url.Values{}
+	err := textValues(values, q.Name, textParams{contains: "name", eq: "name_eq", prefix: "name_prefix"})
+	if err == nil {
+		err = textValues(values, q.Country, textParams{eq: "country"})
+	}
+	if err == nil {
+		err = textValues(values, q.Gender, textParams{eq: "gender"})
+	}
+	if err != nil {
+		return nil, err
+	}
+	rangeValues(values, "birth_year", q.BirthYear, strconv.Itoa)
+
+	var after string
+	if q.After != nil {
+		after = q.After.Encode()
+	}
+	pageValues(values, q.Sort, q.Limit, q.Offset, after)
+
+	return values, nil
+}
+
+// moviesValues - параметры списка фильмов, ActorID задается путем /actors/{id}/movies
+func moviesValues(q MoviesQuery) (url.Values, error) {
+	values := url.Values{}
+	err := textValues(values, q.Name, textParams{contains: "name", eq: "name_eq", prefix: "name_prefix"})
+	if err == nil {
+		err = textValues(values, q.Genre, textParams{eq: "genre", prefix: "genre_prefix"})
+	}
+	if err == nil {
+		err = textValues(values, q.Country, textParams{eq: "country"})
+	}
+	if err != nil {
+		return nil, err
+	}
+	rangeValues(values, "release_date", q.ReleaseDate, func(t time.Time) string { return t.Format(time.RFC3339) })
+	rangeValues(values, "rating", q.Rating, strconv.Itoa)
+
+	var after string
+	if q.After != nil {
+		after = q.After.Encode()
+	}
+	pageValues(values, q.Sort, q.Limit, q.Offset, after)
+
+	return values, nil
+}
+
+func textValues(values url.Values, f TextFilter, params textParams) error {
+	conditions := []struct {
+		param  string
+		kind   string
+		values []string
+	}{{params.contains, "contains", f.Contains}, {params.eq, "eq", f.Eq}, {params.prefix, "prefix", f.Prefix}}
+
+	for _, c := range conditions {
+		if len(c.values) == 0 {
+			continue
+		}
+		if c.param == "" {
+			return fmt.Errorf("%w: %s condition is not supported for %s", domain.ErrInvalidFilter, c.kind, params.eq)
+		}
+		values[c.param] = c.values
+	}
+
+	return nil
+}
+
+func rangeValues[T any](values url.Values, field string, r domain.Range[T], format func(T) string) {
+	bounds := []struct {
+		suffix string
+		bound  *T
+	}{{"_gt", r.Gt}, {"_gte", r.Gte}, {"_lt", r.Lt}, {"_lte", r.Lte}}
+
+	for _, b := range bounds {
+		if b.bound != nil {
+			values.Set(field+b.suffix, format(*b.bound))
+		}
+	}
+}
+
+func pageValues(values url.Values, sort Sort, limit, offset int, after string) {
+	if len(sort) > 0 {
+		values.Set("sort", sort.String())
+	}
+	if limit > 0 {
+		values.Set("limit", strconv.Itoa(limit))
+	}
+	if offset > 0 {
+		values.Set("offset", strconv.Itoa(offset))
+	}
+	if after != "" {
+		values.Set("cursor", after)
+	}
+}
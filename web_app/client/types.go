@@ -0,0 +1,53 @@
+package client
+
+import (
+	"arch-demo/internal/domain"
+	"time"
+)
+
+// Типы запросов и ответов - те же, что у сервиса. Пакет domain внутренний,
+// поэтому другие модули получают их через эти псевдонимы, а не копируют.
+type (
+	Actor       = domain.Actor
+	ActorUpdate = domain.ActorUpdate
+	Movie       = domain.Movie
+	MovieUpdate = domain.MovieUpdate
+	MovieScore  = domain.MovieScore
+
+	CastEntry       = domain.CastEntry
+	CastEntryUpdate = domain.CastEntryUpdate
+	CastMember      = domain.CastMember
+
+	ActorsQuery = domain.ActorsQuery
+	MoviesQuery = domain.MoviesQuery
+	TextFilter  = domain.TextFilter
+	IntRange    = domain.Range[int]
+	TimeRange   = domain.Range[time.Time]
+	Sort        = domain.Sort
+	SortKey     = domain.SortKey
+
+	ActorPage = domain.Page[domain.Actor]
+	MoviePage = domain.Page[domain.Movie]
+
+	FieldError = domain.FieldError
+)
+
+// значения полей, которые принимает сервис
+const (
+	GenderMale   = domain.GenderMale
+	GenderFemale = domain.GenderFemale
+	GenderOther  = domain.GenderOther
+
+	RoleLead       = domain.RoleLead
+	RoleSupporting = domain.RoleSupporting
+)
+
+// ParseSort разбирает сортировку вида "-rating,name", см. ActorSortFields и MovieSortFields
+func ParseSort(value string, allowed []string) (Sort, error) {
+	return domain.ParseSort(value, allowed)
+}
+
+var (
+	ActorSortFields = domain.ActorSortFields
+	MovieSortFields = domain.MovieSortFields
+)
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"arch-demo/internal/api"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestConditionalGet - одна запись отвечает 304 и по ETag, и по дате, а списки и состав
+// только по ETag: после удаления записи If-Modified-Since не должен отдать устаревший 304
+func TestConditionalGet(t *testing.T) {
+	handler := newTestRouter(t)
+
+	do := func(method, target, body string, want int, headers ...string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(api.APIKeyHeader, testAdminKey)
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Fatalf("%s %s = %d, want %d: %s", method, target, rec.Code, want, rec.Body.String())
+		}
+		return rec
+	}
+	id := func(rec *httptest.ResponseRecorder) string {
+		t.Helper()
+		var created struct {
+			ID int `json:"id"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil || created.ID == 0 {
+			t.Fatalf("response has no id: %s", rec.Body.String())
+		}
+		return strconv.Itoa(created.ID)
+	}
+	// later - дата позже любого изменения: по ней любая копия выглядит актуальной
+	later := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+
+	lead := id(do(http.MethodPost, "/actors", `{"name": "Al Pacino", "birth_year": 1940, "country_of_birth": "US", "gender": "male"}`, http.StatusCreated))
+	support := id(do(http.MethodPost, "/actors", `{"name": "Val Kilmer", "birth_year": 1959, "country_of_birth": "US", "gender": "male"}`, http.StatusCreated))
+	movie := id(do(http.MethodPost, "/movies", `{"name": "Heat", "release_date": "1995-12-15T00:00:00Z", "country": "US", "genre": "crime", "rating": 5}`, http.StatusCreated))
+	cast := "/movies/" + movie + "/actors"
+	do(http.MethodPost, cast, `[`+lead+`, `+support+`]`, http.StatusCreated)
+
+	t.Run("single record", func(t *testing.T) {
+		rec := do(http.MethodGet, "/actors/"+lead, "", http.StatusOK)
+		lastModified := rec.Header().Get("Last-Modified")
+		if lastModified == "" {
+			t.Fatal("GET /actors/{id} has no Last-Modified")
+		}
+		do(http.MethodGet, "/actors/"+lead, "", http.StatusNotModified, "If-None-Match", rec.Header().Get("ETag"))
+		do(http.MethodGet, "/actors/"+lead, "", http.StatusNotModified, "If-Modified-Since", lastModified)
+	})
+
+	collections := []string{"/actors", "/movies", "/actors/" + lead + "/movies", cast}
+	etags := make(map[string]string)
+	for _, target := range collections {
+		rec := do(http.MethodGet, target, "", http.StatusOK)
+		if rec.Header().Get("Last-Modified") != "" {
+			t.Errorf("GET %s has Last-Modified", target)
+		}
+		etags[target] = rec.Header().Get("ETag")
+		if !strings.HasPrefix(etags[target], `W/"`) {
+			t.Errorf("GET %s ETag = %q, want a weak tag", target, etags[target])
+		}
+		do(http.MethodGet, target, "", http.StatusNotModified, "If-None-Match", etags[target])
+		do(http.MethodGet, target, "", http.StatusOK, "If-Modified-Since", later)
+	}
+
+	// удаление меняет списки, но не время изменения оставшихся записей
+	do(http.MethodDelete, cast+"/"+support, "", http.StatusNoContent)
+	do(http.MethodDelete, "/actors/"+support, "", http.StatusAccepted)
+
+	counts := map[string]int{"/actors": 1, "/movies": 1, "/actors/" + lead + "/movies": 1, cast: 1}
+	for _, target := range collections {
+		for _, header := range [][]string{{"If-None-Match", etags[target]}, {"If-Modified-Since", later}} {
+			rec := do(http.MethodGet, target, "", http.StatusOK, header...)
+
+			var items []json.RawMessage
+			if target == cast {
+				_ = json.Unmarshal(rec.Body.Bytes(), &items)
+			} else {
+				var page struct {
+					Items []json.RawMessage `json:"items"`
+				}
+				_ = json.Unmarshal(rec.Body.Bytes(), &page)
+				items = page.Items
+			}
+			if len(items) != counts[target] {
+				t.Errorf("GET %s with %s after delete: %d items, want %d", target, header[0], len(items), counts[target])
+			}
+		}
+	}
+	if rec := do(http.MethodGet, "/actors", "", http.StatusOK); rec.Header().Get("ETag") == etags["/actors"] {
+		t.Error("ETag of /actors did not change after delete")
+	}
+}
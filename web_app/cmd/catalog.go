@@ -0,0 +1,171 @@
+package main
+
+import (
+	"arch-demo/internal/config"
+	"arch-demo/internal/domain"
+	"arch-demo/internal/services"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+)
+
+const importUsage = `usage: import [flags] <file>
+
+Upserts actors, movies and cast from file, "-" reads stdin. The format is taken
+from -format or the file extension: .csv for CSV, anything else for NDJSON.
+Prints the report and exits with status 1 if any record failed.`
+
+const exportUsage = `usage: export [flags] [file]
+
+Writes all actors, movies and cast to file or stdout. The format is taken
+from -format or the file extension: .csv for CSV, anything else for NDJSON.`
+
+// runImport реализует подкоманду import, флаги хранилища те же, что и у сервера
+func runImport(args []string) {
+	var format string
+	var dryRun bool
+	cfg, args, err := config.LoadWithFlags("import", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&format, "format", "", "ndjson or csv, by default chosen by the file extension")
+		fs.BoolVar(&dryRun, "dry-run", false, "validate records without saving anything")
+	})
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintln(os.Stderr, importUsage)
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(args) != 1 {
+		log.Fatal(importUsage)
+	}
+	if cfg.Storage.Backend == config.BackendMemory {
+		log.Fatalf("import into the %s backend would be lost on exit, use %s or %s",
+			config.BackendMemory, config.BackendPostgres, config.BackendSQLite)
+	}
+
+	in := io.Reader(os.Stdin)
+	if args[0] != "-" {
+		file, err := os.Open(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		in = file
+	}
+
+	catalog, closeStorage := newCatalog(cfg)
+	defer closeStorage()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	report, importErr := catalog.Import(domain.WithCaller(ctx, "import"), catalogFormat(format, args[0]), in, dryRun)
+
+	out := json.NewEncoder(os.Stdout)
+	out.SetIndent("", "  ")
+	if err = out.Encode(report); err != nil {
+		log.Println(err)
+	}
+
+	if importErr != nil {
+		closeStorage()
+		log.Fatal(importErr)
+	}
+	if report.Failed > 0 {
+		closeStorage()
+		os.Exit(1)
+	}
+}
+
+// runExport реализует подкоманду export
+func runExport(args []string) {
+	var format string
+	cfg, args, err := config.LoadWithFlags("export", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&format, "format", "", "ndjson or csv, by default chosen by the file extension")
+	})
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintln(os.Stderr, exportUsage)
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(args) > 1 {
+		log.Fatal(exportUsage)
+	}
+
+	path := "-"
+	if len(args) == 1 {
+		path = args[0]
+	}
+
+	catalog, closeStorage := newCatalog(cfg)
+	defer closeStorage()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if path == "-" {
+		err = catalog.Export(ctx, catalogFormat(format, path), os.Stdout)
+	} else {
+		err = exportFile(ctx, catalog, catalogFormat(format, path), path)
+	}
+	if err != nil {
+		closeStorage()
+		log.Fatal(err)
+	}
+}
+
+// exportFile не оставляет на диске обрезанный файл, если выгрузка не удалась
+func exportFile(ctx context.Context, catalog services.CatalogService, format domain.CatalogFormat, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	err = catalog.Export(ctx, format, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+	}
+
+	return err
+}
+
+func catalogFormat(flagValue, path string) domain.CatalogFormat {
+	if flagValue != "" {
+		return domain.CatalogFormat(flagValue)
+	}
+	if filepath.Ext(path) == ".csv" {
+		return domain.FormatCSV
+	}
+
+	return domain.FormatNDJSON
+}
+
+// newCatalog собирает сервисы так же, как сервер, чтобы импорт проходил те же
+// проверки и попадал в журнал изменений
+func newCatalog(cfg config.Config) (services.CatalogService, func()) {
+	store, closeStorage, err := newStorage(cfg.Storage)
+	if err != nil {
+		log.Fatalf("failed to init %s storage: %v", cfg.Storage.Backend, err)
+	}
+
+	auditLog := services.NewAuditLog(store)
+	policy := domain.DeletePolicy(cfg.Storage.DeletePolicy)
+
+	return services.NewCatalogService(
+		services.NewActorService(store, policy, auditLog),
+		services.NewMovieService(store, policy, auditLog),
+	), closeStorage
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"arch-demo/internal/config"
+	"arch-demo/internal/storage/db"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+)
+
+const migrateUsage = `usage: migrate [flags] <command>
+
+commands:
+  up          apply all pending migrations
+  down [N]    revert the last N migrations (default 1)
+  goto V      migrate up or down to version V
+  version     print the current and the latest known schema version`
+
+// runMigrate реализует подкоманду migrate, флаги те же, что и у сервера
+func runMigrate(args []string) {
+	cfg, args, err := config.Load("migrate", args)
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if cfg.Storage.Backend == config.BackendMemory {
+		log.Fatalf("migrations apply only to the %s and %s backends", config.BackendPostgres, config.BackendSQLite)
+	}
+	if len(args) == 0 {
+		log.Fatal(migrateUsage)
+	}
+
+	dbCon, err := db.Open(db.Dialect(cfg.Storage.Backend), cfg.Storage.DSN)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		if err := dbCon.Close(); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	migrator, err := db.NewMigrator(dbCon, db.Dialect(cfg.Storage.Backend))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch command := args[0]; {
+	case command == "up" && len(args) == 1:
+		err = migrator.Up()
+	case command == "down" && len(args) <= 2:
+		steps := 1
+		if len(args) == 2 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				log.Fatalf("down expects a positive number of steps, got %q", args[1])
+			}
+		}
+		err = migrator.Down(steps)
+	case command == "goto" && len(args) == 2:
+		var version int
+		version, err = strconv.Atoi(args[1])
+		if err != nil {
+			log.Fatalf("goto expects a version number, got %q", args[1])
+		}
+		err = migrator.Migrate(version)
+	case command == "version" && len(args) == 1:
+	default:
+		log.Fatal(migrateUsage)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	version, err := migrator.Version()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("schema version %d, latest %d\n", version, migrator.Latest())
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"arch-demo/internal/api"
+	"arch-demo/internal/api/openapi"
+	"arch-demo/internal/config"
+	"arch-demo/internal/storage/inmemory"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const testAdminKey = "test-admin-key-0123456789"
+
+// newTestRouter - маршрутизатор как в main, с аутентификацией, чтобы в нем были и маршруты /admin
+func newTestRouter(t *testing.T) http.Handler {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.Auth.Enabled = true
+	cfg.Auth.AdminKey = testAdminKey
+	r, _, err := newRouter(cfg, inmemory.NewStorage())
+	if err != nil {
+		t.Fatalf("newRouter: %v", err)
+	}
+
+	return r
+}
+
+// TestOpenAPIRoutes - каждый маршрут описан в спецификации, а каждая операция спецификации есть среди маршрутов
+func TestOpenAPIRoutes(t *testing.T) {
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	var routes []string
+	err = chi.Walk(newTestRouter(t).(chi.Routes), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if len(route) > 1 {
+			route = strings.TrimSuffix(route, "/")
+		}
+		routes = append(routes, method+" "+route)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk: %v", err)
+	}
+
+	operations := doc.Operations()
+	for _, route := range routes {
+		if !slices.Contains(operations, route) {
+			t.Errorf("route %s is not described in openapi.json", route)
+		}
+	}
+	for _, op := range operations {
+		if !slices.Contains(routes, op) {
+			t.Errorf("openapi.json describes %s, but there is no such route", op)
+		}
+	}
+}
+
+// TestOpenAPIResponses проходит по всем операциям спецификации и проверяет ответы
+// обработчиков по ней: поле, статус или тип содержимого, которых нет в спецификации, - ошибка
+func TestOpenAPIResponses(t *testing.T) {
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	covered := make(map[string]bool)
+	validate := api.ValidateOpenAPI(doc, func(r *http.Request, route openapi.Route, err error) {
+		t.Errorf("%s %s: %v", r.Method, r.URL, err)
+	})
+	handler := validate(newTestRouter(t))
+
+	type response struct {
+		status int
+		header http.Header
+		body   map[string]any
+	}
+	do := func(method, target, body string, want int, headers ...string) response {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(api.APIKeyHeader, testAdminKey)
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Fatalf("%s %s = %d, want %d: %s", method, target, rec.Code, want, rec.Body.String())
+		}
+		if route, ok := doc.Find(method, req.URL.Path); ok {
+			covered[route.Method+" "+route.Pattern] = true
+		}
+
+		var decoded map[string]any
+		_ = json.Unmarshal(rec.Body.Bytes(), &decoded)
+		return response{status: rec.Code, header: rec.Header(), body: decoded}
+	}
+	id := func(r response) string {
+		t.Helper()
+		value, ok := r.body["id"].(float64)
+		if !ok {
+			t.Fatalf("response has no id: %v", r.body)
+		}
+		return strconv.Itoa(int(value))
+	}
+
+	do(http.MethodGet, "/openapi.json", "", http.StatusOK)
+	do(http.MethodGet, "/docs", "", http.StatusOK)
+
+	user := id(do(http.MethodPost, "/admin/users", `{"name": "critic", "role": "editor"}`, http.StatusCreated))
+	do(http.MethodGet, "/admin/users", "", http.StatusOK)
+	do(http.MethodGet, "/admin/users/"+user, "", http.StatusOK)
+	do(http.MethodPatch, "/admin/users/"+user, `{"role": "viewer"}`, http.StatusOK)
+	key := id(do(http.MethodPost, "/admin/keys", `{"user_id": `+user+`, "name": "ci"}`, http.StatusCreated))
+	do(http.MethodGet, "/admin/keys", "", http.StatusOK)
+	do(http.MethodDelete, "/admin/keys/"+key, "", http.StatusNoContent)
+	do(http.MethodDelete, "/admin/users/"+user, "", http.StatusNoContent)
+
+	lead := do(http.MethodPost, "/actors", `{"name": "Al Pacino", "birth_year": 1940, "country_of_birth": "US", "gender": "male"}`, http.StatusCreated)
+	support := id(do(http.MethodPost, "/actors", `{"name": "Val Kilmer", "birth_year": 1959, "country_of_birth": "US", "gender": "male"}`, http.StatusCreated))
+	do(http.MethodGet, "/actors?name=Al&sort=-birth_year&limit=10", "", http.StatusOK)
+	do(http.MethodGet, "/actors/"+id(lead), "", http.StatusNotModified, "If-None-Match", lead.header.Get("ETag"))
+	do(http.MethodPatch, "/actors/"+id(lead), `{"gender": "other"}`, http.StatusOK, "If-Match", lead.header.Get("ETag"))
+	do(http.MethodGet, "/actors/"+id(lead), "", http.StatusOK)
+	do(http.MethodGet, "/actors/"+id(lead)+"/history", "", http.StatusOK)
+	do(http.MethodPost, "/actors:batch", `[{"name": "Amy Brenneman", "birth_year": 1964, "country_of_birth": "US", "gender": "female"},
+		{"id": `+support+`, "birth_year": 1958}]`, http.StatusOK)
+
+	movie := id(do(http.MethodPost, "/movies", `{"name": "Heat", "release_date": "1995-12-15T00:00:00Z", "country": "US", "genre": "crime", "rating": 5}`, http.StatusCreated))
+	other := id(do(http.MethodPost, "/movies", `{"name": "Ronin", "release_date": "1998-09-25T00:00:00Z", "country": "US", "genre": "action", "rating": 4}`, http.StatusCreated))
+	do(http.MethodGet, "/movies?genre=crime&release_date_gte=1990-01-01&sort=-score", "", http.StatusOK)
+	do(http.MethodGet, "/movies/"+movie, "", http.StatusOK)
+	do(http.MethodPatch, "/movies/"+movie, `{"rating": 4}`, http.StatusOK)
+	do(http.MethodGet, "/movies/"+movie+"/history", "", http.StatusOK)
+	do(http.MethodPost, "/movies:batch", `[{"name": "Thief", "release_date": "1981-03-27T00:00:00Z", "country": "US", "genre": "crime", "rating": 5},
+		{"id": `+other+`, "rating": 3}]`, http.StatusOK)
+
+	cast := "/movies/" + movie + "/actors"
+	do(http.MethodPost, cast, `[`+id(lead)+`]`, http.StatusCreated)
+	do(http.MethodPut, cast, `[{"actor_id": `+id(lead)+`, "character": "Vincent Hanna", "billing_order": 1, "role": "lead"}]`, http.StatusOK)
+	do(http.MethodPatch, cast, `[{"actor_id": `+support+`, "character": "Chris"}]`, http.StatusOK)
+	do(http.MethodGet, cast, "", http.StatusOK)
+	do(http.MethodPut, cast+"/"+support, `{"character": "Chris Shiherlis", "billing_order": 2}`, http.StatusOK)
+	do(http.MethodPatch, cast+"/"+support, `{"role": "supporting"}`, http.StatusOK)
+	do(http.MethodGet, "/actors/"+id(lead)+"/movies", "", http.StatusOK)
+	do(http.MethodDelete, cast+"/"+support, "", http.StatusNoContent)
+	do(http.MethodDelete, cast, "", http.StatusNoContent)
+
+	reviews := "/movies/" + movie + "/reviews"
+	review := id(do(http.MethodPost, reviews, `{"rating": 5, "text": "Great"}`, http.StatusCreated))
+	do(http.MethodPost, reviews, `{"rating": 4, "text": "Still great"}`, http.StatusOK)
+	do(http.MethodGet, "/reviews?status=pending", "", http.StatusOK)
+	do(http.MethodPatch, reviews+"/"+review, `{"status": "approved"}`, http.StatusOK)
+	do(http.MethodGet, reviews+"?limit=5", "", http.StatusOK)
+
+	do(http.MethodGet, "/search?q=heat&type=movie", "", http.StatusOK)
+	do(http.MethodGet, "/export", "", http.StatusOK)
+	do(http.MethodGet, "/export?format=csv", "", http.StatusOK)
+	do(http.MethodPost, "/import?dry_run=true", "", http.StatusOK, "Content-Type", "application/x-ndjson")
+
+	do(http.MethodDelete, "/actors/"+support, "", http.StatusAccepted)
+	do(http.MethodDelete, "/movies/"+other, "", http.StatusAccepted)
+	do(http.MethodGet, "/trash", "", http.StatusOK)
+	do(http.MethodPost, "/actors/"+support+"/restore", "", http.StatusOK)
+	do(http.MethodPost, "/movies/"+other+"/restore", "", http.StatusOK)
+
+	// ошибки тоже проверяются по спецификации, как problem+json
+	do(http.MethodGet, "/movies/1000", "", http.StatusNotFound)
+	do(http.MethodPost, reviews, `{"rating": 9}`, http.StatusUnprocessableEntity)
+
+	// запрос не по спецификации до обработчика не доходит
+	invalid := do(http.MethodPatch, "/actors/"+id(lead), `{"gender_identity": "male"}`, http.StatusBadRequest)
+	if invalid.body["code"] != "invalid_request" {
+		t.Errorf("PATCH with unknown field: code %v, want invalid_request", invalid.body["code"])
+	}
+	do(http.MethodGet, "/movies?limit=many", "", http.StatusBadRequest)
+
+	// устаревшее поле sex по-прежнему меняет пол, а не пропускается молча
+	if aliased := do(http.MethodPatch, "/actors/"+id(lead), `{"sex": "female"}`, http.StatusOK); aliased.body["gender"] != "female" {
+		t.Errorf("PATCH with deprecated sex: gender %v, want female", aliased.body["gender"])
+	}
+
+	for _, op := range doc.Operations() {
+		if !covered[op] {
+			t.Errorf("%s is not exercised by the test, add a request for it", op)
+		}
+	}
+}
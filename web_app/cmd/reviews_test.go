@@ -0,0 +1,79 @@
+package main
+
+import (
+	"arch-demo/internal/api"
+	"arch-demo/internal/config"
+	"arch-demo/internal/storage/inmemory"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestSubmitReview - отзыв оставляет только аутентифицированный вызывающий: с аутентификацией
+// анонимный получает 401, без нее маршрута отправки нет, а чтение отзывов остается
+func TestSubmitReview(t *testing.T) {
+	tests := []struct {
+		name string
+		auth bool
+		// key - заголовок X-API-Key отправки отзыва
+		key  string
+		want int
+		code string
+	}{
+		{name: "auth enabled", auth: true, key: testAdminKey, want: http.StatusCreated},
+		{name: "auth enabled, anonymous", auth: true, want: http.StatusUnauthorized, code: "unauthenticated"},
+		{name: "auth disabled", auth: false, want: http.StatusMethodNotAllowed, code: "method_not_allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Auth.Enabled = tt.auth
+			cfg.Auth.AdminKey = testAdminKey
+			handler, _, err := newRouter(cfg, inmemory.NewStorage())
+			if err != nil {
+				t.Fatalf("newRouter: %v", err)
+			}
+
+			do := func(method, target, body, key string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(method, target, strings.NewReader(body))
+				req.Header.Set("Content-Type", "application/json")
+				if key != "" {
+					req.Header.Set(api.APIKeyHeader, key)
+				}
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				return rec
+			}
+
+			rec := do(http.MethodPost, "/movies", `{"name": "Heat", "release_date": "1995-12-15T00:00:00Z", "country": "US", "genre": "crime", "rating": 5}`, testAdminKey)
+			var movie struct {
+				ID int `json:"id"`
+			}
+			if err = json.Unmarshal(rec.Body.Bytes(), &movie); rec.Code != http.StatusCreated || err != nil {
+				t.Fatalf("POST /movies = %d: %s", rec.Code, rec.Body.String())
+			}
+			reviews := "/movies/" + strconv.Itoa(movie.ID) + "/reviews"
+
+			rec = do(http.MethodPost, reviews, `{"rating": 4}`, tt.key)
+			if rec.Code != tt.want {
+				t.Fatalf("POST %s = %d, want %d: %s", reviews, rec.Code, tt.want, rec.Body.String())
+			}
+			if tt.code != "" {
+				var problem struct {
+					Code string `json:"code"`
+				}
+				if err = json.Unmarshal(rec.Body.Bytes(), &problem); err != nil || problem.Code != tt.code {
+					t.Errorf("POST %s: code %q, want %q", reviews, problem.Code, tt.code)
+				}
+			}
+
+			if rec = do(http.MethodGet, reviews, "", testAdminKey); rec.Code != http.StatusOK {
+				t.Errorf("GET %s = %d, want 200: %s", reviews, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"arch-demo/internal/api"
+	"arch-demo/internal/api/openapi"
+	"arch-demo/internal/config"
+	"arch-demo/internal/domain"
+	"arch-demo/internal/services"
+	"github.com/go-chi/chi/v5"
+	"log/slog"
+	"net/http"
+)
+
+// newRouter собирает сервисы и маршруты поверх хранилища. Сервис корзины
+// возвращается отдельно: его периодически очищает runPurge.
+func newRouter(cfg config.Config, store storage) (http.Handler, services.TrashService, error) {
+	auditLog := services.NewAuditLog(store)
+	actorsService := services.NewActorService(store, domain.DeletePolicy(cfg.Storage.DeletePolicy), auditLog)
+	actorsHandler := api.NewActorsHandler(actorsService)
+	moviesService := services.NewMovieService(store, domain.DeletePolicy(cfg.Storage.DeletePolicy), auditLog)
+	var moviesHandler api.MoviesHandler
+	// новый отзыв меняет оценку фильма, поэтому сбрасывает кэш списков фильмов
+	var scoreChanged func()
+	if cfg.Cache.MoviesTTL > 0 {
+		cached := services.NewCachedMoviesService(moviesService, cfg.Cache.MoviesTTL, cfg.Cache.MaxEntries)
+		moviesHandler = api.NewLaptopsHandler(cached)
+		scoreChanged = cached.Invalidate
+	} else {
+		moviesHandler = api.NewLaptopsHandler(moviesService)
+	}
+	reviewsHandler := api.NewReviewsHandler(services.NewReviewsService(store, scoreChanged))
+	// импорт идет через тот же сервис фильмов, что и обработчики, чтобы сбрасывать его кэш
+	catalogHandler := api.NewCatalogHandler(services.NewCatalogService(actorsService, moviesHandler.Service))
+	trashService := services.NewTrashService(store, cfg.Storage.TrashRetention)
+	trashHandler := api.NewTrashHandler(trashService)
+	searchHandler := api.NewSearchHandler(services.NewSearchService(store))
+
+	tokens, err := newTokenVerifier(cfg.Auth.JWT)
+	if err != nil {
+		return nil, services.TrashService{}, err
+	}
+	authService := services.NewAuthService(store, tokens, cfg.Auth.AdminKey)
+	adminHandler := api.NewAdminHandler(authService)
+
+	access := api.NewAccess(cfg.Auth.Enabled)
+	viewer := access.Require(domain.RoleViewer)
+	editor := access.Require(domain.RoleEditor)
+	admin := access.Require(domain.RoleAdmin)
+
+	r := chi.NewRouter()
+	r.Use(api.RequestID)
+	if cfg.Auth.Enabled {
+		r.Use(api.Authenticate(authService))
+	} else {
+		// без аутентификации вызывающий представляется сам, через X-Caller
+		r.Use(api.Caller)
+	}
+	// по истечении дедлайна контекст запроса отменяется, вместе с ним и запросы к базе
+	r.Use(api.Timeout(cfg.HTTP.RequestTimeout))
+	if cfg.HTTP.ValidateOpenAPI {
+		doc, err := openapi.Load()
+		if err != nil {
+			return nil, services.TrashService{}, err
+		}
+		r.Use(api.ValidateOpenAPI(doc, logInvalidResponse))
+	}
+	r.NotFound(api.NotFound)
+	r.MethodNotAllowed(api.MethodNotAllowed)
+	// чтение доступно viewer, изменения - editor, удаление и восстановление актеров и фильмов - admin.
+	// Удаление актеров из состава - изменение фильма, поэтому ему хватает editor.
+	r.Route("/", func(r chi.Router) {
+		// спецификация и Swagger UI открыты всем, как и сам список маршрутов
+		r.Get("/openapi.json", api.OpenAPI)
+		r.Get("/docs", api.SwaggerUI)
+
+		// пакеты выполняются в одной транзакции: сохраняются все элементы или ни один
+		r.With(editor).Post("/actors:batch", actorsHandler.Batch)
+		r.With(editor).Post("/movies:batch", moviesHandler.Batch)
+
+		r.Route("/actors", func(r chi.Router) {
+			r.With(editor).Post("/", actorsHandler.Create) //добавление нового актера
+			r.With(viewer).Get("/", actorsHandler.List)    //получение списка актеров
+
+			r.Route("/{id}", func(r chi.Router) {
+				r.With(viewer).Get("/", actorsHandler.Get)      //получение одного актера по id
+				r.With(editor).Patch("/", actorsHandler.Update) //частичное обновление актера, можно обновить любое значение
+				r.With(admin).Delete("/", actorsHandler.Delete) //удаление актера по id
+				r.With(admin).Post("/restore", actorsHandler.Restore)
+				r.With(viewer).Get("/history", actorsHandler.History) //журнал изменений актера
+
+				r.With(viewer).Get("/movies", moviesHandler.ListByActor) //фильмография актера, фильтры и сортировка как у /movies
+			})
+		})
+
+		r.With(viewer).Get("/trash", trashHandler.List)      //мягко удаленные актеры и фильмы
+		r.With(viewer).Get("/search", searchHandler.Search)  //поиск актеров и фильмов по названию
+		r.With(editor).Get("/reviews", reviewsHandler.Queue) //отзывы для модерации, по умолчанию ожидающие
+
+		r.With(viewer).Get("/export", catalogHandler.Export)  //выгрузка каталога в NDJSON или CSV
+		r.With(editor).Post("/import", catalogHandler.Import) //загрузка каталога, с dry_run=true только проверка
+
+		r.Route("/movies", func(r chi.Router) {
+			r.With(editor).Post("/", moviesHandler.Create)
+			r.With(viewer).Get("/", moviesHandler.List)
+
+			r.Route("/{id}", func(r chi.Router) {
+				r.With(viewer).Get("/", moviesHandler.Get)
+				r.With(editor).Patch("/", moviesHandler.Update)
+				r.With(admin).Delete("/", moviesHandler.Delete)
+				r.With(admin).Post("/restore", moviesHandler.Restore)
+				r.With(viewer).Get("/history", moviesHandler.History) //журнал изменений фильма и его состава
+				r.Route("/reviews", func(r chi.Router) {
+					r.With(viewer).Get("/", reviewsHandler.List)                  //одобренные отзывы на фильм
+					r.With(editor).Patch("/{review_id}", reviewsHandler.Moderate) //одобрение или отклонение отзыва
+					// автор отзыва - аутентифицированный вызывающий: без аутентификации имя из X-Caller
+					// не подтверждено, и один клиент заменял бы отзывы другого
+					if cfg.Auth.Enabled {
+						r.With(viewer).Post("/", reviewsHandler.Submit) //оценка и отзыв вызывающего, прежний заменяется
+					}
+				})
+				r.Route("/actors", func(r chi.Router) {
+					r.With(viewer).Get("/", moviesHandler.GetActors)
+					r.With(editor).Post("/", moviesHandler.CreateActorsForMovie)
+					r.With(editor).Put("/", moviesHandler.ReplaceCast)
+					r.With(editor).Patch("/", moviesHandler.UpdateCast)
+					r.With(editor).Delete("/", moviesHandler.ClearCast)
+
+					r.Route("/{actor_id}", func(r chi.Router) {
+						r.With(editor).Put("/", moviesHandler.SetCastMember)
+						r.With(editor).Patch("/", moviesHandler.UpdateCastMember)
+						r.With(editor).Delete("/", moviesHandler.RemoveCastMember)
+					})
+				})
+			})
+
+		})
+
+		// пользователи и ключи имеют смысл только при включенной аутентификации
+		if cfg.Auth.Enabled {
+			r.Route("/admin", func(r chi.Router) {
+				r.Use(admin)
+
+				r.Route("/users", func(r chi.Router) {
+					r.Post("/", adminHandler.CreateUser)
+					r.Get("/", adminHandler.ListUsers)
+					r.Get("/{id}", adminHandler.GetUser)
+					r.Patch("/{id}", adminHandler.UpdateUser)
+					r.Delete("/{id}", adminHandler.DeleteUser)
+				})
+
+				r.Route("/keys", func(r chi.Router) {
+					r.Post("/", adminHandler.CreateKey)
+					r.Get("/", adminHandler.ListKeys)
+					r.Delete("/{id}", adminHandler.DeleteKey)
+				})
+			})
+		}
+
+	})
+
+	return r, trashService, nil
+}
+
+// logInvalidResponse - ответ уже отправлен клиенту, поэтому расхождение со спецификацией только пишется в лог
+func logInvalidResponse(r *http.Request, route openapi.Route, err error) {
+	slog.Error("response does not match the openapi spec", "method", route.Method, "path", route.Pattern, "url", r.URL.String(), "error", err)
+}
@@ -2,18 +2,19 @@ package api
 
 import (
 	"arch-demo/internal/domain"
-	"encoding/json"
-	"errors"
-	"log"
+	"context"
 	"net/http"
 )
 
 type ActorsService interface {
-	Create(actor domain.Actor) (domain.Actor, error)
-	Get(id int) (domain.Actor, error)
-	Delete(id int) error
-	Update(id int, actorUpdate domain.ActorUpdate) (domain.Actor, error)
-	List(sortBy, orderBy, nameQuery, countryOfBirthQuery string) ([]domain.Actor, error)
+	Create(ctx context.Context, actor domain.Actor) (domain.Actor, error)
+	Get(ctx context.Context, id int) (domain.Actor, error)
+	Delete(ctx context.Context, id, version int) error
+	Restore(ctx context.Context, id int) (domain.Actor, error)
+	History(ctx context.Context, id int) ([]domain.AuditEntry, error)
+	Update(ctx context.Context, id, version int, actorUpdate domain.ActorUpdate) (domain.Actor, error)
+	List(ctx context.Context, query domain.ActorsQuery) (domain.Page[domain.Actor], error)
+	Batch(ctx context.Context, items []domain.ActorBatchItem) ([]domain.BatchResult[domain.Actor], error)
 }
 
 type ActorsHandler struct {
@@ -27,175 +28,171 @@ func NewActorsHandler(service ActorsService) ActorsHandler {
 }
 
 func (h ActorsHandler) Create(w http.ResponseWriter, r *http.Request) {
-	// необходимо удостоверится, что в запросе контент нужного типа
-	if r.Header.Get("Content-Type") != "application/json" {
-		http.Error(w, "content type not allowed", http.StatusUnsupportedMediaType)
+	var newActor domain.Actor
+	err := decodeJSON(r, &newActor)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	var newActor domain.Actor
-	err := json.NewDecoder(r.Body).Decode(&newActor)
+	createdActor, err := h.Service.Create(r.Context(), newActor)
 	if err != nil {
-		http.Error(w, "failed to read request body", http.StatusBadRequest)
-		log.Println(err)
+		writeError(w, r, err)
 		return
 	}
 
-	createdActor, err := h.Service.Create(newActor)
-	if err != nil {
-		switch {
-		case errors.Is(err, domain.ErrFieldsRequired):
-			http.Error(w, "all required fields must have values", http.StatusUnprocessableEntity)
-		case errors.Is(err, domain.ErrExists):
-			http.Error(w, "actor already exists", http.StatusConflict)
-		default:
-			http.Error(w, "unexpected error", http.StatusInternalServerError)
-		}
-		log.Println(err)
+	setETag(w, createdActor.Version)
+	writeJSON(w, r, http.StatusCreated, createdActor)
+}
 
+func (h ActorsHandler) List(w http.ResponseWriter, r *http.Request) {
+	limit, offset, after, err := pageParams[domain.Actor](r)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	data, err := json.Marshal(createdActor)
-	if err != nil {
-		http.Error(w, "failed to create response data", http.StatusInternalServerError)
-		log.Println(err)
+	query := domain.ActorsQuery{
+		Limit:  limit,
+		Offset: offset,
+		After:  after,
+	}
+	if err = actorsFilter(r.URL.Query(), &query); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if query.Sort, err = domain.ParseSort(r.URL.Query().Get("sort"), domain.ActorSortFields); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	w.Header().Add("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	_, err = w.Write(data)
+	page, err := h.Service.List(r.Context(), query)
 	if err != nil {
-		log.Println(err)
+		writeError(w, r, err)
 		return
 	}
+
+	writeCached(w, r, pageValidators(page, actorRevision), page)
 }
 
-func (h ActorsHandler) List(w http.ResponseWriter, r *http.Request) {
-	sortBy := r.URL.Query().Get("sort")
-	orderBy := r.URL.Query().Get("order")
-	nameQuery := r.URL.Query().Get("name")
-	countryOfBirthQuery := r.URL.Query().Get("country")
+// Batch - POST /actors:batch, массив элементов: без id запись создается, с id обновляется.
+// Пакет выполняется целиком или не выполняется вовсе, ответ перечисляет результат каждого элемента.
+func (h ActorsHandler) Batch(w http.ResponseWriter, r *http.Request) {
+	var items []domain.ActorBatchItem
+	err := decodeJSON(r, &items)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
 
-	filteredActors, err := h.Service.List(sortBy, orderBy, nameQuery, countryOfBirthQuery)
+	results, err := h.Service.Batch(r.Context(), items)
 	if err != nil {
-		http.Error(w, "failed to get actors", http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
-	data, err := json.Marshal(filteredActors)
+
+	writeJSON(w, r, http.StatusOK, batchResponse[domain.Actor]{Items: results})
+}
+
+func (h ActorsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := getID(r)
 	if err != nil {
-		log.Println(err)
-		http.Error(w, "failed to create response data", http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
-	// если не передать content-type, то клиент воспримет контент как text/plain, а не json
-	w.Header().Add("Content-Type", "application/json")
-	_, err = w.Write(data)
+	actor, err := h.Service.Get(r.Context(), id)
 	if err != nil {
-		log.Println(err)
+		writeError(w, r, err)
 		return
 	}
+
+	writeCached(w, r, actorValidators(actor), actor)
 }
 
-func (h ActorsHandler) Get(w http.ResponseWriter, r *http.Request) {
-	id, err := getID(w, r)
+func (h ActorsHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := getID(r)
 	if err != nil {
-		log.Println(err)
+		writeError(w, r, err)
 		return
 	}
 
-	actor, err := h.Service.Get(id)
+	version, err := ifMatch(r)
 	if err != nil {
-		switch {
-		case errors.Is(err, domain.ErrNotFound):
-			http.Error(w, "actor not found", http.StatusNotFound)
-		default:
-			http.Error(w, "unexpected error", http.StatusInternalServerError)
-		}
-
-		log.Println(err)
+		writeError(w, r, err)
 		return
 	}
 
-	data, err := json.Marshal(actor)
+	var actorUpdate domain.ActorUpdate
+	err = readJSON(r, &actorUpdate)
 	if err != nil {
-		http.Error(w, "failed to create response data", http.StatusInternalServerError)
+		writeError(w, r, err)
+		return
 	}
 
-	// если не передать content-type, то клиент воспримет контент как text/plain, а не json
-	w.Header().Add("Content-Type", "application/json")
-	_, err = w.Write(data)
+	updatedActor, err := h.Service.Update(r.Context(), id, version, actorUpdate)
 	if err != nil {
-		log.Println(err)
+		writeError(w, r, err)
 		return
 	}
+
+	setETag(w, updatedActor.Version)
+	writeJSON(w, r, http.StatusOK, updatedActor)
 }
 
-func (h ActorsHandler) Update(w http.ResponseWriter, r *http.Request) {
-	id, err := getID(w, r)
+func (h ActorsHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := getID(r)
 	if err != nil {
-		log.Println(err)
+		writeError(w, r, err)
 		return
 	}
 
-	// более короткая и удобная запись вместо io.ReadAll
-	var actorUpdate domain.ActorUpdate
-	err = json.NewDecoder(r.Body).Decode(&actorUpdate)
+	version, err := ifMatch(r)
 	if err != nil {
-		log.Println(err)
-		http.Error(w, "failed to unmarshall request body", http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
 
-	updatedActor, err := h.Service.Update(id, actorUpdate)
+	err = h.Service.Delete(r.Context(), id, version)
 	if err != nil {
-		switch {
-		case errors.Is(err, domain.ErrNotFound):
-			http.Error(w, "actor not found", http.StatusNotFound)
-		default:
-			http.Error(w, "unexpected error", http.StatusInternalServerError)
-		}
-		log.Println(err)
-
+		writeError(w, r, err)
 		return
 	}
 
-	data, err := json.Marshal(updatedActor)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Restore - POST /actors/{id}/restore, возвращает запись из корзины
+func (h ActorsHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	id, err := getID(r)
 	if err != nil {
-		http.Error(w, "failed to create response data", http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
-	// если не передать content-type, то клиент воспримет контент как text/plain, а не json
-	w.Header().Add("Content-Type", "application/json")
-	_, err = w.Write(data)
+	restored, err := h.Service.Restore(r.Context(), id)
 	if err != nil {
-		log.Println(err)
+		writeError(w, r, err)
 		return
 	}
+
+	setETag(w, restored.Version)
+	writeJSON(w, r, http.StatusOK, restored)
 }
 
-func (h ActorsHandler) Delete(w http.ResponseWriter, r *http.Request) {
-	id, err := getID(w, r)
+// History - GET /actors/{id}/history, журнал изменений от старых к новым
+func (h ActorsHandler) History(w http.ResponseWriter, r *http.Request) {
+	id, err := getID(r)
 	if err != nil {
-		log.Println(err)
+		writeError(w, r, err)
 		return
 	}
 
-	err = h.Service.Delete(id)
+	entries, err := h.Service.History(r.Context(), id)
 	if err != nil {
-		switch {
-		case errors.Is(err, domain.ErrNotFound):
-			http.Error(w, "actor not found", http.StatusNotFound)
-		default:
-			http.Error(w, "unexpected error", http.StatusInternalServerError)
-		}
-		log.Println(err)
-
+		writeError(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusAccepted)
+	writeJSON(w, r, http.StatusOK, entries)
 }
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"arch-demo/internal/domain"
+	"context"
+	"net/http"
+)
+
+type AdminService interface {
+	CreateUser(ctx context.Context, user domain.User) (domain.User, error)
+	GetUser(ctx context.Context, id int) (domain.User, error)
+	ListUsers(ctx context.Context) ([]domain.User, error)
+	UpdateUser(ctx context.Context, id int, update domain.UserUpdate) (domain.User, error)
+	DeleteUser(ctx context.Context, id int) error
+	CreateAPIKey(ctx context.Context, key domain.APIKey) (domain.APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]domain.APIKey, error)
+	DeleteAPIKey(ctx context.Context, id int) error
+}
+
+// AdminHandler - управление пользователями и их API-ключами
+type AdminHandler struct {
+	Service AdminService
+}
+
+func NewAdminHandler(service AdminService) AdminHandler {
+	return AdminHandler{
+		Service: service,
+	}
+}
+
+func (h AdminHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	var newUser domain.User
+	err := decodeJSON(r, &newUser)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	createdUser, err := h.Service.CreateUser(r.Context(), newUser)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, createdUser)
+}
+
+func (h AdminHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := h.Service.ListUsers(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, users)
+}
+
+func (h AdminHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	id, err := getID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	user, err := h.Service.GetUser(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, user)
+}
+
+// UpdateUser меняет роль или блокирует пользователя, это сразу действует на его ключи и токены
+func (h AdminHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	id, err := getID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var userUpdate domain.UserUpdate
+	err = decodeJSON(r, &userUpdate)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	updatedUser, err := h.Service.UpdateUser(r.Context(), id, userUpdate)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, updatedUser)
+}
+
+func (h AdminHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	id, err := getID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	err = h.Service.DeleteUser(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateKey - POST /admin/keys, секрет ключа есть только в этом ответе
+func (h AdminHandler) CreateKey(w http.ResponseWriter, r *http.Request) {
+	var newKey domain.APIKey
+	err := decodeJSON(r, &newKey)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	createdKey, err := h.Service.CreateAPIKey(r.Context(), newKey)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, createdKey)
+}
+
+func (h AdminHandler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.Service.ListAPIKeys(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, keys)
+}
+
+func (h AdminHandler) DeleteKey(w http.ResponseWriter, r *http.Request) {
+	id, err := getID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	err = h.Service.DeleteAPIKey(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
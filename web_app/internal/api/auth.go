@@ -0,0 +1,95 @@
+package api
+
+import (
+	"arch-demo/internal/domain"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// APIKeyHeader - заголовок с API-ключом, JWT передается в Authorization: Bearer
+const APIKeyHeader = "X-API-Key"
+
+type Authenticator interface {
+	AuthenticateKey(ctx context.Context, secret string) (domain.Principal, error)
+	AuthenticateToken(ctx context.Context, token string) (domain.Principal, error)
+}
+
+// Authenticate проверяет API-ключ или JWT и кладет вызывающего в контекст.
+// Запрос без учетных данных проходит дальше анонимным, отказать ему - дело Require;
+// неверные учетные данные отклоняются сразу, даже на маршрутах без проверки роли.
+func Authenticate(auth Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok, err := authenticate(r, auth)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+			if ok {
+				r = r.WithContext(domain.WithPrincipal(r.Context(), principal))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func authenticate(r *http.Request, auth Authenticator) (domain.Principal, bool, error) {
+	key := r.Header.Get(APIKeyHeader)
+	authorization := r.Header.Get("Authorization")
+
+	switch {
+	case key != "" && authorization != "":
+		return domain.Principal{}, false, fmt.Errorf("%w: pass either %s or Authorization, not both",
+			domain.ErrUnauthenticated, APIKeyHeader)
+	case key != "":
+		principal, err := auth.AuthenticateKey(r.Context(), key)
+		return principal, err == nil, err
+	case authorization != "":
+		scheme, token, _ := strings.Cut(authorization, " ")
+		if !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+			return domain.Principal{}, false, fmt.Errorf("%w: expected Authorization: Bearer <token>", domain.ErrUnauthenticated)
+		}
+		principal, err := auth.AuthenticateToken(r.Context(), strings.TrimSpace(token))
+		return principal, err == nil, err
+	}
+
+	return domain.Principal{}, false, nil
+}
+
+// Access строит проверки ролей для маршрутов. С выключенной аутентификацией
+// проверки пропускают все запросы, как до ее появления.
+type Access struct {
+	enabled bool
+}
+
+func NewAccess(enabled bool) Access {
+	return Access{enabled: enabled}
+}
+
+// Require пропускает запрос, только если роль вызывающего не ниже role:
+// анонимный получает 401, вызывающий с недостаточной ролью - 403
+func (a Access) Require(role domain.UserRole) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !a.enabled {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := domain.PrincipalFrom(r.Context())
+			if !ok {
+				writeError(w, r, domain.ErrUnauthenticated)
+				return
+			}
+			if !principal.Role.Allows(role) {
+				writeError(w, r, fmt.Errorf("%w: %s role required, %q has %s", domain.ErrForbidden, role,
+					principal.Subject, principal.Role))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"arch-demo/internal/domain"
+	"net/http"
+)
+
+func (h MoviesHandler) GetActors(w http.ResponseWriter, r *http.Request) {
+	id, err := getID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// фильм читается до состава: если состав поменяется между чтениями, ETag получится
+	// со старой версией фильма и уже не совпадет со следующим, а не наоборот
+	movie, err := h.Service.Get(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	cast, err := h.Service.GetCast(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// замена состава меняет версию фильма, изменения самих актеров - их версии
+	revisions := []revision{movieRevision(movie)}
+	for _, member := range cast {
+		revisions = append(revisions, actorRevision(member.Actor))
+	}
+
+	writeCached(w, r, collectionValidators(revisions), cast)
+}
+
+// CreateActorsForMovie принимает массив id актеров и заменяет ими состав фильма
+func (h MoviesHandler) CreateActorsForMovie(w http.ResponseWriter, r *http.Request) {
+	id, err := getID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var actorsForMovie []int
+	err = decodeJSON(r, &actorsForMovie)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	_, actorsIDs, err := h.Service.CreateActorsForMovie(r.Context(), id, actorsForMovie)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, actorsIDs)
+}
+
+// ReplaceCast - PUT /movies/{id}/actors, состав заменяется переданным списком целиком
+func (h MoviesHandler) ReplaceCast(w http.ResponseWriter, r *http.Request) {
+	id, err := getID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var cast []domain.CastEntry
+	err = decodeJSON(r, &cast)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	updatedCast, err := h.Service.ReplaceCast(r.Context(), id, cast)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, updatedCast)
+}
+
+// UpdateCast - PATCH /movies/{id}/actors, переданные актеры добавляются или обновляются,
+// остальные не меняются
+func (h MoviesHandler) UpdateCast(w http.ResponseWriter, r *http.Request) {
+	id, err := getID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var entries []domain.CastEntry
+	err = readJSON(r, &entries)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	updatedCast, err := h.Service.UpdateCast(r.Context(), id, entries)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, updatedCast)
+}
+
+// ClearCast - DELETE /movies/{id}/actors, из фильма убираются все актеры
+func (h MoviesHandler) ClearCast(w http.ResponseWriter, r *http.Request) {
+	id, err := getID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	_, err = h.Service.ReplaceCast(r.Context(), id, nil)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetCastMember - PUT /movies/{id}/actors/{actor_id}, актер добавляется в состав
+// или его запись заменяется целиком
+func (h MoviesHandler) SetCastMember(w http.ResponseWriter, r *http.Request) {
+	id, actorID, err := castIDs(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var entry domain.CastEntry
+	err = decodeJSON(r, &entry)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	// id актера берется из пути, в теле его можно не передавать
+	entry.ActorID = actorID
+
+	member, err := h.Service.SetCastMember(r.Context(), id, entry)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, member)
+}
+
+// UpdateCastMember - PATCH /movies/{id}/actors/{actor_id}, частичное обновление роли
+func (h MoviesHandler) UpdateCastMember(w http.ResponseWriter, r *http.Request) {
+	id, actorID, err := castIDs(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var update domain.CastEntryUpdate
+	err = readJSON(r, &update)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	member, err := h.Service.UpdateCastMember(r.Context(), id, actorID, update)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, member)
+}
+
+// RemoveCastMember - DELETE /movies/{id}/actors/{actor_id}, актер убирается из фильма
+func (h MoviesHandler) RemoveCastMember(w http.ResponseWriter, r *http.Request) {
+	id, actorID, err := castIDs(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	err = h.Service.RemoveCastMember(r.Context(), id, actorID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func castIDs(r *http.Request) (int, int, error) {
+	id, err := getID(r)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	actorID, err := urlParamID(r, "actor_id")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return id, actorID, nil
+}
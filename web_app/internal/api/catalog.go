@@ -0,0 +1,149 @@
+package api
+
+import (
+	"arch-demo/internal/domain"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type CatalogService interface {
+	Export(ctx context.Context, format domain.CatalogFormat, w io.Writer) error
+	Import(ctx context.Context, format domain.CatalogFormat, r io.Reader, dryRun bool) (domain.ImportReport, error)
+}
+
+type CatalogHandler struct {
+	Service CatalogService
+}
+
+func NewCatalogHandler(service CatalogService) CatalogHandler {
+	return CatalogHandler{
+		Service: service,
+	}
+}
+
+// типы содержимого форматов каталога; для NDJSON общепринятого типа нет, принимаем оба ходовых
+var catalogMediaTypes = map[string]domain.CatalogFormat{
+	"application/x-ndjson": domain.FormatNDJSON,
+	"application/jsonl":    domain.FormatNDJSON,
+	"text/csv":             domain.FormatCSV,
+}
+
+var catalogContentTypes = map[domain.CatalogFormat]string{
+	domain.FormatNDJSON: "application/x-ndjson",
+	domain.FormatCSV:    "text/csv; charset=utf-8",
+}
+
+// Export - GET /export, формат задается параметром format или заголовком Accept, по умолчанию NDJSON.
+// Ответ пишется по мере чтения каталога, поэтому ошибку после начала ответа вернуть уже нельзя:
+// соединение обрывается, чтобы клиент не принял обрезанную выгрузку за полную.
+func (h CatalogHandler) Export(w http.ResponseWriter, r *http.Request) {
+	format, err := exportFormat(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", catalogContentTypes[format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="catalog.%s"`, format))
+
+	out := &trackingWriter{w: w}
+	err = h.Service.Export(r.Context(), format, out)
+	if err == nil {
+		return
+	}
+	if !out.written {
+		w.Header().Del("Content-Disposition")
+		writeError(w, r, err)
+		return
+	}
+
+	log.Printf("export aborted: %v", err)
+	panic(http.ErrAbortHandler)
+}
+
+func exportFormat(r *http.Request) (domain.CatalogFormat, error) {
+	if format, ok, err := queryFormat(r); ok {
+		return format, err
+	}
+
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if format, ok := catalogMediaTypes[mediaType]; err == nil && ok {
+			return format, nil
+		}
+	}
+
+	return domain.FormatNDJSON, nil
+}
+
+// Import - POST /import, формат берется из Content-Type или параметра format.
+// С dry_run=true записи только проверяются. Отчет возвращается со статусом 200,
+// даже если часть строк не загрузилась: ошибки по строкам - в его поле errors.
+func (h CatalogHandler) Import(w http.ResponseWriter, r *http.Request) {
+	format, err := importFormat(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	dryRun := false
+	if value := r.URL.Query().Get("dry_run"); value != "" {
+		dryRun, err = strconv.ParseBool(value)
+		if err != nil {
+			writeError(w, r, fmt.Errorf("%w: dry_run must be true or false", errInvalidQuery))
+			return
+		}
+	}
+
+	report, err := h.Service.Import(r.Context(), format, r.Body, dryRun)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, report)
+}
+
+func importFormat(r *http.Request) (domain.CatalogFormat, error) {
+	if format, ok, err := queryFormat(r); ok {
+		return format, err
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	format, ok := catalogMediaTypes[mediaType]
+	if err != nil || !ok {
+		return "", fmt.Errorf("%w: expected application/x-ndjson or text/csv", errUnsupportedMediaType)
+	}
+
+	return format, nil
+}
+
+// queryFormat - явно заданный параметр format важнее заголовков
+func queryFormat(r *http.Request) (domain.CatalogFormat, bool, error) {
+	format := domain.CatalogFormat(r.URL.Query().Get("format"))
+	switch format {
+	case "":
+		return "", false, nil
+	case domain.FormatNDJSON, domain.FormatCSV:
+		return format, true, nil
+	}
+
+	return "", true, fmt.Errorf("%w %q, expected ndjson or csv", domain.ErrUnknownFormat, format)
+}
+
+// trackingWriter запоминает, начался ли ответ, чтобы решить, можно ли еще ответить ошибкой
+type trackingWriter struct {
+	w       io.Writer
+	written bool
+}
+
+func (t *trackingWriter) Write(p []byte) (int, error) {
+	t.written = true
+	return t.w.Write(p)
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"arch-demo/internal/domain"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// cacheControl разрешает клиенту хранить ответ, но требует проверять его при каждом
+// использовании: с ETag и Last-Modified проверка обходится ответом 304 без тела
+const cacheControl = "private, no-cache"
+
+// validators - то, по чему клиент проверяет, не устарела ли его копия ответа.
+// Нулевой lastModified - заголовка Last-Modified нет, If-Modified-Since не проверяется.
+type validators struct {
+	etag         string
+	lastModified time.Time
+}
+
+func actorValidators(actor domain.Actor) validators {
+	return validators{etag: etag(actor.Version), lastModified: actor.UpdatedAt}
+}
+
+func movieValidators(movie domain.Movie) validators {
+	return validators{etag: etag(movie.Version), lastModified: movie.UpdatedAt}
+}
+
+// revision - id и версия записи, входящей в ответ
+type revision struct {
+	id      int
+	version int
+}
+
+// collectionValidators строит слабый ETag из версий всех записей ответа и extra.
+// Last-Modified у коллекций нет: удаление записи или ее уход из выборки не меняет
+// время изменения оставшихся, и If-Modified-Since вернул бы устаревшую копию.
+func collectionValidators(revisions []revision, extra ...any) validators {
+	h := fnv.New64a()
+	for _, part := range extra {
+		_, _ = fmt.Fprintf(h, "%v;", part)
+	}
+	for _, r := range revisions {
+		_, _ = fmt.Fprintf(h, "%d:%d;", r.id, r.version)
+	}
+
+	return validators{etag: fmt.Sprintf(`W/"%x"`, h.Sum64())}
+}
+
+func pageValidators[T any](page domain.Page[T], revisionOf func(T) revision) validators {
+	revisions := make([]revision, 0, len(page.Items))
+	for _, item := range page.Items {
+		revisions = append(revisions, revisionOf(item))
+	}
+
+	return collectionValidators(revisions, page.Total, page.NextCursor)
+}
+
+func actorRevision(actor domain.Actor) revision {
+	return revision{id: actor.ID, version: actor.Version}
+}
+
+func movieRevision(movie domain.Movie) revision {
+	return revision{id: movie.ID, version: movie.Version}
+}
+
+// writeCached отвечает на GET с заголовками кэширования или 304, если копия клиента
+// актуальна. По RFC 9110 If-None-Match важнее If-Modified-Since: если он передан,
+// дата не проверяется.
+func writeCached(w http.ResponseWriter, r *http.Request, v validators, body any) {
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("ETag", v.etag)
+	if !v.lastModified.IsZero() {
+		w.Header().Set("Last-Modified", v.lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(r, v) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, body)
+}
+
+func notModified(r *http.Request, v validators) bool {
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		return etagListMatches(header, v.etag)
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || v.lastModified.IsZero() {
+		return false
+	}
+
+	// в Last-Modified время с точностью до секунды
+	return !v.lastModified.Truncate(time.Second).After(since)
+}
+
+// etagListMatches сравнивает теги слабо, как требует If-None-Match: W/"1" совпадает с "1"
+func etagListMatches(header, tag string) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+
+	tag = strings.TrimPrefix(tag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == tag {
+			return true
+		}
+	}
+
+	return false
+}
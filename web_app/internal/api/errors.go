@@ -0,0 +1,169 @@
+package api
+
+import (
+	"arch-demo/internal/domain"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5/middleware"
+	"log"
+	"net/http"
+)
+
+var (
+	errUnsupportedMediaType = errors.New("content type not allowed")
+	errMalformedBody        = errors.New("failed to read request body")
+	errInvalidID            = errors.New("failed to parse id")
+	errRouteNotFound        = errors.New("route not found")
+	errMethodNotAllowed     = errors.New("method not allowed")
+	errInvalidQuery         = errors.New("invalid query parameter")
+	errInvalidRequest       = errors.New("request does not match the API specification")
+)
+
+// problemType описывает, как ошибка выглядит для клиента. Code - стабильный
+// машиночитаемый идентификатор, на него клиенты могут опираться вместо текста.
+type problemType struct {
+	err    error
+	status int
+	code   string
+	title  string
+}
+
+// problemTypes - единственное место, где ошибки сопоставляются с http-статусами.
+// Порядок важен: берется первая ошибка, для которой сработал errors.Is.
+var problemTypes = []problemType{
+	{domain.ErrUnauthenticated, http.StatusUnauthorized, "unauthenticated", "Authentication required"},
+	{domain.ErrForbidden, http.StatusForbidden, "forbidden", "Permission denied"},
+	{domain.ErrFieldsRequired, http.StatusUnprocessableEntity, "fields_required", "Required fields are missing"},
+	{domain.ErrValidation, http.StatusUnprocessableEntity, "validation_failed", "Invalid field values"},
+	{domain.ErrReferenced, http.StatusConflict, "referenced", "Resource is still referenced"},
+	{domain.ErrVersionMismatch, http.StatusPreconditionFailed, "precondition_failed", "Resource has been modified"},
+	{domain.ErrExists, http.StatusConflict, "already_exists", "Resource already exists"},
+	{domain.ErrNotFound, http.StatusNotFound, "not_found", "Resource not found"},
+	{domain.ErrNotExists, http.StatusNotFound, "not_exists", "Referenced resource does not exist"},
+	{domain.ErrIDRequired, http.StatusBadRequest, "id_required", "ID is required"},
+	{domain.ErrInvalidPage, http.StatusBadRequest, "invalid_pagination", "Invalid pagination parameters"},
+	{domain.ErrInvalidCursor, http.StatusBadRequest, "invalid_cursor", "Invalid cursor"},
+	{domain.ErrUnknownFormat, http.StatusBadRequest, "unknown_format", "Unknown catalog format"},
+	{domain.ErrInvalidImport, http.StatusBadRequest, "invalid_import", "Import file can't be read"},
+	{domain.ErrInvalidBatch, http.StatusBadRequest, "invalid_batch", "Invalid batch"},
+	{domain.ErrInvalidSearch, http.StatusBadRequest, "invalid_search", "Invalid search query"},
+	{domain.ErrInvalidFilter, http.StatusBadRequest, "invalid_filter", "Invalid filter"},
+	{domain.ErrInvalidSort, http.StatusBadRequest, "invalid_sort", "Invalid sort"},
+	{errInvalidQuery, http.StatusBadRequest, "invalid_query", "Invalid query parameter"},
+	{errInvalidRequest, http.StatusBadRequest, "invalid_request", "Request does not match the API specification"},
+	{errInvalidID, http.StatusBadRequest, "invalid_id", "Invalid ID"},
+	{errMalformedBody, http.StatusBadRequest, "malformed_body", "Malformed request body"},
+	{errInvalidPrecondition, http.StatusBadRequest, "invalid_precondition", "Invalid precondition header"},
+	{errUnsupportedMediaType, http.StatusUnsupportedMediaType, "unsupported_media_type", "Unsupported media type"},
+	{errRouteNotFound, http.StatusNotFound, "route_not_found", "Route not found"},
+	{errMethodNotAllowed, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed"},
+	{context.DeadlineExceeded, http.StatusGatewayTimeout, "timeout", "Request timed out"},
+}
+
+var internalProblem = problemType{
+	status: http.StatusInternalServerError,
+	code:   "internal",
+	title:  "Unexpected error",
+}
+
+// problem - тело ответа об ошибке в формате RFC 7807 (application/problem+json)
+type problem struct {
+	Type      string              `json:"type"`
+	Title     string              `json:"title"`
+	Status    int                 `json:"status"`
+	Code      string              `json:"code"`
+	Detail    string              `json:"detail,omitempty"`
+	Instance  string              `json:"instance,omitempty"`
+	RequestID string              `json:"request_id,omitempty"`
+	Errors    []domain.FieldError `json:"errors,omitempty"`
+	// ReferencedBy - фильмы, из-за которых актера нельзя удалить
+	ReferencedBy []domain.Movie `json:"referenced_by,omitempty"`
+	// Items - что стало с каждым элементом отмененного пакета
+	Items []domain.BatchItemStatus `json:"items,omitempty"`
+}
+
+func problemFor(err error) problemType {
+	for _, p := range problemTypes {
+		if errors.Is(err, p.err) {
+			return p
+		}
+	}
+
+	return internalProblem
+}
+
+// writeError отвечает клиенту ошибкой в формате problem+json и пишет её в лог
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	log.Println(err)
+
+	if errors.Is(err, context.Canceled) {
+		// клиент уже ушел, отвечать некому
+		return
+	}
+
+	p := problemFor(err)
+	body := problem{
+		Type:      "urn:problem-type:" + p.code,
+		Title:     p.title,
+		Status:    p.status,
+		Code:      p.code,
+		Instance:  r.URL.Path,
+		RequestID: middleware.GetReqID(r.Context()),
+	}
+
+	// текст внутренних ошибок клиенту не показываем
+	if p.status < http.StatusInternalServerError || p.status == http.StatusGatewayTimeout {
+		body.Detail = err.Error()
+	}
+
+	var validationErr *domain.ValidationError
+	if errors.As(err, &validationErr) {
+		body.Errors = validationErr.Fields
+	}
+
+	var referencedErr *domain.ReferencedError
+	if errors.As(err, &referencedErr) {
+		body.ReferencedBy = referencedErr.Movies
+	}
+
+	// поля ошибки проверки уже есть у элемента пакета, на верхнем уровне они бы повторились
+	var batchErr *domain.BatchError
+	if errors.As(err, &batchErr) {
+		body.Items = batchErr.Items
+		body.Errors = nil
+		if body.Detail == "" {
+			body.Items = make([]domain.BatchItemStatus, len(batchErr.Items))
+			for i, item := range batchErr.Items {
+				item.Error = ""
+				body.Items[i] = item
+			}
+		}
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, p.title, p.status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	if p.status == http.StatusUnauthorized {
+		// RFC 9110 требует указать в ответе 401, как аутентифицироваться
+		w.Header().Set("WWW-Authenticate", `Bearer realm="arch-demo"`)
+	}
+	w.WriteHeader(p.status)
+	_, err = w.Write(data)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, errRouteNotFound)
+}
+
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, errMethodNotAllowed)
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"arch-demo/internal/domain"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var errInvalidPrecondition = errors.New("invalid If-Match header")
+
+// etag - сильный тег сущности из версии записи, например "3"
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+func setETag(w http.ResponseWriter, version int) {
+	w.Header().Set("ETag", etag(version))
+}
+
+// ifMatch возвращает версию из заголовка If-Match. 0 - заголовка нет или в нем "*",
+// то есть подходит любая версия существующей записи. If-Match сравнивает теги
+// строго, поэтому слабый тег или чужой тег не совпадет ни с одной версией.
+func ifMatch(r *http.Request) (int, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	if strings.Contains(header, ",") {
+		return 0, fmt.Errorf("%w: only one entity tag is supported, got %q", errInvalidPrecondition, header)
+	}
+
+	if strings.HasPrefix(header, "W/") {
+		return 0, fmt.Errorf("weak entity tag %s never matches, err: %w", header, domain.ErrVersionMismatch)
+	}
+
+	if len(header) < 2 || header[0] != '"' || header[len(header)-1] != '"' {
+		return 0, fmt.Errorf("%w: entity tag must be quoted, got %q", errInvalidPrecondition, header)
+	}
+
+	version, err := strconv.Atoi(header[1 : len(header)-1])
+	if err != nil || version <= 0 {
+		return 0, fmt.Errorf("entity tag %s matches no version, err: %w", header, domain.ErrVersionMismatch)
+	}
+
+	return version, nil
+}
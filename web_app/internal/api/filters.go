@@ -0,0 +1,148 @@
+package api
+
+import (
+	"arch-demo/internal/domain"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Фильтры списков. Каждый параметр - отдельное условие, все условия должны выполняться
+// одновременно. Повторенный параметр (?genre=drama&genre=comedy) подходит под любое
+// из своих значений. У текстовых полей name - подстрока, name_eq - точное совпадение,
+// name_prefix - начало; у чисел и дат суффиксы _gt, _gte, _lt, _lte задают границы.
+
+// pageAndSortParams - параметры страницы и сортировки, допустимые рядом с фильтрами
+var pageAndSortParams = []string{"limit", "offset", "cursor", "sort"}
+
+var actorFilterParams = filterNames(
+	[]string{"name", "name_eq", "name_prefix", "country", "gender"},
+	rangeParams("birth_year"),
+)
+
+var movieFilterParams = filterNames(
+	[]string{"name", "name_eq", "name_prefix", "genre", "genre_prefix", "country"},
+	rangeParams("release_date"),
+	rangeParams("rating"),
+)
+
+func actorsFilter(values url.Values, query *domain.ActorsQuery) error {
+	if err := checkParams(values, actorFilterParams); err != nil {
+		return err
+	}
+
+	query.Name = textFilter(values, "name")
+	query.Country = domain.TextFilter{Eq: nonEmpty(values["country"])}
+	query.Gender = domain.TextFilter{Eq: nonEmpty(values["gender"])}
+
+	var err error
+	query.BirthYear, err = parseRange(values, "birth_year", strconv.Atoi)
+	return err
+}
+
+func moviesFilter(values url.Values, query *domain.MoviesQuery) error {
+	if err := checkParams(values, movieFilterParams); err != nil {
+		return err
+	}
+
+	query.Name = textFilter(values, "name")
+	query.Genre = domain.TextFilter{Eq: nonEmpty(values["genre"]), Prefix: nonEmpty(values["genre_prefix"])}
+	query.Country = domain.TextFilter{Eq: nonEmpty(values["country"])}
+
+	var err error
+	if query.ReleaseDate, err = parseRange(values, "release_date", parseDate); err != nil {
+		return err
+	}
+	query.Rating, err = parseRange(values, "rating", strconv.Atoi)
+	return err
+}
+
+// textFilter - условия name, name_eq и name_prefix для поля name
+func textFilter(values url.Values, field string) domain.TextFilter {
+	return domain.TextFilter{
+		Contains: nonEmpty(values[field]),
+		Eq:       nonEmpty(values[field+"_eq"]),
+		Prefix:   nonEmpty(values[field+"_prefix"]),
+	}
+}
+
+// nonEmpty отбрасывает пустые значения: ?name= значит то же, что и отсутствие параметра
+func nonEmpty(values []string) []string {
+	return slices.DeleteFunc(slices.Clone(values), func(v string) bool { return v == "" })
+}
+
+func rangeParams(field string) []string {
+	return []string{field + "_gt", field + "_gte", field + "_lt", field + "_lte"}
+}
+
+func filterNames(groups ...[]string) []string {
+	var names []string
+	for _, group := range groups {
+		names = append(names, group...)
+	}
+
+	return names
+}
+
+// parseRange разбирает границы field_gt, field_gte, field_lt и field_lte.
+// Граница задается одним значением, повторять ее бессмысленно.
+func parseRange[T any](values url.Values, field string, parse func(string) (T, error)) (domain.Range[T], error) {
+	var r domain.Range[T]
+	bounds := []struct {
+		param string
+		bound **T
+	}{{field + "_gt", &r.Gt}, {field + "_gte", &r.Gte}, {field + "_lt", &r.Lt}, {field + "_lte", &r.Lte}}
+
+	for _, b := range bounds {
+		raw := nonEmpty(values[b.param])
+		if len(raw) == 0 {
+			continue
+		}
+		if len(raw) > 1 {
+			return r, fmt.Errorf("%w: %s must be given once", domain.ErrInvalidFilter, b.param)
+		}
+
+		value, err := parse(raw[0])
+		if err != nil {
+			return r, fmt.Errorf("%w: %s: invalid value %q", domain.ErrInvalidFilter, b.param, raw[0])
+		}
+		*b.bound = &value
+	}
+
+	return r, nil
+}
+
+// parseDate принимает дату в RFC 3339 или просто YYYY-MM-DD
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+// checkParams отклоняет неизвестные параметры: опечатка в имени фильтра
+// иначе молча вернула бы весь список
+func checkParams(values url.Values, filters []string) error {
+	if values.Has("order") {
+		// раньше направление и поле сортировки передавались в sort и order, теперь - только в sort
+		return fmt.Errorf("%w: order is not supported, use sort=-field for descending order", domain.ErrInvalidSort)
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if !slices.Contains(filters, name) && !slices.Contains(pageAndSortParams, name) {
+			allowed := filterNames(filters, pageAndSortParams)
+			return fmt.Errorf("%w: unknown parameter %q, allowed: %s", domain.ErrInvalidFilter, name, strings.Join(allowed, ", "))
+		}
+	}
+
+	return nil
+}
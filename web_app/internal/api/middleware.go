@@ -0,0 +1,49 @@
+package api
+
+import (
+	"arch-demo/internal/domain"
+	"context"
+	"github.com/go-chi/chi/v5/middleware"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RequestID берет X-Request-Id из запроса или генерирует новый и возвращает его
+// в заголовке ответа, тот же id попадает в тело ошибок
+func RequestID(next http.Handler) http.Handler {
+	return middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(middleware.RequestIDHeader, middleware.GetReqID(r.Context()))
+		next.ServeHTTP(w, r)
+	}))
+}
+
+// Timeout ограничивает время жизни контекста запроса. В отличие от
+// middleware.Timeout из chi ничего не пишет в ответ сам: обработчик получает
+// context.DeadlineExceeded от хранилища и отвечает 504 через writeError.
+func Timeout(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// CallerHeader - заголовок, в котором клиент передает, от чьего имени он работает
+const CallerHeader = "X-Caller"
+
+// Caller кладет в контекст имя из заголовка, его записывает журнал изменений. Заголовок
+// ничем не подтверждается, поэтому имя помечается как неподтвержденное, а аутентифицированным
+// вызывающий от него не становится. Без заголовка запрос считается анонимным.
+func Caller(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if caller := strings.TrimSpace(r.Header.Get(CallerHeader)); caller != "" {
+			r = r.WithContext(domain.WithCaller(r.Context(), domain.UnverifiedCaller(caller)))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -2,22 +2,30 @@ package api
 
 import (
 	"arch-demo/internal/domain"
-	"encoding/json"
-	"errors"
+	"context"
+	"fmt"
 	"github.com/go-chi/chi/v5"
-	"log"
 	"net/http"
 	"strconv"
 )
 
 type MoviesService interface {
-	Create(actor domain.Movie) (domain.Movie, error)
-	Get(id int) (domain.Movie, error)
-	Delete(id int) error
-	Update(id int, actorUpdate domain.MovieUpdate) (domain.Movie, error)
-	List(orderBy, sortBy, nameQuery, genreQuery string) []domain.Movie
-	GetActorsByMovie(id int) ([]domain.Actor, error)
-	CreateActorsForMovie(id int, actorsByMovie []int) (int, []int, error)
+	Create(ctx context.Context, actor domain.Movie) (domain.Movie, error)
+	Get(ctx context.Context, id int) (domain.Movie, error)
+	Delete(ctx context.Context, id, version int) error
+	Restore(ctx context.Context, id int) (domain.Movie, error)
+	History(ctx context.Context, id int) ([]domain.AuditEntry, error)
+	Update(ctx context.Context, id, version int, actorUpdate domain.MovieUpdate) (domain.Movie, error)
+	List(ctx context.Context, query domain.MoviesQuery) (domain.Page[domain.Movie], error)
+	Batch(ctx context.Context, items []domain.MovieBatchItem) ([]domain.BatchResult[domain.Movie], error)
+	ListByActor(ctx context.Context, actorID int, query domain.MoviesQuery) (domain.Page[domain.Movie], error)
+	GetCast(ctx context.Context, movieID int) ([]domain.CastMember, error)
+	CreateActorsForMovie(ctx context.Context, id int, actorsByMovie []int) (int, []int, error)
+	ReplaceCast(ctx context.Context, movieID int, cast []domain.CastEntry) ([]domain.CastMember, error)
+	UpdateCast(ctx context.Context, movieID int, entries []domain.CastEntry) ([]domain.CastMember, error)
+	SetCastMember(ctx context.Context, movieID int, entry domain.CastEntry) (domain.CastMember, error)
+	UpdateCastMember(ctx context.Context, movieID, actorID int, update domain.CastEntryUpdate) (domain.CastMember, error)
+	RemoveCastMember(ctx context.Context, movieID, actorID int) error
 }
 
 type MoviesHandler struct {
@@ -31,278 +39,217 @@ func NewLaptopsHandler(service MoviesService) MoviesHandler {
 }
 
 func (h MoviesHandler) List(w http.ResponseWriter, r *http.Request) {
-	SortBy := r.URL.Query().Get("sort")
-	OrderBy := r.URL.Query().Get("order")
-	NameQuery := r.URL.Query().Get("name")
-	GenreQuery := r.URL.Query().Get("genre")
-
-	filteredMovies := h.Service.List(SortBy, OrderBy, NameQuery, GenreQuery)
-	data, err := json.Marshal(filteredMovies)
+	query, err := moviesQuery(r)
 	if err != nil {
-		log.Println(err)
-		http.Error(w, "failed to create response data", http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
-	// если не передать content-type, то клиент воспримет контент как text/plain, а не json
-	w.Header().Add("Content-Type", "application/json")
-	_, err = w.Write(data)
+	page, err := h.Service.List(r.Context(), query)
 	if err != nil {
-		log.Println(err)
+		writeError(w, r, err)
 		return
 	}
-}
 
-func (h MoviesHandler) Create(w http.ResponseWriter, r *http.Request) {
-	if r.Header.Get("Content-Type") != "application/json" {
-		http.Error(w, "content type not allowed", http.StatusUnsupportedMediaType)
-		return
-	}
+	writeCached(w, r, pageValidators(page, movieRevision), page)
+}
 
-	var newMovie domain.Movie
-	err := json.NewDecoder(r.Body).Decode(&newMovie)
+// ListByActor - фильмография актера, параметры те же, что у List
+func (h MoviesHandler) ListByActor(w http.ResponseWriter, r *http.Request) {
+	actorID, err := getID(r)
 	if err != nil {
-		http.Error(w, "failed to unmarshall data", http.StatusBadRequest)
-		log.Println(err)
+		writeError(w, r, err)
 		return
 	}
 
-	createdMovie, err := h.Service.Create(newMovie)
+	query, err := moviesQuery(r)
 	if err != nil {
-		switch {
-		case errors.Is(err, domain.ErrFieldsRequired):
-			http.Error(w, "all required fields must have values", http.StatusUnprocessableEntity)
-		case errors.Is(err, domain.ErrExists):
-			http.Error(w, "movie already exists", http.StatusConflict)
-		default:
-			http.Error(w, "unexpected error", http.StatusInternalServerError)
-		}
-		log.Println(err)
-
+		writeError(w, r, err)
 		return
 	}
 
-	data, err := json.Marshal(createdMovie)
+	page, err := h.Service.ListByActor(r.Context(), actorID, query)
 	if err != nil {
-		http.Error(w, "failed to create response data", http.StatusInternalServerError)
-		log.Println(err)
+		writeError(w, r, err)
 		return
 	}
 
-	w.Header().Add("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	_, err = w.Write(data)
-	if err != nil {
-		log.Println(err)
-		return
-	}
+	writeCached(w, r, pageValidators(page, movieRevision), page)
 }
 
-func (h MoviesHandler) Get(w http.ResponseWriter, r *http.Request) {
-	id, err := getID(w, r)
+func moviesQuery(r *http.Request) (domain.MoviesQuery, error) {
+	limit, offset, after, err := pageParams[domain.Movie](r)
 	if err != nil {
-		log.Println(err)
-		return
+		return domain.MoviesQuery{}, err
 	}
 
-	movie, err := h.Service.Get(id)
-	if err != nil {
-		switch {
-		case errors.Is(err, domain.ErrNotFound):
-			http.Error(w, "actor not found", http.StatusNotFound)
-		default:
-			http.Error(w, "unexpected error", http.StatusInternalServerError)
-		}
-
-		log.Println(err)
-		return
+	query := domain.MoviesQuery{
+		Limit:  limit,
+		Offset: offset,
+		After:  after,
 	}
-
-	data, err := json.Marshal(movie)
-	if err != nil {
-		http.Error(w, "failed to create response data", http.StatusInternalServerError)
+	if err = moviesFilter(r.URL.Query(), &query); err != nil {
+		return domain.MoviesQuery{}, err
 	}
+	query.Sort, err = domain.ParseSort(r.URL.Query().Get("sort"), domain.MovieSortFields)
 
-	// если не передать content-type, то клиент воспримет контент как text/plain, а не json
-	w.Header().Add("Content-Type", "application/json")
-	_, err = w.Write(data)
-	if err != nil {
-		log.Println(err)
-		return
-	}
+	return query, err
 }
 
-func (h MoviesHandler) Update(w http.ResponseWriter, r *http.Request) {
-	id, err := getID(w, r)
+func (h MoviesHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var newMovie domain.Movie
+	err := decodeJSON(r, &newMovie)
 	if err != nil {
-		log.Println(err)
+		writeError(w, r, err)
 		return
 	}
 
-	// более короткая и удобная запись вместо io.ReadAll
-	var movieUpdate domain.MovieUpdate
-	err = json.NewDecoder(r.Body).Decode(&movieUpdate)
+	createdMovie, err := h.Service.Create(r.Context(), newMovie)
 	if err != nil {
-		log.Println(err)
-		http.Error(w, "failed to unmarshall request body", http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
 
-	updatedMovie, err := h.Service.Update(id, movieUpdate)
-	if err != nil {
-		switch {
-		case errors.Is(err, domain.ErrNotFound):
-			http.Error(w, "movie not found", http.StatusNotFound)
-		default:
-			http.Error(w, "unexpected error", http.StatusInternalServerError)
-		}
-		log.Println(err)
-
-		return
-	}
+	setETag(w, createdMovie.Version)
+	writeJSON(w, r, http.StatusCreated, createdMovie)
+}
 
-	data, err := json.Marshal(updatedMovie)
+// Batch - POST /movies:batch, массив элементов: без id запись создается, с id обновляется.
+// Пакет выполняется целиком или не выполняется вовсе, ответ перечисляет результат каждого элемента.
+func (h MoviesHandler) Batch(w http.ResponseWriter, r *http.Request) {
+	var items []domain.MovieBatchItem
+	err := decodeJSON(r, &items)
 	if err != nil {
-		http.Error(w, "failed to create response data", http.StatusInternalServerError)
+		writeError(w, r, err)
+		return
 	}
 
-	// если не передать content-type, то клиент воспримет контент как text/plain, а не json
-	w.Header().Add("Content-Type", "application/json")
-	_, err = w.Write(data)
+	results, err := h.Service.Batch(r.Context(), items)
 	if err != nil {
-		log.Println(err)
+		writeError(w, r, err)
 		return
 	}
+
+	writeJSON(w, r, http.StatusOK, batchResponse[domain.Movie]{Items: results})
 }
 
-func (h MoviesHandler) Delete(w http.ResponseWriter, r *http.Request) {
-	id, err := getID(w, r)
+func (h MoviesHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := getID(r)
 	if err != nil {
-		log.Println(err)
+		writeError(w, r, err)
 		return
 	}
 
-	err = h.Service.Delete(id)
+	movie, err := h.Service.Get(r.Context(), id)
 	if err != nil {
-		switch {
-		case errors.Is(err, domain.ErrNotFound):
-			http.Error(w, "movie not found", http.StatusNotFound)
-		default:
-			http.Error(w, "unexpected error", http.StatusInternalServerError)
-		}
-		log.Println(err)
-
+		writeError(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusAccepted)
+	writeCached(w, r, movieValidators(movie), movie)
 }
 
-func (h MoviesHandler) GetActors(w http.ResponseWriter, r *http.Request) {
-	id, err := getID(w, r)
+func (h MoviesHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := getID(r)
 	if err != nil {
-		log.Println(err)
+		writeError(w, r, err)
 		return
 	}
 
-	actorsByMovie, err := h.Service.GetActorsByMovie(id)
+	version, err := ifMatch(r)
 	if err != nil {
-		switch {
-		case errors.Is(err, domain.ErrNotFound):
-			http.Error(w, "actors not found", http.StatusNotFound)
-		default:
-			http.Error(w, "unexpected error", http.StatusInternalServerError)
-		}
-
-		log.Println(err)
+		writeError(w, r, err)
 		return
 	}
 
-	data, err := json.Marshal(actorsByMovie)
+	var movieUpdate domain.MovieUpdate
+	err = readJSON(r, &movieUpdate)
 	if err != nil {
-		http.Error(w, "failed to create response data", http.StatusInternalServerError)
+		writeError(w, r, err)
+		return
 	}
 
-	// если не передать content-type, то клиент воспримет контент как text/plain, а не json
-	w.Header().Add("Content-Type", "application/json")
-	_, err = w.Write(data)
+	updatedMovie, err := h.Service.Update(r.Context(), id, version, movieUpdate)
 	if err != nil {
-		log.Println(err)
+		writeError(w, r, err)
 		return
 	}
+
+	setETag(w, updatedMovie.Version)
+	writeJSON(w, r, http.StatusOK, updatedMovie)
 }
 
-func (h MoviesHandler) CreateActorsForMovie(w http.ResponseWriter, r *http.Request) {
-	if r.Header.Get("Content-Type") != "application/json" {
-		http.Error(w, "content type not allowed", http.StatusUnsupportedMediaType)
+func (h MoviesHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := getID(r)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	idParam := chi.URLParam(r, "id")
-	if idParam == "" {
-		log.Println("id required")
-		http.Error(w, "id required", http.StatusBadRequest)
+	version, err := ifMatch(r)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	id, err := strconv.Atoi(idParam)
+	err = h.Service.Delete(r.Context(), id, version)
 	if err != nil {
-		log.Println(err)
-		http.Error(w, "failed to parse id query param", http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
 
-	var actorsForMovie []int
-	err = json.NewDecoder(r.Body).Decode(&actorsForMovie)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Restore - POST /movies/{id}/restore, возвращает запись из корзины
+func (h MoviesHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	id, err := getID(r)
 	if err != nil {
-		http.Error(w, "failed to unmarshall data", http.StatusBadRequest)
-		log.Println(err)
+		writeError(w, r, err)
 		return
 	}
 
-	var actorsIDs []int
-	_, actorsIDs, err = h.Service.CreateActorsForMovie(id, actorsForMovie)
+	restored, err := h.Service.Restore(r.Context(), id)
 	if err != nil {
-		switch {
-		case errors.Is(err, domain.ErrNotFound):
-			http.Error(w, "actors not found", http.StatusNotFound)
-		default:
-			http.Error(w, "unexpected error", http.StatusInternalServerError)
-		}
-		log.Println(err)
-
+		writeError(w, r, err)
 		return
 	}
 
-	data, err := json.Marshal(actorsIDs)
-	if err != nil {
-		http.Error(w, "failed to create response data", http.StatusInternalServerError)
-	}
+	setETag(w, restored.Version)
+	writeJSON(w, r, http.StatusOK, restored)
+}
 
-	// если не передать content-type, то клиент воспримет контент как text/plain, а не json
-	w.Header().Add("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	_, err = w.Write(data)
-	if err != nil {
-		log.Println(err)
-		return
-	}
+func getID(r *http.Request) (int, error) {
+	return urlParamID(r, "id")
 }
 
-func getID(w http.ResponseWriter, r *http.Request) (int, error) {
-	idParam := chi.URLParam(r, "id")
+func urlParamID(r *http.Request, name string) (int, error) {
+	idParam := chi.URLParam(r, name)
 	if idParam == "" {
-		log.Println("id required")
-		http.Error(w, "id required", http.StatusBadRequest)
 		return 0, domain.ErrIDRequired
 	}
 
 	id, err := strconv.Atoi(idParam)
 	if err != nil {
-		log.Println(err)
-		http.Error(w, "failed to parse id query param", http.StatusBadRequest)
-		return 0, err
+		return 0, fmt.Errorf("%w %q: %v", errInvalidID, idParam, err)
 	}
 
 	return id, nil
 }
+
+// History - GET /movies/{id}/history, журнал изменений от старых к новым
+func (h MoviesHandler) History(w http.ResponseWriter, r *http.Request) {
+	id, err := getID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	entries, err := h.Service.History(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, entries)
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"arch-demo/internal/api/openapi"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// OpenAPI - GET /openapi.json, спецификация API
+func OpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, err := w.Write(openapi.JSON())
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// swaggerUIPage загружает Swagger UI с CDN, сам сервис отдает только спецификацию
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>arch-demo API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});
+  </script>
+</body>
+</html>
+`
+
+// SwaggerUI - GET /docs, страница для чтения спецификации и пробных запросов
+func SwaggerUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, err := io.WriteString(w, swaggerUIPage)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// ValidateOpenAPI проверяет запросы и ответы по спецификации. Запрос, который ей
+// не соответствует, получает 400 invalid_request и до обработчика не доходит.
+// Ответ к этому моменту уже отправлен, поэтому несоответствие ответа только
+// передается в invalidResponse. Запросы к путям, которых нет в спецификации,
+// пропускаются как есть: им ответит 404 или 405 маршрутизатора.
+func ValidateOpenAPI(doc *openapi.Document, invalidResponse func(r *http.Request, route openapi.Route, err error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route, ok := doc.Find(r.Method, r.URL.Path)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var body []byte
+			if r.Body != nil && route.JSONBody(r.Header.Get("Content-Type")) {
+				var err error
+				if body, err = io.ReadAll(r.Body); err != nil {
+					writeError(w, r, fmt.Errorf("%w: %v", errMalformedBody, err))
+					return
+				}
+				r.Body = io.NopCloser(bytes.NewReader(body))
+			}
+			if err := doc.ValidateRequest(route, r, body); err != nil {
+				writeError(w, r, fmt.Errorf("%w: %v", errInvalidRequest, err))
+				return
+			}
+
+			rec := &recordingWriter{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+			if err := doc.ValidateResponse(route, rec.status, w.Header(), rec.body.Bytes()); err != nil {
+				invalidResponse(r, route, err)
+			}
+		})
+	}
+}
+
+// jsonMediaType - JSON ли тело с таким Content-Type
+func jsonMediaType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
+
+// recordingWriter запоминает статус ответа и копию тела, если оно в JSON
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if jsonMediaType(w.Header().Get("Content-Type")) {
+		w.body.Write(p)
+	}
+
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap дает http.ResponseController добраться до исходного ResponseWriter
+func (w *recordingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
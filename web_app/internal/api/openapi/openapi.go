@@ -0,0 +1,312 @@
+// Package openapi - спецификация API в формате OpenAPI 3 и проверка запросов и ответов по ней.
+// Проверяется то подмножество JSON Schema, которое использует openapi.json: типы, обязательные
+// и лишние поля, enum, границы чисел и длины массивов, date-time, $ref и allOf.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+//go:embed openapi.json
+var spec []byte
+
+// JSON - спецификация в том виде, в котором ее отдает GET /openapi.json
+func JSON() []byte {
+	return spec
+}
+
+// Document - разобранная спецификация
+type Document struct {
+	Paths      map[string]PathItem `json:"paths"`
+	Components struct {
+		Parameters map[string]*Parameter `json:"parameters"`
+		Responses  map[string]*Response  `json:"responses"`
+		Schemas    map[string]*Schema    `json:"schemas"`
+	} `json:"components"`
+
+	routes []route
+}
+
+// PathItem - операции пути по http-методам и общие для них параметры
+type PathItem struct {
+	Parameters []*Parameter
+	Operations map[string]*Operation
+}
+
+func (p *PathItem) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	p.Operations = make(map[string]*Operation)
+	for name, raw := range fields {
+		if name == "parameters" {
+			if err := json.Unmarshal(raw, &p.Parameters); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var op Operation
+		if err := json.Unmarshal(raw, &op); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		p.Operations[strings.ToUpper(name)] = &op
+	}
+
+	return nil
+}
+
+type Operation struct {
+	Summary     string               `json:"summary"`
+	Role        string               `json:"x-role"`
+	Parameters  []*Parameter         `json:"parameters"`
+	RequestBody *RequestBody         `json:"requestBody"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+	Ref      string  `json:"$ref"`
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Ref     string                `json:"$ref"`
+	Content map[string]*MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Enum                 []any              `json:"enum"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	AllOf                []*Schema          `json:"allOf"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	MinItems             *int               `json:"minItems"`
+	MaxItems             *int               `json:"maxItems"`
+}
+
+// route - операция вместе с разобранным шаблоном пути
+type route struct {
+	pattern   string
+	method    string
+	segments  []string
+	operation *Operation
+	// parameters - параметры пути и операции, ссылки уже разрешены
+	parameters []*Parameter
+}
+
+// Load разбирает встроенную спецификацию
+func Load() (*Document, error) {
+	return Parse(spec)
+}
+
+// Parse разбирает спецификацию и проверяет, что все ссылки в ней ведут на существующие компоненты
+func Parse(data []byte) (*Document, error) {
+	var d Document
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, fmt.Errorf("failed to parse openapi document: %w", err)
+	}
+
+	for pattern, item := range d.Paths {
+		for method, op := range item.Operations {
+			rt := route{pattern: pattern, method: method, segments: strings.Split(pattern, "/"), operation: op}
+			for _, p := range append(slices.Clone(item.Parameters), op.Parameters...) {
+				resolved, err := d.parameter(p)
+				if err != nil {
+					return nil, fmt.Errorf("%s %s: %w", method, pattern, err)
+				}
+				rt.parameters = append(rt.parameters, resolved)
+			}
+			for status, resp := range op.Responses {
+				if _, err := d.response(resp); err != nil {
+					return nil, fmt.Errorf("%s %s: response %s: %w", method, pattern, status, err)
+				}
+			}
+			d.routes = append(d.routes, rt)
+		}
+	}
+	for name, s := range d.Components.Schemas {
+		if err := d.checkRefs(s); err != nil {
+			return nil, fmt.Errorf("schema %s: %w", name, err)
+		}
+	}
+
+	// порядок нужен только для воспроизводимости Operations и выбора маршрута
+	sort.Slice(d.routes, func(i, j int) bool {
+		if d.routes[i].pattern != d.routes[j].pattern {
+			return d.routes[i].pattern < d.routes[j].pattern
+		}
+		return d.routes[i].method < d.routes[j].method
+	})
+
+	return &d, nil
+}
+
+// Operations - все операции спецификации в виде "GET /movies/{id}"
+func (d *Document) Operations() []string {
+	ops := make([]string, 0, len(d.routes))
+	for _, rt := range d.routes {
+		ops = append(ops, rt.method+" "+rt.pattern)
+	}
+
+	return ops
+}
+
+// Route - операция, под которую подошел запрос
+type Route struct {
+	// Pattern - путь из спецификации, например /movies/{id}
+	Pattern    string
+	Method     string
+	Operation  *Operation
+	PathParams map[string]string
+
+	parameters []*Parameter
+}
+
+// Find ищет операцию по методу и пути запроса. Если путь подходит под несколько
+// шаблонов, выбирается тот, в котором больше постоянных сегментов.
+func (d *Document) Find(method, path string) (Route, bool) {
+	if len(path) > 1 {
+		path = strings.TrimSuffix(path, "/")
+	}
+	segments := strings.Split(path, "/")
+
+	var best *route
+	bestLiterals := -1
+	for i := range d.routes {
+		rt := &d.routes[i]
+		if rt.method != method || len(rt.segments) != len(segments) {
+			continue
+		}
+
+		literals, ok := 0, true
+		for j, segment := range rt.segments {
+			if isTemplate(segment) {
+				ok = segments[j] != ""
+			} else {
+				ok = segment == segments[j]
+				literals++
+			}
+			if !ok {
+				break
+			}
+		}
+		if ok && literals > bestLiterals {
+			best, bestLiterals = rt, literals
+		}
+	}
+	if best == nil {
+		return Route{}, false
+	}
+
+	params := make(map[string]string)
+	for j, segment := range best.segments {
+		if isTemplate(segment) {
+			params[segment[1:len(segment)-1]] = segments[j]
+		}
+	}
+
+	return Route{
+		Pattern:    best.pattern,
+		Method:     best.method,
+		Operation:  best.operation,
+		PathParams: params,
+		parameters: best.parameters,
+	}, true
+}
+
+func isTemplate(segment string) bool {
+	return strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
+
+func (d *Document) parameter(p *Parameter) (*Parameter, error) {
+	if p.Ref == "" {
+		return p, d.checkRefs(p.Schema)
+	}
+
+	name, ok := strings.CutPrefix(p.Ref, "#/components/parameters/")
+	resolved := d.Components.Parameters[name]
+	if !ok || resolved == nil {
+		return nil, fmt.Errorf("unknown parameter %s", p.Ref)
+	}
+
+	return resolved, d.checkRefs(resolved.Schema)
+}
+
+func (d *Document) response(r *Response) (*Response, error) {
+	if r.Ref != "" {
+		name, ok := strings.CutPrefix(r.Ref, "#/components/responses/")
+		resolved := d.Components.Responses[name]
+		if !ok || resolved == nil {
+			return nil, fmt.Errorf("unknown response %s", r.Ref)
+		}
+		r = resolved
+	}
+
+	for _, media := range r.Content {
+		if err := d.checkRefs(media.Schema); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// schema разрешает $ref, ссылки проверены в Parse
+func (d *Document) schema(s *Schema) *Schema {
+	for s != nil && s.Ref != "" {
+		s = d.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+
+	return s
+}
+
+func (d *Document) checkRefs(s *Schema) error {
+	if s == nil {
+		return nil
+	}
+	if s.Ref != "" {
+		name, ok := strings.CutPrefix(s.Ref, "#/components/schemas/")
+		if !ok || d.Components.Schemas[name] == nil {
+			return fmt.Errorf("unknown schema %s", s.Ref)
+		}
+		return nil
+	}
+
+	for _, p := range s.Properties {
+		if err := d.checkRefs(p); err != nil {
+			return err
+		}
+	}
+	for _, sub := range s.AllOf {
+		if err := d.checkRefs(sub); err != nil {
+			return err
+		}
+	}
+
+	return d.checkRefs(s.Items)
+}
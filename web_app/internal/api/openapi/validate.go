@@ -0,0 +1,352 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValidateRequest проверяет параметры пути и query и тело запроса, если оно в JSON.
+// Тела других описанных форматов (импорт в NDJSON и CSV) не читаются: их проверяет обработчик.
+func (d *Document) ValidateRequest(rt Route, r *http.Request, body []byte) error {
+	query := r.URL.Query()
+	declared := make(map[string]bool)
+
+	for _, p := range rt.parameters {
+		switch p.In {
+		case "path":
+			if err := d.validateParam(p, []string{rt.PathParams[p.Name]}); err != nil {
+				return err
+			}
+		case "query":
+			declared[p.Name] = true
+			if err := d.validateParam(p, query[p.Name]); err != nil {
+				return err
+			}
+		}
+	}
+
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !declared[name] {
+			return fmt.Errorf("unknown query parameter %q", name)
+		}
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	if !rt.JSONBody(contentType) {
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if isJSON(mediaType) && mediaType != "application/json" {
+		return fmt.Errorf("request body: content type %s is not accepted", mediaType)
+	}
+	if mediaType != "" && !isJSON(mediaType) && !json.Valid(body) {
+		// тело в чужом формате отклонит обработчик со статусом 415
+		return nil
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		if rt.Operation.RequestBody.Required {
+			return fmt.Errorf("request body is required")
+		}
+		return nil
+	}
+
+	return d.validateJSON(rt.Operation.RequestBody.Content["application/json"].Schema, body, "request body", false)
+}
+
+// JSONBody - нужно ли читать тело запроса с таким Content-Type, чтобы проверить его как JSON.
+// PATCH исторически разбирает JSON при любом Content-Type и без него, поэтому читается все,
+// кроме описанных в спецификации форматов не в JSON.
+func (rt Route) JSONBody(contentType string) bool {
+	body := rt.Operation.RequestBody
+	if body == nil || body.Content["application/json"] == nil {
+		return false
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	_, documented := body.Content[mediaType]
+	return isJSON(mediaType) || !documented
+}
+
+// ValidateResponse проверяет, что статус ответа описан в спецификации, а тело
+// соответствует типу и схеме этого статуса. body - тело ответа, если он в JSON.
+func (d *Document) ValidateResponse(rt Route, status int, header http.Header, body []byte) error {
+	resp, ok := rt.Operation.Responses[strconv.Itoa(status)]
+	if !ok {
+		resp, ok = rt.Operation.Responses["default"]
+	}
+	if !ok {
+		return fmt.Errorf("status %d is not documented", status)
+	}
+	resp, _ = d.response(resp)
+
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	if len(resp.Content) == 0 {
+		if len(body) > 0 || mediaType != "" {
+			return fmt.Errorf("status %d: response must have no body, got %s", status, mediaType)
+		}
+		return nil
+	}
+
+	media, ok := resp.Content[mediaType]
+	if !ok {
+		return fmt.Errorf("status %d: content type %q is not documented", status, mediaType)
+	}
+	if !isJSON(mediaType) {
+		return nil
+	}
+
+	return d.validateJSON(media.Schema, body, fmt.Sprintf("status %d", status), true)
+}
+
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// validateJSON разбирает и проверяет тело. В ответах (closed) запрещены все поля, которых нет в
+// схеме: их пишет сам сервис, и такое поле значит, что спецификация отстала от кода
+func (d *Document) validateJSON(s *Schema, data []byte, at string, closed bool) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Errorf("%s: invalid JSON: %v", at, err)
+	}
+	if err := d.validate(s, v, "", closed); err != nil {
+		return fmt.Errorf("%s: %w", at, err)
+	}
+
+	return nil
+}
+
+// validateParam приводит строковые значения параметра к типу схемы и проверяет их.
+// Параметр-массив повторяется в запросе (?genre=a&genre=b), каждое значение проверяется по items.
+func (d *Document) validateParam(p *Parameter, values []string) error {
+	at := p.In + " parameter " + p.Name
+	if len(values) == 0 {
+		if p.Required {
+			return fmt.Errorf("%s is required", at)
+		}
+		return nil
+	}
+
+	s := d.schema(p.Schema)
+	if s == nil {
+		return nil
+	}
+	if s.Type == "array" {
+		s = d.schema(s.Items)
+	}
+
+	for _, raw := range values {
+		value, err := coerce(s.Type, raw)
+		if err != nil {
+			return errorAt(at, "%q is not a valid %s", raw, s.Type)
+		}
+		if err = d.validate(s, value, at, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func coerce(typ, raw string) (any, error) {
+	switch typ {
+	case "integer":
+		if _, err := strconv.ParseInt(raw, 10, 64); err != nil {
+			return nil, err
+		}
+		return json.Number(raw), nil
+	case "number":
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			return nil, err
+		}
+		return json.Number(raw), nil
+	case "boolean":
+		return strconv.ParseBool(raw)
+	}
+
+	return raw, nil
+}
+
+// validate проверяет значение, разобранное encoding/json с UseNumber.
+// at - путь к значению вида items[0].name, пустой у корня.
+func (d *Document) validate(s *Schema, v any, at string, closed bool) error {
+	s = d.schema(s)
+	if s == nil {
+		return nil
+	}
+
+	for _, sub := range s.AllOf {
+		if err := d.validate(sub, v, at, false); err != nil {
+			return err
+		}
+	}
+
+	if s.Type != "" && !hasType(s.Type, v) {
+		return errorAt(at, "expected %s, got %s", s.Type, typeOf(v))
+	}
+
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(e any) bool { return fmt.Sprint(e) == fmt.Sprint(v) }) {
+		return errorAt(at, "%v is not one of %v", v, s.Enum)
+	}
+
+	switch v := v.(type) {
+	case string:
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, v); err != nil {
+				return errorAt(at, "%q is not an RFC 3339 date-time", v)
+			}
+		}
+	case json.Number:
+		n, _ := v.Float64()
+		if s.Minimum != nil && n < *s.Minimum {
+			return errorAt(at, "%v is less than %v", v, *s.Minimum)
+		}
+		if s.Maximum != nil && n > *s.Maximum {
+			return errorAt(at, "%v is greater than %v", v, *s.Maximum)
+		}
+	case []any:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			return errorAt(at, "expected at least %d items, got %d", *s.MinItems, len(v))
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			return errorAt(at, "expected at most %d items, got %d", *s.MaxItems, len(v))
+		}
+		for i, item := range v {
+			if err := d.validate(s.Items, item, fmt.Sprintf("%s[%d]", at, i), closed); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		return d.validateObject(s, v, at, closed)
+	}
+
+	return nil
+}
+
+func (d *Document) validateObject(s *Schema, v map[string]any, at string, closed bool) error {
+	for _, name := range s.Required {
+		if _, ok := v[name]; !ok {
+			return errorAt(at, "%s is required", name)
+		}
+	}
+
+	names := make([]string, 0, len(v))
+	for name := range v {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	// объект без описанных полей (значения в истории изменений) может быть любым
+	closed = closed && (len(s.Properties) > 0 || len(s.AllOf) > 0)
+	for _, name := range names {
+		prop, ok := d.property(s, name)
+		if !ok {
+			if closed || s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				return errorAt(at, "unknown field %s", name)
+			}
+			continue
+		}
+		if err := d.validate(prop, v[name], field(at, name), closed); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// property ищет поле в самой схеме и в частях allOf
+func (d *Document) property(s *Schema, name string) (*Schema, bool) {
+	if prop, ok := s.Properties[name]; ok {
+		return prop, true
+	}
+	for _, sub := range s.AllOf {
+		if prop, ok := d.property(d.schema(sub), name); ok {
+			return prop, true
+		}
+	}
+
+	return nil, false
+}
+
+func hasType(typ string, v any) bool {
+	switch typ {
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "number":
+		_, ok := v.(json.Number)
+		return ok
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := n.Int64()
+		return err == nil
+	}
+
+	return true
+}
+
+func typeOf(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	}
+
+	return fmt.Sprintf("%T", v)
+}
+
+func field(at, name string) string {
+	if at == "" {
+		return name
+	}
+
+	return at + "." + name
+}
+
+func errorAt(at, format string, args ...any) error {
+	if at == "" {
+		return fmt.Errorf(format, args...)
+	}
+
+	return fmt.Errorf("%s: "+format, append([]any{at}, args...)...)
+}
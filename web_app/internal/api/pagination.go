@@ -0,0 +1,37 @@
+package api
+
+import (
+	"arch-demo/internal/domain"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// pageParams разбирает query-параметры limit, offset и cursor
+func pageParams[T any](r *http.Request) (limit, offset int, after *domain.Cursor[T], err error) {
+	query := r.URL.Query()
+
+	if value := query.Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return 0, 0, nil, fmt.Errorf("%w: limit must be a positive number", domain.ErrInvalidPage)
+		}
+	}
+
+	if value := query.Get("offset"); value != "" {
+		offset, err = strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return 0, 0, nil, fmt.Errorf("%w: offset must be a non-negative number", domain.ErrInvalidPage)
+		}
+	}
+
+	if value := query.Get("cursor"); value != "" {
+		cursor, err := domain.DecodeCursor[T](value)
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		after = &cursor
+	}
+
+	return limit, offset, after, nil
+}
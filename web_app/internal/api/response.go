@@ -0,0 +1,53 @@
+package api
+
+import (
+	"arch-demo/internal/domain"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+)
+
+// batchResponse - ответ на выполненный пакет, в том же порядке, что и запрос
+type batchResponse[T any] struct {
+	Items []domain.BatchResult[T] `json:"items"`
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		writeError(w, r, fmt.Errorf("failed to create response data: %w", err))
+		return
+	}
+
+	// если не передать content-type, то клиент воспримет контент как text/plain, а не json
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(data)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// decodeJSON проверяет content-type и разбирает тело запроса в v
+func decodeJSON(r *http.Request, v any) error {
+	// необходимо удостоверится, что в запросе контент нужного типа
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return errUnsupportedMediaType
+	}
+
+	return readJSON(r, v)
+}
+
+// readJSON разбирает тело без проверки content-type, PATCH исторически принимает его без заголовка
+func readJSON(r *http.Request, v any) error {
+	// более короткая и удобная запись вместо io.ReadAll
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errMalformedBody, err)
+	}
+
+	return nil
+}
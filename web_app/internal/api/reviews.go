@@ -0,0 +1,143 @@
+package api
+
+import (
+	"arch-demo/internal/domain"
+	"context"
+	"fmt"
+	"net/http"
+)
+
+type ReviewsService interface {
+	Submit(ctx context.Context, movieID int, review domain.Review) (domain.Review, bool, error)
+	Moderate(ctx context.Context, movieID, reviewID int, moderation domain.ReviewModeration) (domain.Review, error)
+	List(ctx context.Context, query domain.ReviewsQuery) (domain.Page[domain.Review], error)
+}
+
+type ReviewsHandler struct {
+	Service ReviewsService
+}
+
+func NewReviewsHandler(service ReviewsService) ReviewsHandler {
+	return ReviewsHandler{
+		Service: service,
+	}
+}
+
+// Submit - POST /movies/{id}/reviews, {"rating": 1..5, "text": "..."}. Отзыв того же
+// автора заменяется: 201 - отзыв новый, 200 - заменен прежний.
+func (h ReviewsHandler) Submit(w http.ResponseWriter, r *http.Request) {
+	movieID, err := getID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var review domain.Review
+	if err = decodeJSON(r, &review); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	saved, created, err := h.Service.Submit(r.Context(), movieID, review)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	writeJSON(w, r, status, saved)
+}
+
+// List - GET /movies/{id}/reviews, одобренные отзывы на фильм, новые первыми
+func (h ReviewsHandler) List(w http.ResponseWriter, r *http.Request) {
+	movieID, err := getID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	query, err := reviewsQuery(r, nil)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	query.MovieID = movieID
+	query.Status = domain.ReviewApproved
+
+	h.writePage(w, r, query)
+}
+
+// Queue - GET /reviews?status=pending, отзывы на все фильмы для модерации.
+// По умолчанию - ожидающие модерации.
+func (h ReviewsHandler) Queue(w http.ResponseWriter, r *http.Request) {
+	query, err := reviewsQuery(r, []string{"status"})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	query.Status = r.URL.Query().Get("status")
+	if query.Status == "" {
+		query.Status = domain.ReviewPending
+	}
+
+	h.writePage(w, r, query)
+}
+
+// Moderate - PATCH /movies/{id}/reviews/{review_id}, {"status": "approved" | "rejected"}
+func (h ReviewsHandler) Moderate(w http.ResponseWriter, r *http.Request) {
+	movieID, err := getID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	reviewID, err := urlParamID(r, "review_id")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var moderation domain.ReviewModeration
+	if err = decodeJSON(r, &moderation); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	review, err := h.Service.Moderate(r.Context(), movieID, reviewID, moderation)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, review)
+}
+
+func (h ReviewsHandler) writePage(w http.ResponseWriter, r *http.Request, query domain.ReviewsQuery) {
+	page, err := h.Service.List(r.Context(), query)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, page)
+}
+
+// reviewsQuery разбирает параметры страницы. Порядок отзывов всегда один, поэтому sort не принимается.
+func reviewsQuery(r *http.Request, filters []string) (domain.ReviewsQuery, error) {
+	values := r.URL.Query()
+	if values.Has("sort") {
+		return domain.ReviewsQuery{}, fmt.Errorf("%w: reviews are always listed newest first", domain.ErrInvalidSort)
+	}
+	if err := checkParams(values, filters); err != nil {
+		return domain.ReviewsQuery{}, err
+	}
+
+	limit, offset, after, err := pageParams[domain.Review](r)
+	if err != nil {
+		return domain.ReviewsQuery{}, err
+	}
+
+	return domain.ReviewsQuery{Limit: limit, Offset: offset, After: after}, nil
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"arch-demo/internal/domain"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+type SearchService interface {
+	Search(ctx context.Context, query domain.SearchQuery) ([]domain.SearchHit, error)
+}
+
+type SearchHandler struct {
+	Service SearchService
+}
+
+func NewSearchHandler(service SearchService) SearchHandler {
+	return SearchHandler{
+		Service: service,
+	}
+}
+
+// searchResponse - найденные записи, лучшие совпадения первыми
+type searchResponse struct {
+	Items []domain.SearchHit `json:"items"`
+}
+
+// Search - GET /search?q=, поиск актеров и фильмов по названию. Параметр type
+// (можно несколько раз) оставляет только actor или movie, limit - сколько записей вернуть.
+func (h SearchHandler) Search(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := domain.SearchQuery{
+		Text:  params.Get("q"),
+		Types: params["type"],
+	}
+
+	if value := params.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			writeError(w, r, fmt.Errorf("%w: limit must be a positive number", domain.ErrInvalidSearch))
+			return
+		}
+		query.Limit = limit
+	}
+
+	hits, err := h.Service.Search(r.Context(), query)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, searchResponse{Items: hits})
+}
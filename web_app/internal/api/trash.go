@@ -0,0 +1,32 @@
+package api
+
+import (
+	"arch-demo/internal/domain"
+	"context"
+	"net/http"
+)
+
+type TrashService interface {
+	List(ctx context.Context) (domain.Trash, error)
+}
+
+type TrashHandler struct {
+	Service TrashService
+}
+
+func NewTrashHandler(service TrashService) TrashHandler {
+	return TrashHandler{
+		Service: service,
+	}
+}
+
+// List - GET /trash, мягко удаленные актеры и фильмы, последние удаленные первыми
+func (h TrashHandler) List(w http.ResponseWriter, r *http.Request) {
+	trash, err := h.Service.List(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, trash)
+}
@@ -0,0 +1,398 @@
+package config
+
+import (
+	"arch-demo/internal/domain"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	BackendMemory   = "memory"
+	BackendPostgres = "postgres"
+	BackendSQLite   = "sqlite"
+)
+
+// envPrefix - префикс переменных окружения, например APP_STORAGE_DSN
+const envPrefix = "APP_"
+
+type Config struct {
+	Storage StorageConfig `yaml:"storage"`
+	HTTP    HTTPConfig    `yaml:"http"`
+	Cache   CacheConfig   `yaml:"cache"`
+	Auth    AuthConfig    `yaml:"auth"`
+	Log     LogConfig     `yaml:"log"`
+}
+
+type StorageConfig struct {
+	Backend string `yaml:"backend"`
+	DSN     string `yaml:"dsn"`
+	// DeletePolicy - что делать со связями фильм-актер при удалении: restrict, cascade или soft
+	DeletePolicy string `yaml:"delete_policy"`
+	// TrashRetention - сколько мягко удаленные записи хранятся в корзине до окончательного удаления
+	TrashRetention time.Duration `yaml:"trash_retention"`
+	// PurgeInterval - как часто корзина очищается от записей старше TrashRetention
+	PurgeInterval time.Duration `yaml:"purge_interval"`
+}
+
+type HTTPConfig struct {
+	Addr            string        `yaml:"addr"`
+	ReadTimeout     time.Duration `yaml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// RequestTimeout - дедлайн контекста запроса, по его истечении запросы к базе отменяются
+	RequestTimeout time.Duration `yaml:"request_timeout"`
+	// ValidateOpenAPI включает проверку запросов и ответов по спецификации /openapi.json
+	ValidateOpenAPI bool `yaml:"validate_openapi"`
+}
+
+type CacheConfig struct {
+	// MoviesTTL - сколько страница списка фильмов живет в кэше, 0 выключает кэш
+	MoviesTTL time.Duration `yaml:"movies_ttl"`
+	// MaxEntries - сколько страниц хранится одновременно
+	MaxEntries int `yaml:"max_entries"`
+}
+
+type AuthConfig struct {
+	// Enabled включает проверку API-ключей и JWT, по умолчанию включена. Без нее все маршруты
+	// открыты, выключать ее стоит только для разработки (-auth=false)
+	Enabled bool `yaml:"enabled"`
+	// AdminKey - ключ с ролью admin для заведения первых пользователей, лучше передавать через окружение
+	AdminKey string    `yaml:"admin_key"`
+	JWT      JWTConfig `yaml:"jwt"`
+}
+
+// JWTConfig - ключи проверки токенов; если не задан ни один, токены не принимаются
+type JWTConfig struct {
+	HS256Secret string `yaml:"hs256_secret"`
+	// RS256PublicKey - путь к открытому ключу RSA в PEM
+	RS256PublicKey string `yaml:"rs256_public_key"`
+	Issuer         string `yaml:"issuer"`
+	Audience       string `yaml:"audience"`
+}
+
+// секреты короче этого слишком просто подобрать
+const (
+	minAdminKeyLength    = 16
+	minHS256SecretLength = 32
+)
+
+type LogConfig struct {
+	Level string `yaml:"level"`
+}
+
+func Default() Config {
+	return Config{
+		Storage: StorageConfig{
+			Backend:        BackendMemory,
+			DeletePolicy:   string(domain.DeleteSoft),
+			TrashRetention: 30 * 24 * time.Hour,
+			PurgeInterval:  time.Hour,
+		},
+		HTTP: HTTPConfig{
+			Addr:            ":8080",
+			ReadTimeout:     5 * time.Second,
+			WriteTimeout:    10 * time.Second,
+			IdleTimeout:     60 * time.Second,
+			ShutdownTimeout: 10 * time.Second,
+			RequestTimeout:  8 * time.Second,
+		},
+		Cache: CacheConfig{
+			MaxEntries: 1000,
+		},
+		Auth: AuthConfig{
+			Enabled: true,
+		},
+		Log: LogConfig{
+			Level: "info",
+		},
+	}
+}
+
+// Load собирает конфигурацию в порядке возрастания приоритета:
+// значения по умолчанию, файл (-config или APP_CONFIG), переменные окружения, флаги.
+// Вторым значением возвращаются аргументы, оставшиеся после флагов.
+func Load(name string, args []string) (Config, []string, error) {
+	return LoadWithFlags(name, args, nil)
+}
+
+// LoadWithFlags - Load для подкоманд со своими флагами: register добавляет их
+// в общий набор, значения подкоманда читает из своих переменных после разбора
+func LoadWithFlags(name string, args []string, register func(fs *flag.FlagSet)) (Config, []string, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	if register != nil {
+		register(fs)
+	}
+	configPath := fs.String("config", os.Getenv(envPrefix+"CONFIG"), "path to a YAML or JSON config file")
+	// флаги регистрируются со значениями по умолчанию только для -help,
+	// применяются лишь те, что явно переданы
+	defaults := Default()
+	backend := fs.String("storage", defaults.Storage.Backend, "storage backend: memory, postgres or sqlite")
+	dsn := fs.String("dsn", "", "storage data source name")
+	deletePolicy := fs.String("delete-policy", defaults.Storage.DeletePolicy, "delete policy: restrict, cascade or soft")
+	trashRetention := fs.Duration("trash-retention", defaults.Storage.TrashRetention, "how long soft deleted records are kept")
+	purgeInterval := fs.Duration("purge-interval", defaults.Storage.PurgeInterval, "how often expired soft deleted records are purged")
+	addr := fs.String("addr", defaults.HTTP.Addr, "HTTP listen address")
+	readTimeout := fs.Duration("read-timeout", defaults.HTTP.ReadTimeout, "HTTP read timeout")
+	writeTimeout := fs.Duration("write-timeout", defaults.HTTP.WriteTimeout, "HTTP write timeout")
+	idleTimeout := fs.Duration("idle-timeout", defaults.HTTP.IdleTimeout, "HTTP keep-alive idle timeout")
+	shutdownTimeout := fs.Duration("shutdown-timeout", defaults.HTTP.ShutdownTimeout, "graceful shutdown timeout")
+	requestTimeout := fs.Duration("request-timeout", defaults.HTTP.RequestTimeout, "per-request deadline")
+	validateOpenAPI := fs.Bool("validate-openapi", defaults.HTTP.ValidateOpenAPI, "reject requests that don't match the OpenAPI spec and log mismatched responses")
+	moviesCacheTTL := fs.Duration("movies-cache-ttl", defaults.Cache.MoviesTTL, "how long movie list pages are cached, 0 disables the cache")
+	cacheMaxEntries := fs.Int("cache-max-entries", defaults.Cache.MaxEntries, "maximum number of cached pages")
+	authEnabled := fs.Bool("auth", defaults.Auth.Enabled, "require API keys or JWT, -auth=false opens every route for development; secrets are read from the config file or environment only")
+	jwtPublicKey := fs.String("jwt-rs256-public-key", "", "path to the PEM RSA public key for RS256 tokens")
+	jwtIssuer := fs.String("jwt-issuer", "", "expected JWT issuer")
+	jwtAudience := fs.String("jwt-audience", "", "expected JWT audience")
+	logLevel := fs.String("log-level", defaults.Log.Level, "log level: debug, info, warn or error")
+
+	if err := fs.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+
+	if *configPath != "" {
+		if err := cfg.loadFile(*configPath); err != nil {
+			return Config{}, nil, err
+		}
+	}
+
+	if err := cfg.loadEnv(); err != nil {
+		return Config{}, nil, err
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "storage":
+			cfg.Storage.Backend = *backend
+		case "dsn":
+			cfg.Storage.DSN = *dsn
+		case "delete-policy":
+			cfg.Storage.DeletePolicy = *deletePolicy
+		case "trash-retention":
+			cfg.Storage.TrashRetention = *trashRetention
+		case "purge-interval":
+			cfg.Storage.PurgeInterval = *purgeInterval
+		case "addr":
+			cfg.HTTP.Addr = *addr
+		case "read-timeout":
+			cfg.HTTP.ReadTimeout = *readTimeout
+		case "write-timeout":
+			cfg.HTTP.WriteTimeout = *writeTimeout
+		case "idle-timeout":
+			cfg.HTTP.IdleTimeout = *idleTimeout
+		case "shutdown-timeout":
+			cfg.HTTP.ShutdownTimeout = *shutdownTimeout
+		case "request-timeout":
+			cfg.HTTP.RequestTimeout = *requestTimeout
+		case "validate-openapi":
+			cfg.HTTP.ValidateOpenAPI = *validateOpenAPI
+		case "movies-cache-ttl":
+			cfg.Cache.MoviesTTL = *moviesCacheTTL
+		case "cache-max-entries":
+			cfg.Cache.MaxEntries = *cacheMaxEntries
+		case "auth":
+			cfg.Auth.Enabled = *authEnabled
+		case "jwt-rs256-public-key":
+			cfg.Auth.JWT.RS256PublicKey = *jwtPublicKey
+		case "jwt-issuer":
+			cfg.Auth.JWT.Issuer = *jwtIssuer
+		case "jwt-audience":
+			cfg.Auth.JWT.Audience = *jwtAudience
+		case "log-level":
+			cfg.Log.Level = *logLevel
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, nil, err
+	}
+
+	return cfg, fs.Args(), nil
+}
+
+// loadFile читает YAML; JSON является его подмножеством, поэтому разбирается тем же декодером
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	// неизвестные ключи - почти всегда опечатка, поэтому это ошибка
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err = dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func (c *Config) loadEnv() error {
+	stringFields := map[string]*string{
+		"STORAGE_BACKEND":           &c.Storage.Backend,
+		"STORAGE_DSN":               &c.Storage.DSN,
+		"STORAGE_DELETE_POLICY":     &c.Storage.DeletePolicy,
+		"HTTP_ADDR":                 &c.HTTP.Addr,
+		"LOG_LEVEL":                 &c.Log.Level,
+		"AUTH_ADMIN_KEY":            &c.Auth.AdminKey,
+		"AUTH_JWT_HS256_SECRET":     &c.Auth.JWT.HS256Secret,
+		"AUTH_JWT_RS256_PUBLIC_KEY": &c.Auth.JWT.RS256PublicKey,
+		"AUTH_JWT_ISSUER":           &c.Auth.JWT.Issuer,
+		"AUTH_JWT_AUDIENCE":         &c.Auth.JWT.Audience,
+	}
+	for name, field := range stringFields {
+		if value, ok := os.LookupEnv(envPrefix + name); ok {
+			*field = value
+		}
+	}
+
+	durationFields := map[string]*time.Duration{
+		"STORAGE_TRASH_RETENTION": &c.Storage.TrashRetention,
+		"STORAGE_PURGE_INTERVAL":  &c.Storage.PurgeInterval,
+		"HTTP_READ_TIMEOUT":       &c.HTTP.ReadTimeout,
+		"HTTP_WRITE_TIMEOUT":      &c.HTTP.WriteTimeout,
+		"HTTP_IDLE_TIMEOUT":       &c.HTTP.IdleTimeout,
+		"HTTP_SHUTDOWN_TIMEOUT":   &c.HTTP.ShutdownTimeout,
+		"HTTP_REQUEST_TIMEOUT":    &c.HTTP.RequestTimeout,
+		"CACHE_MOVIES_TTL":        &c.Cache.MoviesTTL,
+	}
+	for name, field := range durationFields {
+		value, ok := os.LookupEnv(envPrefix + name)
+		if !ok {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%s%s: %w", envPrefix, name, err)
+		}
+		*field = d
+	}
+
+	intFields := map[string]*int{
+		"CACHE_MAX_ENTRIES": &c.Cache.MaxEntries,
+	}
+	for name, field := range intFields {
+		value, ok := os.LookupEnv(envPrefix + name)
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s%s: %w", envPrefix, name, err)
+		}
+		*field = n
+	}
+
+	boolFields := map[string]*bool{
+		"AUTH_ENABLED":          &c.Auth.Enabled,
+		"HTTP_VALIDATE_OPENAPI": &c.HTTP.ValidateOpenAPI,
+	}
+	for name, field := range boolFields {
+		value, ok := os.LookupEnv(envPrefix + name)
+		if !ok {
+			continue
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s%s: %w", envPrefix, name, err)
+		}
+		*field = b
+	}
+
+	return nil
+}
+
+// Validate возвращает все найденные ошибки сразу, а не только первую
+func (c Config) Validate() error {
+	var errs []error
+
+	switch c.Storage.Backend {
+	case BackendMemory:
+	case BackendPostgres, BackendSQLite:
+		if c.Storage.DSN == "" {
+			errs = append(errs, fmt.Errorf("storage.dsn is required for the %s backend", c.Storage.Backend))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("storage.backend %q is unknown, expected %s, %s or %s",
+			c.Storage.Backend, BackendMemory, BackendPostgres, BackendSQLite))
+	}
+
+	switch domain.DeletePolicy(c.Storage.DeletePolicy) {
+	case domain.DeleteRestrict, domain.DeleteCascade, domain.DeleteSoft:
+	default:
+		errs = append(errs, fmt.Errorf("storage.delete_policy %q is unknown, expected %s, %s or %s",
+			c.Storage.DeletePolicy, domain.DeleteRestrict, domain.DeleteCascade, domain.DeleteSoft))
+	}
+
+	if _, _, err := net.SplitHostPort(c.HTTP.Addr); err != nil {
+		errs = append(errs, fmt.Errorf("http.addr %q is invalid: %w", c.HTTP.Addr, err))
+	}
+
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"storage.trash_retention", c.Storage.TrashRetention},
+		{"storage.purge_interval", c.Storage.PurgeInterval},
+		{"http.read_timeout", c.HTTP.ReadTimeout},
+		{"http.write_timeout", c.HTTP.WriteTimeout},
+		{"http.idle_timeout", c.HTTP.IdleTimeout},
+		{"http.shutdown_timeout", c.HTTP.ShutdownTimeout},
+		{"http.request_timeout", c.HTTP.RequestTimeout},
+	}
+	for _, t := range durations {
+		if t.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive, got %s", t.name, t.value))
+		}
+	}
+
+	if c.Cache.MoviesTTL < 0 {
+		errs = append(errs, fmt.Errorf("cache.movies_ttl must not be negative, got %s", c.Cache.MoviesTTL))
+	}
+	if c.Cache.MaxEntries <= 0 {
+		errs = append(errs, fmt.Errorf("cache.max_entries must be positive, got %d", c.Cache.MaxEntries))
+	}
+
+	if c.Auth.AdminKey != "" && len(c.Auth.AdminKey) < minAdminKeyLength {
+		errs = append(errs, fmt.Errorf("auth.admin_key must be at least %d characters long", minAdminKeyLength))
+	}
+	if c.Auth.JWT.HS256Secret != "" && len(c.Auth.JWT.HS256Secret) < minHS256SecretLength {
+		errs = append(errs, fmt.Errorf("auth.jwt.hs256_secret must be at least %d characters long", minHS256SecretLength))
+	}
+	// без ключа администратора и ключей JWT войти в сервис с проверкой доступа некому
+	if c.Auth.Enabled && c.Auth.AdminKey == "" && c.Auth.JWT.HS256Secret == "" && c.Auth.JWT.RS256PublicKey == "" {
+		errs = append(errs, errors.New("auth.enabled requires auth.admin_key, auth.jwt.hs256_secret or auth.jwt.rs256_public_key, "+
+			"pass -auth=false to run without authentication"))
+	}
+
+	if _, err := c.Log.SlogLevel(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+
+	return nil
+}
+
+func (l LogConfig) SlogLevel() (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(l.Level)); err != nil {
+		return 0, fmt.Errorf("log.level %q is unknown, expected debug, info, warn or error", l.Level)
+	}
+
+	return level, nil
+}
@@ -0,0 +1,186 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testAdminKey = "test-admin-key-0123456789"
+
+// adminKeyEnv - ключ администратора, без которого не проходит конфигурация с включенной аутентификацией
+var adminKeyEnv = map[string]string{"APP_AUTH_ADMIN_KEY": testAdminKey}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name string
+		// file - содержимое файла конфигурации, пустое - файла нет
+		file string
+		// fileFromEnv - путь к файлу передается в APP_CONFIG, а не флагом -config
+		fileFromEnv bool
+		env         map[string]string
+		args        []string
+		check       func(t *testing.T, cfg Config)
+		// wantErrs - части текста ошибки, все должны в ней встретиться
+		wantErrs []string
+	}{
+		{
+			name: "defaults",
+			env:  adminKeyEnv,
+			check: func(t *testing.T, cfg Config) {
+				if cfg.Storage.Backend != BackendMemory || cfg.HTTP.Addr != ":8080" || !cfg.Auth.Enabled {
+					t.Errorf("defaults = %+v", cfg)
+				}
+			},
+		},
+		{
+			// аутентификация включена по умолчанию, и без ключей войти было бы некому
+			name:     "auth without credentials",
+			wantErrs: []string{"auth.enabled requires auth.admin_key, auth.jwt.hs256_secret or auth.jwt.rs256_public_key"},
+		},
+		{
+			name: "auth disabled for development",
+			args: []string{"-auth=false"},
+			check: func(t *testing.T, cfg Config) {
+				if cfg.Auth.Enabled {
+					t.Error("auth enabled, want disabled by the flag")
+				}
+			},
+		},
+		{
+			name: "file overrides defaults",
+			file: "http:\n  addr: \":9000\"\n  request_timeout: 3s\ncache:\n  movies_ttl: 1m\n",
+			env:  adminKeyEnv,
+			check: func(t *testing.T, cfg Config) {
+				if cfg.HTTP.Addr != ":9000" || cfg.HTTP.RequestTimeout != 3*time.Second || cfg.Cache.MoviesTTL != time.Minute {
+					t.Errorf("http = %+v, cache = %+v, want values from the file", cfg.HTTP, cfg.Cache)
+				}
+				// то, чего нет в файле, остается по умолчанию
+				if cfg.HTTP.ReadTimeout != 5*time.Second || cfg.Cache.MaxEntries != 1000 {
+					t.Errorf("http = %+v, cache = %+v, want defaults for missing keys", cfg.HTTP, cfg.Cache)
+				}
+			},
+		},
+		{
+			name: "json file",
+			file: `{"storage": {"backend": "sqlite", "dsn": "/tmp/app.db"}}`,
+			env:  adminKeyEnv,
+			check: func(t *testing.T, cfg Config) {
+				if cfg.Storage.Backend != BackendSQLite || cfg.Storage.DSN != "/tmp/app.db" {
+					t.Errorf("storage = %+v, want sqlite from json", cfg.Storage)
+				}
+			},
+		},
+		{
+			name: "env overrides file",
+			file: "http:\n  addr: \":9000\"\nauth:\n  enabled: false\n",
+			env:  map[string]string{"APP_HTTP_ADDR": ":9100", "APP_AUTH_ENABLED": "true", "APP_AUTH_ADMIN_KEY": testAdminKey, "APP_CACHE_MAX_ENTRIES": "10", "APP_HTTP_IDLE_TIMEOUT": "2m"},
+			check: func(t *testing.T, cfg Config) {
+				if cfg.HTTP.Addr != ":9100" || !cfg.Auth.Enabled || cfg.Cache.MaxEntries != 10 || cfg.HTTP.IdleTimeout != 2*time.Minute {
+					t.Errorf("cfg = %+v, want values from the environment", cfg)
+				}
+			},
+		},
+		{
+			name: "flags override env and file",
+			file: "http:\n  addr: \":9000\"\nlog:\n  level: warn\n",
+			env:  map[string]string{"APP_HTTP_ADDR": ":9100", "APP_AUTH_ENABLED": "true"},
+			args: []string{"-addr", ":9200", "-auth=false", "serve"},
+			check: func(t *testing.T, cfg Config) {
+				if cfg.HTTP.Addr != ":9200" || cfg.Auth.Enabled {
+					t.Errorf("addr %q, auth %v, want values from the flags", cfg.HTTP.Addr, cfg.Auth.Enabled)
+				}
+				if cfg.Log.Level != "warn" {
+					t.Errorf("log level %q, want warn from the file: a flag that is not passed overrides nothing", cfg.Log.Level)
+				}
+			},
+		},
+		{
+			name:        "config path from env",
+			file:        "log:\n  level: debug\n",
+			fileFromEnv: true,
+			env:         adminKeyEnv,
+			check: func(t *testing.T, cfg Config) {
+				if cfg.Log.Level != "debug" {
+					t.Errorf("log level %q, want debug from APP_CONFIG file", cfg.Log.Level)
+				}
+			},
+		},
+		{
+			name:     "unknown key in file",
+			file:     "http:\n  adr: \":9000\"\n",
+			wantErrs: []string{"field adr not found"},
+		},
+		{
+			name:     "invalid env duration",
+			env:      map[string]string{"APP_HTTP_READ_TIMEOUT": "soon"},
+			wantErrs: []string{"APP_HTTP_READ_TIMEOUT"},
+		},
+		{
+			name:     "unknown flag",
+			args:     []string{"-verbose"},
+			wantErrs: []string{"-verbose"},
+		},
+		{
+			name: "all validation errors at once",
+			file: "storage:\n  backend: sqlite\n  delete_policy: never\nhttp:\n  addr: nowhere\n  read_timeout: 0s\n" +
+				"cache:\n  max_entries: 0\nauth:\n  admin_key: short\nlog:\n  level: loud\n",
+			wantErrs: []string{
+				"storage.dsn is required for the sqlite backend",
+				`storage.delete_policy "never" is unknown`,
+				`http.addr "nowhere" is invalid`,
+				"http.read_timeout must be positive",
+				"cache.max_entries must be positive",
+				"auth.admin_key must be at least 16 characters long",
+				`log.level "loud" is unknown`,
+			},
+		},
+		{
+			name:     "validation after flags",
+			args:     []string{"-storage", "postgres"},
+			wantErrs: []string{"storage.dsn is required for the postgres backend"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.file != "" {
+				path := filepath.Join(t.TempDir(), "config.yaml")
+				if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				if tt.fileFromEnv {
+					t.Setenv("APP_CONFIG", path)
+				} else {
+					args = append([]string{"-config", path}, args...)
+				}
+			}
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			cfg, rest, err := Load("test", args)
+			if len(tt.wantErrs) > 0 {
+				if err == nil {
+					t.Fatalf("Load = %+v, want error", cfg)
+				}
+				for _, want := range tt.wantErrs {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("error %q does not mention %q", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if len(tt.args) > 0 && tt.args[len(tt.args)-1] == "serve" && (len(rest) != 1 || rest[0] != "serve") {
+				t.Errorf("rest args = %q, want [serve]", rest)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
@@ -1,5 +1,7 @@
 package domain
 
+import "time"
+
 // Характеристики актера: полное имя, год рождения, страна рождения, пол
 type Actor struct {
 	ID             int    `json:"id" db:"id"`
@@ -7,13 +9,32 @@ type Actor struct {
 	BirthYear      int    `json:"birth_year" db:"birth_year"`
 	CountryOfBirth string `json:"country_of_birth" db:"country_of_birth"`
 	Gender         string `json:"gender" db:"gender"`
+	// Version растет с каждым изменением записи, по нему работает If-Match
+	Version int `json:"version" db:"version"`
+	// UpdatedAt - время последнего изменения, по нему работает If-Modified-Since
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	// DeletedAt заполнен только у мягко удаленных записей
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
 }
 
 type ActorUpdate struct {
 	Name           *string `json:"name,omitempty"`
 	BirthYear      *int    `json:"birth_year,omitempty"`
 	CountryOfBirth *string `json:"country_of_birth,omitempty"`
-	Sex            *string `json:"sex,omitempty"`
+	Gender         *string `json:"gender,omitempty"`
+	// Deprecated: Sex - прежнее имя Gender, его еще присылают старые клиенты.
+	// Используйте GenderValue, чтобы учесть оба поля.
+	Sex *string `json:"sex,omitempty"`
+}
+
+// GenderValue возвращает новый пол из gender или из устаревшего sex, nil - пол не меняется.
+// Если переданы оба поля, действует gender.
+func (u ActorUpdate) GenderValue() *string {
+	if u.Gender != nil {
+		return u.Gender
+	}
+
+	return u.Sex
 }
 
 //{
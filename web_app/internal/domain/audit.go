@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// виды записей в журнале изменений
+const (
+	AuditActor = "actor"
+	AuditMovie = "movie"
+)
+
+// действия, которые попадают в журнал
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	AuditCast    = "cast"
+)
+
+// AnonymousCaller - идентичность вызывающего, если она не передана
+const AnonymousCaller = "anonymous"
+
+// UnverifiedCaller помечает имя, которое вызывающий назвал сам, без аутентификации:
+// в журнале его нельзя принять за подтвержденного пользователя
+func UnverifiedCaller(name string) string {
+	return "unverified:" + name
+}
+
+// AuditEntry - одно изменение актера или фильма: кто, когда и что поменял
+type AuditEntry struct {
+	ID       int           `json:"id"`
+	Entity   string        `json:"entity"`
+	EntityID int           `json:"entity_id"`
+	Action   string        `json:"action"`
+	Caller   string        `json:"caller"`
+	At       time.Time     `json:"at"`
+	Changes  []FieldChange `json:"changes"`
+}
+
+// FieldChange - значение поля до и после изменения в том виде, в каком оно
+// отдается в json. При создании Before пустой, при удалении пустой After.
+type FieldChange struct {
+	Field  string          `json:"field"`
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+type callerKey struct{}
+
+// WithCaller сохраняет в контексте, от чьего имени выполняется запрос
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+func CallerFrom(ctx context.Context) string {
+	if caller, ok := ctx.Value(callerKey{}).(string); ok && caller != "" {
+		return caller
+	}
+
+	return AnonymousCaller
+}
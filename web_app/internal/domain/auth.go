@@ -0,0 +1,114 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrUnauthenticated = errors.New("authentication required")
+	ErrForbidden       = errors.New("permission denied")
+)
+
+// UserRole - уровень доступа пользователя; не путать с ролью актера в фильме
+type UserRole string
+
+// роли упорядочены: каждая следующая может все, что может предыдущая
+const (
+	RoleViewer UserRole = "viewer"
+	RoleEditor UserRole = "editor"
+	RoleAdmin  UserRole = "admin"
+)
+
+var roleRanks = map[UserRole]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+}
+
+func (r UserRole) Valid() bool {
+	return roleRanks[r] > 0
+}
+
+// Allows сообщает, хватает ли роли r для действия, которому нужна роль required
+func (r UserRole) Allows(required UserRole) bool {
+	return r.Valid() && roleRanks[r] >= roleRanks[required]
+}
+
+// Principal - аутентифицированный вызывающий
+type Principal struct {
+	Subject string   `json:"subject"`
+	Role    UserRole `json:"role"`
+}
+
+// User - учетная запись, от имени которой работают ключи и JWT с sub, равным Name
+type User struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Role      UserRole  `json:"role"`
+	Disabled  bool      `json:"disabled"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type UserUpdate struct {
+	Role     *UserRole `json:"role,omitempty"`
+	Disabled *bool     `json:"disabled,omitempty"`
+}
+
+// APIKey хранит только хэш секрета, сам секрет отдается один раз при создании.
+// Prefix - начало секрета, по нему ключ можно узнать в списке.
+type APIKey struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Name      string    `json:"name"`
+	Prefix    string    `json:"prefix"`
+	Hash      string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	// Secret заполнен только в ответе на создание ключа
+	Secret string `json:"secret,omitempty"`
+}
+
+func (u *User) Validate() error {
+	var v validator
+	v.name("name", u.Name)
+
+	if v.required("role", u.Role != "") && !u.Role.Valid() {
+		v.add("role", "must be one of viewer, editor, admin")
+	}
+
+	return v.err()
+}
+
+func (u *UserUpdate) Validate() error {
+	var v validator
+	if u.Role != nil && !u.Role.Valid() {
+		v.add("role", "must be one of viewer, editor, admin")
+	}
+
+	return v.err()
+}
+
+func (k *APIKey) Validate() error {
+	var v validator
+	if v.required("user_id", k.UserID != 0) && k.UserID < 0 {
+		v.add("user_id", "must be positive")
+	}
+	v.name("name", k.Name)
+
+	return v.err()
+}
+
+type principalKey struct{}
+
+// WithPrincipal сохраняет в контексте аутентифицированного вызывающего, он же
+// попадает в журнал изменений
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	ctx = WithCaller(ctx, principal.Subject)
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
@@ -0,0 +1,141 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// MaxBatchSize - больше элементов в одном пакете не принимается: пакет выполняется
+// в одной транзакции и все это время держит блокировки
+const MaxBatchSize = 1000
+
+var ErrInvalidBatch = errors.New("invalid batch")
+
+// результат элемента пакета
+const (
+	BatchCreated = "created"
+	BatchUpdated = "updated"
+	// BatchFailed - на этом элементе пакет остановился
+	BatchFailed = "failed"
+	// BatchRolledBack - элемент выполнился, но отменен вместе с пакетом
+	BatchRolledBack = "rolled_back"
+	// BatchSkipped - до элемента дело не дошло
+	BatchSkipped = "skipped"
+)
+
+// ActorBatchItem - элемент POST /actors:batch: без id актер создается,
+// с id частично обновляется, как в PATCH /actors/{id}
+type ActorBatchItem struct {
+	ID int
+	// Version - ожидаемая версия обновляемой записи, 0 - любая
+	Version int
+	Create  Actor
+	Update  ActorUpdate
+}
+
+func (i *ActorBatchItem) UnmarshalJSON(data []byte) error {
+	return unmarshalBatchItem(data, &i.ID, &i.Version, &i.Create, &i.Update)
+}
+
+// MovieBatchItem - элемент POST /movies:batch, устроен как ActorBatchItem
+type MovieBatchItem struct {
+	ID      int
+	Version int
+	Create  Movie
+	Update  MovieUpdate
+}
+
+func (i *MovieBatchItem) UnmarshalJSON(data []byte) error {
+	return unmarshalBatchItem(data, &i.ID, &i.Version, &i.Create, &i.Update)
+}
+
+func unmarshalBatchItem(data []byte, id, version *int, create, update any) error {
+	var key struct {
+		ID      int `json:"id"`
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &key); err != nil {
+		return err
+	}
+	*id, *version = key.ID, key.Version
+
+	if key.ID == 0 {
+		return json.Unmarshal(data, create)
+	}
+
+	return json.Unmarshal(data, update)
+}
+
+// BatchResult - сохраненная запись элемента пакета
+type BatchResult[T any] struct {
+	Index  int    `json:"index"`
+	Status string `json:"status"`
+	Item   T      `json:"item"`
+}
+
+// BatchItemStatus - что стало с элементом отмененного пакета
+type BatchItemStatus struct {
+	Index  int          `json:"index"`
+	Status string       `json:"status"`
+	Error  string       `json:"error,omitempty"`
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// BatchError - пакет не выполнен, и ни одно его изменение не сохранено.
+// Err - первая ошибка, по ней errors.Is определяет вид ошибки.
+type BatchError struct {
+	Err   error
+	Items []BatchItemStatus
+}
+
+// NewBatchError готовит отчет, в котором ни один из n элементов еще не выполнялся
+func NewBatchError(n int) *BatchError {
+	e := &BatchError{Items: make([]BatchItemStatus, n)}
+	for i := range e.Items {
+		e.Items[i] = BatchItemStatus{Index: i, Status: BatchSkipped}
+	}
+
+	return e
+}
+
+// Fail отмечает ошибку элемента index, первая ошибка становится ошибкой пакета
+func (e *BatchError) Fail(index int, err error) {
+	if e.Err == nil {
+		e.Err = err
+	}
+
+	item := &e.Items[index]
+	item.Status = BatchFailed
+	item.Error = err.Error()
+
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		item.Fields = validationErr.Fields
+	}
+}
+
+// RolledBack отмечает элементы до index как выполненные и отмененные
+func (e *BatchError) RolledBack(index int) {
+	for i := 0; i < index && i < len(e.Items); i++ {
+		e.Items[i].Status = BatchRolledBack
+	}
+}
+
+func (e *BatchError) Failed() bool {
+	return e.Err != nil
+}
+
+func (e *BatchError) Error() string {
+	for _, item := range e.Items {
+		if item.Status == BatchFailed {
+			return fmt.Sprintf("batch item %d: %v", item.Index, e.Err)
+		}
+	}
+
+	return fmt.Sprintf("batch: %v", e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,92 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// роли актера в фильме
+const (
+	RoleLead       = "lead"
+	RoleSupporting = "supporting"
+)
+
+// CastEntry - участие актера в фильме. BillingOrder - место в титрах начиная с 1,
+// 0 значит "не задано": новый актер встает в конец титров, у существующего место не меняется.
+type CastEntry struct {
+	ActorID      int    `json:"actor_id"`
+	Character    string `json:"character"`
+	BillingOrder int    `json:"billing_order"`
+	Role         string `json:"role"`
+}
+
+type CastEntryUpdate struct {
+	Character    *string `json:"character,omitempty"`
+	BillingOrder *int    `json:"billing_order,omitempty"`
+	Role         *string `json:"role,omitempty"`
+}
+
+// CastMember - актер вместе с его ролью в фильме, в таком виде отдается состав фильма
+type CastMember struct {
+	Actor
+	Character    string `json:"character"`
+	BillingOrder int    `json:"billing_order"`
+	Role         string `json:"role"`
+}
+
+func (m CastMember) Entry() CastEntry {
+	return CastEntry{
+		ActorID:      m.ID,
+		Character:    m.Character,
+		BillingOrder: m.BillingOrder,
+		Role:         m.Role,
+	}
+}
+
+// Validate проверяет одну запись состава, пустая роль считается второстепенной
+func (e *CastEntry) Validate() error {
+	var v validator
+	e.validate(&v, "")
+
+	return v.err()
+}
+
+// ValidateCast проверяет весь список сразу, поля ошибок имеют вид "[1].role"
+func ValidateCast(entries []CastEntry) error {
+	var v validator
+	seen := make(map[int]bool, len(entries))
+	for i := range entries {
+		prefix := fmt.Sprintf("[%d].", i)
+		entries[i].validate(&v, prefix)
+
+		if entries[i].ActorID != 0 && seen[entries[i].ActorID] {
+			v.add(prefix+"actor_id", "is duplicated")
+		}
+		seen[entries[i].ActorID] = true
+	}
+
+	return v.err()
+}
+
+func (e *CastEntry) validate(v *validator, prefix string) {
+	if v.required(prefix+"actor_id", e.ActorID != 0) && e.ActorID < 0 {
+		v.add(prefix+"actor_id", "must be positive")
+	}
+
+	if len([]rune(e.Character)) > MaxNameLength {
+		v.add(prefix+"character", "must be at most 255 characters long")
+	}
+
+	if e.BillingOrder < 0 {
+		v.add(prefix+"billing_order", "must not be negative")
+	}
+
+	e.Role = strings.ToLower(strings.TrimSpace(e.Role))
+	switch e.Role {
+	case "":
+		e.Role = RoleSupporting
+	case RoleLead, RoleSupporting:
+	default:
+		v.add(prefix+"role", "must be one of lead, supporting")
+	}
+}
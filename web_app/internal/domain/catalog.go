@@ -0,0 +1,177 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrMalformedRecord - строку импорта не удалось разобрать, остальные строки это не затрагивает
+	ErrMalformedRecord = errors.New("malformed record")
+	// ErrInvalidImport - файл импорта нельзя читать дальше, например у CSV неизвестная колонка
+	ErrInvalidImport = errors.New("invalid import file")
+	ErrUnknownFormat = errors.New("unknown catalog format")
+)
+
+// CatalogFormat - формат выгрузки и импорта каталога
+type CatalogFormat string
+
+const (
+	// FormatNDJSON - JSON Lines, по объекту на строку
+	FormatNDJSON CatalogFormat = "ndjson"
+	FormatCSV    CatalogFormat = "csv"
+)
+
+// типы записей выгрузки каталога
+const (
+	RecordActor = "actor"
+	RecordMovie = "movie"
+	RecordCast  = "cast"
+)
+
+// CatalogRecord - одна строка выгрузки: актер, фильм или участие актера в фильме.
+// Заполнено только поле, соответствующее Type.
+type CatalogRecord struct {
+	Type  string
+	Actor Actor
+	Movie Movie
+	Cast  CastLink
+}
+
+// CastLink - запись состава вместе с фильмом, к которому она относится
+type CastLink struct {
+	MovieID int `json:"movie_id"`
+	CastEntry
+}
+
+// Validate проверяет ссылку на фильм и саму запись состава
+func (l *CastLink) Validate() error {
+	var v validator
+	if v.required("movie_id", l.MovieID != 0) && l.MovieID < 0 {
+		v.add("movie_id", "must be positive")
+	}
+	l.CastEntry.validate(&v, "")
+
+	return v.err()
+}
+
+// MarshalJSON пишет запись плоским объектом с полем type, как строку NDJSON
+func (r CatalogRecord) MarshalJSON() ([]byte, error) {
+	switch r.Type {
+	case RecordActor:
+		return json.Marshal(struct {
+			Type string `json:"type"`
+			Actor
+		}{r.Type, r.Actor})
+	case RecordMovie:
+		return json.Marshal(struct {
+			Type string `json:"type"`
+			Movie
+		}{r.Type, r.Movie})
+	case RecordCast:
+		return json.Marshal(struct {
+			Type string `json:"type"`
+			CastLink
+		}{r.Type, r.Cast})
+	}
+
+	return nil, fmt.Errorf("unknown record type %q", r.Type)
+}
+
+func (r *CatalogRecord) UnmarshalJSON(data []byte) error {
+	var header struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return err
+	}
+
+	*r = CatalogRecord{Type: header.Type}
+	switch header.Type {
+	case RecordActor:
+		return json.Unmarshal(data, &r.Actor)
+	case RecordMovie:
+		return json.Unmarshal(data, &r.Movie)
+	case RecordCast:
+		return json.Unmarshal(data, &r.Cast)
+	case "":
+		return errors.New("type is required")
+	}
+
+	return fmt.Errorf("unknown record type %q, expected actor, movie or cast", header.Type)
+}
+
+// ID - id записи в файле импорта; для записи состава id нет
+func (r CatalogRecord) ID() int {
+	switch r.Type {
+	case RecordActor:
+		return r.Actor.ID
+	case RecordMovie:
+		return r.Movie.ID
+	}
+
+	return 0
+}
+
+// MaxImportErrors ограничивает отчет об импорте: ошибки сверх этого только считаются
+const MaxImportErrors = 1000
+
+// ImportReport - итог импорта. Строки с ошибками пропускаются, остальные применяются.
+type ImportReport struct {
+	DryRun    bool `json:"dry_run"`
+	Lines     int  `json:"lines"`
+	Created   int  `json:"created"`
+	Updated   int  `json:"updated"`
+	Unchanged int  `json:"unchanged"`
+	Failed    int  `json:"failed"`
+	// ErrorsTruncated - ошибок больше, чем MaxImportErrors, в Errors только первые
+	ErrorsTruncated bool          `json:"errors_truncated,omitempty"`
+	Errors          []ImportError `json:"errors"`
+}
+
+// ImportError - ошибка в строке line файла импорта
+type ImportError struct {
+	Line   int          `json:"line"`
+	Type   string       `json:"type,omitempty"`
+	ID     int          `json:"id,omitempty"`
+	Error  string       `json:"error"`
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// результат импорта одной строки
+const (
+	ImportCreated   = "created"
+	ImportUpdated   = "updated"
+	ImportUnchanged = "unchanged"
+)
+
+// Add учитывает результат строки: action при успехе или err
+func (r *ImportReport) Add(line int, record CatalogRecord, action string, err error) {
+	r.Lines++
+
+	if err == nil {
+		switch action {
+		case ImportCreated:
+			r.Created++
+		case ImportUpdated:
+			r.Updated++
+		default:
+			r.Unchanged++
+		}
+		return
+	}
+
+	r.Failed++
+	if len(r.Errors) >= MaxImportErrors {
+		r.ErrorsTruncated = true
+		return
+	}
+
+	importErr := ImportError{Line: line, Type: record.Type, ID: record.ID(), Error: err.Error()}
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		importErr.Fields = validationErr.Fields
+	}
+	r.Errors = append(r.Errors, importErr)
+}
@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DeletePolicy определяет, что происходит со связями фильм-актер при удалении
+type DeletePolicy string
+
+const (
+	// DeleteRestrict запрещает удалять актера, пока он есть в составе хотя бы одного фильма
+	DeleteRestrict DeletePolicy = "restrict"
+	// DeleteCascade удаляет запись вместе со всеми ее связями
+	DeleteCascade DeletePolicy = "cascade"
+	// DeleteSoft только помечает запись удаленной, связи остаются, но не видны при чтении
+	DeleteSoft DeletePolicy = "soft"
+)
+
+// ReferencedError - актера нельзя удалить, пока на него ссылаются фильмы
+type ReferencedError struct {
+	Movies []Movie
+}
+
+func (e *ReferencedError) Error() string {
+	ids := make([]string, 0, len(e.Movies))
+	for _, m := range e.Movies {
+		ids = append(ids, strconv.Itoa(m.ID))
+	}
+
+	return fmt.Sprintf("%s by movies: %s", ErrReferenced, strings.Join(ids, ", "))
+}
+
+func (e *ReferencedError) Unwrap() error {
+	return ErrReferenced
+}
+
+// Trash - мягко удаленные записи, которые еще можно восстановить
+type Trash struct {
+	Actors []Actor `json:"actors"`
+	Movies []Movie `json:"movies"`
+}
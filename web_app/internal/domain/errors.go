@@ -1,6 +1,9 @@
 package domain
 
-import "errors"
+import (
+	"errors"
+	"strings"
+)
 
 var (
 	ErrFieldsRequired = errors.New("all required fields must have values")
@@ -8,4 +11,34 @@ var (
 	ErrNotFound       = errors.New("not found")
 	ErrIDRequired     = errors.New("id required")
 	ErrNotExists      = errors.New("doesn't exists")
+	ErrValidation     = errors.New("invalid field values")
+	ErrReferenced     = errors.New("still referenced")
+	// ErrVersionMismatch - запись изменилась с тех пор, как клиент ее прочитал
+	ErrVersionMismatch = errors.New("version mismatch")
 )
+
+// FieldError - ошибка в конкретном поле сущности, Field совпадает с json-именем поля
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError перечисляет все неверные поля сразу. Err - одна из ошибок
+// выше, по ней errors.Is определяет вид ошибки.
+type ValidationError struct {
+	Err    error
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		messages = append(messages, f.Field+": "+f.Message)
+	}
+
+	return e.Err.Error() + ": " + strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
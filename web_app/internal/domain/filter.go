@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// TextFilter - условия на строковое поле с учетом регистра. Значения одного условия
+// объединяются через или, разные условия - через и: Eq ["a", "b"] и Prefix ["x"]
+// значит "(a или b) и начинается с x". Пустой фильтр подходит под любое значение.
+type TextFilter struct {
+	Eq       []string
+	Prefix   []string
+	Contains []string
+}
+
+func (f TextFilter) Match(value string) bool {
+	return matchAny(f.Eq, func(v string) bool { return value == v }) &&
+		matchAny(f.Prefix, func(v string) bool { return strings.HasPrefix(value, v) }) &&
+		matchAny(f.Contains, func(v string) bool { return strings.Contains(value, v) })
+}
+
+func matchAny(values []string, match func(string) bool) bool {
+	return len(values) == 0 || slices.ContainsFunc(values, match)
+}
+
+// Range - границы значения, nil - граница не задана
+type Range[T any] struct {
+	Gt  *T
+	Gte *T
+	Lt  *T
+	Lte *T
+}
+
+func (r Range[T]) Match(value T, compare func(a, b T) int) bool {
+	return (r.Gt == nil || compare(value, *r.Gt) > 0) &&
+		(r.Gte == nil || compare(value, *r.Gte) >= 0) &&
+		(r.Lt == nil || compare(value, *r.Lt) < 0) &&
+		(r.Lte == nil || compare(value, *r.Lte) <= 0)
+}
+
+// normalizeCountries приводит коды стран в фильтре к тому виду, в котором они хранятся
+func (f *TextFilter) normalizeCountries(param string) error {
+	// значения могут принадлежать вызывающему, меняем копию
+	f.Eq = slices.Clone(f.Eq)
+	for i, value := range f.Eq {
+		country, err := normalizeCountry(value)
+		if err != nil {
+			return fmt.Errorf("%w: %s: unknown country code %q", ErrInvalidFilter, param, value)
+		}
+		f.Eq[i] = country
+	}
+
+	return nil
+}
+
+// Normalize проверяет фильтр и приводит коды стран и пол к виду, в котором они хранятся,
+// иначе "rus" и "Male" ничего бы не нашли
+func (q *ActorsQuery) Normalize() error {
+	q.Gender.Eq = slices.Clone(q.Gender.Eq)
+	for i, gender := range q.Gender.Eq {
+		q.Gender.Eq[i] = strings.ToLower(strings.TrimSpace(gender))
+		switch q.Gender.Eq[i] {
+		case GenderMale, GenderFemale, GenderOther:
+		default:
+			return fmt.Errorf("%w: gender must be one of male, female, other", ErrInvalidFilter)
+		}
+	}
+
+	return q.Country.normalizeCountries("country")
+}
+
+func (q *MoviesQuery) Normalize() error {
+	return q.Country.normalizeCountries("country")
+}
@@ -9,6 +9,15 @@ type Movie struct {
 	Country     string    `json:"country"`
 	Genre       string    `json:"genre"`
 	Rating      int8      `json:"rating"`
+	// Score - оценка зрителей по одобренным отзывам. Меняется только через отзывы,
+	// значения из запросов на создание и изменение фильма не принимаются.
+	Score MovieScore `json:"score"`
+	// Version растет с каждым изменением записи, по нему работает If-Match
+	Version int `json:"version"`
+	// UpdatedAt - время последнего изменения, по нему работает If-Modified-Since
+	UpdatedAt time.Time `json:"updated_at"`
+	// DeletedAt заполнен только у мягко удаленных записей
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 type MovieUpdate struct {
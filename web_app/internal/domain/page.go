@@ -0,0 +1,114 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 500
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidPage   = errors.New("invalid pagination parameters")
+)
+
+// Page - конверт для списочных ответов
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      int    `json:"total"`
+}
+
+// Cursor указывает на последнюю отданную запись. В нем хранятся значения
+// ключей сортировки, а не номер строки, поэтому следующая страница
+// не съезжает, если между запросами записи добавили или удалили.
+type Cursor[T any] struct {
+	// Sort - сортировка, для которой выдан курсор, в виде Sort.String
+	Sort string `json:"s"`
+	// Last - последняя запись страницы; после DecodeCursor в ней заполнены
+	// только id и поля, по которым идет сортировка
+	Last T `json:"l"`
+}
+
+// sortPather - запись, которая знает, где в ее json лежит значение поля сортировки
+type sortPather interface {
+	SortPath(field string) []string
+}
+
+// Encode кладет в курсор только id и значения ключей сортировки последней записи:
+// с остальными полями курсор рос бы вместе с записью и раскрывал бы то, по чему
+// не сортировали
+func (c Cursor[T]) Encode() string {
+	// маршалинг доменных структур не может вернуть ошибку
+	data, _ := json.Marshal(struct {
+		Sort string         `json:"s"`
+		Last map[string]any `json:"l"`
+	}{c.Sort, c.keys()})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func (c Cursor[T]) keys() map[string]any {
+	data, _ := json.Marshal(c.Last)
+	// числа остаются текстом, как в записи, чтобы не потерять точность
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var record map[string]any
+	_ = decoder.Decode(&record)
+
+	keys := map[string]any{"id": record["id"]}
+	pather, ok := any(c.Last).(sortPather)
+	if !ok || c.Sort == "" {
+		return keys
+	}
+	for _, field := range strings.Split(c.Sort, ",") {
+		copyPath(keys, record, pather.SortPath(strings.TrimPrefix(field, "-")))
+	}
+
+	return keys
+}
+
+// copyPath копирует из src в dst значение по пути из имен полей json
+func copyPath(dst, src map[string]any, path []string) {
+	if len(path) == 0 {
+		return
+	}
+	value, ok := src[path[0]]
+	if !ok {
+		return
+	}
+	if len(path) == 1 {
+		dst[path[0]] = value
+		return
+	}
+
+	nested, ok := value.(map[string]any)
+	if !ok {
+		return
+	}
+	next, ok := dst[path[0]].(map[string]any)
+	if !ok {
+		next = make(map[string]any)
+		dst[path[0]] = next
+	}
+	copyPath(next, nested, path[1:])
+}
+
+func DecodeCursor[T any](token string) (Cursor[T], error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return Cursor[T]{}, ErrInvalidCursor
+	}
+
+	var c Cursor[T]
+	if err = json.Unmarshal(data, &c); err != nil {
+		return Cursor[T]{}, ErrInvalidCursor
+	}
+
+	return c, nil
+}
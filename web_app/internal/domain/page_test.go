@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestCursor - в курсоре только id и значения активных ключей сортировки
+func TestCursor(t *testing.T) {
+	movie := Movie{
+		ID:          7,
+		Name:        "Heat",
+		ReleaseDate: time.Date(1995, 12, 15, 0, 0, 0, 0, time.UTC),
+		Country:     "US",
+		Genre:       "crime",
+		Rating:      5,
+		Score:       MovieScore{Average: 4.5, Count: 2, Histogram: [MaxRating]int{0, 0, 0, 1, 1}},
+		Version:     3,
+		UpdatedAt:   time.Now().UTC(),
+	}
+
+	tests := []struct {
+		sort string
+		want Movie
+	}{
+		{sort: "-score,name", want: Movie{ID: 7, Name: "Heat", Score: MovieScore{Average: 4.5}}},
+		{sort: "release_date", want: Movie{ID: 7, ReleaseDate: movie.ReleaseDate}},
+		{sort: "", want: Movie{ID: 7}},
+	}
+	for _, tt := range tests {
+		token := Cursor[Movie]{Sort: tt.sort, Last: movie}.Encode()
+		cursor, err := DecodeCursor[Movie](token)
+		if err != nil {
+			t.Fatalf("DecodeCursor(%q): %v", tt.sort, err)
+		}
+		if cursor.Sort != tt.sort || !reflect.DeepEqual(cursor.Last, tt.want) {
+			t.Errorf("cursor for sort %q = %+v, want %+v", tt.sort, cursor, tt.want)
+		}
+	}
+
+	// поля актера в json называются не так, как поля сортировки
+	actor := Actor{ID: 3, Name: "Al Pacino", BirthYear: 1940, CountryOfBirth: "US", Gender: GenderMale, Version: 2}
+	cursor, err := DecodeCursor[Actor](Cursor[Actor]{Sort: "country,-birth_year", Last: actor}.Encode())
+	if err != nil {
+		t.Fatalf("DecodeCursor: %v", err)
+	}
+	if want := (Actor{ID: 3, BirthYear: 1940, CountryOfBirth: "US"}); cursor.Last != want {
+		t.Errorf("actor cursor = %+v, want %+v", cursor.Last, want)
+	}
+
+	// курсоры, выданные до сокращения, с записью целиком, по-прежнему принимаются
+	data, _ := json.Marshal(struct {
+		Sort string `json:"s"`
+		Last Actor  `json:"l"`
+	}{"name", actor})
+	if cursor, err = DecodeCursor[Actor](base64.RawURLEncoding.EncodeToString(data)); err != nil || cursor.Last.Name != actor.Name {
+		t.Errorf("old cursor = %+v, %v, want the full record", cursor, err)
+	}
+}
@@ -0,0 +1,32 @@
+package domain
+
+import "time"
+
+// ActorsQuery - фильтр, сортировка и страница списка актеров. Запись подходит,
+// если выполнены все заданные условия фильтра. After и Offset взаимоисключающие:
+// страница начинается либо после записи из курсора, либо после Offset записей.
+type ActorsQuery struct {
+	Name      TextFilter
+	Country   TextFilter
+	Gender    TextFilter
+	BirthYear Range[int]
+	Sort      Sort
+	Limit     int
+	Offset    int
+	After     *Cursor[Actor]
+}
+
+// MoviesQuery - то же для фильмов. Если задан ActorID, в выборку попадают
+// только фильмы с этим актером, остальные фильтры применяются поверх.
+type MoviesQuery struct {
+	ActorID     int
+	Name        TextFilter
+	Genre       TextFilter
+	Country     TextFilter
+	ReleaseDate Range[time.Time]
+	Rating      Range[int]
+	Sort        Sort
+	Limit       int
+	Offset      int
+	After       *Cursor[Movie]
+}
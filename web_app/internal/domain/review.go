@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// MaxReviewLength ограничивает длину текста отзыва
+const MaxReviewLength = 5000
+
+// статусы отзыва: оценка без текста публикуется сразу, отзыв с текстом ждет модерации
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
+
+// Review - оценка фильма от 1 до 5 и, возможно, текст. У автора один отзыв на фильм,
+// новый заменяет прежний. В оценку фильма идут только одобренные отзывы.
+type Review struct {
+	ID        int       `json:"id"`
+	MovieID   int       `json:"movie_id"`
+	Author    string    `json:"author"`
+	Rating    int8      `json:"rating"`
+	Text      string    `json:"text,omitempty"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ReviewModeration - тело PATCH /movies/{id}/reviews/{review_id}
+type ReviewModeration struct {
+	Status string `json:"status"`
+}
+
+// ReviewsQuery - страница отзывов, новые идут первыми. Пустые MovieID и Status -
+// отзывы на все фильмы и в любом статусе.
+type ReviewsQuery struct {
+	MovieID int
+	Status  string
+	Limit   int
+	Offset  int
+	After   *Cursor[Review]
+}
+
+// ReviewSort - порядок отзывов, в нем же выдается курсор
+const ReviewSort = "-id"
+
+// Validate проверяет отзыв и убирает пробелы вокруг текста
+func (r *Review) Validate() error {
+	var v validator
+
+	if v.required("rating", r.Rating != 0) && (r.Rating < MinRating || r.Rating > MaxRating) {
+		v.add("rating", "must be between 1 and 5")
+	}
+
+	r.Text = strings.TrimSpace(r.Text)
+	if len([]rune(r.Text)) > MaxReviewLength {
+		v.add("text", "must be at most 5000 characters long")
+	}
+
+	return v.err()
+}
+
+func (m ReviewModeration) Validate() error {
+	var v validator
+
+	if v.required("status", m.Status != "") && m.Status != ReviewApproved && m.Status != ReviewRejected {
+		v.add("status", "must be one of approved, rejected")
+	}
+
+	return v.err()
+}
+
+// MovieScore - сводка одобренных оценок фильма
+type MovieScore struct {
+	// Average - среднее с точностью до сотых, 0 - оценок нет
+	Average float64 `json:"average"`
+	Count   int     `json:"count"`
+	// Histogram - число оценок каждого значения, Histogram[0] - число единиц
+	Histogram [MaxRating]int `json:"histogram"`
+}
+
+// Add добавляет delta оценок rating (отрицательная delta убирает их) и пересчитывает
+// среднее. Хранилища считают оценку только через Add, поэтому она у них одинаковая.
+func (s *MovieScore) Add(rating int8, delta int) {
+	s.Histogram[rating-1] += delta
+
+	s.Count, s.Average = 0, 0
+	sum := 0
+	for i, n := range s.Histogram {
+		s.Count += n
+		sum += (i + 1) * n
+	}
+	if s.Count > 0 {
+		s.Average = math.Round(float64(sum)/float64(s.Count)*100) / 100
+	}
+}
@@ -0,0 +1,191 @@
+package domain
+
+import (
+	"cmp"
+	"errors"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+var ErrInvalidSearch = errors.New("invalid search")
+
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+// что ищется
+const (
+	SearchActors = "actor"
+	SearchMovies = "movie"
+)
+
+// SearchQuery - запрос GET /search. Terms - слова Text после NormalizeSearch,
+// по ним хранилища отбирают кандидатов.
+type SearchQuery struct {
+	Text  string
+	Terms []string
+	// Types - SearchActors и/или SearchMovies, пустой - все
+	Types []string
+	Limit int
+}
+
+func (q SearchQuery) Includes(kind string) bool {
+	return len(q.Types) == 0 || slices.Contains(q.Types, kind)
+}
+
+// SearchHit - найденная запись, заполнено одно из полей Actor и Movie
+type SearchHit struct {
+	Type  string  `json:"type"`
+	Score float64 `json:"score"`
+	Actor *Actor  `json:"actor,omitempty"`
+	Movie *Movie  `json:"movie,omitempty"`
+}
+
+func (h SearchHit) Name() string {
+	if h.Actor != nil {
+		return h.Actor.Name
+	}
+
+	return h.Movie.Name
+}
+
+func (h SearchHit) ID() int {
+	if h.Actor != nil {
+		return h.Actor.ID
+	}
+
+	return h.Movie.ID
+}
+
+// CompareSearchHits - сначала лучшие совпадения, при равной оценке порядок все равно
+// однозначен, чтобы одинаковые запросы давали одинаковый ответ
+func CompareSearchHits(a, b SearchHit) int {
+	if c := cmp.Compare(b.Score, a.Score); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.Name(), b.Name()); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.Type, b.Type); c != 0 {
+		return c
+	}
+
+	return cmp.Compare(a.ID(), b.ID())
+}
+
+// searchFolding раскладывает символы на базовые и диакритику и выбрасывает ее:
+// "Dostoëvsky" и "Достоевский" с "ё" ищутся без точек. Совместимые формы (лигатуры,
+// полноширинные символы) тоже приводятся к обычным.
+var searchFolding = transform.Chain(norm.NFKD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+
+// NormalizeSearch разбивает текст на слова для поиска: без регистра, диакритики
+// и знаков препинания
+func NormalizeSearch(text string) []string {
+	folded, _, err := transform.String(searchFolding, text)
+	if err != nil {
+		folded = text
+	}
+
+	return strings.FieldsFunc(strings.ToLower(folded), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// SearchScore оценивает, насколько название подходит под слова запроса: 0 - не подходит,
+// 1 - совпадает целиком. Каждое слово запроса должно найтись в названии целиком,
+// началом слова или с опечаткой; короткие названия при прочих равных выше длинных.
+func SearchScore(terms []string, name string) float64 {
+	words := NormalizeSearch(name)
+	if len(terms) == 0 || len(words) == 0 {
+		return 0
+	}
+
+	total := 0.0
+	for _, term := range terms {
+		best := 0.0
+		for _, word := range words {
+			best = max(best, termScore(term, word))
+		}
+		if best == 0 {
+			return 0
+		}
+		total += best
+	}
+
+	coverage := float64(min(len(terms), len(words))) / float64(len(words))
+	return total / float64(len(terms)) * (0.9 + 0.1*coverage)
+}
+
+// termScore сравнивает слово запроса со словом названия
+func termScore(term, word string) float64 {
+	if term == word {
+		return 1
+	}
+
+	t, w := []rune(term), []rune(word)
+	if len(t) < len(w) && string(w[:len(t)]) == term {
+		return 0.8
+	}
+
+	allowed := Typos(len(t))
+	if allowed == 0 {
+		return 0
+	}
+	if d := editDistance(t, w); d <= allowed {
+		return 0.7 - 0.1*float64(d)
+	}
+	// опечатка в начале слова: "dostoevks" для "dostoevsky"
+	if len(t) < len(w) {
+		if d := editDistance(t, w[:len(t)]); d <= allowed {
+			return 0.5 - 0.1*float64(d)
+		}
+	}
+
+	return 0
+}
+
+// Typos - сколько опечаток допускается в слове запроса длиной n рун:
+// в коротких словах опечатка слишком легко превращает одно слово в другое
+func Typos(n int) int {
+	switch {
+	case n <= 3:
+		return 0
+	case n <= 7:
+		return 1
+	}
+
+	return 2
+}
+
+// editDistance - расстояние Дамерау-Левенштейна: вставка, удаление, замена
+// и перестановка соседних символов считаются одной правкой
+func editDistance(a, b []rune) int {
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+
+	return prev[len(b)]
+}
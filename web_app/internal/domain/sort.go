@@ -0,0 +1,229 @@
+package domain
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+	"slices"
+	"strings"
+	"sync"
+)
+
+var ErrInvalidSort = errors.New("invalid sort")
+
+// поля, по которым можно упорядочить актеров
+const (
+	ActorSortName      = "name"
+	ActorSortCountry   = "country"
+	ActorSortBirthYear = "birth_year"
+)
+
+// поля, по которым можно упорядочить фильмы
+const (
+	MovieSortName        = "name"
+	MovieSortGenre       = "genre"
+	MovieSortReleaseDate = "release_date"
+	MovieSortRating      = "rating"
+	MovieSortCountry     = "country"
+	MovieSortScore       = "score"
+)
+
+var (
+	ActorSortFields = []string{ActorSortName, ActorSortCountry, ActorSortBirthYear}
+	MovieSortFields = []string{MovieSortName, MovieSortGenre, MovieSortReleaseDate, MovieSortRating, MovieSortCountry, MovieSortScore}
+)
+
+// SortKey - поле сортировки и направление
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// Sort - ключи сортировки по старшинству. После всех ключей записи сравниваются
+// по id в направлении последнего ключа, поэтому порядок однозначен при любых совпадениях.
+type Sort []SortKey
+
+// ParseSort разбирает "-rating,name": поля через запятую, минус перед полем - по убыванию
+func ParseSort(value string, allowed []string) (Sort, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var sort Sort
+	for _, part := range strings.Split(value, ",") {
+		key := SortKey{Field: strings.TrimSpace(part)}
+		if rest, ok := strings.CutPrefix(key.Field, "-"); ok {
+			key.Field, key.Desc = rest, true
+		}
+
+		if !slices.Contains(allowed, key.Field) {
+			return nil, fmt.Errorf("%w: unknown sort field %q, allowed: %s", ErrInvalidSort, key.Field, strings.Join(allowed, ", "))
+		}
+		if slices.ContainsFunc(sort, func(k SortKey) bool { return k.Field == key.Field }) {
+			return nil, fmt.Errorf("%w: sort field %q is given twice", ErrInvalidSort, key.Field)
+		}
+		sort = append(sort, key)
+	}
+
+	return sort, nil
+}
+
+// String - обратная запись в виде "-rating,name", ее хранит курсор
+func (s Sort) String() string {
+	parts := make([]string, len(s))
+	for i, key := range s {
+		parts[i] = key.Field
+		if key.Desc {
+			parts[i] = "-" + key.Field
+		}
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// IDDesc - направление, в котором сравниваются id после всех ключей
+func (s Sort) IDDesc() bool {
+	return len(s) > 0 && s[len(s)-1].Desc
+}
+
+// Compare сравнивает две записи: compare сравнивает их по одному полю,
+// при равенстве всех полей порядок определяет id
+func (s Sort) Compare(compare func(field string) int, idA, idB int) int {
+	for _, key := range s {
+		c := compare(key.Field)
+		if key.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+
+	if s.IDDesc() {
+		return cmp.Compare(idB, idA)
+	}
+
+	return cmp.Compare(idA, idB)
+}
+
+func (a Actor) CompareField(b Actor, field string) int {
+	switch field {
+	case ActorSortName:
+		return CompareText(a.Name, b.Name)
+	case ActorSortCountry:
+		return CompareText(a.CountryOfBirth, b.CountryOfBirth)
+	case ActorSortBirthYear:
+		return cmp.Compare(a.BirthYear, b.BirthYear)
+	}
+
+	return 0
+}
+
+func (m Movie) CompareField(b Movie, field string) int {
+	switch field {
+	case MovieSortName:
+		return CompareText(m.Name, b.Name)
+	case MovieSortGenre:
+		return CompareText(m.Genre, b.Genre)
+	case MovieSortReleaseDate:
+		return m.ReleaseDate.Compare(b.ReleaseDate)
+	case MovieSortRating:
+		return cmp.Compare(m.Rating, b.Rating)
+	case MovieSortCountry:
+		return CompareText(m.Country, b.Country)
+	case MovieSortScore:
+		return cmp.Compare(m.Score.Average, b.Score.Average)
+	}
+
+	return 0
+}
+
+// SortValue возвращает значение поля, по которому идет сортировка
+func (a Actor) SortValue(field string) any {
+	switch field {
+	case ActorSortName:
+		return a.Name
+	case ActorSortCountry:
+		return a.CountryOfBirth
+	case ActorSortBirthYear:
+		return a.BirthYear
+	}
+
+	return nil
+}
+
+// SortPath - путь к значению поля сортировки в json актера, по нему курсор хранит только нужное
+func (a Actor) SortPath(field string) []string {
+	switch field {
+	case ActorSortName:
+		return []string{"name"}
+	case ActorSortCountry:
+		return []string{"country_of_birth"}
+	case ActorSortBirthYear:
+		return []string{"birth_year"}
+	}
+
+	return nil
+}
+
+// SortPath - путь к значению поля сортировки в json фильма, по нему курсор хранит только нужное
+func (m Movie) SortPath(field string) []string {
+	switch field {
+	case MovieSortName:
+		return []string{"name"}
+	case MovieSortGenre:
+		return []string{"genre"}
+	case MovieSortReleaseDate:
+		return []string{"release_date"}
+	case MovieSortRating:
+		return []string{"rating"}
+	case MovieSortCountry:
+		return []string{"country"}
+	case MovieSortScore:
+		return []string{"score", "average"}
+	}
+
+	return nil
+}
+
+func (m Movie) SortValue(field string) any {
+	switch field {
+	case MovieSortName:
+		return m.Name
+	case MovieSortGenre:
+		return m.Genre
+	case MovieSortReleaseDate:
+		return m.ReleaseDate
+	case MovieSortRating:
+		return m.Rating
+	case MovieSortCountry:
+		return m.Country
+	case MovieSortScore:
+		return m.Score.Average
+	}
+
+	return nil
+}
+
+// collators - collate.Collator не безопасен для одновременного использования,
+// поэтому у каждой горутины свой
+var collators = sync.Pool{
+	New: func() any { return collate.New(language.Und) },
+}
+
+// CompareText сравнивает строки по правилам Unicode (корневая локаль CLDR): "Émile"
+// стоит рядом с "Emile", а не после "Zoe", строчные буквы - рядом с заглавными.
+// Строки, различные побайтово, равными не считаются: так же сравнивают детерминированные
+// collation в postgres, и курсор однозначно указывает место в списке.
+func CompareText(a, b string) int {
+	collator := collators.Get().(*collate.Collator)
+	defer collators.Put(collator)
+
+	if c := collator.CompareString(a, b); c != 0 {
+		return c
+	}
+
+	return cmp.Compare(a, b)
+}
@@ -0,0 +1,144 @@
+package domain
+
+import (
+	"golang.org/x/text/language"
+	"strings"
+	"time"
+)
+
+const (
+	MinRating = 1
+	MaxRating = 5
+	// MinBirthYear - год рождения старше этого скорее опечатка, чем реальный актер
+	MinBirthYear = 1850
+	// MaxNameLength ограничивает длину имени актера и названия фильма
+	MaxNameLength = 255
+)
+
+// FirstReleaseDate - дата первого публичного киносеанса, раньше фильм выйти не мог
+var FirstReleaseDate = time.Date(1895, time.December, 28, 0, 0, 0, 0, time.UTC)
+
+const (
+	GenderMale   = "male"
+	GenderFemale = "female"
+	GenderOther  = "other"
+)
+
+// validator копит ошибки по полям, чтобы вернуть клиенту все сразу
+type validator struct {
+	fields  []FieldError
+	missing int
+}
+
+func (v *validator) add(field, message string) {
+	v.fields = append(v.fields, FieldError{Field: field, Message: message})
+}
+
+func (v *validator) required(field string, set bool) bool {
+	if !set {
+		v.missing++
+		v.add(field, "is required")
+	}
+
+	return set
+}
+
+func (v *validator) name(field string, value string) {
+	if !v.required(field, strings.TrimSpace(value) != "") {
+		return
+	}
+	if len([]rune(value)) > MaxNameLength {
+		v.add(field, "must be at most 255 characters long")
+	}
+}
+
+func (v *validator) country(field string, value *string) {
+	if !v.required(field, *value != "") {
+		return
+	}
+
+	country, err := normalizeCountry(*value)
+	if err != nil {
+		v.add(field, "must be an ISO 3166-1 alpha-2 country code")
+		return
+	}
+	*value = country
+}
+
+// err возвращает ErrFieldsRequired, если не хватает только обязательных полей,
+// и ErrValidation, если среди ошибок есть неверные значения
+func (v *validator) err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+
+	err := ErrValidation
+	if v.missing == len(v.fields) {
+		err = ErrFieldsRequired
+	}
+
+	return &ValidationError{Err: err, Fields: v.fields}
+}
+
+// normalizeCountry принимает код страны в любом регистре и возвращает его в виде "RU"
+func normalizeCountry(value string) (string, error) {
+	region, err := language.ParseRegion(strings.TrimSpace(value))
+	if err != nil || !region.IsCountry() {
+		return "", ErrValidation
+	}
+
+	// ParseRegion понимает и трехбуквенные и цифровые коды, храним всегда двухбуквенный
+	return region.String(), nil
+}
+
+// Validate проверяет актера и приводит к единому виду код страны и пол.
+// now передается явно, чтобы проверка не зависела от часов в тестах.
+func (a *Actor) Validate(now time.Time) error {
+	var v validator
+
+	v.name("name", a.Name)
+
+	if v.required("birth_year", a.BirthYear != 0) &&
+		(a.BirthYear < MinBirthYear || a.BirthYear > now.Year()) {
+		v.add("birth_year", "must be between 1850 and the current year")
+	}
+
+	v.country("country_of_birth", &a.CountryOfBirth)
+
+	if v.required("gender", a.Gender != "") {
+		a.Gender = strings.ToLower(strings.TrimSpace(a.Gender))
+		switch a.Gender {
+		case GenderMale, GenderFemale, GenderOther:
+		default:
+			v.add("gender", "must be one of male, female, other")
+		}
+	}
+
+	return v.err()
+}
+
+// Validate проверяет фильм и приводит к единому виду код страны
+func (m *Movie) Validate(now time.Time) error {
+	var v validator
+
+	v.name("name", m.Name)
+
+	if v.required("release_date", !m.ReleaseDate.IsZero()) {
+		if m.ReleaseDate.Before(FirstReleaseDate) {
+			v.add("release_date", "must not be earlier than 1895-12-28")
+		}
+		if m.ReleaseDate.After(now) {
+			v.add("release_date", "must not be in the future")
+		}
+	}
+
+	v.country("country", &m.Country)
+
+	v.required("genre", strings.TrimSpace(m.Genre) != "")
+
+	if v.required("rating", m.Rating != 0) && (m.Rating < MinRating || m.Rating > MaxRating) {
+		v.add("rating", "must be between 1 and 5")
+	}
+
+	return v.err()
+}
@@ -0,0 +1,176 @@
+package domain
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+var validationNow = time.Date(2024, time.June, 15, 12, 0, 0, 0, time.UTC)
+
+// fieldsOf - поля из ValidationError, nil если ошибки нет
+func fieldsOf(t *testing.T, err error) []string {
+	t.Helper()
+
+	if err == nil {
+		return nil
+	}
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("error %v is not a ValidationError", err)
+	}
+
+	var fields []string
+	for _, field := range validationErr.Fields {
+		fields = append(fields, field.Field)
+	}
+
+	return fields
+}
+
+func TestActor_Validate(t *testing.T) {
+	valid := Actor{Name: "Al Pacino", BirthYear: 1940, CountryOfBirth: "US", Gender: GenderMale}
+
+	tests := []struct {
+		name   string
+		change func(a *Actor)
+		// wantFields - поля с ошибками, пусто - актер проходит проверку
+		wantFields []string
+		wantErr    error
+		check      func(t *testing.T, a Actor)
+	}{
+		{name: "valid"},
+		{name: "birth year 1849", change: func(a *Actor) { a.BirthYear = 1849 }, wantFields: []string{"birth_year"}, wantErr: ErrValidation},
+		{name: "birth year 1850", change: func(a *Actor) { a.BirthYear = 1850 }},
+		{name: "birth year current", change: func(a *Actor) { a.BirthYear = 2024 }},
+		{name: "birth year next", change: func(a *Actor) { a.BirthYear = 2025 }, wantFields: []string{"birth_year"}, wantErr: ErrValidation},
+		{
+			name:   "alpha-3 country",
+			change: func(a *Actor) { a.CountryOfBirth = "rus" },
+			check: func(t *testing.T, a Actor) {
+				if a.CountryOfBirth != "RU" {
+					t.Errorf("country = %q, want RU", a.CountryOfBirth)
+				}
+			},
+		},
+		{name: "unknown country", change: func(a *Actor) { a.CountryOfBirth = "XX" }, wantFields: []string{"country_of_birth"}, wantErr: ErrValidation},
+		{
+			name:   "gender in other case",
+			change: func(a *Actor) { a.Gender = " Female " },
+			check: func(t *testing.T, a Actor) {
+				if a.Gender != GenderFemale {
+					t.Errorf("gender = %q, want female", a.Gender)
+				}
+			},
+		},
+		{name: "unknown gender", change: func(a *Actor) { a.Gender = "robot" }, wantFields: []string{"gender"}, wantErr: ErrValidation},
+		{name: "long name", change: func(a *Actor) { a.Name = strings.Repeat("я", MaxNameLength+1) }, wantFields: []string{"name"}, wantErr: ErrValidation},
+		{
+			name:       "only missing fields",
+			change:     func(a *Actor) { *a = Actor{} },
+			wantFields: []string{"name", "birth_year", "country_of_birth", "gender"},
+			wantErr:    ErrFieldsRequired,
+		},
+		{
+			name:       "missing and invalid fields",
+			change:     func(a *Actor) { a.Name = ""; a.Gender = "robot" },
+			wantFields: []string{"name", "gender"},
+			wantErr:    ErrValidation,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actor := valid
+			if tt.change != nil {
+				tt.change(&actor)
+			}
+
+			err := actor.Validate(validationNow)
+			if fields := fieldsOf(t, err); !slices.Equal(fields, tt.wantFields) {
+				t.Errorf("Validate fields = %q, want %q", fields, tt.wantFields)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate = %v, want %v", err, tt.wantErr)
+			}
+			if tt.check != nil {
+				tt.check(t, actor)
+			}
+		})
+	}
+}
+
+func TestMovie_Validate(t *testing.T) {
+	valid := Movie{Name: "Heat", ReleaseDate: time.Date(1995, time.December, 15, 0, 0, 0, 0, time.UTC), Country: "US", Genre: "crime", Rating: 5}
+
+	tests := []struct {
+		name       string
+		change     func(m *Movie)
+		wantFields []string
+		wantErr    error
+	}{
+		{name: "valid"},
+		{name: "rating 0 is missing", change: func(m *Movie) { m.Rating = 0 }, wantFields: []string{"rating"}, wantErr: ErrFieldsRequired},
+		{name: "rating 1", change: func(m *Movie) { m.Rating = 1 }},
+		{name: "rating 5", change: func(m *Movie) { m.Rating = 5 }},
+		{name: "rating 6", change: func(m *Movie) { m.Rating = 6 }, wantFields: []string{"rating"}, wantErr: ErrValidation},
+		{name: "negative rating", change: func(m *Movie) { m.Rating = -1 }, wantFields: []string{"rating"}, wantErr: ErrValidation},
+		{name: "before the first screening", change: func(m *Movie) { m.ReleaseDate = FirstReleaseDate.AddDate(0, 0, -1) }, wantFields: []string{"release_date"}, wantErr: ErrValidation},
+		{name: "first screening", change: func(m *Movie) { m.ReleaseDate = FirstReleaseDate }},
+		{name: "now", change: func(m *Movie) { m.ReleaseDate = validationNow }},
+		{name: "future", change: func(m *Movie) { m.ReleaseDate = validationNow.Add(time.Second) }, wantFields: []string{"release_date"}, wantErr: ErrValidation},
+		{name: "blank genre", change: func(m *Movie) { m.Genre = "  " }, wantFields: []string{"genre"}, wantErr: ErrFieldsRequired},
+		{name: "numeric country", change: func(m *Movie) { m.Country = "999" }, wantFields: []string{"country"}, wantErr: ErrValidation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			movie := valid
+			if tt.change != nil {
+				tt.change(&movie)
+			}
+
+			err := movie.Validate(validationNow)
+			if fields := fieldsOf(t, err); !slices.Equal(fields, tt.wantFields) {
+				t.Errorf("Validate fields = %q, want %q", fields, tt.wantFields)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNormalizeCountry(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "RU", want: "RU"},
+		{value: "ru", want: "RU"},
+		{value: " us ", want: "US"},
+		{value: "RUS", want: "RU"},
+		{value: "deu", want: "DE"},
+		{value: "643", want: "RU"},
+		{value: "", wantErr: true},
+		{value: "XX", wantErr: true},
+		{value: "Russia", wantErr: true},
+		// 150 - Европа: регион, но не страна
+		{value: "150", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := normalizeCountry(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("normalizeCountry(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("normalizeCountry(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
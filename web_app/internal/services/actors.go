@@ -2,54 +2,73 @@ package services
 
 import (
 	"arch-demo/internal/domain"
+	"context"
 	"errors"
 	"fmt"
+	"time"
 )
 
 type ActorsRepository interface {
-	InsertActor(actor domain.Actor) (domain.Actor, error)
-	IsActorExists(actor domain.Actor) (bool, error)
-	GetActorByID(id int) (domain.Actor, error)
-	DeleteActor(id int) error
-	UpdateActor(actor domain.Actor) error
-	GetAllActors() ([]domain.Actor, error)
-	SortAndOrderByActor(sortBy, orderBy string, actors []domain.Actor) []domain.Actor
-	FilterActors(nameQuery, countryOfBirthQuery string) ([]domain.Actor, error)
+	UnitOfWork
+	InsertActor(ctx context.Context, actor domain.Actor) (domain.Actor, error)
+	// IsActorExists сообщает, есть ли неудаленный актер с тем же именем
+	IsActorExists(ctx context.Context, actor domain.Actor) (bool, error)
+	GetActorByID(ctx context.Context, id int) (domain.Actor, error)
+	// DeleteActor ничего не делает, если записи нет или она уже удалена. Если version не 0,
+	// а версия записи другая, возвращает ErrVersionMismatch.
+	DeleteActor(ctx context.Context, id, version int, policy domain.DeletePolicy) error
+	// RestoreActor снимает пометку об удалении, для записи не из корзины возвращает ErrNotFound
+	RestoreActor(ctx context.Context, id int) (domain.Actor, error)
+	// UpdateActor возвращает сохраненную запись с новыми версией и временем изменения;
+	// если версия в хранилище уже не actor.Version, возвращает ErrVersionMismatch
+	UpdateActor(ctx context.Context, actor domain.Actor) (domain.Actor, error)
+	// ListActors возвращает страницу и общее число записей, подходящих под фильтр
+	ListActors(ctx context.Context, query domain.ActorsQuery) ([]domain.Actor, int, error)
 }
 
 type ActorsService struct {
-	Storage ActorsRepository
+	Storage      ActorsRepository
+	DeletePolicy domain.DeletePolicy
+	Audit        AuditLog
 }
 
-func NewActorService(storage ActorsRepository) ActorsService {
+func NewActorService(storage ActorsRepository, deletePolicy domain.DeletePolicy, audit AuditLog) ActorsService {
 	return ActorsService{
-		Storage: storage,
+		Storage:      storage,
+		DeletePolicy: deletePolicy,
+		Audit:        audit,
 	}
 }
 
-func (s ActorsService) Create(actor domain.Actor) (domain.Actor, error) {
+func (s ActorsService) Create(ctx context.Context, actor domain.Actor) (domain.Actor, error) {
 	// входящие параметры необходимо валидировать
-	if actor.Name == "" || actor.Gender == "" || actor.BirthYear == 0 || actor.CountryOfBirth == "" {
-		return domain.Actor{}, domain.ErrFieldsRequired
+	if err := actor.Validate(time.Now()); err != nil {
+		return domain.Actor{}, err
 	}
+	// пометку об удалении ставит только хранилище
+	actor.DeletedAt = nil
 
-	isActorExist, err := s.Storage.IsActorExists(actor)
+	isActorExist, err := s.Storage.IsActorExists(ctx, actor)
+	if err != nil {
+		return domain.Actor{}, fmt.Errorf("failed to check actor, unexpected error: %w", err)
+	}
 	if isActorExist {
-		return domain.Actor{}, err
+		return domain.Actor{}, fmt.Errorf("actor name: %q, err: %w", actor.Name, domain.ErrExists)
 	}
 
-	newActor, err := s.Storage.InsertActor(actor)
+	newActor, err := s.Storage.InsertActor(ctx, actor)
 	if err != nil {
 		return domain.Actor{}, err
 	}
 
+	s.Audit.Record(ctx, domain.AuditActor, newActor.ID, domain.AuditCreate, nil, newActor)
 	return newActor, nil
 }
 
-func (s ActorsService) Get(id int) (domain.Actor, error) {
-	actor, err := s.Storage.GetActorByID(id)
+func (s ActorsService) Get(ctx context.Context, id int) (domain.Actor, error) {
+	actor, err := s.Storage.GetActorByID(ctx, id)
 	if errors.Is(err, domain.ErrNotFound) {
-		return domain.Actor{}, err
+		return domain.Actor{}, fmt.Errorf("actor id: %d, err: %w", id, err)
 	}
 
 	if err != nil {
@@ -59,11 +78,17 @@ func (s ActorsService) Get(id int) (domain.Actor, error) {
 	return actor, nil
 }
 
-func (s ActorsService) Update(id int, actorUpdate domain.ActorUpdate) (domain.Actor, error) {
-	actor, err := s.Storage.GetActorByID(id)
-	if errors.Is(err, domain.ErrNotFound) {
+// Update с version, отличным от 0, применяет изменения, только если запись все еще этой версии.
+// Даже без version запись не перетрет изменения, сделанные после ее чтения здесь.
+func (s ActorsService) Update(ctx context.Context, id, version int, actorUpdate domain.ActorUpdate) (domain.Actor, error) {
+	actor, err := s.Storage.GetActorByID(ctx, id)
+	if err != nil {
 		return domain.Actor{}, err
 	}
+	if version != 0 && actor.Version != version {
+		return domain.Actor{}, fmt.Errorf("actor id: %d, version: %d, expected: %d, err: %w", id, actor.Version, version, domain.ErrVersionMismatch)
+	}
+	before := actor
 
 	if actorUpdate.Name != nil {
 		actor.Name = *actorUpdate.Name
@@ -77,17 +102,52 @@ func (s ActorsService) Update(id int, actorUpdate domain.ActorUpdate) (domain.Ac
 		actor.CountryOfBirth = *actorUpdate.CountryOfBirth
 	}
 
-	if actorUpdate.Sex != nil {
-		actor.Gender = *actorUpdate.Sex
+	if gender := actorUpdate.GenderValue(); gender != nil {
+		actor.Gender = *gender
 	}
 
-	s.Storage.UpdateActor(actor)
+	// проверяем актера целиком, каким он станет после обновления
+	if err = actor.Validate(time.Now()); err != nil {
+		return domain.Actor{}, err
+	}
 
+	actor, err = s.Storage.UpdateActor(ctx, actor)
+	if err != nil {
+		return domain.Actor{}, fmt.Errorf("failed to update actor id: %d, err: %w", id, err)
+	}
+
+	s.Audit.Record(ctx, domain.AuditActor, id, domain.AuditUpdate, before, actor)
 	return actor, nil
 }
 
-func (s ActorsService) Delete(id int) error {
-	_, err := s.Storage.GetActorByID(id)
+// Batch создает и обновляет записи пакетом в одной транзакции: сохраняются либо все
+// элементы, либо ни один, и тогда *domain.BatchError объясняет, что стало с каждым
+func (s ActorsService) Batch(ctx context.Context, items []domain.ActorBatchItem) ([]domain.BatchResult[domain.Actor], error) {
+	now := time.Now()
+	return runBatch(ctx, s.Storage, batch[domain.Actor]{
+		size: len(items),
+		validate: func(i int) error {
+			// обновление можно проверить только вместе с записью, это сделает Update
+			if items[i].ID != 0 {
+				return nil
+			}
+			return items[i].Create.Validate(now)
+		},
+		apply: func(ctx context.Context, i int) (domain.Actor, string, error) {
+			item := items[i]
+			if item.ID == 0 {
+				actor, err := s.Create(ctx, item.Create)
+				return actor, domain.BatchCreated, err
+			}
+			actor, err := s.Update(ctx, item.ID, item.Version, item.Update)
+			return actor, domain.BatchUpdated, err
+		},
+	})
+}
+
+// Delete с version, отличным от 0, удаляет запись, только если она все еще этой версии
+func (s ActorsService) Delete(ctx context.Context, id, version int) error {
+	actor, err := s.Storage.GetActorByID(ctx, id)
 	if errors.Is(err, domain.ErrNotFound) {
 		return fmt.Errorf("actor id: %d, err: %w", id, err)
 	}
@@ -96,40 +156,88 @@ func (s ActorsService) Delete(id int) error {
 		return fmt.Errorf("failed to find actor, unexpected error: %w", err)
 	}
 
-	err = s.Storage.DeleteActor(id)
+	err = s.Storage.DeleteActor(ctx, id, version, s.DeletePolicy)
+	if errors.Is(err, domain.ErrVersionMismatch) {
+		return fmt.Errorf("actor id: %d, expected version: %d, err: %w", id, version, err)
+	}
 	if err != nil {
 		return err
 	}
 
+	s.Audit.Record(ctx, domain.AuditActor, id, domain.AuditDelete, actor, nil)
 	return nil
 }
 
-func (s ActorsService) List(sortBy, orderBy, nameQuery, countryOfBirthQuery string) ([]domain.Actor, error) {
-	actors, err := s.Storage.GetAllActors()
+// Restore возвращает мягко удаленную запись из корзины
+func (s ActorsService) Restore(ctx context.Context, id int) (domain.Actor, error) {
+	actor, err := s.Storage.RestoreActor(ctx, id)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.Actor{}, fmt.Errorf("actor id: %d is not in the trash, err: %w", id, err)
+	}
+
 	if err != nil {
-		return []domain.Actor{}, err
+		return domain.Actor{}, fmt.Errorf("failed to restore actor: %w", err)
 	}
-	var filteredActors []domain.Actor
 
-	if nameQuery != "" || countryOfBirthQuery != "" {
-		filteredActors, err = s.Storage.FilterActors(nameQuery, countryOfBirthQuery)
-		if err != nil {
-			return []domain.Actor{}, err
+	s.Audit.Record(ctx, domain.AuditActor, id, domain.AuditRestore, nil, actor)
+	return actor, nil
+}
+
+// History возвращает журнал изменений, он доступен и после удаления записи
+func (s ActorsService) History(ctx context.Context, id int) ([]domain.AuditEntry, error) {
+	entries, err := s.Audit.History(ctx, domain.AuditActor, id)
+	if err != nil {
+		return []domain.AuditEntry{}, fmt.Errorf("failed to read actor history: %w", err)
+	}
+
+	// пустая история у существующей записи возможна, например, если журнал появился позже нее
+	if len(entries) == 0 {
+		if _, err = s.Get(ctx, id); err != nil {
+			return []domain.AuditEntry{}, err
 		}
-	} else {
-		filteredActors = actors
 	}
 
-	switch {
-	case sortBy == "" && orderBy == "":
-		actors = s.Storage.SortAndOrderByActor("name", "asc", filteredActors)
-	case sortBy == "" && orderBy != "":
-		actors = s.Storage.SortAndOrderByActor("name", orderBy, filteredActors)
-	case sortBy != "" && orderBy == "":
-		actors = s.Storage.SortAndOrderByActor(sortBy, "asc", filteredActors)
-	default:
-		actors = s.Storage.SortAndOrderByActor(sortBy, orderBy, filteredActors)
+	return entries, nil
+}
+
+func (s ActorsService) List(ctx context.Context, query domain.ActorsQuery) (domain.Page[domain.Actor], error) {
+	if err := query.Normalize(); err != nil {
+		return domain.Page[domain.Actor]{}, err
+	}
+
+	var err error
+	if query.Sort, err = listSort(query.Sort, domain.ActorSortFields); err != nil {
+		return domain.Page[domain.Actor]{}, err
+	}
+
+	limit, err := pageLimit(query.Limit, query.Offset, query.After != nil)
+	if err != nil {
+		return domain.Page[domain.Actor]{}, err
+	}
+
+	if query.After != nil && query.After.Sort != query.Sort.String() {
+		return domain.Page[domain.Actor]{}, fmt.Errorf("%w: cursor was issued for another sort order", domain.ErrInvalidCursor)
+	}
+
+	// запрашиваем на одну запись больше, чтобы понять, есть ли следующая страница
+	query.Limit = limit + 1
+	actors, total, err := s.Storage.ListActors(ctx, query)
+	if err != nil {
+		return domain.Page[domain.Actor]{}, err
+	}
+
+	page := domain.Page[domain.Actor]{
+		Items: actors,
+		Total: total,
+	}
+
+	if len(actors) > limit {
+		page.Items = actors[:limit]
+		page.NextCursor = domain.Cursor[domain.Actor]{
+			Sort: query.Sort.String(),
+			Last: actors[limit-1],
+		}.Encode()
 	}
 
-	return actors, nil
+	return page, nil
 }
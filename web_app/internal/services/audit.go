@@ -0,0 +1,121 @@
+package services
+
+import (
+	"arch-demo/internal/domain"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+type AuditRepository interface {
+	InsertAuditEntry(ctx context.Context, entry domain.AuditEntry) error
+	// ListAuditEntries возвращает историю записи от старых изменений к новым
+	ListAuditEntries(ctx context.Context, entity string, entityID int) ([]domain.AuditEntry, error)
+}
+
+// AuditLog пишет в журнал разницу между состояниями записи до и после изменения.
+// Нулевое значение ничего не пишет и возвращает пустую историю.
+type AuditLog struct {
+	Storage AuditRepository
+}
+
+func NewAuditLog(storage AuditRepository) AuditLog {
+	return AuditLog{
+		Storage: storage,
+	}
+}
+
+// Record сохраняет изменение уже выполненной операции. Откатить ее нельзя,
+// поэтому ошибка записи в журнал не возвращается вызывающему, а пишется в лог.
+func (l AuditLog) Record(ctx context.Context, entity string, entityID int, action string, before, after any) {
+	if l.Storage == nil {
+		return
+	}
+
+	changes, err := diff(before, after)
+	if err != nil {
+		slog.Error("failed to build audit entry", "entity", entity, "id", entityID, "error", err)
+		return
+	}
+	if len(changes) == 0 {
+		return
+	}
+
+	entry := domain.AuditEntry{
+		Entity:   entity,
+		EntityID: entityID,
+		Action:   action,
+		Caller:   domain.CallerFrom(ctx),
+		At:       time.Now().UTC(),
+		Changes:  changes,
+	}
+
+	// запрос мог быть отменен сразу после изменения, запись в журнал все равно нужна
+	if err = l.Storage.InsertAuditEntry(context.WithoutCancel(ctx), entry); err != nil {
+		slog.Error("failed to write audit entry", "entity", entity, "id", entityID, "action", action, "error", err)
+	}
+}
+
+func (l AuditLog) History(ctx context.Context, entity string, entityID int) ([]domain.AuditEntry, error) {
+	if l.Storage == nil {
+		return []domain.AuditEntry{}, nil
+	}
+
+	return l.Storage.ListAuditEntries(ctx, entity, entityID)
+}
+
+// diff сравнивает json-представления before и after поле за полем,
+// nil с одной из сторон означает, что записи до или после не было
+func diff(before, after any) ([]domain.FieldChange, error) {
+	beforeFields, err := jsonFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := jsonFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(beforeFields)+len(afterFields))
+	for name := range beforeFields {
+		names[name] = true
+	}
+	for name := range afterFields {
+		names[name] = true
+	}
+
+	var changes []domain.FieldChange
+	for name := range names {
+		b, a := beforeFields[name], afterFields[name]
+		if !bytes.Equal(b, a) {
+			changes = append(changes, domain.FieldChange{Field: name, Before: b, After: a})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+
+	return changes, nil
+}
+
+func jsonFields(v any) (map[string]json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"arch-demo/internal/domain"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type AuthRepository interface {
+	// InsertUser возвращает ErrExists, если пользователь с таким именем уже есть
+	InsertUser(ctx context.Context, user domain.User) (domain.User, error)
+	GetUserByID(ctx context.Context, id int) (domain.User, error)
+	GetUserByName(ctx context.Context, name string) (domain.User, error)
+	ListUsers(ctx context.Context) ([]domain.User, error)
+	UpdateUser(ctx context.Context, user domain.User) (domain.User, error)
+	// DeleteUser удаляет пользователя вместе с его ключами, для несуществующего возвращает ErrNotFound
+	DeleteUser(ctx context.Context, id int) error
+	// InsertAPIKey возвращает ErrNotExists, если пользователя ключа нет
+	InsertAPIKey(ctx context.Context, key domain.APIKey) (domain.APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, hash string) (domain.APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]domain.APIKey, error)
+	DeleteAPIKey(ctx context.Context, id int) error
+}
+
+const (
+	// apiKeyPrefix отличает наши ключи от других секретов, например в сканерах утечек
+	apiKeyPrefix = "ak_"
+	// apiKeyShownLength - сколько начальных символов ключа видно в списке ключей
+	apiKeyShownLength = len(apiKeyPrefix) + 8
+	// BootstrapSubject - от этого имени работает ключ администратора из конфигурации
+	BootstrapSubject = "bootstrap"
+)
+
+// AuthService проверяет API-ключи и JWT и управляет пользователями и их ключами.
+// Ключ администратора из конфигурации нигде не хранится, он нужен, чтобы завести
+// первых пользователей.
+type AuthService struct {
+	Storage  AuthRepository
+	Tokens   TokenVerifier
+	AdminKey string
+}
+
+func NewAuthService(storage AuthRepository, tokens TokenVerifier, adminKey string) AuthService {
+	return AuthService{
+		Storage:  storage,
+		Tokens:   tokens,
+		AdminKey: adminKey,
+	}
+}
+
+// AuthenticateKey находит пользователя по API-ключу. Хранятся только хэши ключей,
+// поэтому утечка базы не раскрывает сами ключи.
+func (s AuthService) AuthenticateKey(ctx context.Context, secret string) (domain.Principal, error) {
+	if s.AdminKey != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(s.AdminKey)) == 1 {
+		return domain.Principal{Subject: BootstrapSubject, Role: domain.RoleAdmin}, nil
+	}
+
+	key, err := s.Storage.GetAPIKeyByHash(ctx, hashAPIKey(secret))
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.Principal{}, fmt.Errorf("%w: unknown api key", domain.ErrUnauthenticated)
+	}
+	if err != nil {
+		return domain.Principal{}, err
+	}
+
+	// ключи удаляются вместе с пользователем, но ключ мог быть найден до удаления
+	user, err := s.Storage.GetUserByID(ctx, key.UserID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.Principal{}, fmt.Errorf("%w: api key owner is deleted", domain.ErrUnauthenticated)
+	}
+	if err != nil {
+		return domain.Principal{}, err
+	}
+
+	return principalOf(user)
+}
+
+// AuthenticateToken проверяет подпись и сроки JWT. Роль берется не из токена, а у
+// пользователя из sub: так смена роли или блокировка действуют и на выданные токены.
+func (s AuthService) AuthenticateToken(ctx context.Context, token string) (domain.Principal, error) {
+	claims, err := s.Tokens.Verify(token, time.Now())
+	if err != nil {
+		return domain.Principal{}, err
+	}
+
+	user, err := s.Storage.GetUserByName(ctx, claims.Subject)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.Principal{}, fmt.Errorf("%w: unknown token subject %q", domain.ErrUnauthenticated, claims.Subject)
+	}
+	if err != nil {
+		return domain.Principal{}, err
+	}
+
+	return principalOf(user)
+}
+
+func principalOf(user domain.User) (domain.Principal, error) {
+	if user.Disabled {
+		return domain.Principal{}, fmt.Errorf("%w: user %q is disabled", domain.ErrUnauthenticated, user.Name)
+	}
+
+	return domain.Principal{Subject: user.Name, Role: user.Role}, nil
+}
+
+func (s AuthService) CreateUser(ctx context.Context, user domain.User) (domain.User, error) {
+	if err := user.Validate(); err != nil {
+		return domain.User{}, err
+	}
+	if user.Name == BootstrapSubject {
+		return domain.User{}, fmt.Errorf("user name %q is reserved, err: %w", user.Name, domain.ErrExists)
+	}
+
+	return s.Storage.InsertUser(ctx, user)
+}
+
+func (s AuthService) GetUser(ctx context.Context, id int) (domain.User, error) {
+	user, err := s.Storage.GetUserByID(ctx, id)
+	if err != nil {
+		return domain.User{}, fmt.Errorf("user id: %d, err: %w", id, err)
+	}
+
+	return user, nil
+}
+
+func (s AuthService) ListUsers(ctx context.Context) ([]domain.User, error) {
+	return s.Storage.ListUsers(ctx)
+}
+
+func (s AuthService) UpdateUser(ctx context.Context, id int, update domain.UserUpdate) (domain.User, error) {
+	if err := update.Validate(); err != nil {
+		return domain.User{}, err
+	}
+
+	user, err := s.GetUser(ctx, id)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	if update.Role != nil {
+		user.Role = *update.Role
+	}
+	if update.Disabled != nil {
+		user.Disabled = *update.Disabled
+	}
+
+	return s.Storage.UpdateUser(ctx, user)
+}
+
+func (s AuthService) DeleteUser(ctx context.Context, id int) error {
+	if err := s.Storage.DeleteUser(ctx, id); err != nil {
+		return fmt.Errorf("user id: %d, err: %w", id, err)
+	}
+
+	return nil
+}
+
+// CreateAPIKey выпускает ключ для пользователя. Секрет есть только в возвращенном
+// значении, получить его повторно нельзя.
+func (s AuthService) CreateAPIKey(ctx context.Context, key domain.APIKey) (domain.APIKey, error) {
+	if err := key.Validate(); err != nil {
+		return domain.APIKey{}, err
+	}
+
+	secret, err := newAPIKeySecret()
+	if err != nil {
+		return domain.APIKey{}, err
+	}
+	key.Prefix = secret[:apiKeyShownLength]
+	key.Hash = hashAPIKey(secret)
+
+	created, err := s.Storage.InsertAPIKey(ctx, key)
+	if err != nil {
+		return domain.APIKey{}, fmt.Errorf("user id: %d, err: %w", key.UserID, err)
+	}
+	created.Secret = secret
+
+	return created, nil
+}
+
+func (s AuthService) ListAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	return s.Storage.ListAPIKeys(ctx)
+}
+
+func (s AuthService) DeleteAPIKey(ctx context.Context, id int) error {
+	if err := s.Storage.DeleteAPIKey(ctx, id); err != nil {
+		return fmt.Errorf("api key id: %d, err: %w", id, err)
+	}
+
+	return nil
+}
+
+func newAPIKeySecret() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+
+	return apiKeyPrefix + hex.EncodeToString(buf), nil
+}
+
+// hashAPIKey - ключи случайные и длинные, поэтому медленный хэш вроде bcrypt не нужен
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
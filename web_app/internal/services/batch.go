@@ -0,0 +1,72 @@
+package services
+
+import (
+	"arch-demo/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// UnitOfWork выполняет несколько вызовов хранилища как одно изменение. Все вызовы
+// с контекстом, который Atomic передает в fn, идут в одной транзакции; если fn вернула
+// ошибку, ни одно изменение не сохраняется. Atomic внутри Atomic присоединяется к внешней.
+type UnitOfWork interface {
+	Atomic(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// batch описывает пакет для runBatch: validate проверяет элемент до начала транзакции,
+// apply выполняет его внутри нее и возвращает сохраненную запись и ее статус
+type batch[T any] struct {
+	size     int
+	validate func(i int) error
+	apply    func(ctx context.Context, i int) (T, string, error)
+}
+
+// runBatch сначала проверяет все элементы, чтобы сообщить обо всех ошибках разом,
+// а потом выполняет их по порядку в одной транзакции до первой ошибки
+func runBatch[T any](ctx context.Context, uow UnitOfWork, b batch[T]) ([]domain.BatchResult[T], error) {
+	if b.size == 0 {
+		return nil, fmt.Errorf("%w: batch is empty", domain.ErrInvalidBatch)
+	}
+	if b.size > domain.MaxBatchSize {
+		return nil, fmt.Errorf("%w: %d items, at most %d are allowed", domain.ErrInvalidBatch, b.size, domain.MaxBatchSize)
+	}
+
+	report := domain.NewBatchError(b.size)
+	for i := 0; i < b.size; i++ {
+		if err := b.validate(i); err != nil {
+			report.Fail(i, err)
+		}
+	}
+	if report.Failed() {
+		return nil, report
+	}
+
+	results := make([]domain.BatchResult[T], b.size)
+	err := uow.Atomic(ctx, func(ctx context.Context) error {
+		for i := range results {
+			item, status, err := b.apply(ctx, i)
+			if err != nil {
+				report.Fail(i, err)
+				report.RolledBack(i)
+				return report
+			}
+			results[i] = domain.BatchResult[T]{Index: i, Status: status, Item: item}
+		}
+
+		return nil
+	})
+
+	var batchErr *domain.BatchError
+	if errors.As(err, &batchErr) {
+		return nil, err
+	}
+	// не удалась сама транзакция, например ее фиксация: отменено все
+	if err != nil {
+		report.Err = err
+		report.RolledBack(b.size)
+		return nil, report
+	}
+
+	return results, nil
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"arch-demo/internal/domain"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// CachedMoviesService - MoviesService с кэшем страниц List в памяти процесса.
+// Кэш сбрасывается целиком после любого изменения фильмов или состава через этот
+// сервис. Изменения, сделанные другими экземплярами приложения, становятся видны
+// не позже чем через ttl.
+type CachedMoviesService struct {
+	MoviesService
+	cache *pageCache[domain.Movie]
+}
+
+func NewCachedMoviesService(service MoviesService, ttl time.Duration, maxEntries int) CachedMoviesService {
+	return CachedMoviesService{
+		MoviesService: service,
+		cache:         newPageCache[domain.Movie](ttl, maxEntries),
+	}
+}
+
+// List отдает страницу из кэша, если она есть. Страница общая для всех вызывающих,
+// менять ее нельзя.
+func (s CachedMoviesService) List(ctx context.Context, query domain.MoviesQuery) (domain.Page[domain.Movie], error) {
+	key := moviesQueryKey(query)
+	page, generation, ok := s.cache.get(key)
+	if ok {
+		return page, nil
+	}
+
+	page, err := s.MoviesService.List(ctx, query)
+	if err != nil {
+		return domain.Page[domain.Movie]{}, err
+	}
+
+	s.cache.put(key, generation, page)
+	return page, nil
+}
+
+// moviesQueryKey - ключ кэша. Курсор и границы фильтра - указатели, поэтому запрос
+// идет в ключ в виде json, а курсор - в своем текстовом виде.
+func moviesQueryKey(query domain.MoviesQuery) string {
+	var after string
+	if query.After != nil {
+		after = query.After.Encode()
+		query.After = nil
+	}
+
+	key, _ := json.Marshal(query)
+	return string(key) + "|" + after
+}
+
+// Invalidate сбрасывает кэш после изменений фильмов в обход сервиса, например их оценок
+func (s CachedMoviesService) Invalidate() {
+	s.cache.invalidate()
+}
+
+// Изменения сбрасывают кэш и после ошибки: часть изменений могла успеть сохраниться.
+
+func (s CachedMoviesService) Create(ctx context.Context, movie domain.Movie) (domain.Movie, error) {
+	defer s.cache.invalidate()
+	return s.MoviesService.Create(ctx, movie)
+}
+
+func (s CachedMoviesService) Update(ctx context.Context, id, version int, movieUpdate domain.MovieUpdate) (domain.Movie, error) {
+	defer s.cache.invalidate()
+	return s.MoviesService.Update(ctx, id, version, movieUpdate)
+}
+
+func (s CachedMoviesService) Batch(ctx context.Context, items []domain.MovieBatchItem) ([]domain.BatchResult[domain.Movie], error) {
+	defer s.cache.invalidate()
+	return s.MoviesService.Batch(ctx, items)
+}
+
+func (s CachedMoviesService) Delete(ctx context.Context, id, version int) error {
+	defer s.cache.invalidate()
+	return s.MoviesService.Delete(ctx, id, version)
+}
+
+func (s CachedMoviesService) Restore(ctx context.Context, id int) (domain.Movie, error) {
+	defer s.cache.invalidate()
+	return s.MoviesService.Restore(ctx, id)
+}
+
+// изменения состава меняют версию фильма, которая есть в страницах списка
+
+func (s CachedMoviesService) CreateActorsForMovie(ctx context.Context, id int, actorsByMovie []int) (int, []int, error) {
+	defer s.cache.invalidate()
+	return s.MoviesService.CreateActorsForMovie(ctx, id, actorsByMovie)
+}
+
+func (s CachedMoviesService) ReplaceCast(ctx context.Context, movieID int, cast []domain.CastEntry) ([]domain.CastMember, error) {
+	defer s.cache.invalidate()
+	return s.MoviesService.ReplaceCast(ctx, movieID, cast)
+}
+
+func (s CachedMoviesService) UpdateCast(ctx context.Context, movieID int, entries []domain.CastEntry) ([]domain.CastMember, error) {
+	defer s.cache.invalidate()
+	return s.MoviesService.UpdateCast(ctx, movieID, entries)
+}
+
+func (s CachedMoviesService) SetCastMember(ctx context.Context, movieID int, entry domain.CastEntry) (domain.CastMember, error) {
+	defer s.cache.invalidate()
+	return s.MoviesService.SetCastMember(ctx, movieID, entry)
+}
+
+func (s CachedMoviesService) UpdateCastMember(ctx context.Context, movieID, actorID int, update domain.CastEntryUpdate) (domain.CastMember, error) {
+	defer s.cache.invalidate()
+	return s.MoviesService.UpdateCastMember(ctx, movieID, actorID, update)
+}
+
+func (s CachedMoviesService) RemoveCastMember(ctx context.Context, movieID, actorID int) error {
+	defer s.cache.invalidate()
+	return s.MoviesService.RemoveCastMember(ctx, movieID, actorID)
+}
+
+// pageCache хранит страницы до ttl. Поколение растет при каждом сбросе: страница,
+// прочитанная из хранилища до сброса, а сохраняемая после, в кэш уже не попадет.
+type pageCache[T any] struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	generation uint64
+	entries    map[string]cachedPage[T]
+}
+
+type cachedPage[T any] struct {
+	page    domain.Page[T]
+	expires time.Time
+}
+
+func newPageCache[T any](ttl time.Duration, maxEntries int) *pageCache[T] {
+	return &pageCache[T]{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]cachedPage[T]),
+	}
+}
+
+// get возвращает страницу и текущее поколение, с которым ее потом нужно передать в put
+func (c *pageCache[T]) get(key string) (domain.Page[T], uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return domain.Page[T]{}, c.generation, false
+	}
+
+	return entry.page, c.generation, true
+}
+
+func (c *pageCache[T]) put(key string, generation uint64, page domain.Page[T]) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	// при переполнении проще начать заново, чем вести учет давности записей
+	if len(c.entries) >= c.maxEntries {
+		clear(c.entries)
+	}
+	c.entries[key] = cachedPage[T]{page: page, expires: time.Now().Add(c.ttl)}
+}
+
+func (c *pageCache[T]) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	clear(c.entries)
+}
@@ -0,0 +1,256 @@
+package services
+
+import (
+	"arch-demo/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Состав фильма хранилище умеет только читать и заменять целиком, все частичные
+// изменения собираются здесь из текущего состава и записываются одной заменой.
+// Чтение, замена и запись в журнал идут в одном Atomic, иначе из двух одновременных
+// правок состава одна потерялась бы.
+
+func (s MoviesService) GetCast(ctx context.Context, movieID int) ([]domain.CastMember, error) {
+	cast, err := s.Storage.GetCast(ctx, movieID)
+	if err != nil {
+		return []domain.CastMember{}, err
+	}
+
+	return cast, nil
+}
+
+// CreateActorsForMovie заменяет состав списком id, титры идут в порядке списка
+func (s MoviesService) CreateActorsForMovie(ctx context.Context, id int, actorsByMovie []int) (int, []int, error) {
+	actorsIDs := uniqueIDs(actorsByMovie)
+
+	cast := make([]domain.CastEntry, 0, len(actorsIDs))
+	for i, actorID := range actorsIDs {
+		cast = append(cast, domain.CastEntry{ActorID: actorID, BillingOrder: i + 1, Role: domain.RoleSupporting})
+	}
+
+	_, err := s.replaceCast(ctx, id, cast)
+	if err != nil {
+		return 0, []int{0}, err
+	}
+
+	return id, actorsIDs, nil
+}
+
+// ReplaceCast заменяет состав целиком, пустой список удаляет всех актеров
+func (s MoviesService) ReplaceCast(ctx context.Context, movieID int, cast []domain.CastEntry) ([]domain.CastMember, error) {
+	if err := domain.ValidateCast(cast); err != nil {
+		return []domain.CastMember{}, err
+	}
+
+	return s.saveCast(ctx, movieID, nil, cast)
+}
+
+// UpdateCast добавляет переданных актеров в состав или заменяет их записи,
+// остальные актеры остаются как были
+func (s MoviesService) UpdateCast(ctx context.Context, movieID int, entries []domain.CastEntry) ([]domain.CastMember, error) {
+	if err := domain.ValidateCast(entries); err != nil {
+		return []domain.CastMember{}, err
+	}
+
+	var updated []domain.CastMember
+	err := s.Storage.Atomic(ctx, func(ctx context.Context) error {
+		current, err := s.Storage.GetCast(ctx, movieID)
+		if err != nil {
+			return err
+		}
+
+		cast := make([]domain.CastEntry, 0, len(current)+len(entries))
+		for _, member := range current {
+			cast = append(cast, member.Entry())
+		}
+
+		var added []domain.CastEntry
+		for _, entry := range entries {
+			i := slices.IndexFunc(cast, func(e domain.CastEntry) bool {
+				return e.ActorID == entry.ActorID
+			})
+			if i < 0 {
+				added = append(added, entry)
+				continue
+			}
+
+			if entry.BillingOrder == 0 {
+				entry.BillingOrder = cast[i].BillingOrder
+			}
+			cast[i] = entry
+		}
+
+		updated, err = s.saveCast(ctx, movieID, cast, added)
+		return err
+	})
+	if err != nil {
+		return []domain.CastMember{}, err
+	}
+
+	return updated, nil
+}
+
+// SetCastMember добавляет актера в состав или полностью заменяет его запись
+func (s MoviesService) SetCastMember(ctx context.Context, movieID int, entry domain.CastEntry) (domain.CastMember, error) {
+	if err := entry.Validate(); err != nil {
+		return domain.CastMember{}, err
+	}
+
+	cast, err := s.UpdateCast(ctx, movieID, []domain.CastEntry{entry})
+	if err != nil {
+		return domain.CastMember{}, err
+	}
+
+	return findCastMember(cast, movieID, entry.ActorID)
+}
+
+// UpdateCastMember частично обновляет запись актера, который уже есть в составе
+func (s MoviesService) UpdateCastMember(ctx context.Context, movieID, actorID int, update domain.CastEntryUpdate) (domain.CastMember, error) {
+	var updated domain.CastMember
+	err := s.Storage.Atomic(ctx, func(ctx context.Context) error {
+		current, err := s.Storage.GetCast(ctx, movieID)
+		if err != nil {
+			return err
+		}
+
+		member, err := findCastMember(current, movieID, actorID)
+		if err != nil {
+			return err
+		}
+
+		entry := member.Entry()
+		if update.Character != nil {
+			entry.Character = *update.Character
+		}
+
+		if update.BillingOrder != nil {
+			entry.BillingOrder = *update.BillingOrder
+		}
+
+		if update.Role != nil {
+			entry.Role = *update.Role
+		}
+
+		updated, err = s.SetCastMember(ctx, movieID, entry)
+		return err
+	})
+	if err != nil {
+		return domain.CastMember{}, err
+	}
+
+	return updated, nil
+}
+
+// RemoveCastMember убирает актера из состава, сам актер не удаляется
+func (s MoviesService) RemoveCastMember(ctx context.Context, movieID, actorID int) error {
+	return s.Storage.Atomic(ctx, func(ctx context.Context) error {
+		current, err := s.Storage.GetCast(ctx, movieID)
+		if err != nil {
+			return err
+		}
+
+		if _, err = findCastMember(current, movieID, actorID); err != nil {
+			return err
+		}
+
+		cast := make([]domain.CastEntry, 0, len(current))
+		for _, member := range current {
+			if member.ID != actorID {
+				cast = append(cast, member.Entry())
+			}
+		}
+
+		_, err = s.replaceCast(ctx, movieID, cast)
+		return err
+	})
+}
+
+// saveCast дописывает added в конец титров, если у них не задано место, и сохраняет состав
+func (s MoviesService) saveCast(ctx context.Context, movieID int, cast, added []domain.CastEntry) ([]domain.CastMember, error) {
+	last := 0
+	for _, entry := range cast {
+		last = max(last, entry.BillingOrder)
+	}
+	for _, entry := range added {
+		last = max(last, entry.BillingOrder)
+	}
+
+	for _, entry := range added {
+		if entry.BillingOrder == 0 {
+			last++
+			entry.BillingOrder = last
+		}
+		cast = append(cast, entry)
+	}
+
+	return s.replaceCast(ctx, movieID, cast)
+}
+
+// castState - состав фильма в журнале изменений, весь состав пишется одним полем
+type castState struct {
+	Cast []domain.CastEntry `json:"cast"`
+}
+
+func newCastState(cast []domain.CastMember) castState {
+	entries := make([]domain.CastEntry, 0, len(cast))
+	for _, member := range cast {
+		entries = append(entries, member.Entry())
+	}
+
+	return castState{Cast: entries}
+}
+
+// replaceCast сохраняет состав и пишет в журнал фильма, каким он был до и после замены
+func (s MoviesService) replaceCast(ctx context.Context, movieID int, cast []domain.CastEntry) ([]domain.CastMember, error) {
+	var after []domain.CastMember
+	err := s.Storage.Atomic(ctx, func(ctx context.Context) error {
+		// для несуществующего фильма ошибку вернет сама замена
+		before, err := s.Storage.GetCast(ctx, movieID)
+		if err != nil && !errors.Is(err, domain.ErrNotFound) {
+			return err
+		}
+
+		if err = s.Storage.ReplaceCast(ctx, movieID, cast); err != nil {
+			return err
+		}
+
+		if after, err = s.Storage.GetCast(ctx, movieID); err != nil {
+			return err
+		}
+
+		s.Audit.Record(ctx, domain.AuditMovie, movieID, domain.AuditCast, newCastState(before), newCastState(after))
+		return nil
+	})
+	if err != nil {
+		return []domain.CastMember{}, err
+	}
+
+	return after, nil
+}
+
+func findCastMember(cast []domain.CastMember, movieID, actorID int) (domain.CastMember, error) {
+	for _, member := range cast {
+		if member.ID == actorID {
+			return member, nil
+		}
+	}
+
+	return domain.CastMember{}, fmt.Errorf("actor id: %d is not in the cast of movie id: %d, err: %w", actorID, movieID, domain.ErrNotFound)
+}
+
+// uniqueIDs убирает повторы, сохраняя порядок первого появления
+func uniqueIDs(ids []int) []int {
+	seen := make(map[int]bool, len(ids))
+	unique := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	return unique
+}
@@ -0,0 +1,400 @@
+package services
+
+import (
+	"arch-demo/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// CatalogActors и CatalogMovies - то, что импорт и выгрузка берут у ActorsService и
+// MoviesService. Через интерфейс подходит и CachedMoviesService, и его кэш сбрасывается.
+type CatalogActors interface {
+	Create(ctx context.Context, actor domain.Actor) (domain.Actor, error)
+	Get(ctx context.Context, id int) (domain.Actor, error)
+	Update(ctx context.Context, id, version int, actorUpdate domain.ActorUpdate) (domain.Actor, error)
+	List(ctx context.Context, query domain.ActorsQuery) (domain.Page[domain.Actor], error)
+}
+
+type CatalogMovies interface {
+	Create(ctx context.Context, movie domain.Movie) (domain.Movie, error)
+	Update(ctx context.Context, id, version int, movieUpdate domain.MovieUpdate) (domain.Movie, error)
+	List(ctx context.Context, query domain.MoviesQuery) (domain.Page[domain.Movie], error)
+	GetCast(ctx context.Context, movieID int) ([]domain.CastMember, error)
+	SetCastMember(ctx context.Context, movieID int, entry domain.CastEntry) (domain.CastMember, error)
+}
+
+// CatalogService выгружает и загружает каталог целиком: актеров, фильмы и составы.
+// Записи читаются и пишутся по одной, файл целиком в память не загружается.
+type CatalogService struct {
+	Actors CatalogActors
+	Movies CatalogMovies
+}
+
+func NewCatalogService(actors CatalogActors, movies CatalogMovies) CatalogService {
+	return CatalogService{
+		Actors: actors,
+		Movies: movies,
+	}
+}
+
+// Export пишет сначала всех актеров, потом фильмы, потом составы: при импорте
+// записи состава ссылаются на уже загруженных актеров и фильмы
+func (s CatalogService) Export(ctx context.Context, format domain.CatalogFormat, w io.Writer) error {
+	records, err := newRecordWriter(format, w)
+	if err != nil {
+		return err
+	}
+
+	err = eachActor(ctx, s.Actors, domain.ActorsQuery{}, func(actor domain.Actor) (bool, error) {
+		return true, records.Write(domain.CatalogRecord{Type: domain.RecordActor, Actor: actor})
+	})
+	if err != nil {
+		return err
+	}
+
+	err = eachMovie(ctx, s.Movies, domain.MoviesQuery{}, func(movie domain.Movie) (bool, error) {
+		return true, records.Write(domain.CatalogRecord{Type: domain.RecordMovie, Movie: movie})
+	})
+	if err != nil {
+		return err
+	}
+
+	// второй проход по фильмам вместо того, чтобы держать в памяти их id
+	err = eachMovie(ctx, s.Movies, domain.MoviesQuery{}, func(movie domain.Movie) (bool, error) {
+		cast, err := s.Movies.GetCast(ctx, movie.ID)
+		if err != nil {
+			return false, err
+		}
+		for _, member := range cast {
+			link := domain.CastLink{MovieID: movie.ID, CastEntry: member.Entry()}
+			if err = records.Write(domain.CatalogRecord{Type: domain.RecordCast, Cast: link}); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return records.Flush()
+}
+
+// Import применяет записи по одной: актеры и фильмы ищутся по имени и обновляются,
+// если нашлись, иначе создаются. Строка с ошибкой пропускается и попадает в отчет.
+// При dryRun записи только проверяются, ничего не сохраняется.
+//
+// id актеров и фильмов в файле - это id из выгрузки, а не из этого хранилища: записи
+// состава, ссылающиеся на них, получают id загруженных записей. id, которых в файле
+// не было, считаются id уже существующих записей.
+func (s CatalogService) Import(ctx context.Context, format domain.CatalogFormat, r io.Reader, dryRun bool) (domain.ImportReport, error) {
+	report := domain.ImportReport{DryRun: dryRun, Errors: []domain.ImportError{}}
+
+	records, err := newRecordReader(format, r)
+	if err != nil {
+		return report, err
+	}
+
+	imp := importer{
+		CatalogService: s,
+		dryRun:         dryRun,
+		actorIDs:       make(map[int]int),
+		movieIDs:       make(map[int]int),
+	}
+
+	for {
+		record, line, err := records.Read()
+		if errors.Is(err, io.EOF) {
+			return report, nil
+		}
+		if err != nil && !errors.Is(err, domain.ErrMalformedRecord) {
+			return report, fmt.Errorf("import stopped at line %d after %d records: %w", line, report.Lines, err)
+		}
+
+		action := ""
+		if err == nil {
+			action, err = imp.apply(ctx, record)
+		}
+		// отмена или дедлайн относятся ко всему импорту, а не к строке
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return report, fmt.Errorf("import stopped at line %d after %d records: %w", line, report.Lines, err)
+		}
+		report.Add(line, record, action, err)
+	}
+}
+
+type importer struct {
+	CatalogService
+	dryRun bool
+	// actorIDs и movieIDs - id из файла в id хранилища
+	actorIDs map[int]int
+	movieIDs map[int]int
+	// lastPlaceholder - при dryRun записи не создаются, вместо их id выдаются отрицательные
+	lastPlaceholder int
+}
+
+func (imp *importer) apply(ctx context.Context, record domain.CatalogRecord) (string, error) {
+	switch record.Type {
+	case domain.RecordActor:
+		return imp.importActor(ctx, record.Actor)
+	case domain.RecordMovie:
+		return imp.importMovie(ctx, record.Movie)
+	case domain.RecordCast:
+		return imp.importCast(ctx, record.Cast)
+	}
+
+	return "", fmt.Errorf("%w: unknown record type %q", domain.ErrMalformedRecord, record.Type)
+}
+
+func (imp *importer) placeholder() int {
+	imp.lastPlaceholder--
+	return imp.lastPlaceholder
+}
+
+func (imp *importer) importActor(ctx context.Context, actor domain.Actor) (string, error) {
+	fileID := actor.ID
+	if err := actor.Validate(time.Now()); err != nil {
+		return "", err
+	}
+
+	existing, found, err := findActor(ctx, imp.Actors, actor.Name)
+	if err != nil {
+		return "", err
+	}
+
+	if !found {
+		id := imp.placeholder()
+		if !imp.dryRun {
+			actor.ID = 0
+			created, err := imp.Actors.Create(ctx, actor)
+			if err != nil {
+				return "", err
+			}
+			id = created.ID
+		}
+		imp.remember(imp.actorIDs, fileID, id)
+		return domain.ImportCreated, nil
+	}
+
+	imp.remember(imp.actorIDs, fileID, existing.ID)
+
+	var update domain.ActorUpdate
+	changed := false
+	if actor.BirthYear != existing.BirthYear {
+		update.BirthYear, changed = &actor.BirthYear, true
+	}
+	if actor.CountryOfBirth != existing.CountryOfBirth {
+		update.CountryOfBirth, changed = &actor.CountryOfBirth, true
+	}
+	if actor.Gender != existing.Gender {
+		update.Gender, changed = &actor.Gender, true
+	}
+
+	return imp.applyUpdate(changed, func() error {
+		_, err := imp.Actors.Update(ctx, existing.ID, existing.Version, update)
+		return err
+	})
+}
+
+func (imp *importer) importMovie(ctx context.Context, movie domain.Movie) (string, error) {
+	fileID := movie.ID
+	if err := movie.Validate(time.Now()); err != nil {
+		return "", err
+	}
+
+	existing, found, err := findMovie(ctx, imp.Movies, movie.Name)
+	if err != nil {
+		return "", err
+	}
+
+	if !found {
+		id := imp.placeholder()
+		if !imp.dryRun {
+			movie.ID = 0
+			created, err := imp.Movies.Create(ctx, movie)
+			if err != nil {
+				return "", err
+			}
+			id = created.ID
+		}
+		imp.remember(imp.movieIDs, fileID, id)
+		return domain.ImportCreated, nil
+	}
+
+	imp.remember(imp.movieIDs, fileID, existing.ID)
+
+	var update domain.MovieUpdate
+	changed := false
+	if !movie.ReleaseDate.Equal(existing.ReleaseDate) {
+		update.ReleaseDate, changed = &movie.ReleaseDate, true
+	}
+	if movie.Country != existing.Country {
+		update.Country, changed = &movie.Country, true
+	}
+	if movie.Genre != existing.Genre {
+		update.Genre, changed = &movie.Genre, true
+	}
+	if movie.Rating != existing.Rating {
+		update.Rating, changed = &movie.Rating, true
+	}
+
+	return imp.applyUpdate(changed, func() error {
+		_, err := imp.Movies.Update(ctx, existing.ID, existing.Version, update)
+		return err
+	})
+}
+
+func (imp *importer) importCast(ctx context.Context, link domain.CastLink) (string, error) {
+	if err := link.Validate(); err != nil {
+		return "", err
+	}
+
+	movieID := imp.resolve(imp.movieIDs, link.MovieID)
+	entry := link.CastEntry
+	entry.ActorID = imp.resolve(imp.actorIDs, link.ActorID)
+
+	// фильм еще не создан, потому что это пробный прогон: сравнивать не с чем
+	if movieID < 0 {
+		return domain.ImportCreated, imp.checkActor(ctx, entry.ActorID)
+	}
+
+	cast, err := imp.Movies.GetCast(ctx, movieID)
+	if err != nil {
+		return "", err
+	}
+
+	action := domain.ImportCreated
+	for _, member := range cast {
+		if member.ID != entry.ActorID {
+			continue
+		}
+		// без места в титрах у актера, который уже в составе, место не меняется
+		if member.Character == entry.Character && member.Role == entry.Role &&
+			(entry.BillingOrder == 0 || entry.BillingOrder == member.BillingOrder) {
+			return domain.ImportUnchanged, nil
+		}
+		action = domain.ImportUpdated
+	}
+
+	if imp.dryRun {
+		if action == domain.ImportCreated {
+			return action, imp.checkActor(ctx, entry.ActorID)
+		}
+		return action, nil
+	}
+
+	if _, err = imp.Movies.SetCastMember(ctx, movieID, entry); err != nil {
+		return "", err
+	}
+
+	return action, nil
+}
+
+// checkActor при dryRun проверяет то, что при настоящем импорте проверило бы хранилище
+func (imp *importer) checkActor(ctx context.Context, id int) error {
+	if id < 0 {
+		return nil
+	}
+
+	_, err := imp.Actors.Get(ctx, id)
+	return err
+}
+
+func (imp *importer) applyUpdate(changed bool, update func() error) (string, error) {
+	if !changed {
+		return domain.ImportUnchanged, nil
+	}
+	if imp.dryRun {
+		return domain.ImportUpdated, nil
+	}
+
+	if err := update(); err != nil {
+		return "", err
+	}
+
+	return domain.ImportUpdated, nil
+}
+
+func (imp *importer) remember(ids map[int]int, fileID, id int) {
+	if fileID != 0 {
+		ids[fileID] = id
+	}
+}
+
+func (imp *importer) resolve(ids map[int]int, fileID int) int {
+	if id, ok := ids[fileID]; ok {
+		return id
+	}
+
+	return fileID
+}
+
+// findActor ищет актера с точно таким именем
+func findActor(ctx context.Context, actors CatalogActors, name string) (domain.Actor, bool, error) {
+	var found domain.Actor
+	query := domain.ActorsQuery{Name: domain.TextFilter{Eq: []string{name}}}
+	err := eachActor(ctx, actors, query, func(actor domain.Actor) (bool, error) {
+		found = actor
+		return false, nil
+	})
+
+	return found, found.ID != 0, err
+}
+
+func findMovie(ctx context.Context, movies CatalogMovies, name string) (domain.Movie, bool, error) {
+	var found domain.Movie
+	query := domain.MoviesQuery{Name: domain.TextFilter{Eq: []string{name}}}
+	err := eachMovie(ctx, movies, query, func(movie domain.Movie) (bool, error) {
+		found = movie
+		return false, nil
+	})
+
+	return found, found.ID != 0, err
+}
+
+func eachActor(ctx context.Context, actors CatalogActors, query domain.ActorsQuery, fn func(domain.Actor) (bool, error)) error {
+	query.Limit = domain.MaxPageLimit
+	return eachPage(func(after *domain.Cursor[domain.Actor]) (domain.Page[domain.Actor], error) {
+		query.After = after
+		return actors.List(ctx, query)
+	}, fn)
+}
+
+func eachMovie(ctx context.Context, movies CatalogMovies, query domain.MoviesQuery, fn func(domain.Movie) (bool, error)) error {
+	query.Limit = domain.MaxPageLimit
+	return eachPage(func(after *domain.Cursor[domain.Movie]) (domain.Page[domain.Movie], error) {
+		query.After = after
+		return movies.List(ctx, query)
+	}, fn)
+}
+
+// eachPage проходит список по курсору и вызывает fn для каждой записи,
+// пока fn не вернет false или ошибку
+func eachPage[T any](list func(after *domain.Cursor[T]) (domain.Page[T], error), fn func(T) (bool, error)) error {
+	var after *domain.Cursor[T]
+	for {
+		page, err := list(after)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range page.Items {
+			more, err := fn(item)
+			if err != nil || !more {
+				return err
+			}
+		}
+
+		if page.NextCursor == "" {
+			return nil
+		}
+		cursor, err := domain.DecodeCursor[T](page.NextCursor)
+		if err != nil {
+			return err
+		}
+		after = &cursor
+	}
+}
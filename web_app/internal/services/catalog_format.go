@@ -0,0 +1,339 @@
+package services
+
+import (
+	"arch-demo/internal/domain"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// maxRecordSize - длина строки NDJSON, после которой файл считается испорченным
+const maxRecordSize = 1 << 20
+
+// recordReader отдает записи по одной вместе с номером строки, в конце данных - io.EOF.
+// Ошибка с ErrMalformedRecord относится только к этой строке, любая другая прерывает чтение.
+type recordReader interface {
+	Read() (domain.CatalogRecord, int, error)
+}
+
+// recordWriter пишет записи через буфер, Flush дописывает остаток
+type recordWriter interface {
+	Write(record domain.CatalogRecord) error
+	Flush() error
+}
+
+func newRecordReader(format domain.CatalogFormat, r io.Reader) (recordReader, error) {
+	switch format {
+	case domain.FormatNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxRecordSize)
+		return &ndjsonReader{scanner: scanner}, nil
+	case domain.FormatCSV:
+		reader := csv.NewReader(r)
+		// короткие строки допустимы: недостающие колонки считаются пустыми
+		reader.FieldsPerRecord = -1
+		reader.ReuseRecord = true
+		return &csvReader{reader: reader}, nil
+	}
+
+	return nil, fmt.Errorf("%w %q, expected ndjson or csv", domain.ErrUnknownFormat, format)
+}
+
+func newRecordWriter(format domain.CatalogFormat, w io.Writer) (recordWriter, error) {
+	switch format {
+	case domain.FormatNDJSON:
+		buf := bufio.NewWriter(w)
+		return ndjsonWriter{buf: buf, enc: json.NewEncoder(buf)}, nil
+	case domain.FormatCSV:
+		return &csvWriter{writer: csv.NewWriter(w)}, nil
+	}
+
+	return nil, fmt.Errorf("%w %q, expected ndjson or csv", domain.ErrUnknownFormat, format)
+}
+
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// Read пропускает пустые строки, номер строки при этом все равно растет
+func (r *ndjsonReader) Read() (domain.CatalogRecord, int, error) {
+	for r.scanner.Scan() {
+		r.line++
+		data := bytes.TrimSpace(r.scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		var record domain.CatalogRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return record, r.line, fmt.Errorf("%w: %v", domain.ErrMalformedRecord, err)
+		}
+		return record, r.line, nil
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return domain.CatalogRecord{}, r.line + 1, fmt.Errorf("%w: line %d is longer than %d bytes",
+				domain.ErrInvalidImport, r.line+1, maxRecordSize)
+		}
+		return domain.CatalogRecord{}, r.line, err
+	}
+
+	return domain.CatalogRecord{}, r.line, io.EOF
+}
+
+type ndjsonWriter struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+// Write - Encode сам дописывает перевод строки после объекта
+func (w ndjsonWriter) Write(record domain.CatalogRecord) error {
+	return w.enc.Encode(record)
+}
+
+func (w ndjsonWriter) Flush() error {
+	return w.buf.Flush()
+}
+
+// csvColumns - колонки CSV: у каждой строки заполнены только колонки ее типа
+var csvColumns = []string{
+	"type", "id", "name",
+	"birth_year", "country_of_birth", "gender",
+	"release_date", "country", "genre", "rating",
+	"movie_id", "actor_id", "character", "billing_order", "role",
+}
+
+type csvWriter struct {
+	writer      *csv.Writer
+	wroteHeader bool
+}
+
+func (w *csvWriter) Write(record domain.CatalogRecord) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	row := make(map[string]string, len(csvColumns))
+	row["type"] = record.Type
+	switch record.Type {
+	case domain.RecordActor:
+		a := record.Actor
+		row["id"] = strconv.Itoa(a.ID)
+		row["name"] = a.Name
+		row["birth_year"] = strconv.Itoa(a.BirthYear)
+		row["country_of_birth"] = a.CountryOfBirth
+		row["gender"] = a.Gender
+	case domain.RecordMovie:
+		m := record.Movie
+		row["id"] = strconv.Itoa(m.ID)
+		row["name"] = m.Name
+		row["release_date"] = m.ReleaseDate.UTC().Format(time.RFC3339)
+		row["country"] = m.Country
+		row["genre"] = m.Genre
+		row["rating"] = strconv.Itoa(int(m.Rating))
+	case domain.RecordCast:
+		c := record.Cast
+		row["movie_id"] = strconv.Itoa(c.MovieID)
+		row["actor_id"] = strconv.Itoa(c.ActorID)
+		row["character"] = c.Character
+		row["billing_order"] = strconv.Itoa(c.BillingOrder)
+		row["role"] = c.Role
+	default:
+		return fmt.Errorf("unknown record type %q", record.Type)
+	}
+
+	values := make([]string, len(csvColumns))
+	for i, column := range csvColumns {
+		values[i] = row[column]
+	}
+
+	return w.writer.Write(values)
+}
+
+// writeHeader пишет заголовок перед первой записью; пустая выгрузка - только заголовок
+func (w *csvWriter) writeHeader() error {
+	if w.wroteHeader {
+		return nil
+	}
+	w.wroteHeader = true
+
+	return w.writer.Write(csvColumns)
+}
+
+func (w *csvWriter) Flush() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	w.writer.Flush()
+
+	return w.writer.Error()
+}
+
+type csvReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+// Read читает заголовок при первом вызове. Колонки ищутся по имени, поэтому их порядок
+// может быть любым, а неизвестная колонка - ошибка всего файла: скорее всего это опечатка.
+func (r *csvReader) Read() (domain.CatalogRecord, int, error) {
+	if r.columns == nil {
+		if err := r.readHeader(); err != nil {
+			return domain.CatalogRecord{}, 1, err
+		}
+	}
+
+	row, err := r.reader.Read()
+	line, _ := r.reader.FieldPos(0)
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return domain.CatalogRecord{}, parseErr.StartLine, fmt.Errorf("%w: %v", domain.ErrMalformedRecord, parseErr.Err)
+	}
+	if err != nil {
+		return domain.CatalogRecord{}, line, err
+	}
+
+	record, err := r.parse(row)
+	return record, line, err
+}
+
+func (r *csvReader) readHeader() error {
+	header, err := r.reader.Read()
+	if errors.Is(err, io.EOF) {
+		return io.EOF
+	}
+	if err != nil {
+		return fmt.Errorf("%w: failed to read csv header: %v", domain.ErrInvalidImport, err)
+	}
+
+	known := make(map[string]bool, len(csvColumns))
+	for _, column := range csvColumns {
+		known[column] = true
+	}
+
+	r.columns = make(map[string]int, len(header))
+	for i, column := range header {
+		if !known[column] {
+			return fmt.Errorf("%w: unknown csv column %q", domain.ErrInvalidImport, column)
+		}
+		r.columns[column] = i
+	}
+	if _, ok := r.columns["type"]; !ok {
+		return fmt.Errorf("%w: csv column type is required", domain.ErrInvalidImport)
+	}
+
+	return nil
+}
+
+func (r *csvReader) parse(row []string) (domain.CatalogRecord, error) {
+	p := csvRow{row: row, columns: r.columns}
+	record := domain.CatalogRecord{Type: p.str("type")}
+
+	switch record.Type {
+	case domain.RecordActor:
+		record.Actor = domain.Actor{
+			ID:             p.int("id"),
+			Name:           p.str("name"),
+			BirthYear:      p.int("birth_year"),
+			CountryOfBirth: p.str("country_of_birth"),
+			Gender:         p.str("gender"),
+		}
+	case domain.RecordMovie:
+		record.Movie = domain.Movie{
+			ID:          p.int("id"),
+			Name:        p.str("name"),
+			ReleaseDate: p.time("release_date"),
+			Country:     p.str("country"),
+			Genre:       p.str("genre"),
+			Rating:      p.int8("rating"),
+		}
+	case domain.RecordCast:
+		record.Cast = domain.CastLink{
+			MovieID: p.int("movie_id"),
+			CastEntry: domain.CastEntry{
+				ActorID:      p.int("actor_id"),
+				Character:    p.str("character"),
+				BillingOrder: p.int("billing_order"),
+				Role:         p.str("role"),
+			},
+		}
+	case "":
+		return record, fmt.Errorf("%w: type is required", domain.ErrMalformedRecord)
+	default:
+		return record, fmt.Errorf("%w: unknown record type %q, expected actor, movie or cast", domain.ErrMalformedRecord, record.Type)
+	}
+
+	if p.err != nil {
+		return record, fmt.Errorf("%w: %v", domain.ErrMalformedRecord, p.err)
+	}
+
+	return record, nil
+}
+
+// csvRow достает значения по имени колонки и запоминает первую ошибку разбора
+type csvRow struct {
+	row     []string
+	columns map[string]int
+	err     error
+}
+
+func (p *csvRow) str(column string) string {
+	i, ok := p.columns[column]
+	if !ok || i >= len(p.row) {
+		return ""
+	}
+
+	return p.row[i]
+}
+
+func (p *csvRow) int(column string) int {
+	value := p.str(column)
+	if value == "" {
+		return 0
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil && p.err == nil {
+		p.err = fmt.Errorf("%s: %q is not a number", column, value)
+	}
+
+	return n
+}
+
+// int8 - рейтинг хранится в int8, большие значения не должны переполняться в допустимые
+func (p *csvRow) int8(column string) int8 {
+	n := p.int(column)
+	if (n < math.MinInt8 || n > math.MaxInt8) && p.err == nil {
+		p.err = fmt.Errorf("%s: %d is out of range", column, n)
+	}
+
+	return int8(n)
+}
+
+// time принимает и полный RFC 3339, как в выгрузке, и просто дату
+func (p *csvRow) time(column string) time.Time {
+	value := p.str(column)
+	if value == "" {
+		return time.Time{}
+	}
+
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	if p.err == nil {
+		p.err = fmt.Errorf("%s: %q is not an RFC 3339 date", column, value)
+	}
+
+	return time.Time{}
+}
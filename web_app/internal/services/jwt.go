@@ -0,0 +1,242 @@
+package services
+
+import (
+	"arch-demo/internal/domain"
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// clockSkew - допустимое расхождение часов с выпустившим токен сервисом
+const clockSkew = 30 * time.Second
+
+// TokenVerifier проверяет JWT локально, без обращения к выпустившему их сервису.
+// Поддерживаются HS256 с общим секретом и RS256 с открытым ключом; алгоритм
+// токена должен совпадать с одним из настроенных ключей. Нулевое значение
+// отклоняет все токены.
+type TokenVerifier struct {
+	secret    []byte
+	publicKey *rsa.PublicKey
+	issuer    string
+	audience  string
+}
+
+type TokenConfig struct {
+	HS256Secret string
+	// RS256PublicKey - открытый ключ RSA в PEM, PKIX или PKCS #1
+	RS256PublicKey []byte
+	// Issuer и Audience, если заданы, должны совпасть с iss и aud токена
+	Issuer   string
+	Audience string
+}
+
+func NewTokenVerifier(cfg TokenConfig) (TokenVerifier, error) {
+	v := TokenVerifier{
+		issuer:   cfg.Issuer,
+		audience: cfg.Audience,
+	}
+	if cfg.HS256Secret != "" {
+		v.secret = []byte(cfg.HS256Secret)
+	}
+
+	if len(cfg.RS256PublicKey) > 0 {
+		key, err := parseRSAPublicKey(cfg.RS256PublicKey)
+		if err != nil {
+			return TokenVerifier{}, err
+		}
+		v.publicKey = key
+	}
+
+	return v, nil
+}
+
+func parseRSAPublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("rs256 public key is not PEM encoded")
+	}
+
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse rs256 public key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("rs256 public key is %T, not RSA", key)
+	}
+
+	return rsaKey, nil
+}
+
+type tokenHeader struct {
+	Alg string `json:"alg"`
+}
+
+// TokenClaims - проверяемые поля токена, остальные игнорируются
+type TokenClaims struct {
+	Subject   string      `json:"sub"`
+	Issuer    string      `json:"iss"`
+	Audience  audience    `json:"aud"`
+	ExpiresAt json.Number `json:"exp"`
+	NotBefore json.Number `json:"nbf"`
+}
+
+// audience по RFC 7519 бывает строкой или массивом строк
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(data, []byte(`"`)) {
+		var single string
+		if err := json.Unmarshal(data, &single); err != nil {
+			return err
+		}
+		*a = audience{single}
+		return nil
+	}
+
+	return json.Unmarshal(data, (*[]string)(a))
+}
+
+// Verify проверяет подпись, exp, nbf, iss и aud. Токен без exp отклоняется:
+// бессрочный токен нельзя отозвать иначе как сменой ключа.
+func (v TokenVerifier) Verify(token string, now time.Time) (TokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return TokenClaims{}, invalidToken("malformed token")
+	}
+
+	var header tokenHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return TokenClaims{}, invalidToken("malformed header")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return TokenClaims{}, invalidToken("malformed signature")
+	}
+
+	if err = v.verifySignature(header.Alg, parts[0]+"."+parts[1], signature); err != nil {
+		return TokenClaims{}, err
+	}
+
+	var claims TokenClaims
+	if err = decodeSegment(parts[1], &claims); err != nil {
+		return TokenClaims{}, invalidToken("malformed claims")
+	}
+
+	if err = v.verifyClaims(claims, now); err != nil {
+		return TokenClaims{}, err
+	}
+
+	return claims, nil
+}
+
+// verifySignature выбирает алгоритм по заголовку, но только среди настроенных ключей:
+// иначе токен с alg none или HS256, подписанный открытым RSA-ключом, прошел бы проверку
+func (v TokenVerifier) verifySignature(alg, signed string, signature []byte) error {
+	switch alg {
+	case "HS256":
+		if v.secret == nil {
+			return invalidToken("HS256 tokens are not accepted")
+		}
+		mac := hmac.New(sha256.New, v.secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return invalidToken("invalid signature")
+		}
+	case "RS256":
+		if v.publicKey == nil {
+			return invalidToken("RS256 tokens are not accepted")
+		}
+		digest := sha256.Sum256([]byte(signed))
+		if err := rsa.VerifyPKCS1v15(v.publicKey, crypto.SHA256, digest[:], signature); err != nil {
+			return invalidToken("invalid signature")
+		}
+	default:
+		return invalidToken(fmt.Sprintf("unsupported algorithm %q", alg))
+	}
+
+	return nil
+}
+
+func (v TokenVerifier) verifyClaims(claims TokenClaims, now time.Time) error {
+	if claims.Subject == "" {
+		return invalidToken("sub is required")
+	}
+
+	expiresAt, err := numericDate(claims.ExpiresAt)
+	if err != nil || expiresAt.IsZero() {
+		return invalidToken("exp is required")
+	}
+	if !now.Before(expiresAt.Add(clockSkew)) {
+		return invalidToken("token is expired")
+	}
+
+	notBefore, err := numericDate(claims.NotBefore)
+	if err != nil {
+		return invalidToken("malformed nbf")
+	}
+	if now.Add(clockSkew).Before(notBefore) {
+		return invalidToken("token is not valid yet")
+	}
+
+	if v.issuer != "" && claims.Issuer != v.issuer {
+		return invalidToken(fmt.Sprintf("unexpected issuer %q", claims.Issuer))
+	}
+
+	if v.audience != "" {
+		found := false
+		for _, aud := range claims.Audience {
+			found = found || aud == v.audience
+		}
+		if !found {
+			return invalidToken("token is not issued for this audience")
+		}
+	}
+
+	return nil
+}
+
+// numericDate разбирает время в секундах от начала эпохи, допускаются дробные секунды.
+// Пустое значение означает, что поля в токене нет.
+func numericDate(n json.Number) (time.Time, error) {
+	if n == "" {
+		return time.Time{}, nil
+	}
+
+	seconds, err := n.Float64()
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return time.Time{}, fmt.Errorf("invalid numeric date %q", n)
+	}
+
+	whole, frac := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(frac*float64(time.Second))), nil
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+func invalidToken(reason string) error {
+	return fmt.Errorf("%w: %s", domain.ErrUnauthenticated, reason)
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"arch-demo/internal/domain"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signedPart(alg, claims string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"`+alg+`","typ":"JWT"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(claims))
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, claims string) string {
+	t.Helper()
+
+	signed := signedPart("RS256", claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("SignPKCS1v15: %v", err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func signHS256(secret []byte, claims string) string {
+	signed := signedPart("HS256", claims)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestTokenVerifier_RS256(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey: %v", err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	pkcs1PEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&key.PublicKey)})
+
+	now := time.Now()
+	claims := fmt.Sprintf(`{"sub":"reader","iss":"sso","aud":"movies","exp":%d}`, now.Unix()+60)
+	// подпись от настоящего токена, а в claims другой пользователь
+	parts := strings.Split(signRS256(t, key, claims), ".")
+	tampered := parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin","exp":4102444800}`)) + "." + parts[2]
+
+	tests := []struct {
+		name    string
+		cfg     TokenConfig
+		token   string
+		wantErr bool
+	}{
+		{
+			name:  "valid",
+			cfg:   TokenConfig{RS256PublicKey: publicPEM, Issuer: "sso", Audience: "movies"},
+			token: signRS256(t, key, claims),
+		},
+		{
+			name:  "pkcs1 public key",
+			cfg:   TokenConfig{RS256PublicKey: pkcs1PEM},
+			token: signRS256(t, key, claims),
+		},
+		{
+			name:    "wrong key",
+			cfg:     TokenConfig{RS256PublicKey: publicPEM},
+			token:   signRS256(t, otherKey, claims),
+			wantErr: true,
+		},
+		{
+			name:    "claims changed after signing",
+			cfg:     TokenConfig{RS256PublicKey: publicPEM},
+			token:   tampered,
+			wantErr: true,
+		},
+		{
+			// открытый ключ известен всем, HS256 с ним в роли секрета подделал бы любой токен
+			name:    "HS256 signed with the public key",
+			cfg:     TokenConfig{RS256PublicKey: publicPEM},
+			token:   signHS256(publicPEM, claims),
+			wantErr: true,
+		},
+		{
+			name:    "HS256 signed with the public key when HS256 is also accepted",
+			cfg:     TokenConfig{RS256PublicKey: publicPEM, HS256Secret: "0123456789abcdef0123456789abcdef"},
+			token:   signHS256(publicPEM, claims),
+			wantErr: true,
+		},
+		{
+			name:    "RS256 without a public key",
+			cfg:     TokenConfig{HS256Secret: "0123456789abcdef0123456789abcdef"},
+			token:   signRS256(t, key, claims),
+			wantErr: true,
+		},
+		{
+			name:    "expired",
+			cfg:     TokenConfig{RS256PublicKey: publicPEM},
+			token:   signRS256(t, key, fmt.Sprintf(`{"sub":"reader","exp":%d}`, now.Unix()-60)),
+			wantErr: true,
+		},
+		{
+			name:    "wrong issuer",
+			cfg:     TokenConfig{RS256PublicKey: publicPEM, Issuer: "other"},
+			token:   signRS256(t, key, claims),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := NewTokenVerifier(tt.cfg)
+			if err != nil {
+				t.Fatalf("NewTokenVerifier: %v", err)
+			}
+
+			got, err := v.Verify(tt.token, now)
+			if tt.wantErr {
+				if !errors.Is(err, domain.ErrUnauthenticated) {
+					t.Errorf("Verify = %+v, %v, want ErrUnauthenticated", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if got.Subject != "reader" {
+				t.Errorf("subject = %q, want reader", got.Subject)
+			}
+		})
+	}
+
+	if _, err = NewTokenVerifier(TokenConfig{RS256PublicKey: []byte("not a key")}); err == nil {
+		t.Error("NewTokenVerifier accepted a key that is not PEM")
+	}
+}
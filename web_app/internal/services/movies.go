@@ -2,50 +2,66 @@ package services
 
 import (
 	"arch-demo/internal/domain"
+	"context"
 	"errors"
 	"fmt"
-	"strings"
+	"time"
 )
 
 type MoviesRepository interface {
-	InsertMovie(actor domain.Movie) (domain.Movie, error)
-	IsMovieExists(actor domain.Movie) (bool, error)
-	GetMovieByID(id int) (domain.Movie, error)
-	UpdateMovie(actor domain.Movie) error
-	DeleteMovie(id int) error
-	GetAllMovies() ([]domain.Movie, error)
-	SortAndOrderByMovie(sortBy, orderBy string, movies []domain.Movie) []domain.Movie
-	GetActorsByMovie(id int) ([]domain.Actor, error)
-	CreateActorsByMovie(id int, actors []int) (int, []int, error)
+	UnitOfWork
+	// InsertMovie возвращает ErrExists, если есть неудаленный фильм с тем же названием
+	InsertMovie(ctx context.Context, actor domain.Movie) (domain.Movie, error)
+	// IsMovieExists сообщает, есть ли неудаленный фильм с тем же названием
+	IsMovieExists(ctx context.Context, actor domain.Movie) (bool, error)
+	GetMovieByID(ctx context.Context, id int) (domain.Movie, error)
+	// UpdateMovie возвращает сохраненную запись с новыми версией и временем изменения;
+	// если версия в хранилище уже не movie.Version, возвращает ErrVersionMismatch
+	UpdateMovie(ctx context.Context, movie domain.Movie) (domain.Movie, error)
+	// DeleteMovie ничего не делает, если записи нет или она уже удалена. Если version не 0,
+	// а версия записи другая, возвращает ErrVersionMismatch.
+	DeleteMovie(ctx context.Context, id, version int, policy domain.DeletePolicy) error
+	// RestoreMovie снимает пометку об удалении, для записи не из корзины возвращает ErrNotFound
+	RestoreMovie(ctx context.Context, id int) (domain.Movie, error)
+	ListMovies(ctx context.Context, query domain.MoviesQuery) ([]domain.Movie, int, error)
+	GetCast(ctx context.Context, movieID int) ([]domain.CastMember, error)
+	ReplaceCast(ctx context.Context, movieID int, cast []domain.CastEntry) error
+	GetActorByID(ctx context.Context, id int) (domain.Actor, error)
 }
 
 type MoviesService struct {
-	Storage MoviesRepository
+	Storage      MoviesRepository
+	DeletePolicy domain.DeletePolicy
+	Audit        AuditLog
 }
 
-func NewMovieService(storage MoviesRepository) MoviesService {
+func NewMovieService(storage MoviesRepository, deletePolicy domain.DeletePolicy, audit AuditLog) MoviesService {
 	return MoviesService{
-		Storage: storage,
+		Storage:      storage,
+		DeletePolicy: deletePolicy,
+		Audit:        audit,
 	}
 }
 
-func (s MoviesService) Create(movie domain.Movie) (domain.Movie, error) {
+func (s MoviesService) Create(ctx context.Context, movie domain.Movie) (domain.Movie, error) {
 	// входящие параметры необходимо валидировать
-	if movie.Name == "" || movie.ReleaseDate.String() == "" ||
-		movie.Country == "" || movie.Genre == "" || movie.Rating == 0 {
-		return domain.Movie{}, domain.ErrFieldsRequired
+	if err := movie.Validate(time.Now()); err != nil {
+		return domain.Movie{}, err
 	}
+	// пометку об удалении ставит только хранилище
+	movie.DeletedAt = nil
 
-	newMovie, err := s.Storage.InsertMovie(movie)
+	newMovie, err := s.Storage.InsertMovie(ctx, movie)
 	if err != nil {
-		return domain.Movie{}, domain.ErrExists
+		return domain.Movie{}, err
 	}
 
+	s.Audit.Record(ctx, domain.AuditMovie, newMovie.ID, domain.AuditCreate, nil, newMovie)
 	return newMovie, nil
 }
 
-func (s MoviesService) Get(id int) (domain.Movie, error) {
-	movie, err := s.Storage.GetMovieByID(id)
+func (s MoviesService) Get(ctx context.Context, id int) (domain.Movie, error) {
+	movie, err := s.Storage.GetMovieByID(ctx, id)
 	if errors.Is(err, domain.ErrNotFound) {
 		return domain.Movie{}, fmt.Errorf("movie id: %d, err: %w", id, err)
 	}
@@ -57,11 +73,17 @@ func (s MoviesService) Get(id int) (domain.Movie, error) {
 	return movie, nil
 }
 
-func (s MoviesService) Update(id int, movieUpdate domain.MovieUpdate) (domain.Movie, error) {
-	movie, err := s.Storage.GetMovieByID(id)
-	if errors.Is(err, domain.ErrNotFound) {
+// Update с version, отличным от 0, применяет изменения, только если запись все еще этой версии.
+// Даже без version запись не перетрет изменения, сделанные после ее чтения здесь.
+func (s MoviesService) Update(ctx context.Context, id, version int, movieUpdate domain.MovieUpdate) (domain.Movie, error) {
+	movie, err := s.Storage.GetMovieByID(ctx, id)
+	if err != nil {
 		return domain.Movie{}, err
 	}
+	if version != 0 && movie.Version != version {
+		return domain.Movie{}, fmt.Errorf("movie id: %d, version: %d, expected: %d, err: %w", id, movie.Version, version, domain.ErrVersionMismatch)
+	}
+	before := movie
 
 	if movieUpdate.Name != nil {
 		movie.Name = *movieUpdate.Name
@@ -83,13 +105,47 @@ func (s MoviesService) Update(id int, movieUpdate domain.MovieUpdate) (domain.Mo
 		movie.Rating = *movieUpdate.Rating
 	}
 
-	s.Storage.UpdateMovie(movie)
+	if err = movie.Validate(time.Now()); err != nil {
+		return domain.Movie{}, err
+	}
+
+	movie, err = s.Storage.UpdateMovie(ctx, movie)
+	if err != nil {
+		return domain.Movie{}, fmt.Errorf("failed to update movie id: %d, err: %w", id, err)
+	}
 
+	s.Audit.Record(ctx, domain.AuditMovie, id, domain.AuditUpdate, before, movie)
 	return movie, nil
 }
 
-func (s MoviesService) Delete(id int) error {
-	_, err := s.Storage.GetMovieByID(id)
+// Batch создает и обновляет записи пакетом в одной транзакции: сохраняются либо все
+// элементы, либо ни один, и тогда *domain.BatchError объясняет, что стало с каждым
+func (s MoviesService) Batch(ctx context.Context, items []domain.MovieBatchItem) ([]domain.BatchResult[domain.Movie], error) {
+	now := time.Now()
+	return runBatch(ctx, s.Storage, batch[domain.Movie]{
+		size: len(items),
+		validate: func(i int) error {
+			// обновление можно проверить только вместе с записью, это сделает Update
+			if items[i].ID != 0 {
+				return nil
+			}
+			return items[i].Create.Validate(now)
+		},
+		apply: func(ctx context.Context, i int) (domain.Movie, string, error) {
+			item := items[i]
+			if item.ID == 0 {
+				movie, err := s.Create(ctx, item.Create)
+				return movie, domain.BatchCreated, err
+			}
+			movie, err := s.Update(ctx, item.ID, item.Version, item.Update)
+			return movie, domain.BatchUpdated, err
+		},
+	})
+}
+
+// Delete с version, отличным от 0, удаляет запись, только если она все еще этой версии
+func (s MoviesService) Delete(ctx context.Context, id, version int) error {
+	movie, err := s.Storage.GetMovieByID(ctx, id)
 	if errors.Is(err, domain.ErrNotFound) {
 		return fmt.Errorf("movie id: %d, err: %w", id, err)
 	}
@@ -98,68 +154,103 @@ func (s MoviesService) Delete(id int) error {
 		return fmt.Errorf("failed to find movie, unexpected error: %w", err)
 	}
 
-	err = s.Storage.DeleteMovie(id)
+	err = s.Storage.DeleteMovie(ctx, id, version, s.DeletePolicy)
+	if errors.Is(err, domain.ErrVersionMismatch) {
+		return fmt.Errorf("movie id: %d, expected version: %d, err: %w", id, version, err)
+	}
 	if err != nil {
 		return err
 	}
 
+	s.Audit.Record(ctx, domain.AuditMovie, id, domain.AuditDelete, movie, nil)
 	return nil
 }
 
-func (s MoviesService) List(orderBy, sortBy, nameQuery, genreQuery string) []domain.Movie {
-	movies, err := s.Storage.GetAllMovies()
+// ListByActor - фильмография актера с теми же фильтрами, сортировкой и пагинацией, что и List
+func (s MoviesService) ListByActor(ctx context.Context, actorID int, query domain.MoviesQuery) (domain.Page[domain.Movie], error) {
+	// без проверки несуществующий актер был бы неотличим от актера без фильмов
+	_, err := s.Storage.GetActorByID(ctx, actorID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.Page[domain.Movie]{}, fmt.Errorf("actor id: %d, err: %w", actorID, err)
+	}
+
 	if err != nil {
-		return []domain.Movie{}
+		return domain.Page[domain.Movie]{}, fmt.Errorf("failed to find actor, unexpected error: %w", err)
 	}
-	var filteredMovies []domain.Movie
 
-	if nameQuery != "" || genreQuery != "" {
-		for i := range movies {
-			if (nameQuery != "" && strings.Contains(movies[i].Name, nameQuery)) ||
-				(genreQuery != "" && strings.Contains(movies[i].Genre, genreQuery)) {
-				filteredMovies = append(filteredMovies, movies[i])
-			}
-		}
+	query.ActorID = actorID
+	return s.List(ctx, query)
+}
 
-	} else {
-		filteredMovies = movies
+// Restore возвращает мягко удаленную запись из корзины
+func (s MoviesService) Restore(ctx context.Context, id int) (domain.Movie, error) {
+	movie, err := s.Storage.RestoreMovie(ctx, id)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.Movie{}, fmt.Errorf("movie id: %d is not in the trash, err: %w", id, err)
 	}
 
-	switch {
-	case sortBy == "" && orderBy == "":
-		movies = s.Storage.SortAndOrderByMovie("name", "asc", filteredMovies)
-	case sortBy == "" && orderBy != "":
-		movies = s.Storage.SortAndOrderByMovie("name", orderBy, filteredMovies)
-	case sortBy != "" && orderBy == "":
-		movies = s.Storage.SortAndOrderByMovie(sortBy, "asc", filteredMovies)
-	default:
-		movies = s.Storage.SortAndOrderByMovie(sortBy, orderBy, filteredMovies)
+	if err != nil {
+		return domain.Movie{}, fmt.Errorf("failed to restore movie: %w", err)
 	}
 
-	return movies
+	s.Audit.Record(ctx, domain.AuditMovie, id, domain.AuditRestore, nil, movie)
+	return movie, nil
 }
 
-func (s MoviesService) GetActorsByMovie(id int) ([]domain.Actor, error) {
-	actors, err := s.Storage.GetActorsByMovie(id) //add error
-	if errors.Is(err, domain.ErrNotFound) {
-		return []domain.Actor{}, err
+// History возвращает журнал изменений, он доступен и после удаления записи
+func (s MoviesService) History(ctx context.Context, id int) ([]domain.AuditEntry, error) {
+	entries, err := s.Audit.History(ctx, domain.AuditMovie, id)
+	if err != nil {
+		return []domain.AuditEntry{}, fmt.Errorf("failed to read movie history: %w", err)
 	}
 
-	if err != nil {
-		return []domain.Actor{}, fmt.Errorf("failed to find actors, unexpected error: %w", err)
+	// пустая история у существующей записи возможна, например, если журнал появился позже нее
+	if len(entries) == 0 {
+		if _, err = s.Get(ctx, id); err != nil {
+			return []domain.AuditEntry{}, err
+		}
 	}
 
-	return actors, nil
+	return entries, nil
 }
 
-func (s MoviesService) CreateActorsForMovie(id int, actorsByMovie []int) (int, []int, error) {
-	var movieID int
-	var actorsIDs []int
-	movieID, actorsIDs, err := s.Storage.CreateActorsByMovie(id, actorsByMovie)
+func (s MoviesService) List(ctx context.Context, query domain.MoviesQuery) (domain.Page[domain.Movie], error) {
+	if err := query.Normalize(); err != nil {
+		return domain.Page[domain.Movie]{}, err
+	}
+
+	var err error
+	if query.Sort, err = listSort(query.Sort, domain.MovieSortFields); err != nil {
+		return domain.Page[domain.Movie]{}, err
+	}
+
+	limit, err := pageLimit(query.Limit, query.Offset, query.After != nil)
+	if err != nil {
+		return domain.Page[domain.Movie]{}, err
+	}
+
+	if query.After != nil && query.After.Sort != query.Sort.String() {
+		return domain.Page[domain.Movie]{}, fmt.Errorf("%w: cursor was issued for another sort order", domain.ErrInvalidCursor)
+	}
 
+	query.Limit = limit + 1
+	movies, total, err := s.Storage.ListMovies(ctx, query)
 	if err != nil {
-		return 0, []int{0}, err
+		return domain.Page[domain.Movie]{}, err
+	}
+
+	page := domain.Page[domain.Movie]{
+		Items: movies,
+		Total: total,
+	}
+
+	if len(movies) > limit {
+		page.Items = movies[:limit]
+		page.NextCursor = domain.Cursor[domain.Movie]{
+			Sort: query.Sort.String(),
+			Last: movies[limit-1],
+		}.Encode()
 	}
 
-	return movieID, actorsIDs, nil
+	return page, nil
 }
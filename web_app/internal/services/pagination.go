@@ -0,0 +1,41 @@
+package services
+
+import (
+	"arch-demo/internal/domain"
+	"fmt"
+)
+
+// pageLimit проверяет параметры страницы и возвращает итоговый размер:
+// по умолчанию DefaultPageLimit, но не больше MaxPageLimit
+func pageLimit(limit, offset int, hasCursor bool) (int, error) {
+	if limit < 0 {
+		return 0, fmt.Errorf("%w: limit must be positive", domain.ErrInvalidPage)
+	}
+
+	if offset < 0 {
+		return 0, fmt.Errorf("%w: offset must not be negative", domain.ErrInvalidPage)
+	}
+
+	if offset > 0 && hasCursor {
+		return 0, fmt.Errorf("%w: offset and cursor can't be used together", domain.ErrInvalidPage)
+	}
+
+	switch {
+	case limit == 0:
+		return domain.DefaultPageLimit, nil
+	case limit > domain.MaxPageLimit:
+		return domain.MaxPageLimit, nil
+	}
+
+	return limit, nil
+}
+
+// listSort проверяет поля сортировки и подставляет сортировку по умолчанию - по первому
+// из допустимых полей. Сортировку разбирает и api, но сервис могут вызвать и в обход него.
+func listSort(sort domain.Sort, allowed []string) (domain.Sort, error) {
+	if len(sort) == 0 {
+		return domain.Sort{{Field: allowed[0]}}, nil
+	}
+
+	return domain.ParseSort(sort.String(), allowed)
+}
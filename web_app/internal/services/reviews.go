@@ -0,0 +1,214 @@
+package services
+
+import (
+	"arch-demo/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+)
+
+type ReviewsRepository interface {
+	UnitOfWork
+	GetMovieByID(ctx context.Context, id int) (domain.Movie, error)
+	GetReview(ctx context.Context, id int) (domain.Review, error)
+	// FindReview возвращает отзыв автора на фильм или ErrNotFound
+	FindReview(ctx context.Context, movieID int, author string) (domain.Review, error)
+	InsertReview(ctx context.Context, review domain.Review) (domain.Review, error)
+	// UpdateReview меняет оценку, текст и статус отзыва review.ID
+	UpdateReview(ctx context.Context, review domain.Review) (domain.Review, error)
+	ListReviews(ctx context.Context, query domain.ReviewsQuery) ([]domain.Review, int, error)
+	// AddMovieScore меняет оценку фильма через domain.MovieScore.Add, а с ней версию
+	// и время изменения фильма: оценка - часть его представления
+	AddMovieScore(ctx context.Context, movieID int, rating int8, delta int) error
+}
+
+// ReviewsService - оценки и отзывы зрителей. Оценка фильма пересчитывается в той же
+// транзакции, что и отзыв, поэтому всегда совпадает с одобренными отзывами.
+type ReviewsService struct {
+	Storage ReviewsRepository
+	// ScoreChanged вызывается после изменения оценки фильма, например чтобы сбросить
+	// кэш списков фильмов. Может быть nil.
+	ScoreChanged func()
+}
+
+func NewReviewsService(storage ReviewsRepository, scoreChanged func()) ReviewsService {
+	return ReviewsService{
+		Storage:      storage,
+		ScoreChanged: scoreChanged,
+	}
+}
+
+// Submit сохраняет отзыв вызывающего на фильм, прежний отзыв того же автора заменяется.
+// created - отзыва от этого автора еще не было. Автор - аутентифицированный вызывающий:
+// по неподтвержденному имени один клиент заменял бы отзывы другого, поэтому без
+// аутентификации Submit возвращает ErrUnauthenticated.
+func (s ReviewsService) Submit(ctx context.Context, movieID int, review domain.Review) (domain.Review, bool, error) {
+	principal, ok := domain.PrincipalFrom(ctx)
+	if !ok {
+		return domain.Review{}, false, fmt.Errorf("%w: reviews are keyed by their author", domain.ErrUnauthenticated)
+	}
+	if err := review.Validate(); err != nil {
+		return domain.Review{}, false, err
+	}
+
+	review.MovieID = movieID
+	review.Author = principal.Subject
+	review.Status = domain.ReviewApproved
+	if review.Text != "" {
+		review.Status = domain.ReviewPending
+	}
+
+	var saved domain.Review
+	var created, scoreChanged bool
+	err := s.Storage.Atomic(ctx, func(ctx context.Context) error {
+		if err := s.movieExists(ctx, movieID); err != nil {
+			return err
+		}
+
+		previous, err := s.Storage.FindReview(ctx, movieID, review.Author)
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			created = true
+			saved, err = s.Storage.InsertReview(ctx, review)
+		case err != nil:
+			return fmt.Errorf("failed to find review, unexpected error: %w", err)
+		default:
+			review.ID = previous.ID
+			saved, err = s.Storage.UpdateReview(ctx, review)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to save review, movie id: %d, err: %w", movieID, err)
+		}
+
+		scoreChanged, err = s.rescore(ctx, previous, saved)
+		return err
+	})
+	if err != nil {
+		return domain.Review{}, false, err
+	}
+
+	s.notify(scoreChanged)
+	return saved, created, nil
+}
+
+// Moderate одобряет или отклоняет отзыв на фильм movieID
+func (s ReviewsService) Moderate(ctx context.Context, movieID, reviewID int, moderation domain.ReviewModeration) (domain.Review, error) {
+	if err := moderation.Validate(); err != nil {
+		return domain.Review{}, err
+	}
+
+	var saved domain.Review
+	var scoreChanged bool
+	err := s.Storage.Atomic(ctx, func(ctx context.Context) error {
+		if err := s.movieExists(ctx, movieID); err != nil {
+			return err
+		}
+
+		previous, err := s.Storage.GetReview(ctx, reviewID)
+		if err == nil && previous.MovieID != movieID {
+			err = domain.ErrNotFound
+		}
+		if errors.Is(err, domain.ErrNotFound) {
+			return fmt.Errorf("review id: %d, err: %w", reviewID, err)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to find review, unexpected error: %w", err)
+		}
+
+		review := previous
+		review.Status = moderation.Status
+		if saved, err = s.Storage.UpdateReview(ctx, review); err != nil {
+			return fmt.Errorf("failed to save review id: %d, err: %w", reviewID, err)
+		}
+
+		scoreChanged, err = s.rescore(ctx, previous, saved)
+		return err
+	})
+	if err != nil {
+		return domain.Review{}, err
+	}
+
+	s.notify(scoreChanged)
+	return saved, nil
+}
+
+// List отдает страницу отзывов, новые первыми. Для отзывов на фильм фильм должен существовать.
+func (s ReviewsService) List(ctx context.Context, query domain.ReviewsQuery) (domain.Page[domain.Review], error) {
+	switch query.Status {
+	case "", domain.ReviewPending, domain.ReviewApproved, domain.ReviewRejected:
+	default:
+		return domain.Page[domain.Review]{}, fmt.Errorf("%w: status must be one of pending, approved, rejected", domain.ErrInvalidFilter)
+	}
+
+	if query.MovieID != 0 {
+		if err := s.movieExists(ctx, query.MovieID); err != nil {
+			return domain.Page[domain.Review]{}, err
+		}
+	}
+
+	limit, err := pageLimit(query.Limit, query.Offset, query.After != nil)
+	if err != nil {
+		return domain.Page[domain.Review]{}, err
+	}
+	if query.After != nil && query.After.Sort != domain.ReviewSort {
+		return domain.Page[domain.Review]{}, fmt.Errorf("%w: cursor was issued for another list", domain.ErrInvalidCursor)
+	}
+
+	// запрашиваем на одну запись больше, чтобы понять, есть ли следующая страница
+	query.Limit = limit + 1
+	reviews, total, err := s.Storage.ListReviews(ctx, query)
+	if err != nil {
+		return domain.Page[domain.Review]{}, err
+	}
+
+	page := domain.Page[domain.Review]{
+		Items: reviews,
+		Total: total,
+	}
+	if len(reviews) > limit {
+		page.Items = reviews[:limit]
+		page.NextCursor = domain.Cursor[domain.Review]{Sort: domain.ReviewSort, Last: reviews[limit-1]}.Encode()
+	}
+
+	return page, nil
+}
+
+func (s ReviewsService) movieExists(ctx context.Context, movieID int) error {
+	_, err := s.Storage.GetMovieByID(ctx, movieID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return fmt.Errorf("movie id: %d, err: %w", movieID, err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find movie, unexpected error: %w", err)
+	}
+
+	return nil
+}
+
+// rescore убирает из оценки фильма прежний отзыв и добавляет новый, если они одобрены.
+// Пустой previous - отзыва раньше не было.
+func (s ReviewsService) rescore(ctx context.Context, previous, current domain.Review) (bool, error) {
+	counted := func(r domain.Review) bool { return r.ID != 0 && r.Status == domain.ReviewApproved }
+	if counted(previous) == counted(current) && (!counted(current) || previous.Rating == current.Rating) {
+		return false, nil
+	}
+
+	if counted(previous) {
+		if err := s.Storage.AddMovieScore(ctx, previous.MovieID, previous.Rating, -1); err != nil {
+			return false, fmt.Errorf("failed to update movie score, movie id: %d, err: %w", previous.MovieID, err)
+		}
+	}
+	if counted(current) {
+		if err := s.Storage.AddMovieScore(ctx, current.MovieID, current.Rating, 1); err != nil {
+			return false, fmt.Errorf("failed to update movie score, movie id: %d, err: %w", current.MovieID, err)
+		}
+	}
+
+	return true, nil
+}
+
+func (s ReviewsService) notify(scoreChanged bool) {
+	if scoreChanged && s.ScoreChanged != nil {
+		s.ScoreChanged()
+	}
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"arch-demo/internal/domain"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxSearchLength - длиннее запрос не бывает осмысленным, а оценка стоит дороже с каждым словом
+const maxSearchLength = 200
+
+// SearchRepository отбирает кандидатов для поиска. В ответе должны быть все записи,
+// подходящие под query.Terms по правилам domain.SearchScore; лишние допустимы,
+// окончательно их отбирает и упорядочивает SearchService.
+type SearchRepository interface {
+	SearchActors(ctx context.Context, query domain.SearchQuery) ([]domain.Actor, error)
+	SearchMovies(ctx context.Context, query domain.SearchQuery) ([]domain.Movie, error)
+}
+
+type SearchService struct {
+	Storage SearchRepository
+}
+
+func NewSearchService(storage SearchRepository) SearchService {
+	return SearchService{
+		Storage: storage,
+	}
+}
+
+// Search ищет актеров и фильмы по названию без учета регистра и диакритики, с опечатками.
+// Оценка считается одинаково для всех хранилищ, поэтому и порядок результатов у них один.
+func (s SearchService) Search(ctx context.Context, query domain.SearchQuery) ([]domain.SearchHit, error) {
+	query, err := prepareSearch(query)
+	if err != nil {
+		return []domain.SearchHit{}, err
+	}
+
+	hits := make([]domain.SearchHit, 0)
+	if query.Includes(domain.SearchActors) {
+		actors, err := s.Storage.SearchActors(ctx, query)
+		if err != nil {
+			return []domain.SearchHit{}, fmt.Errorf("failed to search actors: %w", err)
+		}
+		for i := range actors {
+			if score := domain.SearchScore(query.Terms, actors[i].Name); score > 0 {
+				hits = append(hits, domain.SearchHit{Type: domain.SearchActors, Score: roundScore(score), Actor: &actors[i]})
+			}
+		}
+	}
+
+	if query.Includes(domain.SearchMovies) {
+		movies, err := s.Storage.SearchMovies(ctx, query)
+		if err != nil {
+			return []domain.SearchHit{}, fmt.Errorf("failed to search movies: %w", err)
+		}
+		for i := range movies {
+			if score := domain.SearchScore(query.Terms, movies[i].Name); score > 0 {
+				hits = append(hits, domain.SearchHit{Type: domain.SearchMovies, Score: roundScore(score), Movie: &movies[i]})
+			}
+		}
+	}
+
+	slices.SortFunc(hits, domain.CompareSearchHits)
+	if len(hits) > query.Limit {
+		hits = hits[:query.Limit]
+	}
+
+	return hits, nil
+}
+
+func prepareSearch(query domain.SearchQuery) (domain.SearchQuery, error) {
+	query.Text = strings.TrimSpace(query.Text)
+	if query.Text == "" {
+		return query, fmt.Errorf("%w: q is required", domain.ErrInvalidSearch)
+	}
+	if utf8.RuneCountInString(query.Text) > maxSearchLength {
+		return query, fmt.Errorf("%w: q must be at most %d characters", domain.ErrInvalidSearch, maxSearchLength)
+	}
+
+	query.Terms = domain.NormalizeSearch(query.Text)
+	if len(query.Terms) == 0 {
+		return query, fmt.Errorf("%w: q must contain letters or digits", domain.ErrInvalidSearch)
+	}
+
+	for _, kind := range query.Types {
+		if kind != domain.SearchActors && kind != domain.SearchMovies {
+			return query, fmt.Errorf("%w: unknown type %q, expected %s or %s", domain.ErrInvalidSearch, kind, domain.SearchActors, domain.SearchMovies)
+		}
+	}
+
+	switch {
+	case query.Limit < 0:
+		return query, fmt.Errorf("%w: limit must be positive", domain.ErrInvalidSearch)
+	case query.Limit == 0:
+		query.Limit = domain.DefaultSearchLimit
+	case query.Limit > domain.MaxSearchLimit:
+		query.Limit = domain.MaxSearchLimit
+	}
+
+	return query, nil
+}
+
+// roundScore - оценке хватает трех знаков, остальное - шум вычислений с плавающей точкой
+func roundScore(score float64) float64 {
+	return math.Round(score*1000) / 1000
+}
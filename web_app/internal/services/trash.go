@@ -0,0 +1,47 @@
+package services
+
+import (
+	"arch-demo/internal/domain"
+	"context"
+	"time"
+)
+
+type TrashRepository interface {
+	ListDeletedActors(ctx context.Context) ([]domain.Actor, error)
+	ListDeletedMovies(ctx context.Context) ([]domain.Movie, error)
+	// PurgeDeleted возвращает число окончательно удаленных актеров и фильмов
+	PurgeDeleted(ctx context.Context, before time.Time) (int, int, error)
+}
+
+// TrashService - корзина мягко удаленных записей. Записи хранятся Retention,
+// после чего Purge удаляет их окончательно.
+type TrashService struct {
+	Storage   TrashRepository
+	Retention time.Duration
+}
+
+func NewTrashService(storage TrashRepository, retention time.Duration) TrashService {
+	return TrashService{
+		Storage:   storage,
+		Retention: retention,
+	}
+}
+
+func (s TrashService) List(ctx context.Context) (domain.Trash, error) {
+	actors, err := s.Storage.ListDeletedActors(ctx)
+	if err != nil {
+		return domain.Trash{}, err
+	}
+
+	movies, err := s.Storage.ListDeletedMovies(ctx)
+	if err != nil {
+		return domain.Trash{}, err
+	}
+
+	return domain.Trash{Actors: actors, Movies: movies}, nil
+}
+
+// Purge удаляет записи, пролежавшие в корзине дольше Retention
+func (s TrashService) Purge(ctx context.Context, now time.Time) (int, int, error) {
+	return s.Storage.PurgeDeleted(ctx, now.Add(-s.Retention))
+}
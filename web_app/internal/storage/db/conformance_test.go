@@ -0,0 +1,12 @@
+package db
+
+import (
+	"arch-demo/internal/storage/storagetest"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	storagetest.RunAll(t, func(t *testing.T) storagetest.FullStorage {
+		return newTestStorage(t)
+	})
+}
@@ -2,26 +2,39 @@ package db
 
 import (
 	"arch-demo/internal/domain"
+	"context"
 	"database/sql"
 	"errors"
-	"sort"
-	"strings"
+	"time"
 )
 
 type StorageDB struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect Dialect
 }
 
-func NewDbStorage(dbCon *sql.DB) *StorageDB {
-	return &StorageDB{
-		db: dbCon,
+// NewDbStorage отказывается работать со схемой, версия которой не совпадает
+// с последней известной миграцией: запросы ниже написаны под конкретную схему
+func NewDbStorage(dbCon *sql.DB, dialect Dialect) (*StorageDB, error) {
+	migrator, err := NewMigrator(dbCon, dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = migrator.CheckVersion(); err != nil {
+		return nil, err
 	}
+
+	return &StorageDB{
+		db:      dbCon,
+		dialect: dialect,
+	}, nil
 }
 
-func (s *StorageDB) InsertActor(actor domain.Actor) (domain.Actor, error) {
-	query := `insert into actors (name, birth_year, country_of_birth, gender) values ($1, $2, $3, $4) returning id, name, birth_year, country_of_birth, gender`
+func (s *StorageDB) InsertActor(ctx context.Context, actor domain.Actor) (domain.Actor, error) {
+	query := `insert into actors (name, birth_year, country_of_birth, gender, updated_at) values ($1, $2, $3, $4, $5) returning id, name, birth_year, country_of_birth, gender, version, updated_at`
 	var newActor domain.Actor
-	err := s.db.QueryRow(query, actor.Name, actor.BirthYear, actor.CountryOfBirth, actor.Gender).Scan(&newActor.ID, &newActor.Name, &newActor.BirthYear, &newActor.CountryOfBirth, &newActor.Gender)
+	err := s.conn(ctx).QueryRowContext(ctx, query, actor.Name, actor.BirthYear, actor.CountryOfBirth, actor.Gender, time.Now().UTC()).Scan(&newActor.ID, &newActor.Name, &newActor.BirthYear, &newActor.CountryOfBirth, &newActor.Gender, &newActor.Version, &newActor.UpdatedAt)
 	if err != nil {
 		return domain.Actor{}, err
 	}
@@ -29,13 +42,13 @@ func (s *StorageDB) InsertActor(actor domain.Actor) (domain.Actor, error) {
 	return newActor, nil
 }
 
-func (s *StorageDB) IsActorExists(actor domain.Actor) (bool, error) {
-	query := `select id from actors where name = $1`
-	var id int8
-	err := s.db.QueryRow(query, actor.Name).Scan(&id)
+func (s *StorageDB) IsActorExists(ctx context.Context, actor domain.Actor) (bool, error) {
+	query := `select id from actors where name = $1 and deleted_at is null`
+	var id int
+	err := s.conn(ctx).QueryRowContext(ctx, query, actor.Name).Scan(&id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return false, domain.ErrNotFound
+			return false, nil
 		}
 		return false, err
 	}
@@ -43,13 +56,13 @@ func (s *StorageDB) IsActorExists(actor domain.Actor) (bool, error) {
 	return true, nil
 }
 
-func (s *StorageDB) GetActorByID(id int) (domain.Actor, error) {
-	query := `select id, name, birth_year, country_of_birth, gender from actors where id = $1`
+func (s *StorageDB) GetActorByID(ctx context.Context, id int) (domain.Actor, error) {
+	query := `select id, name, birth_year, country_of_birth, gender, version, updated_at from actors where id = $1 and deleted_at is null`
 	var newActor domain.Actor
-	err := s.db.QueryRow(query, id).Scan(&newActor.ID, &newActor.Name, &newActor.BirthYear, &newActor.CountryOfBirth, &newActor.Gender)
+	err := s.conn(ctx).QueryRowContext(ctx, query, id).Scan(&newActor.ID, &newActor.Name, &newActor.BirthYear, &newActor.CountryOfBirth, &newActor.Gender, &newActor.Version, &newActor.UpdatedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return domain.Actor{}, err
+			return domain.Actor{}, domain.ErrNotFound
 		}
 		return domain.Actor{}, err
 	}
@@ -57,30 +70,80 @@ func (s *StorageDB) GetActorByID(id int) (domain.Actor, error) {
 	return newActor, nil
 }
 
-func (s *StorageDB) DeleteActor(id int) error {
-	query := `DELETE FROM actors WHERE id = $1;`
-	_, err := s.db.Exec(query, id)
-	if err != nil {
-		return err
+// DeleteActor с version, отличным от 0, удаляет актера, только если его версия не изменилась
+func (s *StorageDB) DeleteActor(ctx context.Context, id, version int, policy domain.DeletePolicy) error {
+	if policy == domain.DeleteSoft {
+		query := `update actors set deleted_at = $1, updated_at = $1, version = version + 1 where id = $2 and deleted_at is null`
+		args := []any{time.Now().UTC(), id}
+		if version != 0 {
+			query += ` and version = $3`
+			args = append(args, version)
+		}
+
+		return s.execVersioned(ctx, "actors", id, query, args...)
 	}
 
-	return nil
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		// блокировка строки актера не дает изменить его версию между проверкой и удалением,
+		// а при restrict еще и добавить его в фильм
+		var current int
+		query := s.dialect.lockRows(`select version from actors where id = $1 and deleted_at is null`, lockUpdate)
+		err := tx.QueryRowContext(ctx, query, id).Scan(&current)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if version != 0 && current != version {
+			return domain.ErrVersionMismatch
+		}
+
+		if policy == domain.DeleteRestrict {
+			movies, err := referencingMovies(ctx, tx, id)
+			if err != nil {
+				return err
+			}
+			if len(movies) > 0 {
+				return &domain.ReferencedError{Movies: movies}
+			}
+		}
+
+		// связи в movie_actors удаляет on delete cascade
+		_, err = tx.ExecContext(ctx, `delete from actors where id = $1`, id)
+		return err
+	})
 }
 
-func (s *StorageDB) UpdateActor(actorUpdate domain.Actor) error {
-	query := `update users set name = $1, birth_year = $2, country_of_birth = $3, gender = $4 where id = $5;`
-	_, err := s.db.Exec(query, actorUpdate.Name, actorUpdate.BirthYear, actorUpdate.CountryOfBirth, actorUpdate.Gender, actorUpdate.ID)
+// execVersioned выполняет изменение с условием на версию. Если запись не найдена,
+// это не ошибка: удаление несуществующей записи ничего не делает.
+func (s *StorageDB) execVersioned(ctx context.Context, table string, id int, query string, args ...any) error {
+	result, err := s.conn(ctx).ExecContext(ctx, query, args...)
 	if err != nil {
 		return err
 	}
 
+	n, err := result.RowsAffected()
+	if err != nil || n > 0 {
+		return err
+	}
+
+	if err = versionMissed(ctx, s.conn(ctx), table, id); !errors.Is(err, domain.ErrNotFound) {
+		return err
+	}
+
 	return nil
 }
 
-func (s *StorageDB) GetAllActors() ([]domain.Actor, error) {
-	rows, err := s.db.Query("select * from actors")
+// referencingMovies - видимые фильмы, в составе которых есть актер
+func referencingMovies(ctx context.Context, tx *sql.Tx, actorID int) ([]domain.Movie, error) {
+	query := `select ` + movieColumns + ` from movies
+				where id in (select movie_id from movie_actors where actor_id = $1) and deleted_at is null
+				order by id`
+
+	rows, err := tx.QueryContext(ctx, query, actorID)
 	if err != nil {
-		return []domain.Actor{}, err
+		return nil, err
 	}
 	defer func(rows *sql.Rows) {
 		err = rows.Close()
@@ -89,71 +152,86 @@ func (s *StorageDB) GetAllActors() ([]domain.Actor, error) {
 		}
 	}(rows)
 
-	var actors []domain.Actor
+	var movies []domain.Movie
 	for rows.Next() {
-		var actor domain.Actor
-		if err = rows.Scan(&actor.ID, &actor.Name, &actor.BirthYear, &actor.CountryOfBirth, &actor.Gender); err != nil {
-			return []domain.Actor{}, err
+		movie, err := scanMovie(rows)
+		if err != nil {
+			return nil, err
 		}
-		actors = append(actors, actor)
-	}
-	if err = rows.Err(); err != nil {
-		return []domain.Actor{}, err
+		movies = append(movies, movie)
 	}
 
-	return actors, nil
+	return movies, rows.Err()
 }
 
-func (s *StorageDB) SortAndOrderByActor(sortOrder, orderBy string, actors []domain.Actor) []domain.Actor {
-	switch {
-	case sortOrder == "name":
-		if orderBy == "" || orderBy == "asc" {
-			sort.Slice(actors, func(i, j int) bool {
-				return actors[i].Name < actors[j].Name
-			})
-		} else {
-			sort.Slice(actors, func(i, j int) bool {
-				return actors[i].Name > actors[j].Name
-			})
-		}
-	case sortOrder == "country":
-		if orderBy == "" || orderBy == "asc" {
-			sort.Slice(actors, func(i, j int) bool {
-				return actors[i].CountryOfBirth < actors[j].CountryOfBirth
-			})
-		} else {
-			sort.Slice(actors, func(i, j int) bool {
-				return actors[i].CountryOfBirth > actors[j].CountryOfBirth
-			})
-		}
-	case sortOrder == "birthdate":
-		if orderBy == "" || orderBy == "asc" {
-			sort.Slice(actors, func(i, j int) bool {
-				return actors[i].BirthYear < actors[j].BirthYear
-			})
-		} else {
-			sort.Slice(actors, func(i, j int) bool {
-				return actors[i].BirthYear > actors[j].BirthYear
-			})
-		}
+// UpdateActor записывает актера, только если его версия в базе все еще actorUpdate.Version
+func (s *StorageDB) UpdateActor(ctx context.Context, actorUpdate domain.Actor) (domain.Actor, error) {
+	query := `update actors set name = $1, birth_year = $2, country_of_birth = $3, gender = $4, version = version + 1, updated_at = $5
+				where id = $6 and version = $7 and deleted_at is null
+				returning id, name, birth_year, country_of_birth, gender, version, updated_at`
+	var actor domain.Actor
+	err := s.conn(ctx).QueryRowContext(ctx, query, actorUpdate.Name, actorUpdate.BirthYear, actorUpdate.CountryOfBirth, actorUpdate.Gender,
+		time.Now().UTC(), actorUpdate.ID, actorUpdate.Version).
+		Scan(&actor.ID, &actor.Name, &actor.BirthYear, &actor.CountryOfBirth, &actor.Gender, &actor.Version, &actor.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Actor{}, versionMissed(ctx, s.conn(ctx), "actors", actorUpdate.ID)
 	}
+	if err != nil {
+		return domain.Actor{}, err
+	}
+
+	return actor, nil
+}
 
-	return actors
+var actorSortColumns = map[string]sortColumn{
+	domain.ActorSortName:      {name: "name", text: true},
+	domain.ActorSortCountry:   {name: "country_of_birth", text: true},
+	domain.ActorSortBirthYear: {name: "birth_year"},
 }
 
-func (s *StorageDB) FilterActors(nameQuery, countryOfBirthQuery string) ([]domain.Actor, error) {
-	var filteredActors []domain.Actor
-	actors, err := s.GetAllActors()
+func (s *StorageDB) ListActors(ctx context.Context, query domain.ActorsQuery) ([]domain.Actor, int, error) {
+	b := queryBuilder{dialect: s.dialect}
+	b.where("deleted_at is null")
+
+	b.text("name", query.Name)
+	b.text("country_of_birth", query.Country)
+	b.text("gender", query.Gender)
+	whereRange(&b, "birth_year", query.BirthYear)
+
+	total, err := b.count(ctx, s.conn(ctx), "actors")
 	if err != nil {
-		return []domain.Actor{}, err
+		return []domain.Actor{}, 0, err
+	}
+
+	keys := sortKeys(query.Sort, actorSortColumns)
+	if query.After != nil {
+		b.after(keys, query.After.Last.SortValue, query.After.Last.ID, query.Sort.IDDesc())
 	}
+	b.sortBy(keys, query.Sort.IDDesc())
+	b.page(query.Offset, query.Limit)
 
-	for i := range actors {
-		if (nameQuery != "" && strings.Contains(actors[i].Name, nameQuery)) ||
-			(countryOfBirthQuery != "" && strings.Contains(actors[i].CountryOfBirth, countryOfBirthQuery)) {
-			filteredActors = append(filteredActors, actors[i])
+	rows, err := s.conn(ctx).QueryContext(ctx, b.build(`select id, name, birth_year, country_of_birth, gender, version, updated_at from actors`), b.args...)
+	if err != nil {
+		return []domain.Actor{}, 0, err
+	}
+	defer func(rows *sql.Rows) {
+		err = rows.Close()
+		if err != nil {
+			return
+		}
+	}(rows)
+
+	actors := []domain.Actor{}
+	for rows.Next() {
+		var actor domain.Actor
+		if err = rows.Scan(&actor.ID, &actor.Name, &actor.BirthYear, &actor.CountryOfBirth, &actor.Gender, &actor.Version, &actor.UpdatedAt); err != nil {
+			return []domain.Actor{}, 0, err
 		}
+		actors = append(actors, actor)
+	}
+	if err = rows.Err(); err != nil {
+		return []domain.Actor{}, 0, err
 	}
 
-	return filteredActors, nil
+	return actors, total, nil
 }
@@ -0,0 +1,57 @@
+package db
+
+import (
+	"arch-demo/internal/domain"
+	"context"
+	"database/sql"
+	"encoding/json"
+)
+
+func (s *StorageDB) InsertAuditEntry(ctx context.Context, entry domain.AuditEntry) error {
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return err
+	}
+
+	query := `insert into audit_log (entity, entity_id, action, caller, changes, created_at)
+				values ($1, $2, $3, $4, $5, $6)`
+	_, err = s.conn(ctx).ExecContext(ctx, query, entry.Entity, entry.EntityID, entry.Action, entry.Caller, string(changes), entry.At.UTC())
+
+	return err
+}
+
+func (s *StorageDB) ListAuditEntries(ctx context.Context, entity string, entityID int) ([]domain.AuditEntry, error) {
+	query := `select id, entity, entity_id, action, caller, changes, created_at from audit_log
+				where entity = $1 and entity_id = $2
+				order by id`
+
+	rows, err := s.conn(ctx).QueryContext(ctx, query, entity, entityID)
+	if err != nil {
+		return []domain.AuditEntry{}, err
+	}
+	defer func(rows *sql.Rows) {
+		err = rows.Close()
+		if err != nil {
+			return
+		}
+	}(rows)
+
+	entries := []domain.AuditEntry{}
+	for rows.Next() {
+		var entry domain.AuditEntry
+		var changes []byte
+		if err = rows.Scan(&entry.ID, &entry.Entity, &entry.EntityID, &entry.Action, &entry.Caller, &changes, &entry.At); err != nil {
+			return []domain.AuditEntry{}, err
+		}
+		if err = json.Unmarshal(changes, &entry.Changes); err != nil {
+			return []domain.AuditEntry{}, err
+		}
+		entry.At = entry.At.UTC()
+		entries = append(entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return []domain.AuditEntry{}, err
+	}
+
+	return entries, nil
+}
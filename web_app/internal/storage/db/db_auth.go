@@ -0,0 +1,169 @@
+package db
+
+import (
+	"arch-demo/internal/domain"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// rowScanner - *sql.Row или *sql.Rows, чтобы одиночные запросы и списки читались одинаково
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+const userColumns = `id, name, role, disabled, created_at`
+
+func (s *StorageDB) InsertUser(ctx context.Context, user domain.User) (domain.User, error) {
+	_, err := s.GetUserByName(ctx, user.Name)
+	if err == nil {
+		return domain.User{}, domain.ErrExists
+	}
+	if !errors.Is(err, domain.ErrNotFound) {
+		return domain.User{}, err
+	}
+
+	query := `insert into users (name, role, disabled, created_at) values ($1, $2, $3, $4) returning ` + userColumns
+
+	return scanUser(s.conn(ctx).QueryRowContext(ctx, query, user.Name, user.Role, user.Disabled, time.Now().UTC()))
+}
+
+func (s *StorageDB) GetUserByID(ctx context.Context, id int) (domain.User, error) {
+	return scanUser(s.conn(ctx).QueryRowContext(ctx, `select `+userColumns+` from users where id = $1`, id))
+}
+
+func (s *StorageDB) GetUserByName(ctx context.Context, name string) (domain.User, error) {
+	return scanUser(s.conn(ctx).QueryRowContext(ctx, `select `+userColumns+` from users where name = $1`, name))
+}
+
+func (s *StorageDB) ListUsers(ctx context.Context) ([]domain.User, error) {
+	rows, err := s.conn(ctx).QueryContext(ctx, `select `+userColumns+` from users order by id`)
+	if err != nil {
+		return []domain.User{}, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	users := []domain.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return []domain.User{}, err
+		}
+		users = append(users, user)
+	}
+	if err = rows.Err(); err != nil {
+		return []domain.User{}, err
+	}
+
+	return users, nil
+}
+
+// UpdateUser меняет роль и блокировку, имя и время создания остаются прежними
+func (s *StorageDB) UpdateUser(ctx context.Context, user domain.User) (domain.User, error) {
+	query := `update users set role = $2, disabled = $3 where id = $1 returning ` + userColumns
+
+	return scanUser(s.conn(ctx).QueryRowContext(ctx, query, user.ID, user.Role, user.Disabled))
+}
+
+// DeleteUser - ключи пользователя удаляет on delete cascade
+func (s *StorageDB) DeleteUser(ctx context.Context, id int) error {
+	return s.deleteByID(ctx, `delete from users where id = $1`, id)
+}
+
+func scanUser(row rowScanner) (domain.User, error) {
+	var user domain.User
+	err := row.Scan(&user.ID, &user.Name, &user.Role, &user.Disabled, &user.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.User{}, domain.ErrNotFound
+	}
+	if err != nil {
+		return domain.User{}, err
+	}
+	user.CreatedAt = user.CreatedAt.UTC()
+
+	return user, nil
+}
+
+const apiKeyColumns = `id, user_id, name, prefix, hash, created_at`
+
+func (s *StorageDB) InsertAPIKey(ctx context.Context, key domain.APIKey) (domain.APIKey, error) {
+	_, err := s.GetUserByID(ctx, key.UserID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.APIKey{}, domain.ErrNotExists
+	}
+	if err != nil {
+		return domain.APIKey{}, err
+	}
+
+	query := `insert into api_keys (user_id, name, prefix, hash, created_at) values ($1, $2, $3, $4, $5)
+				returning ` + apiKeyColumns
+
+	return scanAPIKey(s.conn(ctx).QueryRowContext(ctx, query, key.UserID, key.Name, key.Prefix, key.Hash, time.Now().UTC()))
+}
+
+func (s *StorageDB) GetAPIKeyByHash(ctx context.Context, hash string) (domain.APIKey, error) {
+	return scanAPIKey(s.conn(ctx).QueryRowContext(ctx, `select `+apiKeyColumns+` from api_keys where hash = $1`, hash))
+}
+
+func (s *StorageDB) ListAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	rows, err := s.conn(ctx).QueryContext(ctx, `select `+apiKeyColumns+` from api_keys order by id`)
+	if err != nil {
+		return []domain.APIKey{}, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	keys := []domain.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return []domain.APIKey{}, err
+		}
+		keys = append(keys, key)
+	}
+	if err = rows.Err(); err != nil {
+		return []domain.APIKey{}, err
+	}
+
+	return keys, nil
+}
+
+func (s *StorageDB) DeleteAPIKey(ctx context.Context, id int) error {
+	return s.deleteByID(ctx, `delete from api_keys where id = $1`, id)
+}
+
+func scanAPIKey(row rowScanner) (domain.APIKey, error) {
+	var key domain.APIKey
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.Hash, &key.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.APIKey{}, domain.ErrNotFound
+	}
+	if err != nil {
+		return domain.APIKey{}, err
+	}
+	key.CreatedAt = key.CreatedAt.UTC()
+
+	return key, nil
+}
+
+// deleteByID возвращает ErrNotFound, если query ничего не удалил
+func (s *StorageDB) deleteByID(ctx context.Context, query string, id int) error {
+	result, err := s.conn(ctx).ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return domain.ErrNotFound
+	}
+
+	return nil
+}
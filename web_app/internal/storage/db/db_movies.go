@@ -2,16 +2,15 @@ package db
 
 import (
 	"arch-demo/internal/domain"
+	"context"
 	"database/sql"
 	"errors"
-	"github.com/lib/pq"
-	"sort"
-	"strconv"
-	"strings"
+	"fmt"
+	"time"
 )
 
-func (s *StorageDB) InsertMovie(movie domain.Movie) (domain.Movie, error) {
-	isMovieExists, err := s.IsMovieExists(movie)
+func (s *StorageDB) InsertMovie(ctx context.Context, movie domain.Movie) (domain.Movie, error) {
+	isMovieExists, err := s.IsMovieExists(ctx, movie)
 	if err != nil {
 		return domain.Movie{}, err
 	}
@@ -19,13 +18,11 @@ func (s *StorageDB) InsertMovie(movie domain.Movie) (domain.Movie, error) {
 		return domain.Movie{}, domain.ErrExists
 	}
 
-	query := `insert into movies (name, release_date, country, genre, rating) 
-				values ($1, $2, $3, $4, $5) 
-				returning id, name, release_date, country, genre, rating`
+	query := `insert into movies (name, release_date, country, genre, rating, updated_at) 
+				values ($1, $2, $3, $4, $5, $6) 
+				returning ` + movieColumns
 
-	var newMovie domain.Movie
-	err = s.db.QueryRow(query, movie.Name, movie.ReleaseDate, movie.Country, movie.Genre, movie.Rating).
-		Scan(&newMovie.ID, &newMovie.Name, &newMovie.ReleaseDate, &newMovie.Country, &newMovie.Genre, &newMovie.Rating)
+	newMovie, err := scanMovie(s.conn(ctx).QueryRowContext(ctx, query, movie.Name, movie.ReleaseDate.UTC(), movie.Country, movie.Genre, movie.Rating, time.Now().UTC()))
 	if err != nil {
 		return domain.Movie{}, err
 	}
@@ -33,10 +30,10 @@ func (s *StorageDB) InsertMovie(movie domain.Movie) (domain.Movie, error) {
 	return newMovie, nil
 }
 
-func (s *StorageDB) IsMovieExists(movie domain.Movie) (bool, error) {
-	query := `select id from movies where name = $1`
-	var id int8
-	err := s.db.QueryRow(query, movie.Name).Scan(&id)
+func (s *StorageDB) IsMovieExists(ctx context.Context, movie domain.Movie) (bool, error) {
+	query := `select id from movies where name = $1 and deleted_at is null`
+	var id int
+	err := s.conn(ctx).QueryRowContext(ctx, query, movie.Name).Scan(&id)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -48,13 +45,12 @@ func (s *StorageDB) IsMovieExists(movie domain.Movie) (bool, error) {
 	return true, nil
 }
 
-func (s *StorageDB) GetMovieByID(id int) (domain.Movie, error) {
-	query := `select id, name, release_date, country, genre, rating from movies where id = $1`
-	var newMovie domain.Movie
-	err := s.db.QueryRow(query, id).Scan(&newMovie.ID, &newMovie.Name, &newMovie.ReleaseDate, &newMovie.Country, &newMovie.Genre, &newMovie.Rating)
+func (s *StorageDB) GetMovieByID(ctx context.Context, id int) (domain.Movie, error) {
+	query := `select ` + movieColumns + ` from movies where id = $1 and deleted_at is null`
+	newMovie, err := scanMovie(s.conn(ctx).QueryRowContext(ctx, query, id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return domain.Movie{}, err
+			return domain.Movie{}, domain.ErrNotFound
 		}
 		return domain.Movie{}, err
 	}
@@ -62,30 +58,80 @@ func (s *StorageDB) GetMovieByID(id int) (domain.Movie, error) {
 	return newMovie, nil
 }
 
-func (s *StorageDB) UpdateMovie(movieUpdate domain.Movie) error {
-	query := `update movies set name = $1, release_date = $2, country = $3, genre = $4, rating = $5 where id = $6;`
-	_, err := s.db.Exec(query, movieUpdate.Name, movieUpdate.ReleaseDate, movieUpdate.Country, movieUpdate.Genre, movieUpdate.Rating)
+// UpdateMovie записывает фильм, только если его версия в базе все еще movieUpdate.Version
+func (s *StorageDB) UpdateMovie(ctx context.Context, movieUpdate domain.Movie) (domain.Movie, error) {
+	query := `update movies set name = $1, release_date = $2, country = $3, genre = $4, rating = $5, version = version + 1, updated_at = $6
+				where id = $7 and version = $8 and deleted_at is null
+				returning ` + movieColumns
+	movie, err := scanMovie(s.conn(ctx).QueryRowContext(ctx, query, movieUpdate.Name, movieUpdate.ReleaseDate.UTC(), movieUpdate.Country, movieUpdate.Genre, movieUpdate.Rating,
+		time.Now().UTC(), movieUpdate.ID, movieUpdate.Version))
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Movie{}, versionMissed(ctx, s.conn(ctx), "movies", movieUpdate.ID)
+	}
 	if err != nil {
-		return err
+		return domain.Movie{}, err
 	}
 
-	return nil
+	return movie, nil
 }
 
-func (s *StorageDB) DeleteMovie(id int) error {
-	query := `DELETE FROM movies WHERE id = $1;`
-	_, err := s.db.Exec(query, id)
-	if err != nil {
-		return err
+// DeleteMovie при любой политике, кроме soft, удаляет фильм вместе с составом:
+// связи принадлежат фильму и без него не нужны
+func (s *StorageDB) DeleteMovie(ctx context.Context, id, version int, policy domain.DeletePolicy) error {
+	query := `delete from movies where id = $1 and deleted_at is null`
+	args := []any{id}
+	if policy == domain.DeleteSoft {
+		query = `update movies set deleted_at = $2, updated_at = $2, version = version + 1 where id = $1 and deleted_at is null`
+		args = append(args, time.Now().UTC())
+	}
+
+	if version != 0 {
+		query += fmt.Sprintf(` and version = $%d`, len(args)+1)
+		args = append(args, version)
 	}
 
-	return nil
+	return s.execVersioned(ctx, "movies", id, query, args...)
+}
+
+var movieSortColumns = map[string]sortColumn{
+	domain.MovieSortName:        {name: "name", text: true},
+	domain.MovieSortGenre:       {name: "genre", text: true},
+	domain.MovieSortReleaseDate: {name: "release_date"},
+	domain.MovieSortRating:      {name: "rating"},
+	domain.MovieSortCountry:     {name: "country", text: true},
+	domain.MovieSortScore:       {name: "score_average"},
 }
 
-func (s *StorageDB) GetAllMovies() ([]domain.Movie, error) {
-	rows, err := s.db.Query("select * from movies")
+func (s *StorageDB) ListMovies(ctx context.Context, query domain.MoviesQuery) ([]domain.Movie, int, error) {
+	b := queryBuilder{dialect: s.dialect}
+	b.where("deleted_at is null")
+
+	if query.ActorID != 0 {
+		// подзапрос идет по индексу movie_actors(actor_id)
+		b.where(fmt.Sprintf("id in (select movie_id from movie_actors where actor_id = %s)", b.arg(query.ActorID)))
+	}
+
+	b.text("name", query.Name)
+	b.text("genre", query.Genre)
+	b.text("country", query.Country)
+	whereRange(&b, "release_date", query.ReleaseDate)
+	whereRange(&b, "rating", query.Rating)
+
+	total, err := b.count(ctx, s.conn(ctx), "movies")
+	if err != nil {
+		return []domain.Movie{}, 0, err
+	}
+
+	keys := sortKeys(query.Sort, movieSortColumns)
+	if query.After != nil {
+		b.after(keys, query.After.Last.SortValue, query.After.Last.ID, query.Sort.IDDesc())
+	}
+	b.sortBy(keys, query.Sort.IDDesc())
+	b.page(query.Offset, query.Limit)
+
+	rows, err := s.conn(ctx).QueryContext(ctx, b.build(`select `+movieColumns+` from movies`), b.args...)
 	if err != nil {
-		return []domain.Movie{}, err
+		return []domain.Movie{}, 0, err
 	}
 	defer func(rows *sql.Rows) {
 		err = rows.Close()
@@ -94,69 +140,47 @@ func (s *StorageDB) GetAllMovies() ([]domain.Movie, error) {
 		}
 	}(rows)
 
-	var movies []domain.Movie
+	movies := []domain.Movie{}
 	for rows.Next() {
-		var newMovie domain.Movie
-		if err = rows.Scan(&newMovie.ID, &newMovie.Name, &newMovie.ReleaseDate, &newMovie.Country, &newMovie.Genre, &newMovie.Rating); err != nil {
-			return []domain.Movie{}, err
+		newMovie, err := scanMovie(rows)
+		if err != nil {
+			return []domain.Movie{}, 0, err
 		}
 		movies = append(movies, newMovie)
 	}
 	if err = rows.Err(); err != nil {
-		return []domain.Movie{}, err
+		return []domain.Movie{}, 0, err
 	}
 
-	return movies, nil
+	return movies, total, nil
 }
 
-func (s *StorageDB) SortAndOrderByMovie(sortBy, orderBy string, movies []domain.Movie) []domain.Movie {
-	switch {
-	case sortBy == "name" || sortBy == "":
-		if orderBy == "" || orderBy == "asc" {
-			sort.Slice(movies, func(i, j int) bool {
-				return movies[i].Name < movies[j].Name
-			})
-		} else {
-			sort.Slice(movies, func(i, j int) bool {
-				return movies[i].Name > movies[j].Name
-			})
-		}
-
-	case sortBy == "genre":
-		if orderBy == "" || orderBy == "asc" {
-			sort.Slice(movies, func(i, j int) bool {
-				return movies[i].Genre < movies[j].Genre
-			})
-		} else {
-			sort.Slice(movies, func(i, j int) bool {
-				return movies[i].Genre > movies[j].Genre
-			})
-		}
-
-	case sortBy == "date":
-		if orderBy == "" || orderBy == "asc" {
-			sort.Slice(movies, func(i, j int) bool {
-				return movies[i].ReleaseDate.Before(movies[j].ReleaseDate)
-			})
-		} else {
-			sort.Slice(movies, func(i, j int) bool {
-				return movies[i].ReleaseDate.After(movies[j].ReleaseDate)
-			})
-		}
+// GetCast возвращает состав фильма в порядке титров, для фильма без актеров - пустой список
+func (s *StorageDB) GetCast(ctx context.Context, movieID int) ([]domain.CastMember, error) {
+	// в транзакции состав читают, чтобы потом заменить: блокировка строки фильма
+	// не дает двум правкам состава начать с одного и того же состава
+	movieQuery := `select id from movies where id = $1 and deleted_at is null`
+	if _, ok := s.tx(ctx); ok {
+		movieQuery = s.dialect.lockRows(movieQuery, lockUpdate)
+	}
+	var id int
+	err := s.conn(ctx).QueryRowContext(ctx, movieQuery, movieID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return []domain.CastMember{}, fmt.Errorf("movie id: %d, err: %w", movieID, domain.ErrNotFound)
 	}
-
-	return movies
-}
-
-func (s *StorageDB) GetActorsByMovie(id int) ([]domain.Actor, error) {
-	actors, err := s.GetAllActors()
 	if err != nil {
-		return []domain.Actor{}, err
+		return []domain.CastMember{}, err
 	}
 
-	rows, err := s.db.Query("select actors_ids from \"actorsInMovies\" where movie_id = $1", id)
+	query := `select a.id, a.name, a.birth_year, a.country_of_birth, a.gender, a.version, a.updated_at, ma.character_name, ma.billing_order, ma.role
+				from movie_actors ma
+				join actors a on a.id = ma.actor_id
+				where ma.movie_id = $1 and a.deleted_at is null
+				order by ma.billing_order, ma.actor_id`
+
+	rows, err := s.conn(ctx).QueryContext(ctx, query, movieID)
 	if err != nil {
-		return []domain.Actor{}, err
+		return []domain.CastMember{}, err
 	}
 	defer func(rows *sql.Rows) {
 		err = rows.Close()
@@ -165,71 +189,89 @@ func (s *StorageDB) GetActorsByMovie(id int) ([]domain.Actor, error) {
 		}
 	}(rows)
 
-	var actorsIDsStr string
+	cast := []domain.CastMember{}
 	for rows.Next() {
-		if err = rows.Scan(&actorsIDsStr); err != nil {
-			return []domain.Actor{}, err
+		var member domain.CastMember
+		if err = rows.Scan(&member.ID, &member.Name, &member.BirthYear, &member.CountryOfBirth, &member.Gender, &member.Version, &member.UpdatedAt,
+			&member.Character, &member.BillingOrder, &member.Role); err != nil {
+			return []domain.CastMember{}, err
 		}
-		actorsIDsStr = strings.ReplaceAll(actorsIDsStr, "{", "")
-		actorsIDsStr = strings.ReplaceAll(actorsIDsStr, "}", "")
+		cast = append(cast, member)
 	}
 	if err = rows.Err(); err != nil {
-		return []domain.Actor{}, err
+		return []domain.CastMember{}, err
 	}
 
-	var actorsIDs []string
-	actorsIDs = strings.Split(actorsIDsStr, ",")
-	var filteredActors []domain.Actor
-	for _, actor := range actors {
-		for _, actorID := range actorsIDs {
-			if err != nil {
-				return []domain.Actor{}, err
+	return cast, nil
+}
+
+// ReplaceCast заменяет состав фильма целиком в одной транзакции, пустой cast удаляет всех актеров
+func (s *StorageDB) ReplaceCast(ctx context.Context, movieID int, cast []domain.CastEntry) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		// состав - часть фильма, поэтому его замена меняет версию фильма. Заодно блокировка
+		// строки фильма не дает двум одновременным заменам состава перемешаться.
+		result, err := tx.ExecContext(ctx, `update movies set version = version + 1, updated_at = $2
+					where id = $1 and deleted_at is null`, movieID, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("movie id: %d, err: %w", movieID, domain.ErrNotExists)
+		}
+
+		// блокировка конфликтует с DeleteActor в режиме restrict, иначе актер мог бы
+		// попасть в фильм одновременно с удалением
+		actorQuery := s.dialect.lockRows(`select id from actors where id = $1 and deleted_at is null`, lockShare)
+		for _, entry := range cast {
+			var actorID int
+			err = tx.QueryRowContext(ctx, actorQuery, entry.ActorID).Scan(&actorID)
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("actor id: %d, err: %w", entry.ActorID, domain.ErrNotFound)
 			}
-			actorIDInt, err := strconv.Atoi(actorID)
 			if err != nil {
-				return []domain.Actor{}, err
-			}
-			if (actorIDInt) == actor.ID {
-				filteredActors = append(filteredActors, actor)
-				break
+				return err
 			}
 		}
-	}
-
-	return filteredActors, nil
-}
-
-func (s *StorageDB) CreateActorsByMovie(id int, actors []int) (int, []int, error) {
-	_, err := s.GetMovieByID(id)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return 0, []int{0}, domain.ErrNotExists
-		}
-		return 0, []int{0}, err
-	}
 
-	rows, err := s.db.Query("insert into \"actorsInMovies\" (movie_id, actors_ids) values ($1, $2) returning movie_id, actors_ids")
-	if err != nil {
-		return 0, []int{0}, err
-	}
-	defer func(rows *sql.Rows) {
-		err = rows.Close()
+		// роли мягко удаленных актеров вызывающий не видит, поэтому они остаются как были
+		_, err = tx.ExecContext(ctx, `delete from movie_actors
+					where movie_id = $1 and actor_id in (select id from actors where deleted_at is null)`, movieID)
 		if err != nil {
-			return
+			return err
 		}
-	}(rows)
 
-	var movieID int
-	var actorsIDs []int
-	for rows.Next() {
-		if err = rows.Scan(&movieID, pq.Array(&actorsIDs)); err != nil {
-			return 0, []int{0}, err
+		for _, entry := range cast {
+			_, err = tx.ExecContext(ctx, `insert into movie_actors (movie_id, actor_id, character_name, billing_order, role)
+					values ($1, $2, $3, $4, $5)`, movieID, entry.ActorID, entry.Character, entry.BillingOrder, entry.Role)
+			if err != nil {
+				return err
+			}
 		}
 
+		return nil
+	})
+}
+
+// movieColumns - колонки фильма в порядке полей scanMovie, score_1 .. score_5 - гистограмма оценок
+const movieColumns = `id, name, release_date, country, genre, rating, version, updated_at,
+				score_average, score_1, score_2, score_3, score_4, score_5`
+
+// scanMovie читает колонки movieColumns и за ними extra
+func scanMovie(row rowScanner, extra ...any) (domain.Movie, error) {
+	var movie domain.Movie
+	score := &movie.Score
+	fields := []any{&movie.ID, &movie.Name, &movie.ReleaseDate, &movie.Country, &movie.Genre, &movie.Rating, &movie.Version, &movie.UpdatedAt,
+		&score.Average, &score.Histogram[0], &score.Histogram[1], &score.Histogram[2], &score.Histogram[3], &score.Histogram[4]}
+	if err := row.Scan(append(fields, extra...)...); err != nil {
+		return domain.Movie{}, err
 	}
-	if err = rows.Err(); err != nil {
-		return 0, []int{0}, err
+	for _, n := range score.Histogram {
+		score.Count += n
 	}
 
-	return movieID, actorsIDs, nil
+	return movie, nil
 }
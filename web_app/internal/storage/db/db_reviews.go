@@ -0,0 +1,137 @@
+package db
+
+import (
+	"arch-demo/internal/domain"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const reviewColumns = `id, movie_id, author, rating, text, status, created_at, updated_at`
+
+func (s *StorageDB) GetReview(ctx context.Context, id int) (domain.Review, error) {
+	return scanReview(s.conn(ctx).QueryRowContext(ctx, `select `+reviewColumns+` from reviews where id = $1`, id))
+}
+
+func (s *StorageDB) FindReview(ctx context.Context, movieID int, author string) (domain.Review, error) {
+	query := `select ` + reviewColumns + ` from reviews where movie_id = $1 and author = $2`
+	return scanReview(s.conn(ctx).QueryRowContext(ctx, query, movieID, author))
+}
+
+func (s *StorageDB) InsertReview(ctx context.Context, review domain.Review) (domain.Review, error) {
+	if _, err := s.GetMovieByID(ctx, review.MovieID); err != nil {
+		return domain.Review{}, err
+	}
+
+	_, err := s.FindReview(ctx, review.MovieID, review.Author)
+	if err == nil {
+		return domain.Review{}, domain.ErrExists
+	}
+	if !errors.Is(err, domain.ErrNotFound) {
+		return domain.Review{}, err
+	}
+
+	now := time.Now().UTC()
+	query := `insert into reviews (movie_id, author, rating, text, status, created_at, updated_at)
+				values ($1, $2, $3, $4, $5, $6, $6)
+				returning ` + reviewColumns
+
+	return scanReview(s.conn(ctx).QueryRowContext(ctx, query, review.MovieID, review.Author, review.Rating, review.Text, review.Status, now))
+}
+
+func (s *StorageDB) UpdateReview(ctx context.Context, review domain.Review) (domain.Review, error) {
+	query := `update reviews set rating = $2, text = $3, status = $4, updated_at = $5 where id = $1 returning ` + reviewColumns
+	return scanReview(s.conn(ctx).QueryRowContext(ctx, query, review.ID, review.Rating, review.Text, review.Status, time.Now().UTC()))
+}
+
+// ListReviews отдает отзывы в порядке убывания id. Отзывы мягко удаленных фильмов
+// не показываются, пока фильм не восстановят.
+func (s *StorageDB) ListReviews(ctx context.Context, query domain.ReviewsQuery) ([]domain.Review, int, error) {
+	b := queryBuilder{dialect: s.dialect}
+	b.where("movie_id in (select id from movies where deleted_at is null)")
+	if query.MovieID != 0 {
+		b.where("movie_id = " + b.arg(query.MovieID))
+	}
+	if query.Status != "" {
+		b.where("status = " + b.arg(query.Status))
+	}
+
+	total, err := b.count(ctx, s.conn(ctx), "reviews")
+	if err != nil {
+		return []domain.Review{}, 0, err
+	}
+
+	if query.After != nil {
+		b.where("id < " + b.arg(query.After.Last.ID))
+	}
+	b.orderBy = []string{"id desc"}
+	b.page(query.Offset, query.Limit)
+
+	rows, err := s.conn(ctx).QueryContext(ctx, b.build(`select `+reviewColumns+` from reviews`), b.args...)
+	if err != nil {
+		return []domain.Review{}, 0, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	reviews := []domain.Review{}
+	for rows.Next() {
+		review, err := scanReview(rows)
+		if err != nil {
+			return []domain.Review{}, 0, err
+		}
+		reviews = append(reviews, review)
+	}
+	if err = rows.Err(); err != nil {
+		return []domain.Review{}, 0, err
+	}
+
+	return reviews, total, nil
+}
+
+// AddMovieScore пересчитывает оценку через domain.MovieScore.Add, как и Storage.
+// Строка фильма блокируется, чтобы одновременные отзывы не потеряли изменения друг друга.
+func (s *StorageDB) AddMovieScore(ctx context.Context, movieID int, rating int8, delta int) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		var score domain.MovieScore
+		query := s.dialect.lockRows(`select score_1, score_2, score_3, score_4, score_5 from movies where id = $1 and deleted_at is null`, lockUpdate)
+		err := tx.QueryRowContext(ctx, query, movieID).
+			Scan(&score.Histogram[0], &score.Histogram[1], &score.Histogram[2], &score.Histogram[3], &score.Histogram[4])
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		score.Add(rating, delta)
+
+		_, err = tx.ExecContext(ctx, `update movies set score_average = $2, score_1 = $3, score_2 = $4, score_3 = $5, score_4 = $6, score_5 = $7,
+				version = version + 1, updated_at = $8
+				where id = $1`,
+			movieID, score.Average, score.Histogram[0], score.Histogram[1], score.Histogram[2], score.Histogram[3], score.Histogram[4], time.Now().UTC())
+		if err != nil {
+			return fmt.Errorf("failed to update movie score, movie id: %d, err: %w", movieID, err)
+		}
+
+		return nil
+	})
+}
+
+func scanReview(row rowScanner) (domain.Review, error) {
+	var review domain.Review
+	err := row.Scan(&review.ID, &review.MovieID, &review.Author, &review.Rating, &review.Text, &review.Status, &review.CreatedAt, &review.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Review{}, domain.ErrNotFound
+	}
+	if err != nil {
+		return domain.Review{}, err
+	}
+	review.CreatedAt = review.CreatedAt.UTC()
+	review.UpdatedAt = review.UpdatedAt.UTC()
+
+	return review, nil
+}
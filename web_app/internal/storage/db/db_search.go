@@ -0,0 +1,92 @@
+package db
+
+import (
+	"arch-demo/internal/domain"
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// searchCondition отбирает записи по индексу tsvector из миграции 0010_search: он находит
+// слова и их начала. Слово с опечатками так не найти, а порог сходства pg_trgm не
+// гарантирует, что найдется все, что допускает domain.SearchScore: при перестановке
+// в коротком слове общих триграмм может не остаться совсем ("bacd" и "abcdef"). Поэтому
+// индекс сужает выборку только по словам запроса без допустимых опечаток, остальные
+// оценивает сервис, как и все записи в sqlite, где условие всегда пустое. Отобранное
+// не режется по лимиту: ранжирование postgres расходится с domain.SearchScore, и лучшая
+// по оценке сервиса запись могла бы не попасть в ответ.
+func (s *StorageDB) searchCondition(b *queryBuilder, query domain.SearchQuery) {
+	if s.dialect != DialectPostgres {
+		return
+	}
+
+	var prefixes []string
+	for _, term := range query.Terms {
+		if domain.Typos(len([]rune(term))) > 0 {
+			continue
+		}
+		// после NormalizeSearch в словах только буквы и цифры, экранировать в tsquery нечего
+		prefixes = append(prefixes, term+":*")
+	}
+	if len(prefixes) == 0 {
+		return
+	}
+
+	b.where(`to_tsvector('simple', search_normalize(name)) @@ to_tsquery('simple', ` + b.arg(strings.Join(prefixes, " & ")) + `)`)
+}
+
+func (s *StorageDB) SearchActors(ctx context.Context, query domain.SearchQuery) ([]domain.Actor, error) {
+	b := queryBuilder{dialect: s.dialect}
+	b.where("deleted_at is null")
+	s.searchCondition(&b, query)
+
+	rows, err := s.conn(ctx).QueryContext(ctx, b.build(`select id, name, birth_year, country_of_birth, gender, version, updated_at from actors`), b.args...)
+	if err != nil {
+		return []domain.Actor{}, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	actors := []domain.Actor{}
+	for rows.Next() {
+		var actor domain.Actor
+		if err = rows.Scan(&actor.ID, &actor.Name, &actor.BirthYear, &actor.CountryOfBirth, &actor.Gender, &actor.Version, &actor.UpdatedAt); err != nil {
+			return []domain.Actor{}, err
+		}
+		actors = append(actors, actor)
+	}
+	if err = rows.Err(); err != nil {
+		return []domain.Actor{}, err
+	}
+
+	return actors, nil
+}
+
+func (s *StorageDB) SearchMovies(ctx context.Context, query domain.SearchQuery) ([]domain.Movie, error) {
+	b := queryBuilder{dialect: s.dialect}
+	b.where("deleted_at is null")
+	s.searchCondition(&b, query)
+
+	rows, err := s.conn(ctx).QueryContext(ctx, b.build(`select `+movieColumns+` from movies`), b.args...)
+	if err != nil {
+		return []domain.Movie{}, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	movies := []domain.Movie{}
+	for rows.Next() {
+		movie, err := scanMovie(rows)
+		if err != nil {
+			return []domain.Movie{}, err
+		}
+		movies = append(movies, movie)
+	}
+	if err = rows.Err(); err != nil {
+		return []domain.Movie{}, err
+	}
+
+	return movies, nil
+}
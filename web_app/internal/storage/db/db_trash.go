@@ -0,0 +1,131 @@
+package db
+
+import (
+	"arch-demo/internal/domain"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+func (s *StorageDB) RestoreActor(ctx context.Context, id int) (domain.Actor, error) {
+	query := `update actors set deleted_at = null, updated_at = $2, version = version + 1 where id = $1 and deleted_at is not null
+				returning id, name, birth_year, country_of_birth, gender, version, updated_at`
+	var actor domain.Actor
+	err := s.conn(ctx).QueryRowContext(ctx, query, id, time.Now().UTC()).Scan(&actor.ID, &actor.Name, &actor.BirthYear, &actor.CountryOfBirth, &actor.Gender, &actor.Version, &actor.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.Actor{}, domain.ErrNotFound
+		}
+		return domain.Actor{}, err
+	}
+
+	return actor, nil
+}
+
+func (s *StorageDB) RestoreMovie(ctx context.Context, id int) (domain.Movie, error) {
+	query := `update movies set deleted_at = null, updated_at = $2, version = version + 1 where id = $1 and deleted_at is not null
+				returning ` + movieColumns
+	movie, err := scanMovie(s.conn(ctx).QueryRowContext(ctx, query, id, time.Now().UTC()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.Movie{}, domain.ErrNotFound
+		}
+		return domain.Movie{}, err
+	}
+
+	return movie, nil
+}
+
+// ListDeletedActors возвращает мягко удаленных актеров, последние удаленные идут первыми
+func (s *StorageDB) ListDeletedActors(ctx context.Context) ([]domain.Actor, error) {
+	query := `select id, name, birth_year, country_of_birth, gender, version, updated_at, deleted_at from actors
+				where deleted_at is not null
+				order by deleted_at desc, id desc`
+
+	rows, err := s.conn(ctx).QueryContext(ctx, query)
+	if err != nil {
+		return []domain.Actor{}, err
+	}
+	defer func(rows *sql.Rows) {
+		err = rows.Close()
+		if err != nil {
+			return
+		}
+	}(rows)
+
+	actors := []domain.Actor{}
+	for rows.Next() {
+		var actor domain.Actor
+		var deletedAt time.Time
+		if err = rows.Scan(&actor.ID, &actor.Name, &actor.BirthYear, &actor.CountryOfBirth, &actor.Gender, &actor.Version, &actor.UpdatedAt, &deletedAt); err != nil {
+			return []domain.Actor{}, err
+		}
+		actor.DeletedAt = &deletedAt
+		actors = append(actors, actor)
+	}
+	if err = rows.Err(); err != nil {
+		return []domain.Actor{}, err
+	}
+
+	return actors, nil
+}
+
+func (s *StorageDB) ListDeletedMovies(ctx context.Context) ([]domain.Movie, error) {
+	query := `select ` + movieColumns + `, deleted_at from movies
+				where deleted_at is not null
+				order by deleted_at desc, id desc`
+
+	rows, err := s.conn(ctx).QueryContext(ctx, query)
+	if err != nil {
+		return []domain.Movie{}, err
+	}
+	defer func(rows *sql.Rows) {
+		err = rows.Close()
+		if err != nil {
+			return
+		}
+	}(rows)
+
+	movies := []domain.Movie{}
+	for rows.Next() {
+		var deletedAt time.Time
+		movie, err := scanMovie(rows, &deletedAt)
+		if err != nil {
+			return []domain.Movie{}, err
+		}
+		movie.DeletedAt = &deletedAt
+		movies = append(movies, movie)
+	}
+	if err = rows.Err(); err != nil {
+		return []domain.Movie{}, err
+	}
+
+	return movies, nil
+}
+
+// PurgeDeleted окончательно удаляет записи, мягко удаленные раньше before,
+// связи в movie_actors удаляет on delete cascade
+func (s *StorageDB) PurgeDeleted(ctx context.Context, before time.Time) (int, int, error) {
+	purged := make([]int, 0, 2)
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		for _, table := range []string{"actors", "movies"} {
+			result, err := tx.ExecContext(ctx, `delete from `+table+` where deleted_at < $1`, before.UTC())
+			if err != nil {
+				return err
+			}
+			n, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
+			purged = append(purged, int(n))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return purged[0], purged[1], nil
+}
@@ -0,0 +1,232 @@
+package db
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations
+var migrationsFS embed.FS
+
+var (
+	ErrSchemaOutdated = errors.New("database schema is outdated")
+	ErrSchemaTooNew   = errors.New("database schema is newer than supported")
+)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Migrator применяет встроенные в бинарник миграции из migrations/<dialect>.
+// Файлы называются NNNN_name.up.sql и NNNN_name.down.sql, применённые версии
+// хранятся в таблице schema_migrations.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func NewMigrator(dbCon *sql.DB, dialect Dialect) (*Migrator, error) {
+	migrations, err := loadMigrations(dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		db:         dbCon,
+		migrations: migrations,
+	}, nil
+}
+
+func loadMigrations(dialect Dialect) ([]Migration, error) {
+	dir := path.Join("migrations", string(dialect))
+	entries, err := fs.ReadDir(migrationsFS, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for dialect %q: %w", dialect, err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+		base, direction, ok := strings.Cut(strings.TrimSuffix(name, ".sql"), ".")
+		if !ok || !strings.HasSuffix(name, ".sql") || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("unexpected migration file name %s", name)
+		}
+
+		versionStr, title, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(versionStr)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s has no valid version prefix", name)
+		}
+
+		data, err := fs.ReadFile(migrationsFS, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: title}
+			byVersion[version] = m
+		}
+		if m.Name != title {
+			return nil, fmt.Errorf("migration version %d is used by both %s and %s", version, m.Name, title)
+		}
+
+		if direction == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s must have both up and down files", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	for i := range migrations {
+		if migrations[i].Version != i+1 {
+			return nil, fmt.Errorf("migration versions must be sequential, expected %d, got %d", i+1, migrations[i].Version)
+		}
+	}
+
+	return migrations, nil
+}
+
+// Latest - последняя версия схемы, которую знает этот бинарник
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+func (m *Migrator) ensureVersionTable() error {
+	_, err := m.db.Exec(`create table if not exists schema_migrations (
+		version    integer   primary key,
+		name       text      not null,
+		applied_at timestamp not null default current_timestamp
+	)`)
+
+	return err
+}
+
+// Version возвращает текущую версию схемы, 0 - если миграции ещё не применялись
+func (m *Migrator) Version() (int, error) {
+	if err := m.ensureVersionTable(); err != nil {
+		return 0, err
+	}
+
+	var version sql.NullInt64
+	err := m.db.QueryRow(`select max(version) from schema_migrations`).Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(version.Int64), nil
+}
+
+// Up применяет все ещё не применённые миграции
+func (m *Migrator) Up() error {
+	return m.Migrate(m.Latest())
+}
+
+// Down откатывает steps последних миграций
+func (m *Migrator) Down(steps int) error {
+	current, err := m.Version()
+	if err != nil {
+		return err
+	}
+
+	return m.Migrate(max(current-steps, 0))
+}
+
+// Migrate приводит схему к версии target, применяя up или down миграции.
+// Каждая миграция выполняется в своей транзакции вместе с записью в schema_migrations.
+func (m *Migrator) Migrate(target int) error {
+	if target < 0 || target > m.Latest() {
+		return fmt.Errorf("unknown schema version %d, latest is %d", target, m.Latest())
+	}
+
+	current, err := m.Version()
+	if err != nil {
+		return err
+	}
+	if current > m.Latest() {
+		return fmt.Errorf("%w: version %d, latest known %d", ErrSchemaTooNew, current, m.Latest())
+	}
+
+	for current < target {
+		next := m.migrations[current]
+		err = m.apply(next.Up, `insert into schema_migrations (version, name) values ($1, $2)`, next.Version, next.Name)
+		if err != nil {
+			return fmt.Errorf("failed to apply migration %04d_%s: %w", next.Version, next.Name, err)
+		}
+		current++
+	}
+
+	for current > target {
+		prev := m.migrations[current-1]
+		err = m.apply(prev.Down, `delete from schema_migrations where version = $1`, prev.Version)
+		if err != nil {
+			return fmt.Errorf("failed to revert migration %04d_%s: %w", prev.Version, prev.Name, err)
+		}
+		current--
+	}
+
+	return nil
+}
+
+func (m *Migrator) apply(script, bookkeeping string, args ...any) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err = tx.Exec(script); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(bookkeeping, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CheckVersion проверяет, что схема базы ровно той версии, с которой умеет работать StorageDB
+func (m *Migrator) CheckVersion() error {
+	current, err := m.Version()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case current > m.Latest():
+		return fmt.Errorf("%w: version %d, latest known %d", ErrSchemaTooNew, current, m.Latest())
+	case current < m.Latest():
+		return fmt.Errorf("%w: version %d, required %d, run `migrate up`", ErrSchemaOutdated, current, m.Latest())
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package db
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func version(t *testing.T, m *Migrator) int {
+	t.Helper()
+
+	v, err := m.Version()
+	if err != nil {
+		t.Fatalf("Version: %v", err)
+	}
+
+	return v
+}
+
+func TestMigrator_UpDown(t *testing.T) {
+	dbCon, m := newTestMigrator(t)
+
+	if v := version(t, m); v != 0 {
+		t.Fatalf("version of an empty database = %d, want 0", v)
+	}
+	if err := m.CheckVersion(); !errors.Is(err, ErrSchemaOutdated) {
+		t.Errorf("CheckVersion of an empty database = %v, want ErrSchemaOutdated", err)
+	}
+
+	if err := m.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if v := version(t, m); v != m.Latest() {
+		t.Errorf("version after Up = %d, want %d", v, m.Latest())
+	}
+	if err := m.CheckVersion(); err != nil {
+		t.Errorf("CheckVersion after Up = %v", err)
+	}
+	// повторный Up ничего не делает
+	if err := m.Up(); err != nil {
+		t.Fatalf("second Up: %v", err)
+	}
+
+	if err := m.Down(1); err != nil {
+		t.Fatalf("Down(1): %v", err)
+	}
+	if v := version(t, m); v != m.Latest()-1 {
+		t.Errorf("version after Down(1) = %d, want %d", v, m.Latest()-1)
+	}
+
+	// откат всех миграций убирает все таблицы, кроме таблицы версий
+	if err := m.Down(m.Latest()); err != nil {
+		t.Fatalf("Down to 0: %v", err)
+	}
+	if v := version(t, m); v != 0 {
+		t.Errorf("version after Down to 0 = %d, want 0", v)
+	}
+	var tables []string
+	rows, err := dbCon.Query(`select name from sqlite_master where type = 'table' and name not like 'sqlite_%' order by name`)
+	if err != nil {
+		t.Fatalf("list tables: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			t.Fatalf("Scan: %v", err)
+		}
+		tables = append(tables, name)
+	}
+	if len(tables) != 1 || tables[0] != "schema_migrations" {
+		t.Errorf("tables after Down to 0 = %q, want only schema_migrations", tables)
+	}
+
+	// после полного отката схема снова поднимается
+	if err = m.Up(); err != nil {
+		t.Fatalf("Up after Down: %v", err)
+	}
+	if err = m.Migrate(m.Latest() + 1); err == nil {
+		t.Error("Migrate to an unknown version succeeded")
+	}
+}
+
+func TestMigrator_CheckVersion(t *testing.T) {
+	dbCon, m := newTestMigrator(t)
+
+	// схема, с которой работал прошлый релиз
+	if err := m.Migrate(m.Latest() - 1); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if err := m.CheckVersion(); !errors.Is(err, ErrSchemaOutdated) {
+		t.Errorf("CheckVersion of a stale schema = %v, want ErrSchemaOutdated", err)
+	}
+	if _, err := NewDbStorage(dbCon, DialectSQLite); !errors.Is(err, ErrSchemaOutdated) {
+		t.Errorf("NewDbStorage on a stale schema = %v, want ErrSchemaOutdated", err)
+	}
+
+	if err := m.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	// базу уже обновил более новый бинарник
+	if _, err := dbCon.Exec(`insert into schema_migrations (version, name) values ($1, 'future')`, m.Latest()+1); err != nil {
+		t.Fatalf("insert version: %v", err)
+	}
+	if err := m.CheckVersion(); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("CheckVersion of a newer schema = %v, want ErrSchemaTooNew", err)
+	}
+	if err := m.Up(); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("Up on a newer schema = %v, want ErrSchemaTooNew", err)
+	}
+}
+
+// TestMigrator_MovieActors - 0002 переносит массивы id из "actorsInMovies" в строки movie_actors
+// с сохранением порядка, без повторов и ссылок на несуществующих актеров, и откатывается обратно
+func TestMigrator_MovieActors(t *testing.T) {
+	dbCon, m := newTestMigrator(t)
+
+	if err := m.Migrate(1); err != nil {
+		t.Fatalf("Migrate(1): %v", err)
+	}
+	seed := []string{
+		`insert into actors (id, name, birth_year, country_of_birth, gender) values
+			(1, 'Al Pacino', 1940, 'US', 'male'), (2, 'Robert De Niro', 1943, 'US', 'male'), (3, 'Val Kilmer', 1959, 'US', 'male')`,
+		`insert into movies (id, name, release_date, country, genre, rating) values
+			(1, 'Heat', '1995-12-15 00:00:00', 'US', 'crime', 5), (2, 'Ronin', '1998-09-25 00:00:00', 'US', 'action', 4)`,
+		// повтор актера 2 и актер 99, которого нет
+		`insert into "actorsInMovies" (movie_id, actors_ids) values (1, '{2,1,2,99}'), (2, '{}')`,
+	}
+	for _, query := range seed {
+		if _, err := dbCon.Exec(query); err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+
+	if err := m.Migrate(2); err != nil {
+		t.Fatalf("Migrate(2): %v", err)
+	}
+
+	type link struct{ movieID, actorID int }
+	rows, err := dbCon.Query(`select movie_id, actor_id from movie_actors order by movie_id, position`)
+	if err != nil {
+		t.Fatalf("select movie_actors: %v", err)
+	}
+	var links []link
+	for rows.Next() {
+		var l link
+		if err = rows.Scan(&l.movieID, &l.actorID); err != nil {
+			t.Fatalf("Scan: %v", err)
+		}
+		links = append(links, l)
+	}
+	rows.Close()
+	if want := []link{{1, 2}, {1, 1}}; !slices.Equal(links, want) {
+		t.Errorf("movie_actors after 0002 = %v, want %v", links, want)
+	}
+
+	if err = m.Migrate(1); err != nil {
+		t.Fatalf("revert 0002: %v", err)
+	}
+	var ids string
+	if err = dbCon.QueryRow(`select actors_ids from "actorsInMovies" where movie_id = 1`).Scan(&ids); err != nil {
+		t.Fatalf("select actorsInMovies: %v", err)
+	}
+	if ids != "{2,1}" {
+		t.Errorf("actors_ids after revert = %q, want {2,1}", ids)
+	}
+}
@@ -0,0 +1,3 @@
+drop table "actorsInMovies";
+drop table movies;
+drop table actors;
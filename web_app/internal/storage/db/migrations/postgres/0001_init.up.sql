@@ -0,0 +1,21 @@
+create table actors (
+    id               serial primary key,
+    name             text     not null,
+    birth_year       integer  not null,
+    country_of_birth text     not null,
+    gender           text     not null
+);
+
+create table movies (
+    id           serial primary key,
+    name         text        not null,
+    release_date timestamptz not null,
+    country      text        not null,
+    genre        text        not null,
+    rating       smallint    not null
+);
+
+create table "actorsInMovies" (
+    movie_id   integer   primary key references movies (id) on delete cascade,
+    actors_ids integer[] not null default '{}'
+);
@@ -0,0 +1,11 @@
+create table "actorsInMovies" (
+    movie_id   integer   primary key references movies (id) on delete cascade,
+    actors_ids integer[] not null default '{}'
+);
+
+insert into "actorsInMovies" (movie_id, actors_ids)
+select movie_id, array_agg(actor_id order by position)
+from movie_actors
+group by movie_id;
+
+drop table movie_actors;
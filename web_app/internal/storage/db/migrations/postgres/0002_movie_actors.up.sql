@@ -0,0 +1,19 @@
+create table movie_actors (
+    movie_id   integer     not null references movies (id) on delete cascade,
+    actor_id   integer     not null references actors (id) on delete cascade,
+    position   integer     not null,
+    created_at timestamptz not null default now(),
+    primary key (movie_id, actor_id)
+);
+
+create index movie_actors_actor_id_idx on movie_actors (actor_id);
+
+-- переносим массивы как есть, отбрасывая повторы и id несуществующих актеров
+insert into movie_actors (movie_id, actor_id, position)
+select m.movie_id, a.actor_id, min(a.ord) - 1
+from "actorsInMovies" m
+cross join lateral unnest(m.actors_ids) with ordinality as a (actor_id, ord)
+join actors on actors.id = a.actor_id
+group by m.movie_id, a.actor_id;
+
+drop table "actorsInMovies";
@@ -0,0 +1,7 @@
+drop index movies_release_date_idx;
+drop index movies_genre_idx;
+drop index movies_name_idx;
+
+drop index actors_birth_year_idx;
+drop index actors_country_of_birth_idx;
+drop index actors_name_idx;
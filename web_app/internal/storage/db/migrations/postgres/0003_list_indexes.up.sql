@@ -0,0 +1,9 @@
+-- индексы под сортировки списков: (ключ, id) совпадает с order by в StorageDB,
+-- текстовые ключи сравниваются побайтово, поэтому индексы тоже в collate "C"
+create index actors_name_idx on actors (name collate "C", id);
+create index actors_country_of_birth_idx on actors (country_of_birth collate "C", id);
+create index actors_birth_year_idx on actors (birth_year, id);
+
+create index movies_name_idx on movies (name collate "C", id);
+create index movies_genre_idx on movies (genre collate "C", id);
+create index movies_release_date_idx on movies (release_date, id);
@@ -0,0 +1,6 @@
+-- без колонки мягко удаленные записи снова стали бы видны, поэтому удаляем их
+delete from actors where deleted_at is not null;
+delete from movies where deleted_at is not null;
+
+alter table actors drop column deleted_at;
+alter table movies drop column deleted_at;
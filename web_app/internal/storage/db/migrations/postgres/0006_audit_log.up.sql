@@ -0,0 +1,12 @@
+-- журнал изменений не ссылается на actors и movies: история остается и после удаления записи
+create table audit_log (
+    id         bigserial   primary key,
+    entity     text        not null,
+    entity_id  integer     not null,
+    action     text        not null,
+    caller     text        not null,
+    changes    jsonb       not null,
+    created_at timestamptz not null
+);
+
+create index audit_log_entity_idx on audit_log (entity, entity_id, id);
@@ -0,0 +1,2 @@
+alter table actors drop column version;
+alter table movies drop column version;
@@ -0,0 +1,2 @@
+alter table actors drop column updated_at;
+alter table movies drop column updated_at;
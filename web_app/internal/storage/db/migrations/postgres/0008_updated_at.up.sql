@@ -0,0 +1,3 @@
+-- время последнего изменения записи для Last-Modified
+alter table actors add column updated_at timestamptz not null default now();
+alter table movies add column updated_at timestamptz not null default now();
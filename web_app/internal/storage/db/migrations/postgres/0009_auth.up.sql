@@ -0,0 +1,19 @@
+-- пользователи и их API-ключи; сами ключи не хранятся, только sha-256 от них
+create table users (
+    id         serial      primary key,
+    name       text        not null unique,
+    role       text        not null,
+    disabled   boolean     not null default false,
+    created_at timestamptz not null
+);
+
+create table api_keys (
+    id         serial      primary key,
+    user_id    integer     not null references users (id) on delete cascade,
+    name       text        not null,
+    prefix     text        not null,
+    hash       text        not null unique,
+    created_at timestamptz not null
+);
+
+create index api_keys_user_id_idx on api_keys (user_id);
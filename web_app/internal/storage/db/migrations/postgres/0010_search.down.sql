@@ -0,0 +1,7 @@
+drop index movies_name_trgm;
+drop index movies_name_fts;
+drop index actors_name_trgm;
+drop index actors_name_fts;
+drop function search_normalize(text);
+drop extension if exists pg_trgm;
+drop extension if exists unaccent;
@@ -0,0 +1,14 @@
+-- поиск GET /search: слова и их начала находит tsvector, слова с опечатками - триграммы pg_trgm.
+-- В индексе можно использовать только immutable функции, а unaccent лишь stable, потому что
+-- словарь ищется по search_path; с явно указанным словарем результат от него не зависит.
+create extension if not exists unaccent;
+create extension if not exists pg_trgm;
+
+create function search_normalize(value text) returns text
+    language sql immutable strict parallel safe
+    as $$ select lower(public.unaccent('public.unaccent'::regdictionary, value)) $$;
+
+create index actors_name_fts on actors using gin (to_tsvector('simple', search_normalize(name))) where deleted_at is null;
+create index actors_name_trgm on actors using gin (search_normalize(name) gin_trgm_ops) where deleted_at is null;
+create index movies_name_fts on movies using gin (to_tsvector('simple', search_normalize(name))) where deleted_at is null;
+create index movies_name_trgm on movies using gin (search_normalize(name) gin_trgm_ops) where deleted_at is null;
@@ -0,0 +1,14 @@
+-- текст в списках сортируется по правилам Unicode (корневая локаль ICU, как domain.CompareText),
+-- индексы под сортировку пересоздаются в том же collation, иначе order by их не использует;
+-- фильмы теперь сортируются еще по рейтингу и стране
+drop index actors_name_idx;
+drop index actors_country_of_birth_idx;
+drop index movies_name_idx;
+drop index movies_genre_idx;
+
+create index actors_name_idx on actors (name collate "und-x-icu", id);
+create index actors_country_of_birth_idx on actors (country_of_birth collate "und-x-icu", id);
+create index movies_name_idx on movies (name collate "und-x-icu", id);
+create index movies_genre_idx on movies (genre collate "und-x-icu", id);
+create index movies_country_idx on movies (country collate "und-x-icu", id);
+create index movies_rating_idx on movies (rating, id);
@@ -0,0 +1,11 @@
+drop index movies_score_idx;
+
+alter table movies
+    drop column score_5,
+    drop column score_4,
+    drop column score_3,
+    drop column score_2,
+    drop column score_1,
+    drop column score_average;
+
+drop table reviews;
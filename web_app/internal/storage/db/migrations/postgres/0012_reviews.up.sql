@@ -0,0 +1,26 @@
+-- отзывы зрителей, у автора один отзыв на фильм
+create table reviews (
+    id         serial      primary key,
+    movie_id   integer     not null references movies (id) on delete cascade,
+    author     text        not null,
+    rating     smallint    not null check (rating between 1 and 5),
+    text       text        not null default '',
+    status     text        not null,
+    created_at timestamptz not null,
+    updated_at timestamptz not null,
+    unique (movie_id, author)
+);
+
+create index reviews_status_idx on reviews (status, id);
+
+-- оценка фильма по одобренным отзывам хранится вместе с фильмом, чтобы списки
+-- сортировались по ней без подсчета; score_1 .. score_5 - гистограмма оценок
+alter table movies
+    add column score_average double precision not null default 0,
+    add column score_1 integer not null default 0,
+    add column score_2 integer not null default 0,
+    add column score_3 integer not null default 0,
+    add column score_4 integer not null default 0,
+    add column score_5 integer not null default 0;
+
+create index movies_score_idx on movies (score_average, id);
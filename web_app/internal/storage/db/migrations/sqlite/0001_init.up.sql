@@ -0,0 +1,22 @@
+create table actors (
+    id               integer primary key autoincrement,
+    name             text    not null,
+    birth_year       integer not null,
+    country_of_birth text    not null,
+    gender           text    not null
+);
+
+create table movies (
+    id           integer primary key autoincrement,
+    name         text      not null,
+    release_date timestamp not null,
+    country      text      not null,
+    genre        text      not null,
+    rating       integer   not null
+);
+
+-- массивов в sqlite нет, actors_ids хранится текстом в том же виде, что отдает postgres: {1,2,3}
+create table "actorsInMovies" (
+    movie_id   integer primary key references movies (id) on delete cascade,
+    actors_ids text    not null default '{}'
+);
@@ -0,0 +1,11 @@
+create table "actorsInMovies" (
+    movie_id   integer primary key references movies (id) on delete cascade,
+    actors_ids text    not null default '{}'
+);
+
+insert into "actorsInMovies" (movie_id, actors_ids)
+select movie_id, '{' || group_concat(actor_id, ',') || '}'
+from (select movie_id, actor_id from movie_actors order by movie_id, position)
+group by movie_id;
+
+drop table movie_actors;
@@ -0,0 +1,18 @@
+create table movie_actors (
+    movie_id   integer   not null references movies (id) on delete cascade,
+    actor_id   integer   not null references actors (id) on delete cascade,
+    position   integer   not null,
+    created_at timestamp not null default current_timestamp,
+    primary key (movie_id, actor_id)
+);
+
+create index movie_actors_actor_id_idx on movie_actors (actor_id);
+
+-- '{1,2,3}' превращается в json-массив [1,2,3] и разворачивается через json_each
+insert into movie_actors (movie_id, actor_id, position)
+select m.movie_id, cast(j.value as integer), min(j.key)
+from "actorsInMovies" m, json_each('[' || trim(m.actors_ids, '{}') || ']') j
+where cast(j.value as integer) in (select id from actors)
+group by m.movie_id, cast(j.value as integer);
+
+drop table "actorsInMovies";
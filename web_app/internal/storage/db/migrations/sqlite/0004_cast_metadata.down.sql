@@ -0,0 +1,5 @@
+alter table movie_actors drop column role;
+alter table movie_actors drop column character_name;
+
+update movie_actors set billing_order = billing_order - 1;
+alter table movie_actors rename column billing_order to position;
@@ -0,0 +1,7 @@
+-- место в титрах теперь считается с 1
+alter table movie_actors rename column position to billing_order;
+update movie_actors set billing_order = billing_order + 1;
+
+alter table movie_actors add column character_name text not null default '';
+alter table movie_actors add column role text not null default 'supporting'
+    check (role in ('lead', 'supporting'));
@@ -0,0 +1,3 @@
+-- мягко удаленные записи скрыты от всех чтений, связи в movie_actors остаются
+alter table actors add column deleted_at timestamp;
+alter table movies add column deleted_at timestamp;
@@ -0,0 +1,13 @@
+-- журнал изменений не ссылается на actors и movies: история остается и после удаления записи,
+-- changes хранится json-текстом
+create table audit_log (
+    id         integer   primary key autoincrement,
+    entity     text      not null,
+    entity_id  integer   not null,
+    action     text      not null,
+    caller     text      not null,
+    changes    text      not null,
+    created_at timestamp not null
+);
+
+create index audit_log_entity_idx on audit_log (entity, entity_id, id);
@@ -0,0 +1,3 @@
+-- версия записи для If-Match, растет с каждым изменением
+alter table actors add column version integer not null default 1;
+alter table movies add column version integer not null default 1;
@@ -0,0 +1,7 @@
+-- время последнего изменения записи для Last-Modified. sqlite не разрешает
+-- добавлять колонку с неконстантным значением по умолчанию, поэтому заполняем отдельно
+alter table actors add column updated_at timestamp not null default '1970-01-01 00:00:00';
+alter table movies add column updated_at timestamp not null default '1970-01-01 00:00:00';
+
+update actors set updated_at = current_timestamp;
+update movies set updated_at = current_timestamp;
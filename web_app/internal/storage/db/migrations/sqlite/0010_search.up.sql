@@ -0,0 +1,3 @@
+-- в sqlite нет ни pg_trgm, ни снятия диакритики, поэтому поиск оценивает все записи
+-- в приложении, и индексы ему не нужны. Миграция держит нумерацию вровень с postgres.
+select 1;
@@ -0,0 +1,11 @@
+drop index movies_rating_idx;
+drop index movies_country_idx;
+drop index movies_genre_idx;
+drop index movies_name_idx;
+drop index actors_country_of_birth_idx;
+drop index actors_name_idx;
+
+create index actors_name_idx on actors (name, id);
+create index actors_country_of_birth_idx on actors (country_of_birth, id);
+create index movies_name_idx on movies (name, id);
+create index movies_genre_idx on movies (genre, id);
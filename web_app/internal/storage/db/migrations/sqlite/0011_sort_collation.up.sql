@@ -0,0 +1,14 @@
+-- текст в списках сортируется collation unicode, который приложение регистрирует в open.go;
+-- индексы под сортировку пересоздаются в нем же. Сторонние клиенты sqlite этот collation
+-- не знают и не смогут менять actors и movies, пока он не зарегистрирован и у них.
+drop index actors_name_idx;
+drop index actors_country_of_birth_idx;
+drop index movies_name_idx;
+drop index movies_genre_idx;
+
+create index actors_name_idx on actors (name collate unicode, id);
+create index actors_country_of_birth_idx on actors (country_of_birth collate unicode, id);
+create index movies_name_idx on movies (name collate unicode, id);
+create index movies_genre_idx on movies (genre collate unicode, id);
+create index movies_country_idx on movies (country collate unicode, id);
+create index movies_rating_idx on movies (rating, id);
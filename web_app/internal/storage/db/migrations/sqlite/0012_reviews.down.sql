@@ -0,0 +1,10 @@
+drop index movies_score_idx;
+
+alter table movies drop column score_5;
+alter table movies drop column score_4;
+alter table movies drop column score_3;
+alter table movies drop column score_2;
+alter table movies drop column score_1;
+alter table movies drop column score_average;
+
+drop table reviews;
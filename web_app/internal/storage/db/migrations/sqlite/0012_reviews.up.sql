@@ -0,0 +1,25 @@
+-- отзывы зрителей, у автора один отзыв на фильм
+create table reviews (
+    id         integer   primary key autoincrement,
+    movie_id   integer   not null references movies (id) on delete cascade,
+    author     text      not null,
+    rating     integer   not null check (rating between 1 and 5),
+    text       text      not null default '',
+    status     text      not null,
+    created_at timestamp not null,
+    updated_at timestamp not null,
+    unique (movie_id, author)
+);
+
+create index reviews_status_idx on reviews (status, id);
+
+-- оценка фильма по одобренным отзывам хранится вместе с фильмом, чтобы списки
+-- сортировались по ней без подсчета; score_1 .. score_5 - гистограмма оценок
+alter table movies add column score_average real not null default 0;
+alter table movies add column score_1 integer not null default 0;
+alter table movies add column score_2 integer not null default 0;
+alter table movies add column score_3 integer not null default 0;
+alter table movies add column score_4 integer not null default 0;
+alter table movies add column score_5 integer not null default 0;
+
+create index movies_score_idx on movies (score_average, id);
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"arch-demo/internal/domain"
+	"database/sql"
+	"fmt"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"modernc.org/sqlite"
+	"strings"
+)
+
+type Dialect string
+
+const (
+	DialectPostgres Dialect = "postgres"
+	DialectSQLite   Dialect = "sqlite"
+)
+
+// sqliteUnicodeCollation - collation для сортировки текста в sqlite. Своей поддержки
+// Unicode-сортировки в sqlite нет, поэтому сравнивает та же функция, что и в inmemory.
+const sqliteUnicodeCollation = "unicode"
+
+func init() {
+	sqlite.MustRegisterCollationUtf8(sqliteUnicodeCollation, domain.CompareText)
+}
+
+// Open открывает соединение с базой выбранного диалекта и проверяет его
+func Open(dialect Dialect, dsn string) (*sql.DB, error) {
+	var driver string
+	switch dialect {
+	case DialectPostgres:
+		driver = "pgx"
+	case DialectSQLite:
+		driver = "sqlite"
+		dsn = sqliteDSN(dsn)
+	default:
+		return nil, fmt.Errorf("unknown sql dialect %q", dialect)
+	}
+
+	dbCon, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if dialect == DialectSQLite {
+		// sqlite допускает только одного писателя, а для :memory: каждое
+		// новое соединение было бы отдельной пустой базой
+		dbCon.SetMaxOpenConns(1)
+	}
+
+	err = dbCon.Ping()
+	if err != nil {
+		_ = dbCon.Close()
+		return nil, fmt.Errorf("failed to connect to db: %w", err)
+	}
+
+	return dbCon, nil
+}
+
+// sqliteDSN включает внешние ключи, которые в sqlite по умолчанию выключены,
+// и делает like чувствительным к регистру, как в postgres
+func sqliteDSN(dsn string) string {
+	for _, pragma := range []string{"foreign_keys(1)", "case_sensitive_like(1)"} {
+		name, _, _ := strings.Cut(pragma, "(")
+		if strings.Contains(dsn, name) {
+			continue
+		}
+
+		separator := "?"
+		if strings.Contains(dsn, "?") {
+			separator = "&"
+		}
+		dsn += separator + "_pragma=" + pragma
+	}
+
+	return dsn
+}
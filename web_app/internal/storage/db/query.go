@@ -0,0 +1,266 @@
+package db
+
+import (
+	"arch-demo/internal/domain"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// queryBuilder собирает where и order by, складывая значения в args,
+// в сам текст запроса попадают только плейсхолдеры $1, $2, ...
+type queryBuilder struct {
+	dialect    Dialect
+	conditions []string
+	args       []any
+	orderBy    []string
+	limit      int
+	offset     int
+}
+
+func (b *queryBuilder) arg(value any) string {
+	// в sqlite время хранится строкой, сравнивать строки можно только в одной зоне
+	if t, ok := value.(time.Time); ok {
+		value = t.UTC()
+	}
+
+	b.args = append(b.args, value)
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+func (b *queryBuilder) where(condition string) {
+	b.conditions = append(b.conditions, condition)
+}
+
+// contains - проверка на вхождение подстроки с учетом регистра
+func (b *queryBuilder) contains(column, value string) string {
+	return fmt.Sprintf(`%s like %s escape '\'`, column, b.arg("%"+escapeLike(value)+"%"))
+}
+
+// prefix - проверка на начало строки с учетом регистра
+func (b *queryBuilder) prefix(column, value string) string {
+	return fmt.Sprintf(`%s like %s escape '\'`, column, b.arg(escapeLike(value)+"%"))
+}
+
+// text добавляет условия domain.TextFilter: значения одного условия через or, условия через and
+func (b *queryBuilder) text(column string, filter domain.TextFilter) {
+	conditions := func(values []string, condition func(value string) string) {
+		if len(values) == 0 {
+			return
+		}
+		parts := make([]string, len(values))
+		for i, value := range values {
+			parts[i] = condition(value)
+		}
+		b.where(anyOf(parts...))
+	}
+
+	conditions(filter.Eq, func(value string) string { return column + " = " + b.arg(value) })
+	conditions(filter.Prefix, func(value string) string { return b.prefix(column, value) })
+	conditions(filter.Contains, func(value string) string { return b.contains(column, value) })
+}
+
+// whereRange добавляет границы domain.Range; методы не бывают обобщенными, поэтому это функция
+func whereRange[T any](b *queryBuilder, column string, r domain.Range[T]) {
+	bounds := []struct {
+		operator string
+		value    *T
+	}{{">", r.Gt}, {">=", r.Gte}, {"<", r.Lt}, {"<=", r.Lte}}
+	for _, bound := range bounds {
+		if bound.value != nil {
+			b.where(fmt.Sprintf("%s %s %s", column, bound.operator, b.arg(*bound.value)))
+		}
+	}
+}
+
+type sortColumn struct {
+	name string
+	text bool
+}
+
+// sortKey - колонка ключа сортировки и направление
+type sortKey struct {
+	column sortColumn
+	field  string
+	desc   bool
+}
+
+// sortKeys сопоставляет ключам сортировки колонки; сервис уже проверил поля,
+// поэтому неизвестных здесь не бывает
+func sortKeys(sort domain.Sort, columns map[string]sortColumn) []sortKey {
+	keys := make([]sortKey, 0, len(sort))
+	for _, key := range sort {
+		keys = append(keys, sortKey{column: columns[key.Field], field: key.Field, desc: key.Desc})
+	}
+
+	return keys
+}
+
+// expr - колонка в выражении сравнения; текст сравнивается по правилам Unicode,
+// как domain.CompareText
+func (b *queryBuilder) expr(column sortColumn) string {
+	if column.text {
+		return b.dialect.unicodeCollation(column.name)
+	}
+
+	return column.name
+}
+
+// sortBy добавляет сортировку по ключам и id как последнему ключу в направлении
+// последнего ключа: при одном ключе запрос может пройти по индексу (column, id)
+// в любую сторону. Так же упорядочивает и domain.Sort.Compare.
+func (b *queryBuilder) sortBy(keys []sortKey, idDesc bool) {
+	for _, key := range keys {
+		b.orderBy = append(b.orderBy, b.expr(key.column)+" "+direction(key.desc))
+	}
+	b.orderBy = append(b.orderBy, "id "+direction(idDesc))
+}
+
+func direction(desc bool) string {
+	if desc {
+		return "desc"
+	}
+
+	return "asc"
+}
+
+func operator(desc bool) string {
+	if desc {
+		return "<"
+	}
+
+	return ">"
+}
+
+// after оставляет записи, идущие в порядке sortBy строго после last. Направления ключей
+// могут различаться, поэтому вместо сравнения кортежей условие раскрыто:
+// k1 > v1 or (k1 = v1 and k2 < v2) or (k1 = v1 and k2 = v2 and id > last.id)
+func (b *queryBuilder) after(keys []sortKey, value func(field string) any, id int, idDesc bool) {
+	var alternatives []string
+	var equal []string
+	for _, key := range keys {
+		expr := b.expr(key.column)
+		alternatives = append(alternatives, allOf(append(equal, fmt.Sprintf("%s %s %s", expr, operator(key.desc), b.arg(value(key.field))))...))
+		equal = append(equal, fmt.Sprintf("%s = %s", expr, b.arg(value(key.field))))
+	}
+	alternatives = append(alternatives, allOf(append(equal, fmt.Sprintf("id %s %s", operator(idDesc), b.arg(id)))...))
+
+	b.where(anyOf(alternatives...))
+}
+
+func (b *queryBuilder) page(offset, limit int) {
+	b.offset = offset
+	b.limit = limit
+}
+
+func (b *queryBuilder) build(selectFrom string) string {
+	var sb strings.Builder
+	sb.WriteString(selectFrom)
+	if len(b.conditions) > 0 {
+		sb.WriteString(" where ")
+		sb.WriteString(strings.Join(b.conditions, " and "))
+	}
+	if len(b.orderBy) > 0 {
+		sb.WriteString(" order by ")
+		sb.WriteString(strings.Join(b.orderBy, ", "))
+	}
+	if b.limit > 0 {
+		sb.WriteString(" limit " + b.arg(b.limit))
+	} else if b.offset > 0 {
+		// offset без limit в sqlite - синтаксическая ошибка
+		sb.WriteString(" limit " + b.dialect.noLimit())
+	}
+	if b.offset > 0 {
+		sb.WriteString(" offset " + b.arg(b.offset))
+	}
+
+	return sb.String()
+}
+
+// count считает записи, подходящие под уже добавленные условия
+func (b *queryBuilder) count(ctx context.Context, db queryRower, from string) (int, error) {
+	var total int
+	err := db.QueryRowContext(ctx, b.build("select count(*) from "+from), b.args...).Scan(&total)
+
+	return total, err
+}
+
+// unicodeCollation сравнивает строки по правилам Unicode, как domain.CompareText:
+// в postgres - collation корневой локали ICU, в sqlite - функция, зарегистрированная в open.go
+func (d Dialect) unicodeCollation(column string) string {
+	if d == DialectPostgres {
+		return column + ` collate "und-x-icu"`
+	}
+
+	return column + " collate " + sqliteUnicodeCollation
+}
+
+// noLimit - значение limit, которое не ограничивает выборку
+func (d Dialect) noLimit() string {
+	if d == DialectPostgres {
+		return "all"
+	}
+
+	return "-1"
+}
+
+type lockMode string
+
+const (
+	lockShare  lockMode = "for share"
+	lockUpdate lockMode = "for update"
+)
+
+// lockRows добавляет к select блокировку строк. В sqlite блокировок строк нет,
+// там транзакции и так выполняются по одной через единственное соединение.
+func (d Dialect) lockRows(query string, mode lockMode) string {
+	if d == DialectPostgres {
+		return query + " " + string(mode)
+	}
+
+	return query
+}
+
+// queryRower - то общее, что нужно от *sql.DB и *sql.Tx для чтения одной строки
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// versionMissed объясняет, почему изменение с условием на версию не затронуло
+// ни одной строки: записи нет (ErrNotFound) или ее версия уже другая (ErrVersionMismatch)
+func versionMissed(ctx context.Context, q queryRower, table string, id int) error {
+	var exists bool
+	err := q.QueryRowContext(ctx, `select exists (select 1 from `+table+` where id = $1 and deleted_at is null)`, id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return domain.ErrVersionMismatch
+	}
+
+	return domain.ErrNotFound
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// allOf объединяет условия через and
+func allOf(conditions ...string) string {
+	return "(" + strings.Join(conditions, " and ") + ")"
+}
+
+// anyOf объединяет условия через or, пустые условия пропускаются
+func anyOf(conditions ...string) string {
+	var nonEmpty []string
+	for _, c := range conditions {
+		if c != "" {
+			nonEmpty = append(nonEmpty, c)
+		}
+	}
+
+	return "(" + strings.Join(nonEmpty, " or ") + ")"
+}
@@ -0,0 +1,39 @@
+package db
+
+import (
+	"arch-demo/internal/domain"
+	"slices"
+	"testing"
+)
+
+// TestSearchCondition - в postgres индекс сужает поиск только по словам без опечаток:
+// слово с опечатками может не совпасть с записью ни одной триграммой
+func TestSearchCondition(t *testing.T) {
+	tests := []struct {
+		terms    []string
+		wantArgs []any
+	}{
+		{terms: []string{"tom"}, wantArgs: []any{"tom:*"}},
+		{terms: []string{"bacd"}, wantArgs: nil},
+		{terms: []string{"tom", "hanks", "al"}, wantArgs: []any{"tom:* & al:*"}},
+	}
+
+	for _, tt := range tests {
+		s := &StorageDB{dialect: DialectPostgres}
+		b := queryBuilder{dialect: s.dialect}
+		s.searchCondition(&b, domain.SearchQuery{Terms: tt.terms})
+		if !slices.Equal(b.args, tt.wantArgs) {
+			t.Errorf("terms %q: args %q, want %q", tt.terms, b.args, tt.wantArgs)
+		}
+		if len(b.conditions) != min(len(tt.wantArgs), 1) {
+			t.Errorf("terms %q: conditions %q", tt.terms, b.conditions)
+		}
+	}
+
+	s := &StorageDB{dialect: DialectSQLite}
+	b := queryBuilder{dialect: s.dialect}
+	s.searchCondition(&b, domain.SearchQuery{Terms: []string{"tom"}})
+	if len(b.conditions) != 0 {
+		t.Errorf("sqlite conditions %q, want none", b.conditions)
+	}
+}
@@ -0,0 +1,44 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+)
+
+// newTestStorage - sqlite в памяти с примененными миграциями. Соединение
+// единственное, поэтому база живет, пока его не закроют.
+func newTestStorage(t *testing.T) *StorageDB {
+	t.Helper()
+
+	dbCon, migrator := newTestMigrator(t)
+	if err := migrator.Up(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	s, err := NewDbStorage(dbCon, DialectSQLite)
+	if err != nil {
+		t.Fatalf("NewDbStorage: %v", err)
+	}
+
+	return s
+}
+
+// newTestMigrator - пустая sqlite в памяти и мигратор для нее, миграции не применены
+func newTestMigrator(t *testing.T) (*sql.DB, *Migrator) {
+	t.Helper()
+
+	dbCon, err := Open(DialectSQLite, "file::memory:")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = dbCon.Close()
+	})
+
+	migrator, err := NewMigrator(dbCon, DialectSQLite)
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+
+	return dbCon, migrator
+}
@@ -0,0 +1,72 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// conn - то общее у *sql.DB и *sql.Tx, через что хранилище выполняет запросы
+type conn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type txKey struct{}
+
+// txScope - транзакция, открытая Atomic. Хранилище запоминается, чтобы не взять
+// транзакцию другой базы, если в одном контексте работают два хранилища.
+type txScope struct {
+	storage *StorageDB
+	tx      *sql.Tx
+}
+
+// Atomic выполняет fn в одной транзакции: все вызовы хранилища с контекстом, переданным в fn,
+// идут в ней, и если fn вернула ошибку, ни одно изменение не сохраняется.
+// Atomic внутри Atomic выполняется в уже открытой транзакции.
+func (s *StorageDB) Atomic(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := s.tx(ctx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if err = fn(context.WithValue(ctx, txKey{}, txScope{storage: s, tx: tx})); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *StorageDB) tx(ctx context.Context) (*sql.Tx, bool) {
+	scope, ok := ctx.Value(txKey{}).(txScope)
+	if !ok || scope.storage != s {
+		return nil, false
+	}
+
+	return scope.tx, true
+}
+
+// conn возвращает транзакцию Atomic, если вызов идет внутри нее. Запрос мимо нее
+// в sqlite с единственным соединением ждал бы конца транзакции вечно.
+func (s *StorageDB) conn(ctx context.Context) conn {
+	if tx, ok := s.tx(ctx); ok {
+		return tx
+	}
+
+	return s.db
+}
+
+// inTx выполняет fn в транзакции Atomic из контекста или, если ее нет, в своей
+func (s *StorageDB) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	return s.Atomic(ctx, func(ctx context.Context) error {
+		tx, _ := s.tx(ctx)
+		return fn(tx)
+	})
+}
@@ -2,138 +2,254 @@ package inmemory
 
 import (
 	"arch-demo/internal/domain"
-	"golang.org/x/exp/slices"
-	"sort"
-	"strconv"
-	"strings"
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"time"
 )
 
+// Storage безопасен для конкурентного использования: чтения идут параллельно
+// под RLock, любые изменения сериализуются через Lock. Каждый метод начинается
+// с s.in(ctx), чтобы внутри Atomic работать через ее журнал отката.
 type Storage struct {
+	mu sync.RWMutex
+	*data
+	// undo - журнал отката Atomic, nil вне ее
+	undo *[]func()
+}
+
+// data - данные хранилища. Каждое изменение сначала записывается в журнал отката
+// функциями keep*, иначе откат Atomic его не отменит.
+type data struct {
 	actors        []domain.Actor
 	movies        []domain.Movie
-	actorsByMovie map[int][]int
+	actorsByMovie map[int][]domain.CastEntry
+	// moviesByActor - обратный индекс к actorsByMovie для фильмографии актера
+	moviesByActor map[int][]int
+	// movieIndex - позиция фильма в movies по его id
+	movieIndex map[int]int
+	// search - поисковый индекс по названиям актеров и фильмов
+	search *searchIndex
+	// audit - журнал изменений, только дописывается
+	audit        []domain.AuditEntry
+	users        []domain.User
+	apiKeys      []domain.APIKey
+	reviews      []domain.Review
+	lastActorID  int
+	lastMovieID  int
+	lastAuditID  int
+	lastUserID   int
+	lastAPIKeyID int
+	lastReviewID int
 }
 
 func NewStorage() *Storage {
-	return &Storage{
+	return &Storage{data: &data{
 		actors:        make([]domain.Actor, 0),
 		movies:        make([]domain.Movie, 0),
-		actorsByMovie: make(map[int][]int),
-	}
+		actorsByMovie: make(map[int][]domain.CastEntry),
+		moviesByActor: make(map[int][]int),
+		movieIndex:    make(map[int]int),
+		search:        newSearchIndex(),
+	}}
 }
 
-func (s *Storage) InsertActor(actor domain.Actor) (domain.Actor, error) {
-	var lastID int
-	if len(s.actors) > 0 {
-		lastID = s.actors[len(s.actors)-1:][0].ID
+func (s *Storage) InsertActor(ctx context.Context, actor domain.Actor) (domain.Actor, error) {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return domain.Actor{}, err
 	}
 
-	actor.ID = lastID + 1
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	s.actors = append(s.actors, actor)
+	// id берем из счетчика, а не из последнего элемента слайса,
+	// иначе после удаления последнего актера id переиспользуется
+	actor.ID = nextID(s, &s.lastActorID)
+	actor.Version = 1
+	actor.UpdatedAt = time.Now().UTC()
+
+	push(s, &s.actors, actor)
+	s.searchPut(searchDoc{domain.SearchActors, actor.ID}, actor.Name)
 	return actor, nil
 }
 
-func (s *Storage) IsActorExists(actor domain.Actor) (bool, error) {
+func (s *Storage) IsActorExists(ctx context.Context, actor domain.Actor) (bool, error) {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	for i := range s.actors {
-		if strings.Contains(s.actors[i].Name, actor.Name) &&
-			strings.Contains(s.actors[i].Gender, actor.Gender) &&
-			strings.Contains(strconv.Itoa(s.actors[i].BirthYear), strconv.Itoa(actor.BirthYear)) &&
-			strings.Contains(s.actors[i].CountryOfBirth, actor.CountryOfBirth) {
+		if s.actors[i].DeletedAt == nil && s.actors[i].Name == actor.Name {
 			return true, nil
 		}
 	}
 
-	return false, domain.ErrExists
+	return false, nil
+}
+
+func (s *Storage) GetActorByID(ctx context.Context, id int) (domain.Actor, error) {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return domain.Actor{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.actorByID(id)
 }
 
-func (s *Storage) GetActorByID(id int) (domain.Actor, error) {
-	var actor *domain.Actor
+// actorByID не находит мягко удаленных актеров, как и все чтения
+func (s *Storage) actorByID(id int) (domain.Actor, error) {
 	for i := range s.actors {
-		if s.actors[i].ID == id {
-			actor = &s.actors[i]
+		if s.actors[i].ID == id && s.actors[i].DeletedAt == nil {
+			return s.actors[i], nil
 		}
 	}
 
-	if actor == nil {
-		return domain.Actor{}, domain.ErrNotFound
-	}
+	return domain.Actor{}, domain.ErrNotFound
+}
 
-	return *actor, nil
+func (s *Storage) actorExists(id int) bool {
+	_, err := s.actorByID(id)
+	return err == nil
 }
 
-func (s *Storage) DeleteActor(id int) error {
-	s.actors = slices.DeleteFunc(s.actors, func(l1 domain.Actor) bool {
-		return l1.ID == id
+func (s *Storage) DeleteActor(ctx context.Context, id, version int, policy domain.DeletePolicy) error {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.actors, func(actor domain.Actor) bool {
+		return actor.ID == id && actor.DeletedAt == nil
 	})
+	if i < 0 {
+		return nil
+	}
+	if version != 0 && s.actors[i].Version != version {
+		return domain.ErrVersionMismatch
+	}
+
+	switch policy {
+	case domain.DeleteSoft:
+		keepAt(s, &s.actors, i)
+		now := time.Now().UTC()
+		s.actors[i].DeletedAt = &now
+		s.actors[i].UpdatedAt = now
+		s.actors[i].Version++
+		return nil
+	case domain.DeleteRestrict:
+		var movies []domain.Movie
+		for _, movieID := range s.moviesByActor[id] {
+			if movie, err := s.movieByID(movieID); err == nil {
+				movies = append(movies, movie)
+			}
+		}
+		if len(movies) > 0 {
+			slices.SortFunc(movies, func(a, b domain.Movie) int {
+				return cmp.Compare(a.ID, b.ID)
+			})
+			return &domain.ReferencedError{Movies: movies}
+		}
+	}
+
+	keepAll(s, &s.actors)
+	s.actors = slices.Delete(s.actors, i, i+1)
+	s.removeFromCasts(id)
+	s.searchRemove(searchDoc{domain.SearchActors, id})
 
 	return nil
 }
 
-func (s *Storage) UpdateActor(actorUpdate domain.Actor) error {
+// UpdateActor записывает актера, только если его версия в хранилище все еще actorUpdate.Version
+func (s *Storage) UpdateActor(ctx context.Context, actorUpdate domain.Actor) (domain.Actor, error) {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return domain.Actor{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	for i := range s.actors {
-		if s.actors[i].ID == actorUpdate.ID {
+		if s.actors[i].ID == actorUpdate.ID && s.actors[i].DeletedAt == nil {
+			if s.actors[i].Version != actorUpdate.Version {
+				return domain.Actor{}, domain.ErrVersionMismatch
+			}
+			actorUpdate.Version++
+			actorUpdate.UpdatedAt = time.Now().UTC()
+			keepAt(s, &s.actors, i)
 			s.actors[i] = actorUpdate
+			s.searchPut(searchDoc{domain.SearchActors, actorUpdate.ID}, actorUpdate.Name)
+			return actorUpdate, nil
 		}
 	}
 
-	return nil
+	return domain.Actor{}, domain.ErrNotFound
 }
 
-func (s *Storage) GetAllActors() ([]domain.Actor, error) {
-	return s.actors, nil
-}
+// ListActors возвращает копии записей, поэтому вызывающий код может
+// свободно менять результат, не трогая слайс хранилища
+func (s *Storage) ListActors(ctx context.Context, query domain.ActorsQuery) ([]domain.Actor, int, error) {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return []domain.Actor{}, 0, err
+	}
 
-func (s *Storage) SortAndOrderByActor(sortOrder, orderBy string, actors []domain.Actor) []domain.Actor {
-	switch {
-	case sortOrder == "name":
-		if orderBy == "" || orderBy == "asc" {
-			sort.Slice(actors, func(i, j int) bool {
-				return actors[i].Name < actors[j].Name
-			})
-		} else {
-			sort.Slice(actors, func(i, j int) bool {
-				return actors[i].Name > actors[j].Name
-			})
-		}
-	case sortOrder == "country":
-		if orderBy == "" || orderBy == "asc" {
-			sort.Slice(actors, func(i, j int) bool {
-				return actors[i].CountryOfBirth < actors[j].CountryOfBirth
-			})
-		} else {
-			sort.Slice(actors, func(i, j int) bool {
-				return actors[i].CountryOfBirth > actors[j].CountryOfBirth
-			})
+	s.mu.RLock()
+	actors := make([]domain.Actor, 0, len(s.actors))
+	for i := range s.actors {
+		if s.actors[i].DeletedAt == nil && matchActor(s.actors[i], query) {
+			actors = append(actors, s.actors[i])
 		}
-	case sortOrder == "birthdate":
-		if orderBy == "" || orderBy == "asc" {
-			sort.Slice(actors, func(i, j int) bool {
-				return actors[i].BirthYear < actors[j].BirthYear
-			})
-		} else {
-			sort.Slice(actors, func(i, j int) bool {
-				return actors[i].BirthYear > actors[j].BirthYear
-			})
+	}
+	s.mu.RUnlock()
+
+	compare := func(a, b domain.Actor) int {
+		return query.Sort.Compare(func(field string) int { return a.CompareField(b, field) }, a.ID, b.ID)
+	}
+	slices.SortFunc(actors, compare)
+
+	total := len(actors)
+	if query.After != nil {
+		// первая запись строго после курсора, сам курсор мог быть уже удален
+		start, found := slices.BinarySearchFunc(actors, query.After.Last, compare)
+		if found {
+			start++
 		}
+		actors = actors[start:]
 	}
 
-	return actors
+	return paginate(actors, query.Offset, query.Limit), total, nil
+}
+
+// matchActor - запись подходит, только если выполнены все условия фильтра
+func matchActor(actor domain.Actor, query domain.ActorsQuery) bool {
+	return query.Name.Match(actor.Name) &&
+		query.Country.Match(actor.CountryOfBirth) &&
+		query.Gender.Match(actor.Gender) &&
+		query.BirthYear.Match(actor.BirthYear, cmp.Compare[int])
 }
 
-func (s *Storage) FilterActors(nameQuery, countryOfBirthQuery string) ([]domain.Actor, error) {
-	var filteredActors []domain.Actor
-	actors, err := s.GetAllActors()
-	if err != nil {
-		return []domain.Actor{}, err
+func paginate[T any](items []T, offset, limit int) []T {
+	if offset >= len(items) {
+		return items[:0]
 	}
+	items = items[offset:]
 
-	for i := range s.actors {
-		if (nameQuery != "" && strings.Contains(actors[i].Name, nameQuery)) ||
-			(countryOfBirthQuery != "" && strings.Contains(actors[i].CountryOfBirth, countryOfBirthQuery)) {
-			filteredActors = append(filteredActors, actors[i])
-		}
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
 	}
 
-	return filteredActors, nil
+	return items
 }
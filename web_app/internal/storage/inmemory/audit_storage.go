@@ -0,0 +1,42 @@
+package inmemory
+
+import (
+	"arch-demo/internal/domain"
+	"context"
+)
+
+func (s *Storage) InsertAuditEntry(ctx context.Context, entry domain.AuditEntry) error {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry.ID = nextID(s, &s.lastAuditID)
+	push(s, &s.audit, entry)
+
+	return nil
+}
+
+// ListAuditEntries отдает записи в порядке добавления. Changes общий с хранилищем,
+// но записи журнала никогда не меняются, поэтому копировать его не нужно.
+func (s *Storage) ListAuditEntries(ctx context.Context, entity string, entityID int) ([]domain.AuditEntry, error) {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return []domain.AuditEntry{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := []domain.AuditEntry{}
+	for _, entry := range s.audit {
+		if entry.Entity == entity && entry.EntityID == entityID {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
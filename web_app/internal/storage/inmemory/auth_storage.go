@@ -0,0 +1,206 @@
+package inmemory
+
+import (
+	"arch-demo/internal/domain"
+	"context"
+	"time"
+)
+
+func (s *Storage) InsertUser(ctx context.Context, user domain.User) (domain.User, error) {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return domain.User{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.users {
+		if existing.Name == user.Name {
+			return domain.User{}, domain.ErrExists
+		}
+	}
+
+	user.ID = nextID(s, &s.lastUserID)
+	user.CreatedAt = time.Now().UTC()
+	push(s, &s.users, user)
+
+	return user, nil
+}
+
+func (s *Storage) GetUserByID(ctx context.Context, id int) (domain.User, error) {
+	s = s.in(ctx)
+	return s.findUser(ctx, func(user domain.User) bool {
+		return user.ID == id
+	})
+}
+
+func (s *Storage) GetUserByName(ctx context.Context, name string) (domain.User, error) {
+	s = s.in(ctx)
+	return s.findUser(ctx, func(user domain.User) bool {
+		return user.Name == name
+	})
+}
+
+func (s *Storage) findUser(ctx context.Context, match func(domain.User) bool) (domain.User, error) {
+	if err := ctx.Err(); err != nil {
+		return domain.User{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, user := range s.users {
+		if match(user) {
+			return user, nil
+		}
+	}
+
+	return domain.User{}, domain.ErrNotFound
+}
+
+func (s *Storage) ListUsers(ctx context.Context) ([]domain.User, error) {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return []domain.User{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]domain.User, len(s.users))
+	copy(users, s.users)
+
+	return users, nil
+}
+
+// UpdateUser меняет роль и блокировку, имя и время создания остаются прежними
+func (s *Storage) UpdateUser(ctx context.Context, user domain.User) (domain.User, error) {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return domain.User{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.users {
+		if s.users[i].ID == user.ID {
+			keepAt(s, &s.users, i)
+			s.users[i].Role = user.Role
+			s.users[i].Disabled = user.Disabled
+			return s.users[i], nil
+		}
+	}
+
+	return domain.User{}, domain.ErrNotFound
+}
+
+func (s *Storage) DeleteUser(ctx context.Context, id int) error {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.users {
+		if s.users[i].ID != id {
+			continue
+		}
+
+		keepAll(s, &s.users)
+		s.users = append(s.users[:i], s.users[i+1:]...)
+
+		keepAll(s, &s.apiKeys)
+		keys := s.apiKeys[:0]
+		for _, key := range s.apiKeys {
+			if key.UserID != id {
+				keys = append(keys, key)
+			}
+		}
+		s.apiKeys = keys
+
+		return nil
+	}
+
+	return domain.ErrNotFound
+}
+
+func (s *Storage) InsertAPIKey(ctx context.Context, key domain.APIKey) (domain.APIKey, error) {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return domain.APIKey{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := false
+	for _, user := range s.users {
+		found = found || user.ID == key.UserID
+	}
+	if !found {
+		return domain.APIKey{}, domain.ErrNotExists
+	}
+
+	key.ID = nextID(s, &s.lastAPIKeyID)
+	key.CreatedAt = time.Now().UTC()
+	push(s, &s.apiKeys, key)
+
+	return key, nil
+}
+
+func (s *Storage) GetAPIKeyByHash(ctx context.Context, hash string) (domain.APIKey, error) {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return domain.APIKey{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, key := range s.apiKeys {
+		if key.Hash == hash {
+			return key, nil
+		}
+	}
+
+	return domain.APIKey{}, domain.ErrNotFound
+}
+
+func (s *Storage) ListAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return []domain.APIKey{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]domain.APIKey, len(s.apiKeys))
+	copy(keys, s.apiKeys)
+
+	return keys, nil
+}
+
+func (s *Storage) DeleteAPIKey(ctx context.Context, id int) error {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.apiKeys {
+		if s.apiKeys[i].ID == id {
+			keepAll(s, &s.apiKeys)
+			s.apiKeys = append(s.apiKeys[:i], s.apiKeys[i+1:]...)
+			return nil
+		}
+	}
+
+	return domain.ErrNotFound
+}
@@ -0,0 +1,12 @@
+package inmemory
+
+import (
+	"arch-demo/internal/storage/storagetest"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	storagetest.RunAll(t, func(t *testing.T) storagetest.FullStorage {
+		return NewStorage()
+	})
+}
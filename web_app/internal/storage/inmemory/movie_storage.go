@@ -2,160 +2,339 @@ package inmemory
 
 import (
 	"arch-demo/internal/domain"
-	"errors"
+	"cmp"
+	"context"
 	"fmt"
 	"slices"
-	"sort"
-	"strings"
+	"time"
 )
 
-func (s *Storage) InsertMovie(movie domain.Movie) (domain.Movie, error) {
-	var lastID int
-	if len(s.movies) > 0 {
-		lastID = s.movies[len(s.movies)-1:][0].ID
+func (s *Storage) InsertMovie(ctx context.Context, movie domain.Movie) (domain.Movie, error) {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return domain.Movie{}, err
 	}
 
-	movie.ID = lastID + 1
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	s.movies = append(s.movies, movie)
+	if s.movieExists(movie.Name) {
+		return domain.Movie{}, domain.ErrExists
+	}
+
+	movie.ID = nextID(s, &s.lastMovieID)
+	movie.Score = domain.MovieScore{}
+	movie.Version = 1
+	movie.UpdatedAt = time.Now().UTC()
+
+	keepKey(s, s.movieIndex, movie.ID)
+	s.movieIndex[movie.ID] = len(s.movies)
+	push(s, &s.movies, movie)
+	s.searchPut(searchDoc{domain.SearchMovies, movie.ID}, movie.Name)
 	return movie, nil
 }
 
-func (s *Storage) IsMovieExists(movie domain.Movie) (bool, error) {
-	for i := range s.movies {
-		if strings.Contains(s.movies[i].Name, movie.Name) &&
-			s.movies[i].ReleaseDate == movie.ReleaseDate &&
-			strings.Contains(s.movies[i].Country, movie.Country) &&
-			strings.Contains(s.movies[i].Genre, movie.Genre) &&
-			s.movies[i].Rating == movie.Rating {
-			return true, nil
-		}
+func (s *Storage) IsMovieExists(ctx context.Context, movie domain.Movie) (bool, error) {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return false, err
 	}
 
-	return false, nil
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.movieExists(movie.Name), nil
 }
 
-func (s *Storage) GetMovieByID(id int) (domain.Movie, error) {
-	var movie *domain.Movie
+// movieExists - есть ли неудаленный фильм с названием name, вызывается под блокировкой
+func (s *Storage) movieExists(name string) bool {
 	for i := range s.movies {
-		if s.movies[i].ID == id {
-			movie = &s.movies[i]
+		if s.movies[i].DeletedAt == nil && s.movies[i].Name == name {
+			return true
 		}
 	}
 
-	if movie == nil {
+	return false
+}
+
+func (s *Storage) GetMovieByID(ctx context.Context, id int) (domain.Movie, error) {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return domain.Movie{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.movieByID(id)
+}
+
+// movieByID не находит мягко удаленные фильмы, как и все чтения
+func (s *Storage) movieByID(id int) (domain.Movie, error) {
+	i, ok := s.movieIndex[id]
+	if !ok || s.movies[i].DeletedAt != nil {
 		return domain.Movie{}, domain.ErrNotFound
 	}
 
-	return *movie, nil
+	return s.movies[i], nil
 }
 
-func (s *Storage) UpdateMovie(movieUpdate domain.Movie) error {
-	for i := range s.movies {
-		if s.movies[i].ID == movieUpdate.ID {
-			s.movies[i] = movieUpdate
-		}
+// UpdateMovie записывает фильм, только если его версия в хранилище все еще movieUpdate.Version
+func (s *Storage) UpdateMovie(ctx context.Context, movieUpdate domain.Movie) (domain.Movie, error) {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return domain.Movie{}, err
 	}
 
-	return nil
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.movieIndex[movieUpdate.ID]
+	if !ok || s.movies[i].DeletedAt != nil {
+		return domain.Movie{}, domain.ErrNotFound
+	}
+	if s.movies[i].Version != movieUpdate.Version {
+		return domain.Movie{}, domain.ErrVersionMismatch
+	}
+
+	// оценка меняется только через отзывы
+	movieUpdate.Score = s.movies[i].Score
+	movieUpdate.Version++
+	movieUpdate.UpdatedAt = time.Now().UTC()
+	keepAt(s, &s.movies, i)
+	s.movies[i] = movieUpdate
+	s.searchPut(searchDoc{domain.SearchMovies, movieUpdate.ID}, movieUpdate.Name)
+
+	return movieUpdate, nil
 }
 
-func (s *Storage) DeleteMovie(id int) error {
-	s.movies = slices.DeleteFunc(s.movies, func(l1 domain.Movie) bool {
-		return l1.ID == id
-	})
+// DeleteMovie при любой политике, кроме soft, удаляет фильм вместе с составом:
+// связи принадлежат фильму и без него не нужны
+func (s *Storage) DeleteMovie(ctx context.Context, id, version int, policy domain.DeletePolicy) error {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.movieIndex[id]
+	if !ok || s.movies[i].DeletedAt != nil {
+		return nil
+	}
+	if version != 0 && s.movies[i].Version != version {
+		return domain.ErrVersionMismatch
+	}
+
+	if policy == domain.DeleteSoft {
+		keepAt(s, &s.movies, i)
+		now := time.Now().UTC()
+		s.movies[i].DeletedAt = &now
+		s.movies[i].UpdatedAt = now
+		s.movies[i].Version++
+		return nil
+	}
+
+	s.removeMovie(i)
 
 	return nil
 }
 
-func (s *Storage) GetAllMovies() ([]domain.Movie, error) {
-	return s.movies, nil
+// removeMovie удаляет фильм на позиции i вместе с составом и отзывами. Вызывается под s.mu.Lock.
+func (s *Storage) removeMovie(i int) {
+	id := s.movies[i].ID
+	keepAll(s, &s.movies)
+	s.movies = slices.Delete(s.movies, i, i+1)
+	keepKey(s, s.movieIndex, id)
+	delete(s.movieIndex, id)
+	// фильмы после удаленного сдвинулись на одну позицию
+	for ; i < len(s.movies); i++ {
+		keepKey(s, s.movieIndex, s.movies[i].ID)
+		s.movieIndex[s.movies[i].ID] = i
+	}
+
+	s.setCast(id, nil)
+	s.searchRemove(searchDoc{domain.SearchMovies, id})
+	keepAll(s, &s.reviews)
+	s.reviews = slices.DeleteFunc(s.reviews, func(r domain.Review) bool { return r.MovieID == id })
 }
 
-func (s *Storage) SortAndOrderByMovie(sortBy, orderBy string, movies []domain.Movie) []domain.Movie {
-	switch {
-	case sortBy == "name" || sortBy == "":
-		if orderBy == "" || orderBy == "asc" {
-			sort.Slice(movies, func(i, j int) bool {
-				return movies[i].Name < movies[j].Name
-			})
-		} else {
-			sort.Slice(movies, func(i, j int) bool {
-				return movies[i].Name > movies[j].Name
-			})
-		}
+func (s *Storage) ListMovies(ctx context.Context, query domain.MoviesQuery) ([]domain.Movie, int, error) {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return []domain.Movie{}, 0, err
+	}
 
-	case sortBy == "genre":
-		if orderBy == "" || orderBy == "asc" {
-			sort.Slice(movies, func(i, j int) bool {
-				return movies[i].Genre < movies[j].Genre
-			})
-		} else {
-			sort.Slice(movies, func(i, j int) bool {
-				return movies[i].Genre > movies[j].Genre
-			})
+	s.mu.RLock()
+	var movies []domain.Movie
+	if query.ActorID != 0 {
+		// по обратному индексу берем только фильмы актера, не перебирая все
+		movies = make([]domain.Movie, 0, len(s.moviesByActor[query.ActorID]))
+		for _, movieID := range s.moviesByActor[query.ActorID] {
+			movie := s.movies[s.movieIndex[movieID]]
+			if movie.DeletedAt == nil && matchMovie(movie, query) {
+				movies = append(movies, movie)
+			}
+		}
+	} else {
+		movies = make([]domain.Movie, 0, len(s.movies))
+		for i := range s.movies {
+			if s.movies[i].DeletedAt == nil && matchMovie(s.movies[i], query) {
+				movies = append(movies, s.movies[i])
+			}
 		}
+	}
+	s.mu.RUnlock()
 
-	case sortBy == "date":
-		if orderBy == "" || orderBy == "asc" {
-			sort.Slice(movies, func(i, j int) bool {
-				return movies[i].ReleaseDate.Before(movies[j].ReleaseDate)
-			})
-		} else {
-			sort.Slice(movies, func(i, j int) bool {
-				return movies[i].ReleaseDate.After(movies[j].ReleaseDate)
-			})
+	compare := func(a, b domain.Movie) int {
+		return query.Sort.Compare(func(field string) int { return a.CompareField(b, field) }, a.ID, b.ID)
+	}
+	slices.SortFunc(movies, compare)
+
+	total := len(movies)
+	if query.After != nil {
+		start, found := slices.BinarySearchFunc(movies, query.After.Last, compare)
+		if found {
+			start++
 		}
+		movies = movies[start:]
 	}
 
-	return movies
+	return paginate(movies, query.Offset, query.Limit), total, nil
 }
 
-func (s *Storage) GetActorsByMovie(id int) ([]domain.Actor, error) {
-	var actors []domain.Actor
-	actorsIDs := s.actorsByMovie[id]
-	for _, val := range actorsIDs {
-		actorExists := slices.ContainsFunc(s.actors, func(actor domain.Actor) bool {
-			return actor.ID == val
-		})
-		if !actorExists {
-			return []domain.Actor{}, domain.ErrNotFound
+// matchMovie - запись подходит, только если выполнены все условия фильтра
+func matchMovie(movie domain.Movie, query domain.MoviesQuery) bool {
+	return query.Name.Match(movie.Name) &&
+		query.Genre.Match(movie.Genre) &&
+		query.Country.Match(movie.Country) &&
+		query.ReleaseDate.Match(movie.ReleaseDate, time.Time.Compare) &&
+		query.Rating.Match(int(movie.Rating), cmp.Compare[int])
+}
+
+// GetCast возвращает состав фильма в порядке титров, для фильма без актеров - пустой список
+func (s *Storage) GetCast(ctx context.Context, movieID int) ([]domain.CastMember, error) {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return []domain.CastMember{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, err := s.movieByID(movieID); err != nil {
+		return []domain.CastMember{}, fmt.Errorf("movie id: %d, err: %w", movieID, err)
+	}
+
+	cast := make([]domain.CastMember, 0, len(s.actorsByMovie[movieID]))
+	for _, entry := range s.actorsByMovie[movieID] {
+		actor, err := s.actorByID(entry.ActorID)
+		if err != nil {
+			// мягко удаленный актер скрыт, но его роль сохраняется
+			continue
 		}
-		if actorExists {
-			actor, _ := s.GetActorByID(val) //!!
-			actors = append(actors, actor)
+		cast = append(cast, domain.CastMember{
+			Actor:        actor,
+			Character:    entry.Character,
+			BillingOrder: entry.BillingOrder,
+			Role:         entry.Role,
+		})
+	}
+
+	return cast, nil
+}
+
+// ReplaceCast заменяет состав фильма целиком, пустой cast удаляет всех актеров
+func (s *Storage) ReplaceCast(ctx context.Context, movieID int, cast []domain.CastEntry) error {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.movieByID(movieID); err != nil {
+		return fmt.Errorf("movie id: %d, err: %w", movieID, domain.ErrNotExists)
+	}
+
+	for _, entry := range cast {
+		if !s.actorExists(entry.ActorID) {
+			return fmt.Errorf("actor id: %d, err: %w", entry.ActorID, domain.ErrNotFound)
 		}
 	}
 
-	if len(actors) == 0 {
-		return []domain.Actor{}, domain.ErrNotFound
+	// храним свою копию: слайс вызывающего может меняться после возврата
+	cast = slices.Clone(cast)
+	// роли мягко удаленных актеров вызывающий не видит, поэтому они остаются как были
+	for _, entry := range s.actorsByMovie[movieID] {
+		if !s.actorExists(entry.ActorID) {
+			cast = append(cast, entry)
+		}
 	}
+	slices.SortStableFunc(cast, compareCastEntries)
+	s.setCast(movieID, cast)
 
-	return actors, nil
-}
+	// состав - часть фильма, поэтому его замена меняет версию фильма
+	i := s.movieIndex[movieID]
+	keepAt(s, &s.movies, i)
+	s.movies[i].Version++
+	s.movies[i].UpdatedAt = time.Now().UTC()
 
-func (s *Storage) CreateActorsByMovie(id int, actors []int) (int, []int, error) {
-	_, err := s.GetMovieByID(id)
+	return nil
+}
 
-	switch {
-	case errors.Is(err, domain.ErrNotFound):
-		return 0, []int{0}, domain.ErrNotExists
-	case err != nil:
-		return 0, []int{0}, fmt.Errorf("unexpected error %w", err)
+func compareCastEntries(a, b domain.CastEntry) int {
+	if a.BillingOrder != b.BillingOrder {
+		return cmp.Compare(a.BillingOrder, b.BillingOrder)
 	}
 
-	for _, val := range actors {
-		actorExists := slices.ContainsFunc(s.actors, func(actor domain.Actor) bool {
-			return actor.ID == val
+	return cmp.Compare(a.ActorID, b.ActorID)
+}
+
+// setCast заменяет состав фильма и поддерживает обратный индекс moviesByActor,
+// пустой cast удаляет состав целиком. Вызывается под s.mu.Lock.
+func (s *Storage) setCast(movieID int, cast []domain.CastEntry) {
+	keepList(s, s.actorsByMovie, movieID)
+	for _, entry := range s.actorsByMovie[movieID] {
+		keepList(s, s.moviesByActor, entry.ActorID)
+		movies := slices.DeleteFunc(s.moviesByActor[entry.ActorID], func(id int) bool {
+			return id == movieID
 		})
-		if !actorExists {
-			return 0, []int{0}, domain.ErrNotFound
+		if len(movies) == 0 {
+			delete(s.moviesByActor, entry.ActorID)
+		} else {
+			s.moviesByActor[entry.ActorID] = movies
 		}
 	}
 
-	//check if all actors exist
+	if len(cast) == 0 {
+		delete(s.actorsByMovie, movieID)
+		return
+	}
 
-	s.actorsByMovie[id] = actors
+	s.actorsByMovie[movieID] = cast
+	for _, entry := range cast {
+		keepList(s, s.moviesByActor, entry.ActorID)
+		s.moviesByActor[entry.ActorID] = append(s.moviesByActor[entry.ActorID], movieID)
+	}
+}
 
-	return id, actors, nil
+// removeFromCasts убирает актера из всех фильмов, как on delete cascade в базе.
+// Вызывается под s.mu.Lock.
+func (s *Storage) removeFromCasts(actorID int) {
+	keepList(s, s.moviesByActor, actorID)
+	for _, movieID := range s.moviesByActor[actorID] {
+		keepList(s, s.actorsByMovie, movieID)
+		cast := slices.DeleteFunc(s.actorsByMovie[movieID], func(entry domain.CastEntry) bool {
+			return entry.ActorID == actorID
+		})
+		if len(cast) == 0 {
+			delete(s.actorsByMovie, movieID)
+		} else {
+			s.actorsByMovie[movieID] = cast
+		}
+	}
+	delete(s.moviesByActor, actorID)
 }
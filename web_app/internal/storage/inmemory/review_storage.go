@@ -0,0 +1,150 @@
+package inmemory
+
+import (
+	"arch-demo/internal/domain"
+	"context"
+	"time"
+)
+
+func (s *Storage) GetReview(ctx context.Context, id int) (domain.Review, error) {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return domain.Review{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for i := range s.reviews {
+		if s.reviews[i].ID == id {
+			return s.reviews[i], nil
+		}
+	}
+
+	return domain.Review{}, domain.ErrNotFound
+}
+
+func (s *Storage) FindReview(ctx context.Context, movieID int, author string) (domain.Review, error) {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return domain.Review{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for i := range s.reviews {
+		if s.reviews[i].MovieID == movieID && s.reviews[i].Author == author {
+			return s.reviews[i], nil
+		}
+	}
+
+	return domain.Review{}, domain.ErrNotFound
+}
+
+func (s *Storage) InsertReview(ctx context.Context, review domain.Review) (domain.Review, error) {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return domain.Review{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.movieByID(review.MovieID); err != nil {
+		return domain.Review{}, err
+	}
+	for i := range s.reviews {
+		if s.reviews[i].MovieID == review.MovieID && s.reviews[i].Author == review.Author {
+			return domain.Review{}, domain.ErrExists
+		}
+	}
+
+	review.ID = nextID(s, &s.lastReviewID)
+	review.CreatedAt = time.Now().UTC()
+	review.UpdatedAt = review.CreatedAt
+	push(s, &s.reviews, review)
+
+	return review, nil
+}
+
+func (s *Storage) UpdateReview(ctx context.Context, review domain.Review) (domain.Review, error) {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return domain.Review{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.reviews {
+		if s.reviews[i].ID == review.ID {
+			keepAt(s, &s.reviews, i)
+			s.reviews[i].Rating = review.Rating
+			s.reviews[i].Text = review.Text
+			s.reviews[i].Status = review.Status
+			s.reviews[i].UpdatedAt = time.Now().UTC()
+			return s.reviews[i], nil
+		}
+	}
+
+	return domain.Review{}, domain.ErrNotFound
+}
+
+// ListReviews отдает отзывы в порядке убывания id, как и StorageDB. Отзывы
+// мягко удаленных фильмов не показываются, пока фильм не восстановят.
+func (s *Storage) ListReviews(ctx context.Context, query domain.ReviewsQuery) ([]domain.Review, int, error) {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return []domain.Review{}, 0, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reviews := make([]domain.Review, 0)
+	// отзывы добавляются с растущими id, поэтому с конца они уже упорядочены
+	for i := len(s.reviews) - 1; i >= 0; i-- {
+		review := s.reviews[i]
+		if (query.MovieID != 0 && review.MovieID != query.MovieID) ||
+			(query.Status != "" && review.Status != query.Status) {
+			continue
+		}
+		if _, err := s.movieByID(review.MovieID); err != nil {
+			continue
+		}
+		reviews = append(reviews, review)
+	}
+
+	total := len(reviews)
+	if query.After != nil {
+		start := 0
+		for start < len(reviews) && reviews[start].ID >= query.After.Last.ID {
+			start++
+		}
+		reviews = reviews[start:]
+	}
+
+	return paginate(reviews, query.Offset, query.Limit), total, nil
+}
+
+func (s *Storage) AddMovieScore(ctx context.Context, movieID int, rating int8, delta int) error {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.movieIndex[movieID]
+	if !ok || s.movies[i].DeletedAt != nil {
+		return domain.ErrNotFound
+	}
+
+	keepAt(s, &s.movies, i)
+	s.movies[i].Score.Add(rating, delta)
+	s.movies[i].Version++
+	s.movies[i].UpdatedAt = time.Now().UTC()
+	return nil
+}
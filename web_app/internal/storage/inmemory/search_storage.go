@@ -0,0 +1,195 @@
+package inmemory
+
+import (
+	"arch-demo/internal/domain"
+	"context"
+)
+
+// searchDoc - запись в поисковом индексе
+type searchDoc struct {
+	kind string
+	id   int
+}
+
+// searchIndex - обратный индекс по словам названий. Слова запроса ищутся в словаре
+// через триграммы, поэтому находятся и начала слов, и слова с опечатками, а записи
+// берутся из списков слов. Мягко удаленные записи остаются в индексе до очистки
+// корзины и отбрасываются при поиске.
+type searchIndex struct {
+	// words - слова каждой записи, чтобы убрать ее из postings при изменении
+	words map[searchDoc][]string
+	// postings - записи, в названии которых есть слово
+	postings map[string]map[searchDoc]struct{}
+	// trigrams - слова словаря, в которых есть триграмма
+	trigrams map[string]map[string]struct{}
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		words:    make(map[searchDoc][]string),
+		postings: make(map[string]map[searchDoc]struct{}),
+		trigrams: make(map[string]map[string]struct{}),
+	}
+}
+
+func (idx *searchIndex) put(doc searchDoc, name string) {
+	idx.remove(doc)
+	idx.add(doc, domain.NormalizeSearch(name))
+}
+
+// add добавляет слова записи, которой еще нет в индексе
+func (idx *searchIndex) add(doc searchDoc, words []string) {
+	idx.words[doc] = words
+	for _, word := range words {
+		docs, ok := idx.postings[word]
+		if !ok {
+			docs = make(map[searchDoc]struct{})
+			idx.postings[word] = docs
+			for _, trigram := range trigrams(word) {
+				if idx.trigrams[trigram] == nil {
+					idx.trigrams[trigram] = make(map[string]struct{})
+				}
+				idx.trigrams[trigram][word] = struct{}{}
+			}
+		}
+		docs[doc] = struct{}{}
+	}
+}
+
+func (idx *searchIndex) remove(doc searchDoc) {
+	for _, word := range idx.words[doc] {
+		docs := idx.postings[word]
+		delete(docs, doc)
+		if len(docs) > 0 {
+			continue
+		}
+
+		// слово больше не встречается, убираем его из словаря
+		delete(idx.postings, word)
+		for _, trigram := range trigrams(word) {
+			delete(idx.trigrams[trigram], word)
+			if len(idx.trigrams[trigram]) == 0 {
+				delete(idx.trigrams, trigram)
+			}
+		}
+	}
+	delete(idx.words, doc)
+}
+
+// find возвращает id записей вида kind, в которых нашлось каждое слово запроса:
+// целиком, началом слова или с допустимым числом опечаток, как в domain.SearchScore
+func (idx *searchIndex) find(kind string, terms []string) map[int]struct{} {
+	var found map[int]struct{}
+	for _, term := range terms {
+		matched := make(map[int]struct{})
+		for word := range idx.candidateWords(term) {
+			for doc := range idx.postings[word] {
+				if doc.kind != kind {
+					continue
+				}
+				if _, ok := found[doc.id]; found == nil || ok {
+					matched[doc.id] = struct{}{}
+				}
+			}
+		}
+
+		found = matched
+		if len(found) == 0 {
+			break
+		}
+	}
+
+	return found
+}
+
+// candidateWords - слова словаря, подходящие под term. Обычно проверяются только слова
+// с общей триграммой: у начала слова общая первая, а каждая опечатка портит не больше
+// четырех триграмм term (перестановка - четыре), так что при длине больше 4*Typos общая
+// триграмма остается. В более коротком слове запроса опечатка может не оставить общих
+// триграмм ("bacd" и "abcdef"), и тогда проверяется весь словарь.
+func (idx *searchIndex) candidateWords(term string) map[string]struct{} {
+	words := make(map[string]struct{})
+	terms := []string{term}
+
+	n := len([]rune(term))
+	allowed := domain.Typos(n)
+	if allowed > 0 && n <= 4*allowed {
+		for word := range idx.postings {
+			if domain.SearchScore(terms, word) > 0 {
+				words[word] = struct{}{}
+			}
+		}
+		return words
+	}
+
+	for i, trigram := range trigrams(term) {
+		// без опечаток хватает первой триграммы: с нее начинается и само слово, и его продолжения
+		if allowed == 0 && i > 0 {
+			break
+		}
+		for word := range idx.trigrams[trigram] {
+			if _, ok := words[word]; !ok && domain.SearchScore(terms, word) > 0 {
+				words[word] = struct{}{}
+			}
+		}
+	}
+
+	return words
+}
+
+// trigrams - триграммы слова с двумя пробелами в начале и одним в конце,
+// как в pg_trgm: так у коротких слов тоже есть триграммы, а начало слова отличимо
+func trigrams(word string) []string {
+	runes := append([]rune("  "+word), ' ')
+	result := make([]string, 0, len(runes)-2)
+	for i := 0; i+3 <= len(runes); i++ {
+		result = append(result, string(runes[i:i+3]))
+	}
+
+	return result
+}
+
+// SearchActors отдает актеров, в названии которых нашлись все слова запроса
+func (s *Storage) SearchActors(ctx context.Context, query domain.SearchQuery) ([]domain.Actor, error) {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return []domain.Actor{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := s.search.find(domain.SearchActors, query.Terms)
+	actors := make([]domain.Actor, 0, len(ids))
+	if len(ids) == 0 {
+		return actors, nil
+	}
+	for i := range s.actors {
+		if _, ok := ids[s.actors[i].ID]; ok && s.actors[i].DeletedAt == nil {
+			actors = append(actors, s.actors[i])
+		}
+	}
+
+	return actors, nil
+}
+
+// SearchMovies отдает фильмы, в названии которых нашлись все слова запроса
+func (s *Storage) SearchMovies(ctx context.Context, query domain.SearchQuery) ([]domain.Movie, error) {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return []domain.Movie{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := s.search.find(domain.SearchMovies, query.Terms)
+	movies := make([]domain.Movie, 0, len(ids))
+	for id := range ids {
+		if movie, err := s.movieByID(id); err == nil {
+			movies = append(movies, movie)
+		}
+	}
+
+	return movies, nil
+}
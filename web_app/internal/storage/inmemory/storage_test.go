@@ -0,0 +1,182 @@
+package inmemory
+
+import (
+	"arch-demo/internal/domain"
+	"arch-demo/internal/services"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	_ services.ActorsRepository = (*Storage)(nil)
+	_ services.MoviesRepository = (*Storage)(nil)
+)
+
+const (
+	stressWorkers    = 16
+	stressIterations = 200
+)
+
+func newTestActor(i int) domain.Actor {
+	return domain.Actor{
+		Name:           fmt.Sprintf("actor %d", i),
+		BirthYear:      1950 + i%50,
+		CountryOfBirth: "RU",
+		Gender:         "female",
+	}
+}
+
+func newTestMovie(i int) domain.Movie {
+	return domain.Movie{
+		Name:        fmt.Sprintf("movie %d", i),
+		ReleaseDate: time.Date(2000+i%20, 1, 1, 0, 0, 0, 0, time.UTC),
+		Country:     "RU",
+		Genre:       "drama",
+		Rating:      int8(1 + i%5),
+	}
+}
+
+// runConcurrently запускает fn в stressWorkers горутинах одновременно
+func runConcurrently(fn func(worker, iteration int)) {
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for w := 0; w < stressWorkers; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			<-start
+			for i := 0; i < stressIterations; i++ {
+				fn(worker, i)
+			}
+		}(w)
+	}
+	close(start)
+	wg.Wait()
+}
+
+func TestStorage_ConcurrentInsertsGetUniqueIDs(t *testing.T) {
+	ctx := context.Background()
+	s := NewStorage()
+
+	var mu sync.Mutex
+	actorIDs := make(map[int]bool)
+	movieIDs := make(map[int]bool)
+
+	runConcurrently(func(worker, i int) {
+		// у каждой записи свое название: фильм с тем же названием не вставляется
+		n := worker*stressIterations + i
+		actor, err := s.InsertActor(ctx, newTestActor(n))
+		if err != nil {
+			t.Errorf("InsertActor: %v", err)
+			return
+		}
+		movie, err := s.InsertMovie(ctx, newTestMovie(n))
+		if err != nil {
+			t.Errorf("InsertMovie: %v", err)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if actorIDs[actor.ID] {
+			t.Errorf("duplicate actor id %d", actor.ID)
+		}
+		if movieIDs[movie.ID] {
+			t.Errorf("duplicate movie id %d", movie.ID)
+		}
+		actorIDs[actor.ID] = true
+		movieIDs[movie.ID] = true
+	})
+
+	want := stressWorkers * stressIterations
+	actors, _, _ := s.ListActors(ctx, domain.ActorsQuery{})
+	movies, _, _ := s.ListMovies(ctx, domain.MoviesQuery{})
+	if len(actors) != want || len(movies) != want {
+		t.Fatalf("got %d actors and %d movies, want %d of each", len(actors), len(movies), want)
+	}
+}
+
+func TestStorage_IDsAreNotReusedAfterDelete(t *testing.T) {
+	ctx := context.Background()
+	s := NewStorage()
+
+	first, _ := s.InsertActor(ctx, newTestActor(1))
+	_ = s.DeleteActor(ctx, first.ID, 0, domain.DeleteCascade)
+	second, _ := s.InsertActor(ctx, newTestActor(2))
+	if second.ID == first.ID {
+		t.Fatalf("actor id %d reused after delete", first.ID)
+	}
+
+	firstMovie, _ := s.InsertMovie(ctx, newTestMovie(1))
+	_ = s.DeleteMovie(ctx, firstMovie.ID, 0, domain.DeleteCascade)
+	secondMovie, _ := s.InsertMovie(ctx, newTestMovie(2))
+	if secondMovie.ID == firstMovie.ID {
+		t.Fatalf("movie id %d reused after delete", firstMovie.ID)
+	}
+}
+
+// TestStorage_ConcurrentAccess гоняет все методы ActorsRepository и
+// MoviesRepository одновременно; имеет смысл запускать с -race
+func TestStorage_ConcurrentAccess(t *testing.T) {
+	ctx := context.Background()
+	s := NewStorage()
+	for i := 0; i < 20; i++ {
+		_, _ = s.InsertActor(ctx, newTestActor(i))
+		_, _ = s.InsertMovie(ctx, newTestMovie(i))
+	}
+
+	runConcurrently(func(worker, i int) {
+		id := 1 + (worker+i)%20
+
+		switch (worker + i) % 8 {
+		case 0:
+			actor, _ := s.InsertActor(ctx, newTestActor(i))
+			if i%4 == 0 {
+				_ = s.DeleteActor(ctx, actor.ID, 0, domain.DeleteCascade)
+			}
+		case 1:
+			actor, err := s.GetActorByID(ctx, id)
+			if err == nil {
+				actor.Name = fmt.Sprintf("renamed by %d", worker)
+				_, _ = s.UpdateActor(ctx, actor)
+			}
+		case 2:
+			_, _, _ = s.ListActors(ctx, domain.ActorsQuery{Sort: domain.Sort{{Field: domain.ActorSortName, Desc: true}}})
+			_, _, _ = s.ListActors(ctx, domain.ActorsQuery{Name: domain.TextFilter{Contains: []string{"actor"}}, Country: domain.TextFilter{Eq: []string{"RU"}}})
+			_, _ = s.IsActorExists(ctx, newTestActor(i))
+		case 3:
+			movie, _ := s.InsertMovie(ctx, newTestMovie(i))
+			if i%4 == 0 {
+				_ = s.DeleteMovie(ctx, movie.ID, 0, domain.DeleteCascade)
+			}
+		case 4:
+			movie, err := s.GetMovieByID(ctx, id)
+			if err == nil {
+				movie.Rating = int8(1 + worker%5)
+				_, _ = s.UpdateMovie(ctx, movie)
+			}
+		case 5:
+			_, _, _ = s.ListMovies(ctx, domain.MoviesQuery{Genre: domain.TextFilter{Eq: []string{"drama"}}, Sort: domain.Sort{{Field: domain.MovieSortReleaseDate}}})
+			_, _, _ = s.ListMovies(ctx, domain.MoviesQuery{ActorID: id, Sort: domain.Sort{{Field: domain.MovieSortName}}})
+			_, _ = s.IsMovieExists(ctx, newTestMovie(i))
+		case 6:
+			cast := []domain.CastEntry{{ActorID: id, BillingOrder: 1}, {ActorID: 1 + id%20, BillingOrder: 2}}
+			_ = s.ReplaceCast(ctx, id, cast)
+			// изменение переданного слайса не должно задевать хранилище
+			cast[0].ActorID = -1
+		case 7:
+			_, _ = s.GetCast(ctx, id)
+		}
+	})
+
+	for id := 1; id <= 20; id++ {
+		for _, entry := range s.actorsByMovie[id] {
+			if entry.ActorID < 0 {
+				t.Fatalf("movie %d cast shares memory with the caller's slice", id)
+			}
+		}
+	}
+}
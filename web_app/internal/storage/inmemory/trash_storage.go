@@ -0,0 +1,142 @@
+package inmemory
+
+import (
+	"arch-demo/internal/domain"
+	"cmp"
+	"context"
+	"slices"
+	"time"
+)
+
+func (s *Storage) RestoreActor(ctx context.Context, id int) (domain.Actor, error) {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return domain.Actor{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.actors {
+		if s.actors[i].ID == id && s.actors[i].DeletedAt != nil {
+			keepAt(s, &s.actors, i)
+			s.actors[i].DeletedAt = nil
+			s.actors[i].UpdatedAt = time.Now().UTC()
+			s.actors[i].Version++
+			return s.actors[i], nil
+		}
+	}
+
+	return domain.Actor{}, domain.ErrNotFound
+}
+
+func (s *Storage) RestoreMovie(ctx context.Context, id int) (domain.Movie, error) {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return domain.Movie{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.movieIndex[id]
+	if !ok || s.movies[i].DeletedAt == nil {
+		return domain.Movie{}, domain.ErrNotFound
+	}
+
+	keepAt(s, &s.movies, i)
+	s.movies[i].DeletedAt = nil
+	s.movies[i].UpdatedAt = time.Now().UTC()
+	s.movies[i].Version++
+	return s.movies[i], nil
+}
+
+// ListDeletedActors возвращает мягко удаленных актеров, последние удаленные идут первыми
+func (s *Storage) ListDeletedActors(ctx context.Context) ([]domain.Actor, error) {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return []domain.Actor{}, err
+	}
+
+	s.mu.RLock()
+	actors := []domain.Actor{}
+	for i := range s.actors {
+		if s.actors[i].DeletedAt != nil {
+			actors = append(actors, s.actors[i])
+		}
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(actors, func(a, b domain.Actor) int {
+		return compareDeleted(*a.DeletedAt, *b.DeletedAt, a.ID, b.ID)
+	})
+
+	return actors, nil
+}
+
+func (s *Storage) ListDeletedMovies(ctx context.Context) ([]domain.Movie, error) {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return []domain.Movie{}, err
+	}
+
+	s.mu.RLock()
+	movies := []domain.Movie{}
+	for i := range s.movies {
+		if s.movies[i].DeletedAt != nil {
+			movies = append(movies, s.movies[i])
+		}
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(movies, func(a, b domain.Movie) int {
+		return compareDeleted(*a.DeletedAt, *b.DeletedAt, a.ID, b.ID)
+	})
+
+	return movies, nil
+}
+
+// compareDeleted упорядочивает по убыванию времени удаления, затем по убыванию id
+func compareDeleted(a, b time.Time, idA, idB int) int {
+	if c := b.Compare(a); c != 0 {
+		return c
+	}
+
+	return cmp.Compare(idB, idA)
+}
+
+// PurgeDeleted окончательно удаляет записи, мягко удаленные раньше before,
+// вместе с их связями и возвращает, сколько актеров и фильмов удалено
+func (s *Storage) PurgeDeleted(ctx context.Context, before time.Time) (int, int, error) {
+	s = s.in(ctx)
+	if err := ctx.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var actorIDs []int
+	keepAll(s, &s.actors)
+	s.actors = slices.DeleteFunc(s.actors, func(actor domain.Actor) bool {
+		if actor.DeletedAt != nil && actor.DeletedAt.Before(before) {
+			actorIDs = append(actorIDs, actor.ID)
+			return true
+		}
+		return false
+	})
+	for _, id := range actorIDs {
+		s.removeFromCasts(id)
+		s.searchRemove(searchDoc{domain.SearchActors, id})
+	}
+
+	movies := 0
+	for i := len(s.movies) - 1; i >= 0; i-- {
+		if s.movies[i].DeletedAt != nil && s.movies[i].DeletedAt.Before(before) {
+			s.removeMovie(i)
+			movies++
+		}
+	}
+
+	return len(actorIDs), movies, nil
+}
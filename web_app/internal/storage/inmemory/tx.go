@@ -0,0 +1,179 @@
+package inmemory
+
+import (
+	"context"
+	"slices"
+)
+
+type txKey struct{}
+
+// txScope - представление хранилища, с которым работает Atomic, и хранилище, которому оно принадлежит
+type txScope struct {
+	owner *Storage
+	tx    *Storage
+}
+
+// Atomic выполняет fn над данными хранилища, записывая в журнал, как отменить каждое
+// изменение. Если fn вернула ошибку или упала, журнал проигрывается с конца и данные
+// возвращаются к исходным. Все вызовы хранилища с контекстом, переданным в fn, идут через
+// журнал. Пока fn работает, хранилище заблокировано и для чтения, и для записи, так что fn
+// видит данные без чужих изменений, а остальные не видят ее незавершенных изменений.
+// Atomic внутри Atomic присоединяется к внешней.
+//
+// Блокировка на всю fn - осознанное ограничение хранилища в памяти: большой пакет или
+// импорт каталога останавливает всех читателей до своего конца, и они продолжают, когда
+// он закончится. Хранилище рассчитано на разработку и тесты; где важно читать во время
+// долгих изменений, нужен postgres.
+func (s *Storage) Atomic(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s.in(ctx) != s {
+		return fn(ctx)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var undo []func()
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i]()
+		}
+	}()
+
+	tx := &Storage{data: s.data, undo: &undo}
+	if err := fn(context.WithValue(ctx, txKey{}, txScope{owner: s, tx: tx})); err != nil {
+		return err
+	}
+
+	committed = true
+	return nil
+}
+
+// in возвращает представление из Atomic, если вызов идет внутри нее, иначе само хранилище.
+// Вызов мимо представления ждал бы конца Atomic вечно: она держит блокировку хранилища.
+func (s *Storage) in(ctx context.Context) *Storage {
+	scope, ok := ctx.Value(txKey{}).(txScope)
+	if !ok || scope.owner != s {
+		return s
+	}
+
+	return scope.tx
+}
+
+// onRollback добавляет в журнал Atomic отмену изменения, вне Atomic отменять нечего
+func (s *Storage) onRollback(undo func()) {
+	if s.undo != nil {
+		*s.undo = append(*s.undo, undo)
+	}
+}
+
+// Функции keep* вызываются до изменения и запоминают то, что оно затронет. Изменение,
+// не записанное в журнал, откат не отменит.
+
+// keep запоминает значение целиком, например счетчик id
+func keep[T any](s *Storage, p *T) {
+	if s.undo == nil {
+		return
+	}
+	old := *p
+	s.onRollback(func() { *p = old })
+}
+
+// keepAt запоминает элемент слайса перед изменением на месте
+func keepAt[T any](s *Storage, p *[]T, i int) {
+	if s.undo == nil {
+		return
+	}
+	old := (*p)[i]
+	s.onRollback(func() { (*p)[i] = old })
+}
+
+// keepAll копирует слайс перед удалением из него: удаление сдвигает элементы на месте
+func keepAll[T any](s *Storage, p *[]T) {
+	if s.undo == nil {
+		return
+	}
+	old := slices.Clone(*p)
+	s.onRollback(func() { *p = old })
+}
+
+// keepKey запоминает значение ключа в map или его отсутствие
+func keepKey[K comparable, V any](s *Storage, m map[K]V, key K) {
+	if s.undo == nil {
+		return
+	}
+	old, ok := m[key]
+	s.onRollback(func() {
+		if ok {
+			m[key] = old
+		} else {
+			delete(m, key)
+		}
+	})
+}
+
+// keepList - keepKey для map со слайсами, которые меняются на месте
+func keepList[K comparable, V any](s *Storage, m map[K][]V, key K) {
+	if s.undo == nil {
+		return
+	}
+	old, ok := m[key]
+	old = slices.Clone(old)
+	s.onRollback(func() {
+		if ok {
+			m[key] = old
+		} else {
+			delete(m, key)
+		}
+	})
+}
+
+// nextID увеличивает счетчик id и возвращает новый id
+func nextID(s *Storage, last *int) int {
+	keep(s, last)
+	*last++
+
+	return *last
+}
+
+// push дописывает элемент в конец слайса. Откат отрезает последний элемент у того слайса,
+// который будет к тому времени: прежний заголовок мог указывать на массив, который
+// позже сдвинуло удаление на месте.
+func push[T any](s *Storage, p *[]T, item T) {
+	*p = append(*p, item)
+	s.onRollback(func() {
+		var zero T
+		(*p)[len(*p)-1] = zero
+		*p = (*p)[:len(*p)-1]
+	})
+}
+
+// searchPut и searchRemove меняют поисковый индекс, запоминая прежние слова записи
+func (s *Storage) searchPut(doc searchDoc, name string) {
+	s.keepSearch(doc)
+	s.search.put(doc, name)
+}
+
+func (s *Storage) searchRemove(doc searchDoc) {
+	s.keepSearch(doc)
+	s.search.remove(doc)
+}
+
+func (s *Storage) keepSearch(doc searchDoc) {
+	if s.undo == nil {
+		return
+	}
+	old, ok := s.search.words[doc]
+	s.onRollback(func() {
+		s.search.remove(doc)
+		if ok {
+			s.search.add(doc, old)
+		}
+	})
+}
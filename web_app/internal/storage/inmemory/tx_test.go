@@ -0,0 +1,208 @@
+package inmemory
+
+import (
+	"arch-demo/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// dump - все данные хранилища текстом; fmt печатает map в порядке ключей
+func dump(s *Storage) string {
+	return fmt.Sprintf("%+v\n%+v", *s.data, *s.search)
+}
+
+// TestStorage_AtomicRollback - откат по журналу возвращает все данные к исходным,
+// какие бы изменения ни успела сделать fn
+func TestStorage_AtomicRollback(t *testing.T) {
+	ctx := context.Background()
+	s := NewStorage()
+
+	var actors []domain.Actor
+	var movies []domain.Movie
+	for i := 0; i < 5; i++ {
+		actor, _ := s.InsertActor(ctx, newTestActor(i))
+		actors = append(actors, actor)
+		movie, _ := s.InsertMovie(ctx, newTestMovie(i))
+		movies = append(movies, movie)
+	}
+	for i, movie := range movies {
+		cast := []domain.CastEntry{{ActorID: actors[i].ID, BillingOrder: 1}, {ActorID: actors[(i+1)%5].ID, BillingOrder: 2}}
+		if err := s.ReplaceCast(ctx, movie.ID, cast); err != nil {
+			t.Fatalf("ReplaceCast: %v", err)
+		}
+	}
+	user, _ := s.InsertUser(ctx, domain.User{Name: "editor", Role: domain.RoleEditor})
+	_, _ = s.InsertAPIKey(ctx, domain.APIKey{UserID: user.ID, Name: "ci", Hash: "hash"})
+	review, _ := s.InsertReview(ctx, domain.Review{MovieID: movies[0].ID, Author: "editor", Rating: 4, Status: domain.ReviewApproved})
+	_ = s.AddMovieScore(ctx, movies[0].ID, 4, 1)
+	_ = s.InsertAuditEntry(ctx, domain.AuditEntry{Entity: domain.AuditActor, EntityID: actors[0].ID, Action: domain.AuditCreate})
+	_ = s.DeleteActor(ctx, actors[4].ID, 0, domain.DeleteSoft)
+	_ = s.DeleteMovie(ctx, movies[4].ID, 0, domain.DeleteSoft)
+	// замена состава и оценка сменили версии, шагам нужны текущие
+	for i := 0; i < 4; i++ {
+		actors[i], _ = s.GetActorByID(ctx, actors[i].ID)
+		movies[i], _ = s.GetMovieByID(ctx, movies[i].ID)
+	}
+
+	// каждый шаг меняет что-то свое, ошибки шагов проверяются после отката
+	steps := []func(ctx context.Context) error{
+		func(ctx context.Context) error { _, err := s.InsertActor(ctx, newTestActor(10)); return err },
+		func(ctx context.Context) error {
+			actor := actors[0]
+			actor.Name = "renamed"
+			_, err := s.UpdateActor(ctx, actor)
+			return err
+		},
+		func(ctx context.Context) error { return s.DeleteActor(ctx, actors[1].ID, 0, domain.DeleteSoft) },
+		func(ctx context.Context) error { return s.DeleteActor(ctx, actors[2].ID, 0, domain.DeleteCascade) },
+		func(ctx context.Context) error { _, err := s.InsertMovie(ctx, newTestMovie(10)); return err },
+		func(ctx context.Context) error {
+			movie := movies[1]
+			movie.Name = "renamed"
+			_, err := s.UpdateMovie(ctx, movie)
+			return err
+		},
+		func(ctx context.Context) error {
+			return s.ReplaceCast(ctx, movies[3].ID, []domain.CastEntry{{ActorID: actors[3].ID, BillingOrder: 1}})
+		},
+		func(ctx context.Context) error { return s.DeleteMovie(ctx, movies[2].ID, 0, domain.DeleteCascade) },
+		func(ctx context.Context) error {
+			return s.InsertAuditEntry(ctx, domain.AuditEntry{Entity: domain.AuditMovie})
+		},
+		func(ctx context.Context) error {
+			_, err := s.InsertUser(ctx, domain.User{Name: "viewer", Role: domain.RoleViewer})
+			return err
+		},
+		func(ctx context.Context) error {
+			_, err := s.UpdateUser(ctx, domain.User{ID: user.ID, Role: domain.RoleAdmin, Disabled: true})
+			return err
+		},
+		func(ctx context.Context) error {
+			_, err := s.InsertAPIKey(ctx, domain.APIKey{UserID: user.ID, Name: "cd", Hash: "other"})
+			return err
+		},
+		func(ctx context.Context) error { return s.DeleteUser(ctx, user.ID) },
+		func(ctx context.Context) error {
+			_, err := s.InsertReview(ctx, domain.Review{MovieID: movies[1].ID, Author: "viewer", Rating: 2, Status: domain.ReviewApproved})
+			return err
+		},
+		func(ctx context.Context) error {
+			review := review
+			review.Rating = 1
+			_, err := s.UpdateReview(ctx, review)
+			return err
+		},
+		func(ctx context.Context) error { return s.AddMovieScore(ctx, movies[1].ID, 2, 1) },
+		func(ctx context.Context) error { _, err := s.RestoreActor(ctx, actors[4].ID); return err },
+		func(ctx context.Context) error { _, err := s.RestoreMovie(ctx, movies[4].ID); return err },
+		func(ctx context.Context) error {
+			_, _, err := s.PurgeDeleted(ctx, time.Now().Add(time.Hour))
+			return err
+		},
+	}
+
+	before := dump(s)
+	errRollback := errors.New("rollback")
+	err := s.Atomic(ctx, func(ctx context.Context) error {
+		for i, step := range steps {
+			if err := step(ctx); err != nil {
+				return fmt.Errorf("step %d: %w", i, err)
+			}
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("Atomic = %v, want the error of fn", err)
+	}
+	if after := dump(s); after != before {
+		t.Errorf("data after rollback differ:\nbefore %s\nafter  %s", before, after)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("panic in fn did not reach the caller")
+			}
+		}()
+		_ = s.Atomic(ctx, func(ctx context.Context) error {
+			for _, step := range steps {
+				_ = step(ctx)
+			}
+			panic("fn failed")
+		})
+	}()
+	if after := dump(s); after != before {
+		t.Errorf("data after panic in fn differ:\nbefore %s\nafter  %s", before, after)
+	}
+
+	// без ошибки те же изменения остаются
+	err = s.Atomic(ctx, func(ctx context.Context) error {
+		for i, step := range steps {
+			if err := step(ctx); err != nil {
+				return fmt.Errorf("step %d: %w", i, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Atomic: %v", err)
+	}
+	if _, err = s.GetMovieByID(ctx, movies[2].ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("GetMovieByID of a movie deleted in Atomic = %v, want ErrNotFound", err)
+	}
+	if _, err = s.GetUserByID(ctx, user.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("GetUserByID of a user deleted in Atomic = %v, want ErrNotFound", err)
+	}
+}
+
+// TestStorage_AtomicBlocksReaders - читатели ждут конца Atomic, а потом продолжают
+// и видят ее изменения целиком
+func TestStorage_AtomicBlocksReaders(t *testing.T) {
+	ctx := context.Background()
+	s := NewStorage()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Atomic(ctx, func(ctx context.Context) error {
+			for i := 0; i < 3; i++ {
+				if _, err := s.InsertActor(ctx, newTestActor(i)); err != nil {
+					return err
+				}
+			}
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+
+	read := make(chan int, 1)
+	go func() {
+		actors, _, _ := s.ListActors(ctx, domain.ActorsQuery{})
+		read <- len(actors)
+	}()
+
+	select {
+	case n := <-read:
+		t.Fatalf("ListActors returned %d actors while Atomic was running", n)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Atomic: %v", err)
+	}
+	select {
+	case n := <-read:
+		if n != 3 {
+			t.Errorf("ListActors after Atomic = %d actors, want 3", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ListActors did not resume after Atomic")
+	}
+}
@@ -0,0 +1,122 @@
+package storagetest
+
+import (
+	"arch-demo/internal/domain"
+	"arch-demo/internal/services"
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+type AuditStorage interface {
+	Storage
+	services.AuditRepository
+}
+
+// RunAudit проверяет журнал изменений через сервисы, чтобы вместе с хранилищем
+// проверить и то, какую разницу они туда пишут
+func RunAudit(t *testing.T, newStorage func(t *testing.T) AuditStorage) {
+	ctx := domain.WithCaller(context.Background(), "editor")
+
+	t.Run("actor history survives delete", func(t *testing.T) {
+		s := newStorage(t)
+		actors := services.NewActorService(s, domain.DeleteCascade, services.NewAuditLog(s))
+
+		actor, err := actors.Create(ctx, domain.Actor{Name: "lead", BirthYear: 1970, CountryOfBirth: "RU", Gender: domain.GenderMale})
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		name := "renamed"
+		if _, err = actors.Update(ctx, actor.ID, 0, domain.ActorUpdate{Name: &name}); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		if err = actors.Delete(context.Background(), actor.ID, 0); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+
+		history, err := actors.History(ctx, actor.ID)
+		if err != nil {
+			t.Fatalf("History: %v", err)
+		}
+		if got, want := auditActions(history), []string{domain.AuditCreate, domain.AuditUpdate, domain.AuditDelete}; !slices.Equal(got, want) {
+			t.Fatalf("actions = %v, want %v", got, want)
+		}
+
+		for i, entry := range history {
+			if entry.Entity != domain.AuditActor || entry.EntityID != actor.ID {
+				t.Errorf("entry %d is for %s %d", i, entry.Entity, entry.EntityID)
+			}
+			if time.Since(entry.At) > time.Minute || entry.At.Location() != time.UTC {
+				t.Errorf("entry %d at = %v, want recent UTC time", i, entry.At)
+			}
+		}
+		if history[0].Caller != "editor" || history[2].Caller != domain.AnonymousCaller {
+			t.Errorf("callers = %q, %q, want editor and anonymous", history[0].Caller, history[2].Caller)
+		}
+
+		update := history[1].Changes
+		if got, want := formatChanges(update), `name: "lead" -> "renamed"; version: 1 -> 2; `; got != want {
+			t.Errorf("update changes = %s, want %s", got, want)
+		}
+		// отсутствующее значение после чтения из базы приходит как json null
+		for _, change := range history[2].Changes {
+			if change.After != nil && string(change.After) != "null" {
+				t.Errorf("delete change %s has after value %s", change.Field, change.After)
+			}
+		}
+	})
+
+	t.Run("cast changes go to movie history", func(t *testing.T) {
+		s := newStorage(t)
+		f := newFixture(t, ctx, s)
+		movies := services.NewMovieService(s, domain.DeleteCascade, services.NewAuditLog(s))
+
+		if err := movies.RemoveCastMember(ctx, f.first.ID, f.support.ID); err != nil {
+			t.Fatalf("RemoveCastMember: %v", err)
+		}
+
+		history, err := movies.History(ctx, f.first.ID)
+		if err != nil {
+			t.Fatalf("History: %v", err)
+		}
+		if len(history) != 1 || history[0].Action != domain.AuditCast {
+			t.Fatalf("history = %+v, want one cast entry", history)
+		}
+
+		changes := history[0].Changes
+		wantBefore := `[{"actor_id":1,"character":"","billing_order":1,"role":"lead"},{"actor_id":2,"character":"","billing_order":2,"role":"supporting"}]`
+		wantAfter := `[{"actor_id":1,"character":"","billing_order":1,"role":"lead"}]`
+		if len(changes) != 1 || changes[0].Field != "cast" ||
+			string(changes[0].Before) != wantBefore || string(changes[0].After) != wantAfter {
+			t.Errorf("cast changes = %s", formatChanges(changes))
+		}
+
+		// другой фильм и актеры с тем же id в журнале не смешиваются
+		if history, err = movies.History(ctx, f.second.ID); err != nil || len(history) != 0 {
+			t.Errorf("second movie history = %v, %v, want empty", history, err)
+		}
+	})
+}
+
+func auditActions(entries []domain.AuditEntry) []string {
+	actions := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		actions = append(actions, entry.Action)
+	}
+
+	return actions
+}
+
+// formatChanges пропускает updated_at: его значение заранее неизвестно
+func formatChanges(changes []domain.FieldChange) string {
+	var s string
+	for _, change := range changes {
+		if change.Field == "updated_at" {
+			continue
+		}
+		s += change.Field + ": " + string(change.Before) + " -> " + string(change.After) + "; "
+	}
+
+	return s
+}
@@ -0,0 +1,137 @@
+package storagetest
+
+import (
+	"arch-demo/internal/domain"
+	"arch-demo/internal/services"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type AuthStorage interface {
+	services.AuthRepository
+}
+
+const testHS256Secret = "0123456789abcdef0123456789abcdef"
+
+// RunAuth проверяет пользователей и ключи через AuthService: хранилище отвечает
+// и за поиск ключа по хэшу, и за то, что ключи уходят вместе с пользователем
+func RunAuth(t *testing.T, newStorage func(t *testing.T) AuthStorage) {
+	ctx := context.Background()
+
+	newService := func(t *testing.T) services.AuthService {
+		tokens, err := services.NewTokenVerifier(services.TokenConfig{HS256Secret: testHS256Secret, Audience: "movies"})
+		if err != nil {
+			t.Fatalf("NewTokenVerifier: %v", err)
+		}
+
+		return services.NewAuthService(newStorage(t), tokens, "")
+	}
+
+	t.Run("api key authenticates its user until the user is deleted", func(t *testing.T) {
+		auth := newService(t)
+
+		user, err := auth.CreateUser(ctx, domain.User{Name: "editor", Role: domain.RoleEditor})
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		if _, err = auth.CreateUser(ctx, domain.User{Name: "editor", Role: domain.RoleViewer}); !errors.Is(err, domain.ErrExists) {
+			t.Errorf("CreateUser with taken name err = %v, want ErrExists", err)
+		}
+
+		key, err := auth.CreateAPIKey(ctx, domain.APIKey{UserID: user.ID, Name: "ci"})
+		if err != nil {
+			t.Fatalf("CreateAPIKey: %v", err)
+		}
+		if key.Secret == "" || key.Prefix == "" || key.Secret[:len(key.Prefix)] != key.Prefix {
+			t.Fatalf("key = %+v, want secret starting with prefix", key)
+		}
+
+		principal, err := auth.AuthenticateKey(ctx, key.Secret)
+		if err != nil {
+			t.Fatalf("AuthenticateKey: %v", err)
+		}
+		if principal != (domain.Principal{Subject: "editor", Role: domain.RoleEditor}) {
+			t.Errorf("principal = %+v", principal)
+		}
+
+		keys, err := auth.ListAPIKeys(ctx)
+		if err != nil || len(keys) != 1 || keys[0].Secret != "" || keys[0].Hash == key.Secret {
+			t.Errorf("ListAPIKeys = %+v, %v, want one key without secret", keys, err)
+		}
+
+		if _, err = auth.CreateAPIKey(ctx, domain.APIKey{UserID: user.ID + 100, Name: "orphan"}); !errors.Is(err, domain.ErrNotExists) {
+			t.Errorf("CreateAPIKey for unknown user err = %v, want ErrNotExists", err)
+		}
+
+		if err = auth.DeleteUser(ctx, user.ID); err != nil {
+			t.Fatalf("DeleteUser: %v", err)
+		}
+		if _, err = auth.AuthenticateKey(ctx, key.Secret); !errors.Is(err, domain.ErrUnauthenticated) {
+			t.Errorf("AuthenticateKey after delete err = %v, want ErrUnauthenticated", err)
+		}
+		if keys, err = auth.ListAPIKeys(ctx); err != nil || len(keys) != 0 {
+			t.Errorf("ListAPIKeys after delete = %+v, %v, want empty", keys, err)
+		}
+	})
+
+	t.Run("token role and status come from storage", func(t *testing.T) {
+		auth := newService(t)
+
+		user, err := auth.CreateUser(ctx, domain.User{Name: "reader", Role: domain.RoleViewer})
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+
+		now := time.Now().Unix()
+		token := signHS256(fmt.Sprintf(`{"sub":"reader","aud":["movies"],"exp":%d,"role":"admin"}`, now+60))
+
+		principal, err := auth.AuthenticateToken(ctx, token)
+		if err != nil {
+			t.Fatalf("AuthenticateToken: %v", err)
+		}
+		if principal.Role != domain.RoleViewer {
+			t.Errorf("role = %s, want viewer from storage, not the token claim", principal.Role)
+		}
+
+		disabled := true
+		if _, err = auth.UpdateUser(ctx, user.ID, domain.UserUpdate{Disabled: &disabled}); err != nil {
+			t.Fatalf("UpdateUser: %v", err)
+		}
+		if _, err = auth.AuthenticateToken(ctx, token); !errors.Is(err, domain.ErrUnauthenticated) {
+			t.Errorf("AuthenticateToken for disabled user err = %v, want ErrUnauthenticated", err)
+		}
+
+		for name, claims := range map[string]string{
+			"expired":        fmt.Sprintf(`{"sub":"reader","aud":"movies","exp":%d}`, now-120),
+			"without exp":    `{"sub":"reader","aud":"movies"}`,
+			"other audience": fmt.Sprintf(`{"sub":"reader","aud":"billing","exp":%d}`, now+60),
+			"unknown user":   fmt.Sprintf(`{"sub":"nobody","aud":"movies","exp":%d}`, now+60),
+		} {
+			if _, err = auth.AuthenticateToken(ctx, signHS256(claims)); !errors.Is(err, domain.ErrUnauthenticated) {
+				t.Errorf("%s: err = %v, want ErrUnauthenticated", name, err)
+			}
+		}
+
+		unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." +
+			base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"reader","aud":"movies"}`)) + "."
+		if _, err = auth.AuthenticateToken(ctx, unsigned); !errors.Is(err, domain.ErrUnauthenticated) {
+			t.Errorf("alg none: err = %v, want ErrUnauthenticated", err)
+		}
+	})
+}
+
+func signHS256(claims string) string {
+	signed := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(claims))
+
+	mac := hmac.New(sha256.New, []byte(testHS256Secret))
+	mac.Write([]byte(signed))
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,155 @@
+package storagetest
+
+import (
+	"arch-demo/internal/domain"
+	"arch-demo/internal/services"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// RunBatch проверяет Atomic хранилища и пакеты сервисов поверх нее: после ошибки
+// не должно остаться ни записей, ни их связей, ни строк журнала
+func RunBatch(t *testing.T, newStorage func(t *testing.T) AuditStorage) {
+	ctx := context.Background()
+	errStop := errors.New("stop")
+
+	t.Run("error rolls back every change", func(t *testing.T) {
+		s := newStorage(t)
+		f := newFixture(t, ctx, s)
+
+		err := s.Atomic(ctx, func(ctx context.Context) error {
+			actor, err := s.InsertActor(ctx, domain.Actor{Name: "extra", BirthYear: 1980, CountryOfBirth: "US", Gender: domain.GenderFemale})
+			if err != nil {
+				return err
+			}
+			if err = s.ReplaceCast(ctx, f.first.ID, []domain.CastEntry{{ActorID: actor.ID, BillingOrder: 1, Role: domain.RoleLead}}); err != nil {
+				return err
+			}
+			if err = s.DeleteActor(ctx, f.support.ID, 0, domain.DeleteCascade); err != nil {
+				return err
+			}
+			if err = s.InsertAuditEntry(ctx, domain.AuditEntry{Entity: domain.AuditActor, EntityID: actor.ID, Action: domain.AuditCreate}); err != nil {
+				return err
+			}
+
+			// внутри транзакции изменения видны
+			if got := castIDs(t, ctx, s, f.first.ID); !slices.Equal(got, []int{actor.ID}) {
+				t.Errorf("cast inside Atomic = %v, want [%d]", got, actor.ID)
+			}
+			return errStop
+		})
+		if !errors.Is(err, errStop) {
+			t.Fatalf("Atomic = %v, want the error of fn", err)
+		}
+
+		_, total, err := s.ListActors(ctx, domain.ActorsQuery{Limit: 10})
+		if err != nil || total != 2 {
+			t.Errorf("ListActors total = %d, %v, want 2", total, err)
+		}
+		expectIDs(t, "cast of first", castIDs(t, ctx, s, f.first.ID), f.lead.ID, f.support.ID)
+		if movie, _ := s.GetMovieByID(ctx, f.first.ID); movie.Version != f.first.Version {
+			t.Errorf("movie version = %d, want %d", movie.Version, f.first.Version)
+		}
+		if entries, _ := s.ListAuditEntries(ctx, domain.AuditActor, f.support.ID+1); len(entries) != 0 {
+			t.Errorf("audit entries survived the rollback: %v", entries)
+		}
+
+		// хранилище после отката работает как обычно, а id не обязаны переиспользоваться
+		if _, err = s.InsertActor(ctx, domain.Actor{Name: "after", BirthYear: 1980, CountryOfBirth: "US", Gender: domain.GenderFemale}); err != nil {
+			t.Errorf("InsertActor after rollback: %v", err)
+		}
+	})
+
+	t.Run("nested Atomic joins the outer one", func(t *testing.T) {
+		s := newStorage(t)
+
+		err := s.Atomic(ctx, func(ctx context.Context) error {
+			err := s.Atomic(ctx, func(ctx context.Context) error {
+				_, err := s.InsertActor(ctx, domain.Actor{Name: "inner", BirthYear: 1980, CountryOfBirth: "US", Gender: domain.GenderFemale})
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			return errStop
+		})
+		if !errors.Is(err, errStop) {
+			t.Fatalf("Atomic = %v, want the error of fn", err)
+		}
+		if _, total, _ := s.ListActors(ctx, domain.ActorsQuery{Limit: 10}); total != 0 {
+			t.Errorf("inner Atomic committed %d actors", total)
+		}
+	})
+
+	t.Run("batch is applied all or nothing", func(t *testing.T) {
+		s := newStorage(t)
+		actors := services.NewActorService(s, domain.DeleteSoft, services.NewAuditLog(s))
+
+		created, err := actors.Batch(ctx, []domain.ActorBatchItem{
+			{Create: domain.Actor{Name: "lead", BirthYear: 1970, CountryOfBirth: "ru", Gender: domain.GenderMale}},
+			{Create: domain.Actor{Name: "support", BirthYear: 1975, CountryOfBirth: "US", Gender: domain.GenderFemale}},
+		})
+		if err != nil {
+			t.Fatalf("Batch: %v", err)
+		}
+		if created[0].Status != domain.BatchCreated || created[1].Item.Name != "support" || created[0].Item.CountryOfBirth != "RU" {
+			t.Fatalf("Batch results = %+v", created)
+		}
+
+		year := 1971
+		_, err = actors.Batch(ctx, []domain.ActorBatchItem{
+			{ID: created[0].Item.ID, Version: created[0].Item.Version, Update: domain.ActorUpdate{BirthYear: &year}},
+			{Create: domain.Actor{Name: "third", BirthYear: 1980, CountryOfBirth: "US", Gender: domain.GenderMale}},
+			{ID: created[1].Item.ID, Version: created[1].Item.Version + 1, Update: domain.ActorUpdate{BirthYear: &year}},
+		})
+		var batchErr *domain.BatchError
+		if !errors.As(err, &batchErr) || !errors.Is(err, domain.ErrVersionMismatch) {
+			t.Fatalf("Batch = %v, want BatchError with version mismatch", err)
+		}
+		var statuses []string
+		for _, item := range batchErr.Items {
+			statuses = append(statuses, item.Status)
+		}
+		if want := []string{domain.BatchRolledBack, domain.BatchRolledBack, domain.BatchFailed}; !slices.Equal(statuses, want) {
+			t.Errorf("statuses = %v, want %v", statuses, want)
+		}
+
+		if lead, _ := actors.Get(ctx, created[0].Item.ID); lead.BirthYear != 1970 || lead.Version != 1 {
+			t.Errorf("rolled back update is visible: %+v", lead)
+		}
+		if page, _ := actors.List(ctx, domain.ActorsQuery{}); page.Total != 2 {
+			t.Errorf("actors after failed batch = %d, want 2", page.Total)
+		}
+		if history, _ := actors.History(ctx, created[0].Item.ID); len(history) != 1 {
+			t.Errorf("history after failed batch = %d entries, want only create", len(history))
+		}
+	})
+
+	t.Run("invalid items are reported before anything runs", func(t *testing.T) {
+		s := newStorage(t)
+		movies := services.NewMovieService(s, domain.DeleteSoft, services.AuditLog{})
+
+		_, err := movies.Batch(ctx, []domain.MovieBatchItem{
+			{Create: domain.Movie{Name: "no date"}},
+			{ID: 42},
+			{Create: domain.Movie{Name: "bad rating", ReleaseDate: time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC), Country: "US", Genre: "drama", Rating: 9}},
+		})
+		var batchErr *domain.BatchError
+		if !errors.As(err, &batchErr) || !errors.Is(err, domain.ErrFieldsRequired) {
+			t.Fatalf("Batch = %v, want BatchError with missing fields", err)
+		}
+		if got := batchErr.Items[2]; got.Status != domain.BatchFailed || len(got.Fields) != 1 || got.Fields[0].Field != "rating" {
+			t.Errorf("third item = %+v, want failed rating", got)
+		}
+		if got := batchErr.Items[1].Status; got != domain.BatchSkipped {
+			t.Errorf("second item status = %s, want %s", got, domain.BatchSkipped)
+		}
+
+		if _, err = movies.Batch(ctx, nil); !errors.Is(err, domain.ErrInvalidBatch) {
+			t.Errorf("empty Batch = %v, want ErrInvalidBatch", err)
+		}
+	})
+}
@@ -0,0 +1,106 @@
+package storagetest
+
+import (
+	"arch-demo/internal/domain"
+	"arch-demo/internal/services"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// RunCast проверяет, что одновременные частичные правки состава не теряют друг друга,
+// а журнал видит их по очереди: каждая запись начинается с того, чем кончилась прошлая
+func RunCast(t *testing.T, newStorage func(t *testing.T) AuditStorage) {
+	ctx := context.Background()
+	s := newStorage(t)
+	movies := services.NewMovieService(slowCast{s}, domain.DeleteSoft, services.NewAuditLog(s))
+
+	movie, err := s.InsertMovie(ctx, domain.Movie{Name: "Heat", ReleaseDate: time.Date(1995, 12, 15, 0, 0, 0, 0, time.UTC),
+		Country: "US", Genre: "crime", Rating: 5})
+	if err != nil {
+		t.Fatalf("InsertMovie: %v", err)
+	}
+
+	const members = 8
+	var actorIDs []int
+	for i := 0; i < members; i++ {
+		actor, err := s.InsertActor(ctx, domain.Actor{Name: fmt.Sprintf("actor %d", i), BirthYear: 1950, CountryOfBirth: "US", Gender: domain.GenderMale})
+		if err != nil {
+			t.Fatalf("InsertActor: %v", err)
+		}
+		actorIDs = append(actorIDs, actor.ID)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, members)
+	for i, actorID := range actorIDs {
+		wg.Add(1)
+		go func(i, actorID int) {
+			defer wg.Done()
+			_, errs[i] = movies.SetCastMember(ctx, movie.ID, domain.CastEntry{ActorID: actorID, Character: fmt.Sprintf("role %d", i), Role: domain.RoleSupporting})
+		}(i, actorID)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("SetCastMember: %v", err)
+		}
+	}
+
+	cast, err := movies.GetCast(ctx, movie.ID)
+	if err != nil {
+		t.Fatalf("GetCast: %v", err)
+	}
+	var got []int
+	for _, member := range cast {
+		got = append(got, member.ID)
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, actorIDs) {
+		t.Fatalf("cast after concurrent SetCastMember = %v, want %v", got, actorIDs)
+	}
+
+	entries, err := s.ListAuditEntries(ctx, domain.AuditMovie, movie.ID)
+	if err != nil {
+		t.Fatalf("ListAuditEntries: %v", err)
+	}
+	if len(entries) != members {
+		t.Fatalf("%d audit entries, want %d", len(entries), members)
+	}
+	var previous []domain.CastEntry
+	for i, entry := range entries {
+		if len(entry.Changes) != 1 || entry.Action != domain.AuditCast {
+			t.Fatalf("audit entry %d = %+v, want one cast change", i, entry)
+		}
+		var before, after []domain.CastEntry
+		if len(entry.Changes[0].Before) > 0 {
+			if err = json.Unmarshal(entry.Changes[0].Before, &before); err != nil {
+				t.Fatalf("decode before: %v", err)
+			}
+		}
+		if err = json.Unmarshal(entry.Changes[0].After, &after); err != nil {
+			t.Fatalf("decode after: %v", err)
+		}
+		if !slices.Equal(before, previous) || len(after) != i+1 {
+			t.Errorf("audit entry %d: %d members before, %d after, want %d and %d", i, len(before), len(after), len(previous), i+1)
+		}
+		previous = after
+	}
+}
+
+// slowCast задерживает чтение состава, чтобы одновременные правки точно успели
+// прочитать его до чужой замены, если ничто их не упорядочивает
+type slowCast struct {
+	AuditStorage
+}
+
+func (s slowCast) GetCast(ctx context.Context, movieID int) ([]domain.CastMember, error) {
+	cast, err := s.AuditStorage.GetCast(ctx, movieID)
+	time.Sleep(5 * time.Millisecond)
+
+	return cast, err
+}
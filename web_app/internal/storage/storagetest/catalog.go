@@ -0,0 +1,129 @@
+package storagetest
+
+import (
+	"arch-demo/internal/domain"
+	"arch-demo/internal/services"
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func newCatalog(s Storage) services.CatalogService {
+	return services.NewCatalogService(
+		services.NewActorService(s, domain.DeleteSoft, services.AuditLog{}),
+		services.NewMovieService(s, domain.DeleteSoft, services.AuditLog{}),
+	)
+}
+
+// RunCatalog проверяет выгрузку и загрузку каталога через сервисы: хранилища
+// должны одинаково находить записи по имени и отдавать их страницами по курсору
+func RunCatalog(t *testing.T, newStorage func(t *testing.T) Storage) {
+	ctx := context.Background()
+
+	for _, format := range []domain.CatalogFormat{domain.FormatNDJSON, domain.FormatCSV} {
+		t.Run(string(format)+" round trip", func(t *testing.T) {
+			source := newStorage(t)
+			newFixture(t, ctx, source)
+
+			var exported bytes.Buffer
+			if err := newCatalog(source).Export(ctx, format, &exported); err != nil {
+				t.Fatalf("Export: %v", err)
+			}
+
+			// в пустом хранилище id выдаются заново, поэтому состав должен сослаться на новые id
+			target := newStorage(t)
+			if _, err := target.InsertActor(ctx, domain.Actor{Name: "local", BirthYear: 1980, CountryOfBirth: "FR", Gender: domain.GenderFemale}); err != nil {
+				t.Fatalf("InsertActor: %v", err)
+			}
+			catalog := newCatalog(target)
+
+			report, err := catalog.Import(ctx, format, bytes.NewReader(exported.Bytes()), true)
+			if err != nil {
+				t.Fatalf("dry run Import: %v", err)
+			}
+			if report.Created != 7 || report.Failed != 0 {
+				t.Fatalf("dry run report = %+v, want 7 created", report)
+			}
+			if page, _ := catalog.Movies.List(ctx, domain.MoviesQuery{}); page.Total != 0 {
+				t.Fatalf("dry run created %d movies", page.Total)
+			}
+
+			report, err = catalog.Import(ctx, format, bytes.NewReader(exported.Bytes()), false)
+			if err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+			if report.Created != 7 || report.Failed != 0 {
+				t.Fatalf("report = %+v, want 7 created", report)
+			}
+
+			if got, want := castByName(t, ctx, catalog), castByName(t, ctx, newCatalog(source)); got != want {
+				t.Errorf("cast after import = %s, want %s", got, want)
+			}
+
+			report, err = catalog.Import(ctx, format, bytes.NewReader(exported.Bytes()), false)
+			if err != nil || report.Unchanged != 7 {
+				t.Errorf("second Import = %+v, %v, want everything unchanged", report, err)
+			}
+		})
+	}
+
+	t.Run("bad lines are reported and skipped", func(t *testing.T) {
+		catalog := newCatalog(newStorage(t))
+		input := strings.Join([]string{
+			`{"type":"actor","id":7,"name":"lead","birth_year":1970,"country_of_birth":"ru","gender":"male"}`,
+			`{"type":"actor","name":"","birth_year":1970}`,
+			`not json`,
+			``,
+			`{"type":"director","name":"x"}`,
+			`{"type":"movie","id":3,"name":"first","release_date":"2001-01-01T00:00:00Z","country":"US","genre":"drama","rating":4}`,
+			`{"type":"cast","movie_id":3,"actor_id":7,"role":"lead"}`,
+			`{"type":"cast","movie_id":3,"actor_id":99}`,
+		}, "\n")
+
+		report, err := catalog.Import(ctx, domain.FormatNDJSON, strings.NewReader(input), false)
+		if err != nil {
+			t.Fatalf("Import: %v", err)
+		}
+		if report.Created != 3 || report.Failed != 4 {
+			t.Fatalf("report = %+v, want 3 created and 4 failed", report)
+		}
+
+		var lines []int
+		for _, e := range report.Errors {
+			lines = append(lines, e.Line)
+		}
+		if want := []int{2, 3, 5, 8}; !slices.Equal(lines, want) {
+			t.Errorf("error lines = %v, want %v", lines, want)
+		}
+		if fields := report.Errors[0].Fields; len(fields) == 0 {
+			t.Errorf("validation error has no fields: %+v", report.Errors[0])
+		}
+	})
+}
+
+// castByName описывает состав фильмов через имена: id в разных хранилищах свои
+func castByName(t *testing.T, ctx context.Context, catalog services.CatalogService) string {
+	t.Helper()
+
+	page, err := catalog.Movies.List(ctx, domain.MoviesQuery{})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+
+	var names []string
+	for _, movie := range page.Items {
+		cast, err := catalog.Movies.GetCast(ctx, movie.ID)
+		if err != nil {
+			t.Fatalf("GetCast: %v", err)
+		}
+		for _, member := range cast {
+			names = append(names, fmt.Sprintf("%s/%s:%d:%s", movie.Name, member.Name, member.BillingOrder, member.Role))
+		}
+	}
+	slices.Sort(names)
+
+	return strings.Join(names, " ")
+}
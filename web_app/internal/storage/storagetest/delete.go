@@ -0,0 +1,237 @@
+package storagetest
+
+import (
+	"arch-demo/internal/domain"
+	"arch-demo/internal/services"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+type Storage interface {
+	services.ActorsRepository
+	services.MoviesRepository
+}
+
+// fixture - два актера и два фильма: первый актер играет в обоих фильмах, второй только в первом
+type fixture struct {
+	lead, support domain.Actor
+	first, second domain.Movie
+}
+
+func newFixture(t *testing.T, ctx context.Context, s Storage) fixture {
+	t.Helper()
+
+	var f fixture
+	var err error
+	actor := domain.Actor{Name: "lead", BirthYear: 1970, CountryOfBirth: "RU", Gender: domain.GenderMale}
+	if f.lead, err = s.InsertActor(ctx, actor); err != nil {
+		t.Fatalf("InsertActor: %v", err)
+	}
+	actor.Name = "support"
+	if f.support, err = s.InsertActor(ctx, actor); err != nil {
+		t.Fatalf("InsertActor: %v", err)
+	}
+
+	movie := domain.Movie{Name: "first", ReleaseDate: time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC),
+		Country: "US", Genre: "drama", Rating: 4}
+	if f.first, err = s.InsertMovie(ctx, movie); err != nil {
+		t.Fatalf("InsertMovie: %v", err)
+	}
+	movie.Name = "second"
+	if f.second, err = s.InsertMovie(ctx, movie); err != nil {
+		t.Fatalf("InsertMovie: %v", err)
+	}
+
+	cast := []domain.CastEntry{
+		{ActorID: f.lead.ID, BillingOrder: 1, Role: domain.RoleLead},
+		{ActorID: f.support.ID, BillingOrder: 2, Role: domain.RoleSupporting},
+	}
+	if err = s.ReplaceCast(ctx, f.first.ID, cast); err != nil {
+		t.Fatalf("ReplaceCast: %v", err)
+	}
+	if err = s.ReplaceCast(ctx, f.second.ID, cast[:1]); err != nil {
+		t.Fatalf("ReplaceCast: %v", err)
+	}
+
+	// замена состава меняет версию фильма
+	if f.first, err = s.GetMovieByID(ctx, f.first.ID); err != nil {
+		t.Fatalf("GetMovieByID: %v", err)
+	}
+	if f.second, err = s.GetMovieByID(ctx, f.second.ID); err != nil {
+		t.Fatalf("GetMovieByID: %v", err)
+	}
+
+	return f
+}
+
+func castIDs(t *testing.T, ctx context.Context, s Storage, movieID int) []int {
+	t.Helper()
+
+	cast, err := s.GetCast(ctx, movieID)
+	if err != nil {
+		t.Fatalf("GetCast(%d): %v", movieID, err)
+	}
+
+	ids := make([]int, 0, len(cast))
+	for _, member := range cast {
+		ids = append(ids, member.ID)
+	}
+
+	return ids
+}
+
+func filmographyIDs(t *testing.T, ctx context.Context, s Storage, actorID int) []int {
+	t.Helper()
+
+	movies, _, err := s.ListMovies(ctx, domain.MoviesQuery{ActorID: actorID, Sort: domain.Sort{{Field: domain.MovieSortName}}})
+	if err != nil {
+		t.Fatalf("ListMovies(actor %d): %v", actorID, err)
+	}
+
+	ids := make([]int, 0, len(movies))
+	for _, movie := range movies {
+		ids = append(ids, movie.ID)
+	}
+
+	return ids
+}
+
+func expectIDs(t *testing.T, what string, got []int, want ...int) {
+	t.Helper()
+
+	if !slices.Equal(got, want) && !(len(got) == 0 && len(want) == 0) {
+		t.Errorf("%s = %v, want %v", what, got, want)
+	}
+}
+
+// RunDeletePolicies проверяет, что происходит со связями фильм-актер при удалении
+// с каждой из политик. newStorage должен возвращать новое пустое хранилище.
+func RunDeletePolicies(t *testing.T, newStorage func(t *testing.T) Storage) {
+	ctx := context.Background()
+
+	t.Run("restrict keeps referenced actor", func(t *testing.T) {
+		s := newStorage(t)
+		f := newFixture(t, ctx, s)
+
+		err := s.DeleteActor(ctx, f.lead.ID, 0, domain.DeleteRestrict)
+		var referencedErr *domain.ReferencedError
+		if !errors.As(err, &referencedErr) || !errors.Is(err, domain.ErrReferenced) {
+			t.Fatalf("DeleteActor = %v, want ReferencedError", err)
+		}
+
+		var movieIDs []int
+		for _, movie := range referencedErr.Movies {
+			movieIDs = append(movieIDs, movie.ID)
+		}
+		expectIDs(t, "referencing movies", movieIDs, f.first.ID, f.second.ID)
+
+		if _, err = s.GetActorByID(ctx, f.lead.ID); err != nil {
+			t.Errorf("GetActorByID after refused delete: %v", err)
+		}
+		expectIDs(t, "cast", castIDs(t, ctx, s, f.first.ID), f.lead.ID, f.support.ID)
+	})
+
+	t.Run("restrict deletes unreferenced actor", func(t *testing.T) {
+		s := newStorage(t)
+		f := newFixture(t, ctx, s)
+
+		// после удаления первого фильма второй актер больше нигде не играет
+		if err := s.DeleteMovie(ctx, f.first.ID, 0, domain.DeleteRestrict); err != nil {
+			t.Fatalf("DeleteMovie: %v", err)
+		}
+		if err := s.DeleteActor(ctx, f.support.ID, 0, domain.DeleteRestrict); err != nil {
+			t.Fatalf("DeleteActor: %v", err)
+		}
+
+		if _, err := s.GetActorByID(ctx, f.support.ID); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("GetActorByID after delete = %v, want ErrNotFound", err)
+		}
+		expectIDs(t, "filmography", filmographyIDs(t, ctx, s, f.lead.ID), f.second.ID)
+	})
+
+	t.Run("cascade removes links", func(t *testing.T) {
+		s := newStorage(t)
+		f := newFixture(t, ctx, s)
+
+		if err := s.DeleteActor(ctx, f.lead.ID, 0, domain.DeleteCascade); err != nil {
+			t.Fatalf("DeleteActor: %v", err)
+		}
+
+		if _, err := s.GetActorByID(ctx, f.lead.ID); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("GetActorByID after delete = %v, want ErrNotFound", err)
+		}
+		expectIDs(t, "first cast", castIDs(t, ctx, s, f.first.ID), f.support.ID)
+		expectIDs(t, "second cast", castIDs(t, ctx, s, f.second.ID))
+		expectIDs(t, "deleted actor filmography", filmographyIDs(t, ctx, s, f.lead.ID))
+
+		if err := s.DeleteMovie(ctx, f.first.ID, 0, domain.DeleteCascade); err != nil {
+			t.Fatalf("DeleteMovie: %v", err)
+		}
+		if _, err := s.GetCast(ctx, f.first.ID); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("GetCast of deleted movie = %v, want ErrNotFound", err)
+		}
+		expectIDs(t, "filmography", filmographyIDs(t, ctx, s, f.support.ID))
+
+		// актера, удаленного каскадом, можно снова добавить в фильм только как нового
+		err := s.ReplaceCast(ctx, f.second.ID, []domain.CastEntry{{ActorID: f.lead.ID, BillingOrder: 1}})
+		if !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("ReplaceCast with deleted actor = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("soft hides records", func(t *testing.T) {
+		s := newStorage(t)
+		f := newFixture(t, ctx, s)
+
+		if err := s.DeleteActor(ctx, f.lead.ID, 0, domain.DeleteSoft); err != nil {
+			t.Fatalf("DeleteActor: %v", err)
+		}
+
+		if _, err := s.GetActorByID(ctx, f.lead.ID); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("GetActorByID after soft delete = %v, want ErrNotFound", err)
+		}
+		actors, total, err := s.ListActors(ctx, domain.ActorsQuery{Sort: domain.Sort{{Field: domain.ActorSortName}}})
+		if err != nil {
+			t.Fatalf("ListActors: %v", err)
+		}
+		if total != 1 || len(actors) != 1 || actors[0].ID != f.support.ID {
+			t.Errorf("ListActors = %v (total %d), want only the remaining actor", actors, total)
+		}
+		expectIDs(t, "first cast", castIDs(t, ctx, s, f.first.ID), f.support.ID)
+
+		// удаленного актера нельзя добавить в фильм, а замена состава не трогает его роль
+		err = s.ReplaceCast(ctx, f.second.ID, []domain.CastEntry{{ActorID: f.lead.ID, BillingOrder: 1}})
+		if !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("ReplaceCast with soft deleted actor = %v, want ErrNotFound", err)
+		}
+		if err = s.ReplaceCast(ctx, f.first.ID, nil); err != nil {
+			t.Fatalf("ReplaceCast: %v", err)
+		}
+		expectIDs(t, "first cast", castIDs(t, ctx, s, f.first.ID))
+
+		if err = s.DeleteMovie(ctx, f.second.ID, 0, domain.DeleteSoft); err != nil {
+			t.Fatalf("DeleteMovie: %v", err)
+		}
+		if _, err = s.GetMovieByID(ctx, f.second.ID); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("GetMovieByID after soft delete = %v, want ErrNotFound", err)
+		}
+		if _, err = s.GetCast(ctx, f.second.ID); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("GetCast of soft deleted movie = %v, want ErrNotFound", err)
+		}
+		movies, total, err := s.ListMovies(ctx, domain.MoviesQuery{Sort: domain.Sort{{Field: domain.MovieSortName}}})
+		if err != nil {
+			t.Fatalf("ListMovies: %v", err)
+		}
+		if total != 1 || len(movies) != 1 || movies[0].ID != f.first.ID {
+			t.Errorf("ListMovies = %v (total %d), want only the remaining movie", movies, total)
+		}
+
+		// повторное удаление уже удаленной записи ничего не делает
+		if err = s.DeleteActor(ctx, f.lead.ID, 0, domain.DeleteSoft); err != nil {
+			t.Errorf("second DeleteActor = %v, want nil", err)
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package storagetest
+
+import (
+	"arch-demo/internal/domain"
+	"arch-demo/internal/services"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// RunDuplicates проверяет, что все хранилища считают дубликатом одно и то же:
+// неудаленную запись с тем же именем или названием целиком, а не его частью
+func RunDuplicates(t *testing.T, newStorage func(t *testing.T) Storage) {
+	ctx := context.Background()
+	s := newStorage(t)
+	actors := services.NewActorService(s, domain.DeleteSoft, services.AuditLog{})
+	movies := services.NewMovieService(s, domain.DeleteSoft, services.AuditLog{})
+
+	actor := domain.Actor{Name: "Al Pacino", BirthYear: 1940, CountryOfBirth: "US", Gender: domain.GenderMale}
+	pacino, err := actors.Create(ctx, actor)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err = actors.Create(ctx, actor); !errors.Is(err, domain.ErrExists) {
+		t.Errorf("Create duplicate actor = %v, want ErrExists", err)
+	}
+	if exists, err := s.IsActorExists(ctx, domain.Actor{Name: "Nobody"}); exists || err != nil {
+		t.Errorf("IsActorExists for unknown name = %v, %v, want false, nil", exists, err)
+	}
+	// часть имени - другой актер
+	actor.Name = "Al"
+	if _, err = actors.Create(ctx, actor); err != nil {
+		t.Errorf("Create actor with part of an existing name: %v", err)
+	}
+	// удаленный актер дубликатом не считается
+	if err = s.DeleteActor(ctx, pacino.ID, 0, domain.DeleteSoft); err != nil {
+		t.Fatalf("DeleteActor: %v", err)
+	}
+	if _, err = actors.Create(ctx, pacino); err != nil {
+		t.Errorf("Create actor with the name of a deleted one: %v", err)
+	}
+
+	movie := domain.Movie{Name: "Heat", ReleaseDate: time.Date(1995, 12, 15, 0, 0, 0, 0, time.UTC), Country: "US", Genre: "crime", Rating: 5}
+	if _, err = movies.Create(ctx, movie); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err = movies.Create(ctx, movie); !errors.Is(err, domain.ErrExists) {
+		t.Errorf("Create duplicate movie = %v, want ErrExists", err)
+	}
+	if exists, err := s.IsMovieExists(ctx, domain.Movie{Name: "Nobody"}); exists || err != nil {
+		t.Errorf("IsMovieExists for unknown name = %v, %v, want false, nil", exists, err)
+	}
+	movie.Name = "Hea"
+	if _, err = movies.Create(ctx, movie); err != nil {
+		t.Errorf("Create movie with part of an existing name: %v", err)
+	}
+
+	// id дубликата не обязан помещаться в маленькое целое
+	var last domain.Actor
+	for i := 0; i < 130; i++ {
+		actor.Name = fmt.Sprintf("extra %d", i)
+		if last, err = s.InsertActor(ctx, actor); err != nil {
+			t.Fatalf("InsertActor: %v", err)
+		}
+	}
+	if _, err = actors.Create(ctx, last); !errors.Is(err, domain.ErrExists) {
+		t.Errorf("Create duplicate of actor id %d = %v, want ErrExists", last.ID, err)
+	}
+}
@@ -0,0 +1,143 @@
+package storagetest
+
+import (
+	"arch-demo/internal/domain"
+	"arch-demo/internal/services"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// RunFilters проверяет, что условия фильтра списков объединяются через и, повторенные
+// значения одного условия - через или, и что оба хранилища понимают их одинаково
+func RunFilters(t *testing.T, newStorage func(t *testing.T) Storage) {
+	ctx := context.Background()
+	s := newStorage(t)
+
+	actors := []domain.Actor{
+		{Name: "Tom Hanks", BirthYear: 1956, CountryOfBirth: "US", Gender: domain.GenderMale},
+		{Name: "Tom Hardy", BirthYear: 1977, CountryOfBirth: "GB", Gender: domain.GenderMale},
+		{Name: "Meryl Streep", BirthYear: 1949, CountryOfBirth: "US", Gender: domain.GenderFemale},
+		{Name: "tom_lower", BirthYear: 1990, CountryOfBirth: "RU", Gender: domain.GenderOther},
+	}
+	for _, actor := range actors {
+		if _, err := s.InsertActor(ctx, actor); err != nil {
+			t.Fatalf("InsertActor: %v", err)
+		}
+	}
+
+	date := func(year int) time.Time { return time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC) }
+	movies := []domain.Movie{
+		{Name: "Forrest Gump", ReleaseDate: date(1994), Country: "US", Genre: "drama", Rating: 5},
+		{Name: "Cast Away", ReleaseDate: date(2000), Country: "US", Genre: "adventure", Rating: 4},
+		{Name: "Legend", ReleaseDate: date(2015), Country: "GB", Genre: "drama", Rating: 3},
+		{Name: "The Big Lebowski", ReleaseDate: date(1998), Country: "GB", Genre: "comedy", Rating: 5},
+	}
+	for _, movie := range movies {
+		if _, err := s.InsertMovie(ctx, movie); err != nil {
+			t.Fatalf("InsertMovie: %v", err)
+		}
+	}
+
+	year := func(y int) *int { return &y }
+	day := func(y int) *time.Time { d := date(y); return &d }
+
+	actorTests := []struct {
+		name  string
+		query domain.ActorsQuery
+		want  []string
+	}{
+		{name: "no filter", want: []string{"Meryl Streep", "Tom Hanks", "Tom Hardy", "tom_lower"}},
+		{
+			name:  "name and country",
+			query: domain.ActorsQuery{Name: domain.TextFilter{Contains: []string{"Tom"}}, Country: domain.TextFilter{Eq: []string{"us"}}},
+			want:  []string{"Tom Hanks"},
+		},
+		{name: "prefix is case sensitive", query: domain.ActorsQuery{Name: domain.TextFilter{Prefix: []string{"tom"}}}, want: []string{"tom_lower"}},
+		{name: "like wildcards are literal", query: domain.ActorsQuery{Name: domain.TextFilter{Contains: []string{"_"}}}, want: []string{"tom_lower"}},
+		{name: "exact", query: domain.ActorsQuery{Name: domain.TextFilter{Eq: []string{"Tom"}}}, want: nil},
+		{
+			name:  "repeated values",
+			query: domain.ActorsQuery{Country: domain.TextFilter{Eq: []string{"GB", "RU"}}},
+			want:  []string{"Tom Hardy", "tom_lower"},
+		},
+		{
+			name:  "range and gender",
+			query: domain.ActorsQuery{BirthYear: domain.Range[int]{Gte: year(1949), Lt: year(1977)}, Gender: domain.TextFilter{Eq: []string{"Male"}}},
+			want:  []string{"Tom Hanks"},
+		},
+	}
+
+	actorService := services.NewActorService(s, domain.DeleteSoft, services.AuditLog{})
+	for _, tt := range actorTests {
+		page, err := actorService.List(ctx, tt.query)
+		if err != nil {
+			t.Errorf("%s: List: %v", tt.name, err)
+			continue
+		}
+
+		var got []string
+		for _, actor := range page.Items {
+			got = append(got, actor.Name)
+		}
+		if !slices.Equal(got, tt.want) || page.Total != len(tt.want) {
+			t.Errorf("%s: List = %q (total %d), want %q", tt.name, got, page.Total, tt.want)
+		}
+	}
+
+	movieTests := []struct {
+		name  string
+		query domain.MoviesQuery
+		want  []string
+	}{
+		{
+			name:  "genre and country",
+			query: domain.MoviesQuery{Genre: domain.TextFilter{Eq: []string{"drama"}}, Country: domain.TextFilter{Eq: []string{"GBR"}}},
+			want:  []string{"Legend"},
+		},
+		{
+			name:  "repeated genres",
+			query: domain.MoviesQuery{Genre: domain.TextFilter{Eq: []string{"drama", "comedy"}}},
+			want:  []string{"Forrest Gump", "Legend", "The Big Lebowski"},
+		},
+		{
+			name:  "release date and rating",
+			query: domain.MoviesQuery{ReleaseDate: domain.Range[time.Time]{Lt: day(2000)}, Rating: domain.Range[int]{Gte: year(5)}},
+			want:  []string{"Forrest Gump", "The Big Lebowski"},
+		},
+		{
+			name:  "release date bounds",
+			query: domain.MoviesQuery{ReleaseDate: domain.Range[time.Time]{Gt: day(1994), Lte: day(2000)}},
+			want:  []string{"Cast Away", "The Big Lebowski"},
+		},
+		{
+			name:  "prefix and contains",
+			query: domain.MoviesQuery{Name: domain.TextFilter{Prefix: []string{"The", "Le"}, Contains: []string{"Big"}}},
+			want:  []string{"The Big Lebowski"},
+		},
+	}
+
+	movieService := services.NewMovieService(s, domain.DeleteSoft, services.AuditLog{})
+	for _, tt := range movieTests {
+		page, err := movieService.List(ctx, tt.query)
+		if err != nil {
+			t.Errorf("%s: List: %v", tt.name, err)
+			continue
+		}
+
+		var got []string
+		for _, movie := range page.Items {
+			got = append(got, movie.Name)
+		}
+		if !slices.Equal(got, tt.want) || page.Total != len(tt.want) {
+			t.Errorf("%s: List = %q (total %d), want %q", tt.name, got, page.Total, tt.want)
+		}
+	}
+
+	_, err := actorService.List(ctx, domain.ActorsQuery{Country: domain.TextFilter{Eq: []string{"Narnia"}}})
+	if !errors.Is(err, domain.ErrInvalidFilter) {
+		t.Errorf("List with unknown country = %v, want ErrInvalidFilter", err)
+	}
+}
@@ -0,0 +1,95 @@
+package storagetest
+
+import (
+	"arch-demo/internal/domain"
+	"arch-demo/internal/services"
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// RunPaging проверяет постраничную выдачу: курсор не теряет и не повторяет записи,
+// когда между страницами записи добавляются и удаляются, а offset работает и без limit
+func RunPaging(t *testing.T, newStorage func(t *testing.T) Storage) {
+	ctx := context.Background()
+	s := newStorage(t)
+
+	insert := func(name string) domain.Actor {
+		t.Helper()
+		actor, err := s.InsertActor(ctx, domain.Actor{Name: name, BirthYear: 1980, CountryOfBirth: "FR", Gender: domain.GenderOther})
+		if err != nil {
+			t.Fatalf("InsertActor: %v", err)
+		}
+		return actor
+	}
+	remove := func(actor domain.Actor) {
+		t.Helper()
+		if err := s.DeleteActor(ctx, actor.ID, 0, domain.DeleteSoft); err != nil {
+			t.Fatalf("DeleteActor: %v", err)
+		}
+	}
+
+	byName := map[string]domain.Actor{}
+	for i := 1; i <= 10; i++ {
+		name := fmt.Sprintf("Actor %02d", i)
+		byName[name] = insert(name)
+	}
+
+	t.Run("offset without limit", func(t *testing.T) {
+		actors, total, err := s.ListActors(ctx, domain.ActorsQuery{Offset: 7})
+		if err != nil {
+			t.Fatalf("ListActors: %v", err)
+		}
+		if len(actors) != 3 || total != 10 {
+			t.Errorf("ListActors with offset 7 = %d actors of %d, want 3 of 10", len(actors), total)
+		}
+	})
+
+	t.Run("cursor is stable", func(t *testing.T) {
+		actors := services.NewActorService(s, domain.DeleteSoft, services.AuditLog{})
+		sort, err := domain.ParseSort("name", domain.ActorSortFields)
+		if err != nil {
+			t.Fatalf("ParseSort: %v", err)
+		}
+
+		// между страницами: что меняется до и после курсора
+		between := []func(){
+			func() {
+				remove(byName["Actor 04"])
+				insert("Actor 00")
+				insert("Actor 05a")
+			},
+			// с offset удаление уже показанной записи сдвинуло бы следующую страницу
+			func() { remove(byName["Actor 02"]) },
+		}
+
+		var got []string
+		query := domain.ActorsQuery{Sort: sort, Limit: 3}
+		for page := 0; ; page++ {
+			result, err := actors.List(ctx, query)
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			for _, actor := range result.Items {
+				got = append(got, actor.Name)
+			}
+			if result.NextCursor == "" {
+				break
+			}
+			after, err := domain.DecodeCursor[domain.Actor](result.NextCursor)
+			if err != nil {
+				t.Fatalf("DecodeCursor: %v", err)
+			}
+			query.After = &after
+			if page < len(between) {
+				between[page]()
+			}
+		}
+
+		want := []string{"Actor 01", "Actor 02", "Actor 03", "Actor 05", "Actor 05a", "Actor 06", "Actor 07", "Actor 08", "Actor 09", "Actor 10"}
+		if !slices.Equal(got, want) {
+			t.Errorf("pages = %q, want %q", got, want)
+		}
+	})
+}
@@ -0,0 +1,164 @@
+package storagetest
+
+import (
+	"arch-demo/internal/domain"
+	"arch-demo/internal/services"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+type ReviewsStorage interface {
+	Storage
+	services.ReviewsRepository
+}
+
+// RunReviews проверяет отзывы через ReviewsService: в оценку фильма идут только
+// одобренные отзывы, новый отзыв автора заменяет прежний, по оценке сортируется список фильмов,
+// отзыв оставляет только аутентифицированный вызывающий
+func RunReviews(t *testing.T, newStorage func(t *testing.T) ReviewsStorage) {
+	ctx := context.Background()
+	s := newStorage(t)
+
+	var movies []domain.Movie
+	for _, name := range []string{"Heat", "Ronin", "Cobra"} {
+		movie, err := s.InsertMovie(ctx, domain.Movie{Name: name, ReleaseDate: time.Date(1995, 1, 1, 0, 0, 0, 0, time.UTC), Country: "US", Genre: "crime", Rating: 3})
+		if err != nil {
+			t.Fatalf("InsertMovie: %v", err)
+		}
+		movies = append(movies, movie)
+	}
+	heat, ronin, cobra := movies[0], movies[1], movies[2]
+
+	notified := 0
+	reviews := services.NewReviewsService(s, func() { notified++ })
+	submit := func(author string, movieID int, rating int8, text string) (domain.Review, bool) {
+		t.Helper()
+		review, created, err := reviews.Submit(domain.WithPrincipal(ctx, domain.Principal{Subject: author, Role: domain.RoleViewer}), movieID, domain.Review{Rating: rating, Text: text})
+		if err != nil {
+			t.Fatalf("Submit(%s, %d): %v", author, movieID, err)
+		}
+		return review, created
+	}
+	score := func(movieID int) domain.MovieScore {
+		t.Helper()
+		movie, err := s.GetMovieByID(ctx, movieID)
+		if err != nil {
+			t.Fatalf("GetMovieByID: %v", err)
+		}
+		return movie.Score
+	}
+
+	// оценка без текста публикуется сразу, отзыв с текстом ждет модерации
+	if review, created := submit("alice", heat.ID, 5, ""); !created || review.Status != domain.ReviewApproved {
+		t.Errorf("rating without text: created %v, status %q, want new approved review", created, review.Status)
+	}
+	pending, _ := submit("bob", heat.ID, 3, "  Too long  ")
+	if pending.Status != domain.ReviewPending || pending.Text != "Too long" {
+		t.Errorf("review with text = %+v, want pending with trimmed text", pending)
+	}
+	if got := score(heat.ID); got.Count != 1 || got.Average != 5 {
+		t.Errorf("score before moderation = %+v, want one rating of 5", got)
+	}
+
+	if _, err := reviews.Moderate(ctx, ronin.ID, pending.ID, domain.ReviewModeration{Status: domain.ReviewApproved}); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("Moderate review of another movie = %v, want ErrNotFound", err)
+	}
+	if _, err := reviews.Moderate(ctx, heat.ID, pending.ID, domain.ReviewModeration{Status: domain.ReviewApproved}); err != nil {
+		t.Fatalf("Moderate: %v", err)
+	}
+	want := domain.MovieScore{Average: 4, Count: 2, Histogram: [domain.MaxRating]int{0, 0, 1, 0, 1}}
+	if got := score(heat.ID); got != want {
+		t.Errorf("score after approval = %+v, want %+v", got, want)
+	}
+
+	// новый отзыв автора заменяет прежний, а не добавляется к нему
+	if _, created := submit("alice", heat.ID, 4, ""); created {
+		t.Error("second review of the same author was created, want replaced")
+	}
+	want = domain.MovieScore{Average: 3.5, Count: 2, Histogram: [domain.MaxRating]int{0, 0, 1, 1, 0}}
+	if got := score(heat.ID); got != want {
+		t.Errorf("score after replacement = %+v, want %+v", got, want)
+	}
+
+	// оценка - часть представления фильма, ее изменение меняет версию
+	if got, err := s.GetMovieByID(ctx, heat.ID); err != nil || got.Version <= heat.Version {
+		t.Errorf("movie version after reviews = %d (err %v), want greater than %d", got.Version, err, heat.Version)
+	}
+
+	submit("carol", ronin.ID, 5, "")
+	submit("carol", cobra.ID, 2, "")
+	submit("dave", cobra.ID, 1, "Awful")
+
+	sort, err := domain.ParseSort("-score", domain.MovieSortFields)
+	if err != nil {
+		t.Fatalf("ParseSort: %v", err)
+	}
+	var names []string
+	query := domain.MoviesQuery{Sort: sort, Limit: 1}
+	for {
+		page, err := services.NewMovieService(s, domain.DeleteSoft, services.AuditLog{}).List(ctx, query)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		for _, movie := range page.Items {
+			names = append(names, movie.Name)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		after, err := domain.DecodeCursor[domain.Movie](page.NextCursor)
+		if err != nil {
+			t.Fatalf("DecodeCursor: %v", err)
+		}
+		query.After = &after
+	}
+	if want := []string{"Ronin", "Heat", "Cobra"}; !slices.Equal(names, want) {
+		t.Errorf("movies by -score = %q, want %q", names, want)
+	}
+
+	page, err := reviews.List(ctx, domain.ReviewsQuery{MovieID: heat.ID, Status: domain.ReviewApproved})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	var authors []string
+	for _, review := range page.Items {
+		authors = append(authors, review.Author)
+	}
+	if want := []string{"bob", "alice"}; !slices.Equal(authors, want) || page.Total != 2 {
+		t.Errorf("approved reviews of the movie = %q (total %d), want %q newest first", authors, page.Total, want)
+	}
+
+	queue, err := reviews.List(ctx, domain.ReviewsQuery{Status: domain.ReviewPending})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(queue.Items) != 1 || queue.Items[0].Author != "dave" {
+		t.Errorf("pending reviews = %+v, want the review of dave", queue.Items)
+	}
+
+	// отклонение одобренного отзыва убирает его оценку
+	if _, err = reviews.Moderate(ctx, heat.ID, pending.ID, domain.ReviewModeration{Status: domain.ReviewRejected}); err != nil {
+		t.Fatalf("Moderate: %v", err)
+	}
+	if got := score(heat.ID); got.Count != 1 || got.Average != 4 {
+		t.Errorf("score after rejection = %+v, want one rating of 4", got)
+	}
+	if notified != 6 {
+		t.Errorf("score changed %d times, want 6", notified)
+	}
+
+	viewer := domain.WithPrincipal(ctx, domain.Principal{Subject: "alice", Role: domain.RoleViewer})
+	if _, _, err = reviews.Submit(viewer, heat.ID, domain.Review{Rating: 6}); !errors.Is(err, domain.ErrValidation) {
+		t.Errorf("Submit with rating 6 = %v, want ErrValidation", err)
+	}
+	if _, _, err = reviews.Submit(viewer, 1000, domain.Review{Rating: 3}); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("Submit for unknown movie = %v, want ErrNotFound", err)
+	}
+	// имя из X-Caller не подтверждено, отзыв по нему заменил бы чужой
+	if _, _, err = reviews.Submit(domain.WithCaller(ctx, "alice"), heat.ID, domain.Review{Rating: 1}); !errors.Is(err, domain.ErrUnauthenticated) {
+		t.Errorf("Submit without principal = %v, want ErrUnauthenticated", err)
+	}
+}
@@ -0,0 +1,129 @@
+package storagetest
+
+import (
+	"arch-demo/internal/domain"
+	"arch-demo/internal/services"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type SearchStorage interface {
+	Storage
+	services.SearchRepository
+}
+
+// RunSearch проверяет, что хранилище отдает сервису всех подходящих кандидатов:
+// без регистра и диакритики, по началу слова и с опечатками, без удаленных записей
+func RunSearch(t *testing.T, newStorage func(t *testing.T) SearchStorage) {
+	ctx := context.Background()
+
+	s := newStorage(t)
+	for _, name := range []string{"Fyodor Dostoevsky", "Tom Hanks", "Tom", "Tommy Lee Jones", "Фёдор Шаляпин", "Renée Zellweger", "Abcdef"} {
+		if _, err := s.InsertActor(ctx, domain.Actor{Name: name, BirthYear: 1950, CountryOfBirth: "US", Gender: domain.GenderMale}); err != nil {
+			t.Fatalf("InsertActor: %v", err)
+		}
+	}
+	for _, name := range []string{"Crime and Punishment", "Cast Away", "The Idiot"} {
+		movie := domain.Movie{Name: name, ReleaseDate: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), Country: "US", Genre: "drama", Rating: 4}
+		if _, err := s.InsertMovie(ctx, movie); err != nil {
+			t.Fatalf("InsertMovie: %v", err)
+		}
+	}
+
+	renamed, err := s.InsertMovie(ctx, domain.Movie{Name: "Untitled", ReleaseDate: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), Country: "US", Genre: "drama", Rating: 4})
+	if err != nil {
+		t.Fatalf("InsertMovie: %v", err)
+	}
+	renamed.Name = "Forrest Gump"
+	if _, err = s.UpdateMovie(ctx, renamed); err != nil {
+		t.Fatalf("UpdateMovie: %v", err)
+	}
+
+	deleted, err := s.InsertActor(ctx, domain.Actor{Name: "Tom Deleted", BirthYear: 1950, CountryOfBirth: "US", Gender: domain.GenderMale})
+	if err != nil {
+		t.Fatalf("InsertActor: %v", err)
+	}
+	if err = s.DeleteActor(ctx, deleted.ID, 0, domain.DeleteSoft); err != nil {
+		t.Fatalf("DeleteActor: %v", err)
+	}
+
+	search := services.NewSearchService(s)
+	tests := []struct {
+		query string
+		types []string
+		want  []string
+	}{
+		{query: "dostoevsky", want: []string{"Fyodor Dostoevsky"}},
+		{query: "Dostoevski", want: []string{"Fyodor Dostoevsky"}},
+		{query: "dostoyevsky", want: []string{"Fyodor Dostoevsky"}},
+		{query: "федор", want: []string{"Фёдор Шаляпин"}},
+		{query: "renee", want: []string{"Renée Zellweger"}},
+		// перестановка в начале короткого слова не оставляет общих триграмм
+		{query: "bacd", want: []string{"Abcdef"}},
+		// точное совпадение выше совпадения части названия, а оно - выше начала слова
+		{query: "tom", want: []string{"Tom", "Tom Hanks", "Tommy Lee Jones"}},
+		{query: "hanks tom", want: []string{"Tom Hanks"}},
+		{query: "forrest", want: []string{"Forrest Gump"}},
+		{query: "untitled", want: nil},
+		{query: "cast", types: []string{domain.SearchMovies}, want: []string{"Cast Away"}},
+		{query: "idiot crime", want: nil},
+	}
+	for _, tt := range tests {
+		hits, err := search.Search(ctx, domain.SearchQuery{Text: tt.query, Types: tt.types})
+		if err != nil {
+			t.Errorf("Search(%q): %v", tt.query, err)
+			continue
+		}
+
+		var got []string
+		for _, hit := range hits {
+			got = append(got, hit.Name())
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Search(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+
+	if hits, _ := search.Search(ctx, domain.SearchQuery{Text: "tom", Limit: 1}); len(hits) != 1 {
+		t.Errorf("Search with limit 1 returned %d hits", len(hits))
+	}
+	// лимит относится к ответу сервиса: хранилище отдает все подходящие записи, иначе
+	// лучшая по оценке сервиса могла бы отсеяться по порядку самого хранилища
+	for i := 0; i < 30; i++ {
+		if _, err = s.InsertActor(ctx, domain.Actor{Name: fmt.Sprintf("Zimmer Zimmermann %d", i), BirthYear: 1950, CountryOfBirth: "US", Gender: domain.GenderMale}); err != nil {
+			t.Fatalf("InsertActor: %v", err)
+		}
+	}
+	if _, err = s.InsertActor(ctx, domain.Actor{Name: "Zimmer", BirthYear: 1950, CountryOfBirth: "US", Gender: domain.GenderMale}); err != nil {
+		t.Fatalf("InsertActor: %v", err)
+	}
+	candidates, err := s.SearchActors(ctx, domain.SearchQuery{Text: "zimmer", Terms: []string{"zimmer"}, Limit: 1})
+	if err != nil {
+		t.Fatalf("SearchActors: %v", err)
+	}
+	matched := 0
+	for _, actor := range candidates {
+		if strings.HasPrefix(actor.Name, "Zimmer") {
+			matched++
+		}
+	}
+	if matched != 31 {
+		t.Errorf("SearchActors with limit 1 returned %d of 31 matching actors", matched)
+	}
+	hits, err := search.Search(ctx, domain.SearchQuery{Text: "zimmer", Limit: 1})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(hits) != 1 || hits[0].Name() != "Zimmer" {
+		t.Errorf("Search(%q) with limit 1 = %+v, want Zimmer", "zimmer", hits)
+	}
+
+	if _, err = search.Search(ctx, domain.SearchQuery{Text: " !? "}); !errors.Is(err, domain.ErrInvalidSearch) {
+		t.Errorf("Search without words = %v, want ErrInvalidSearch", err)
+	}
+}
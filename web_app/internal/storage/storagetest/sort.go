@@ -0,0 +1,92 @@
+package storagetest
+
+import (
+	"arch-demo/internal/domain"
+	"arch-demo/internal/services"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// RunSort проверяет сортировку по нескольким ключам: текст по правилам Unicode,
+// разные направления у ключей и одинаковый порядок постранично через курсор
+func RunSort(t *testing.T, newStorage func(t *testing.T) Storage) {
+	ctx := context.Background()
+	s := newStorage(t)
+
+	for _, name := range []string{"Zoe", "émile", "Emile", "Élodie", "eve", "Adam"} {
+		actor := domain.Actor{Name: name, BirthYear: 1980, CountryOfBirth: "FR", Gender: domain.GenderOther}
+		if _, err := s.InsertActor(ctx, actor); err != nil {
+			t.Fatalf("InsertActor: %v", err)
+		}
+	}
+
+	actors := services.NewActorService(s, domain.DeleteSoft, services.AuditLog{})
+	page, err := actors.List(ctx, domain.ActorsQuery{})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	var names []string
+	for _, actor := range page.Items {
+		names = append(names, actor.Name)
+	}
+	// диакритика и регистр отличают слова, только когда буквы совпадают
+	if want := []string{"Adam", "Élodie", "Emile", "émile", "eve", "Zoe"}; !slices.Equal(names, want) {
+		t.Errorf("actors by name = %q, want %q", names, want)
+	}
+
+	type movie struct {
+		name, genre string
+		rating      int8
+		year        int
+	}
+	for _, m := range []movie{
+		{"Heat", "crime", 5, 1995}, {"Alien", "horror", 5, 1979}, {"Cobra", "action", 2, 1986}, {"Ronin", "action", 4, 1998},
+		{"Aliens", "horror", 5, 1986}, {"Brazil", "comedy", 4, 1985}, {"Dune", "action", 4, 1984}, {"Thief", "crime", 5, 1981},
+	} {
+		release := time.Date(m.year, 1, 1, 0, 0, 0, 0, time.UTC)
+		if _, err = s.InsertMovie(ctx, domain.Movie{Name: m.name, ReleaseDate: release, Country: "US", Genre: m.genre, Rating: m.rating}); err != nil {
+			t.Fatalf("InsertMovie: %v", err)
+		}
+	}
+
+	movies := services.NewMovieService(s, domain.DeleteSoft, services.AuditLog{})
+	sort, err := domain.ParseSort("-rating,genre,-release_date", domain.MovieSortFields)
+	if err != nil {
+		t.Fatalf("ParseSort: %v", err)
+	}
+
+	var got []string
+	query := domain.MoviesQuery{Sort: sort, Limit: 3}
+	for {
+		page, err := movies.List(ctx, query)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		for _, m := range page.Items {
+			got = append(got, m.Name)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		after, err := domain.DecodeCursor[domain.Movie](page.NextCursor)
+		if err != nil {
+			t.Fatalf("DecodeCursor: %v", err)
+		}
+		query.After = &after
+	}
+
+	want := []string{"Heat", "Thief", "Aliens", "Alien", "Ronin", "Dune", "Brazil", "Cobra"}
+	if !slices.Equal(got, want) {
+		t.Errorf("movies by -rating,genre,-release_date = %q, want %q", got, want)
+	}
+
+	if _, err = domain.ParseSort("rating", domain.ActorSortFields); !errors.Is(err, domain.ErrInvalidSort) {
+		t.Errorf("ParseSort with unknown field = %v, want ErrInvalidSort", err)
+	}
+	if _, err = movies.List(ctx, domain.MoviesQuery{Sort: domain.Sort{{Field: "budget"}}}); !errors.Is(err, domain.ErrInvalidSort) {
+		t.Errorf("List with unknown sort field = %v, want ErrInvalidSort", err)
+	}
+}
@@ -0,0 +1,49 @@
+// Package storagetest - общие проверки, которые должны проходить все хранилища.
+// Тесты каждого хранилища вызывают RunAll со своим конструктором.
+package storagetest
+
+import "testing"
+
+// FullStorage - хранилище со всеми репозиториями сервисов, как те, что выбирает main
+type FullStorage interface {
+	TrashStorage
+	AuditStorage
+	AuthStorage
+	SearchStorage
+	ReviewsStorage
+}
+
+// RunAll запускает все общие проверки, каждую на новых хранилищах из newStorage
+func RunAll(t *testing.T, newStorage func(t *testing.T) FullStorage) {
+	suites := []struct {
+		name string
+		run  func(t *testing.T)
+	}{
+		{"DeletePolicies", func(t *testing.T) { RunDeletePolicies(t, as[Storage](newStorage)) }},
+		{"Trash", func(t *testing.T) { RunTrash(t, as[TrashStorage](newStorage)) }},
+		{"Audit", func(t *testing.T) { RunAudit(t, as[AuditStorage](newStorage)) }},
+		{"Versions", func(t *testing.T) { RunVersions(t, as[TrashStorage](newStorage)) }},
+		{"Auth", func(t *testing.T) { RunAuth(t, as[AuthStorage](newStorage)) }},
+		{"Catalog", func(t *testing.T) { RunCatalog(t, as[Storage](newStorage)) }},
+		{"Batch", func(t *testing.T) { RunBatch(t, as[AuditStorage](newStorage)) }},
+		{"Search", func(t *testing.T) { RunSearch(t, as[SearchStorage](newStorage)) }},
+		{"Filters", func(t *testing.T) { RunFilters(t, as[Storage](newStorage)) }},
+		{"Sort", func(t *testing.T) { RunSort(t, as[Storage](newStorage)) }},
+		{"Paging", func(t *testing.T) { RunPaging(t, as[Storage](newStorage)) }},
+		{"Cast", func(t *testing.T) { RunCast(t, as[AuditStorage](newStorage)) }},
+		{"Duplicates", func(t *testing.T) { RunDuplicates(t, as[Storage](newStorage)) }},
+		{"Reviews", func(t *testing.T) { RunReviews(t, as[ReviewsStorage](newStorage)) }},
+	}
+
+	for _, suite := range suites {
+		t.Run(suite.name, suite.run)
+	}
+}
+
+// as сужает конструктор до интерфейса, который нужен проверке: функции в Go
+// не приводятся к типу с другим результатом, даже если он интерфейс
+func as[S any](newStorage func(t *testing.T) FullStorage) func(t *testing.T) S {
+	return func(t *testing.T) S {
+		return newStorage(t).(S)
+	}
+}
@@ -0,0 +1,105 @@
+package storagetest
+
+import (
+	"arch-demo/internal/domain"
+	"arch-demo/internal/services"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type TrashStorage interface {
+	Storage
+	services.TrashRepository
+}
+
+// RunTrash проверяет корзину: мягко удаленные записи видны в ней, восстанавливаются
+// вместе со связями и окончательно удаляются очисткой
+func RunTrash(t *testing.T, newStorage func(t *testing.T) TrashStorage) {
+	ctx := context.Background()
+
+	t.Run("restore brings back links", func(t *testing.T) {
+		s := newStorage(t)
+		f := newFixture(t, ctx, s)
+
+		if err := s.DeleteActor(ctx, f.lead.ID, 0, domain.DeleteSoft); err != nil {
+			t.Fatalf("DeleteActor: %v", err)
+		}
+		if err := s.DeleteMovie(ctx, f.second.ID, 0, domain.DeleteSoft); err != nil {
+			t.Fatalf("DeleteMovie: %v", err)
+		}
+
+		actors, err := s.ListDeletedActors(ctx)
+		if err != nil {
+			t.Fatalf("ListDeletedActors: %v", err)
+		}
+		if len(actors) != 1 || actors[0].ID != f.lead.ID || actors[0].DeletedAt == nil {
+			t.Fatalf("ListDeletedActors = %v, want the deleted actor with deleted_at", actors)
+		}
+		movies, err := s.ListDeletedMovies(ctx)
+		if err != nil {
+			t.Fatalf("ListDeletedMovies: %v", err)
+		}
+		if len(movies) != 1 || movies[0].ID != f.second.ID || movies[0].DeletedAt == nil {
+			t.Fatalf("ListDeletedMovies = %v, want the deleted movie with deleted_at", movies)
+		}
+
+		actor, err := s.RestoreActor(ctx, f.lead.ID)
+		if err != nil {
+			t.Fatalf("RestoreActor: %v", err)
+		}
+		if actor.ID != f.lead.ID || actor.Name != f.lead.Name || actor.DeletedAt != nil {
+			t.Errorf("RestoreActor = %v, want %v", actor, f.lead)
+		}
+		if _, err = s.RestoreMovie(ctx, f.second.ID); err != nil {
+			t.Fatalf("RestoreMovie: %v", err)
+		}
+
+		expectIDs(t, "first cast", castIDs(t, ctx, s, f.first.ID), f.lead.ID, f.support.ID)
+		expectIDs(t, "filmography", filmographyIDs(t, ctx, s, f.lead.ID), f.first.ID, f.second.ID)
+
+		if _, err = s.RestoreActor(ctx, f.lead.ID); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("RestoreActor of a live actor = %v, want ErrNotFound", err)
+		}
+		if _, err = s.RestoreMovie(ctx, 1000); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("RestoreMovie of a missing movie = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("purge removes expired records", func(t *testing.T) {
+		s := newStorage(t)
+		f := newFixture(t, ctx, s)
+
+		if err := s.DeleteActor(ctx, f.lead.ID, 0, domain.DeleteSoft); err != nil {
+			t.Fatalf("DeleteActor: %v", err)
+		}
+		if err := s.DeleteMovie(ctx, f.second.ID, 0, domain.DeleteSoft); err != nil {
+			t.Fatalf("DeleteMovie: %v", err)
+		}
+
+		actors, movies, err := s.PurgeDeleted(ctx, time.Now().Add(-time.Hour))
+		if err != nil || actors != 0 || movies != 0 {
+			t.Fatalf("PurgeDeleted before deletion = %d, %d, %v, want nothing purged", actors, movies, err)
+		}
+
+		actors, movies, err = s.PurgeDeleted(ctx, time.Now().Add(time.Hour))
+		if err != nil || actors != 1 || movies != 1 {
+			t.Fatalf("PurgeDeleted = %d, %d, %v, want 1 actor and 1 movie", actors, movies, err)
+		}
+
+		if _, err = s.RestoreActor(ctx, f.lead.ID); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("RestoreActor after purge = %v, want ErrNotFound", err)
+		}
+		if _, err = s.RestoreMovie(ctx, f.second.ID); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("RestoreMovie after purge = %v, want ErrNotFound", err)
+		}
+		expectIDs(t, "first cast", castIDs(t, ctx, s, f.first.ID), f.support.ID)
+		expectIDs(t, "support filmography", filmographyIDs(t, ctx, s, f.support.ID), f.first.ID)
+
+		trash, err := s.ListDeletedActors(ctx)
+		if err != nil || len(trash) != 0 {
+			t.Errorf("ListDeletedActors after purge = %v, %v, want empty", trash, err)
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package storagetest
+
+import (
+	"arch-demo/internal/domain"
+	"context"
+	"errors"
+	"testing"
+)
+
+// RunVersions проверяет, что хранилище само сверяет версию при изменении и удалении,
+// а не полагается на проверку в сервисе
+func RunVersions(t *testing.T, newStorage func(t *testing.T) TrashStorage) {
+	ctx := context.Background()
+
+	t.Run("concurrent updates", func(t *testing.T) {
+		s := newStorage(t)
+		f := newFixture(t, ctx, s)
+		if f.lead.Version != 1 || f.first.Version != 2 {
+			t.Fatalf("versions = %d, %d, want 1 after insert and 2 after cast replacement", f.lead.Version, f.first.Version)
+		}
+
+		// оба редактора прочитали одну и ту же версию, второй должен получить ошибку
+		first, second := f.lead, f.lead
+		first.Name = "first editor"
+		second.Name = "second editor"
+		if _, err := s.UpdateActor(ctx, first); err != nil {
+			t.Fatalf("UpdateActor: %v", err)
+		}
+		if _, err := s.UpdateActor(ctx, second); !errors.Is(err, domain.ErrVersionMismatch) {
+			t.Fatalf("stale UpdateActor = %v, want ErrVersionMismatch", err)
+		}
+
+		actor, err := s.GetActorByID(ctx, f.lead.ID)
+		if err != nil {
+			t.Fatalf("GetActorByID: %v", err)
+		}
+		if actor.Name != "first editor" || actor.Version != 2 {
+			t.Errorf("actor = %q version %d, want first editor version 2", actor.Name, actor.Version)
+		}
+
+		movie := f.first
+		movie.Rating = 5
+		updated, err := s.UpdateMovie(ctx, movie)
+		if err != nil {
+			t.Fatalf("UpdateMovie: %v", err)
+		}
+		if updated.Version != 3 || !updated.UpdatedAt.After(f.first.UpdatedAt) {
+			t.Errorf("updated movie version %d at %v, want version 3 after %v", updated.Version, updated.UpdatedAt, f.first.UpdatedAt)
+		}
+		if _, err = s.UpdateMovie(ctx, movie); !errors.Is(err, domain.ErrVersionMismatch) {
+			t.Errorf("stale UpdateMovie = %v, want ErrVersionMismatch", err)
+		}
+
+		movie.ID = 100
+		if _, err = s.UpdateMovie(ctx, movie); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("UpdateMovie of missing movie = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("delete checks version", func(t *testing.T) {
+		s := newStorage(t)
+		f := newFixture(t, ctx, s)
+
+		for _, policy := range []domain.DeletePolicy{domain.DeleteRestrict, domain.DeleteCascade, domain.DeleteSoft} {
+			if err := s.DeleteActor(ctx, f.support.ID, 2, policy); !errors.Is(err, domain.ErrVersionMismatch) {
+				t.Errorf("DeleteActor with %s and stale version = %v, want ErrVersionMismatch", policy, err)
+			}
+			if err := s.DeleteMovie(ctx, f.second.ID, f.second.Version+1, policy); !errors.Is(err, domain.ErrVersionMismatch) {
+				t.Errorf("DeleteMovie with %s and stale version = %v, want ErrVersionMismatch", policy, err)
+			}
+		}
+		if _, err := s.GetActorByID(ctx, f.support.ID); err != nil {
+			t.Fatalf("GetActorByID after refused deletes: %v", err)
+		}
+
+		if err := s.DeleteMovie(ctx, f.second.ID, f.second.Version, domain.DeleteCascade); err != nil {
+			t.Errorf("DeleteMovie with current version: %v", err)
+		}
+		if err := s.DeleteActor(ctx, f.support.ID, 1, domain.DeleteSoft); err != nil {
+			t.Fatalf("DeleteActor with current version: %v", err)
+		}
+
+		// мягкое удаление и восстановление тоже меняют запись
+		actor, err := s.RestoreActor(ctx, f.support.ID)
+		if err != nil {
+			t.Fatalf("RestoreActor: %v", err)
+		}
+		if actor.Version != 3 {
+			t.Errorf("version after soft delete and restore = %d, want 3", actor.Version)
+		}
+	})
+}